@@ -0,0 +1,377 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package about
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+const FileName = ".about.yml"
+
+const Header = "#\n# Generated from the dependencies of the latest scan\n#\n"
+
+// Format is the way an about yaml lists its dependencies.
+type Format string
+
+const (
+	// Stacks lists name:version under a section per language.
+	Stacks Format = "stacks"
+	// Stack is one list of name:version:project:language.
+	Stack Format = "stack"
+	// None is an about yaml without a dependency list.
+	None Format = ""
+)
+
+// Unmarshaler decodes yaml, it is yaml.Unmarshal of whichever copy the caller vendors.
+type Unmarshaler func([]byte, interface{}) error
+
+type About struct {
+	Format Format
+	Deps   deps.GenericDependencies
+	// Sections are the keys of the stacks sections by language, as written.
+	Sections map[lan.Language]string
+	// Unknown are the stacks sections of no known language.
+	Unknown []string
+
+	dat []byte
+}
+
+var stacksVersionRe = regexp.MustCompile(`^[^:]+:[^:]+(:[^:]*)$`)
+
+// Parse reads the dependencies of an about yaml. Empty data is an about yaml
+// without a dependency list. When both stacks and stack are given, stacks is used.
+func Parse(dat []byte, unmarshal Unmarshaler) (*About, error) {
+	a := &About{Format: None, Deps: deps.GenericDependencies{}, Sections: map[lan.Language]string{}, Unknown: []string{}, dat: dat}
+	yml := map[string]interface{}{}
+	if len(bytes.TrimSpace(dat)) == 0 {
+		return a, nil
+	}
+	if err := unmarshal(dat, &yml); err != nil {
+		return nil, err
+	}
+	if stacksI, ok := yml[string(Stacks)]; ok {
+		a.Format = Stacks
+		stacks, err := stringMap(stacksI)
+		if err != nil {
+			return nil, err
+		}
+		for name, listI := range stacks {
+			list, err := stringList(listI)
+			if err != nil {
+				return nil, err
+			}
+			language := lan.GetLanguage(name)
+			if language == lan.Unknown {
+				a.Unknown = append(a.Unknown, name)
+				continue
+			}
+			a.Sections[language] = name
+			for _, dep := range list {
+				if stacksVersionRe.MatchString(dep) {
+					dep = strings.TrimSuffix(dep, stacksVersionRe.FindStringSubmatch(dep)[1])
+				}
+				a.Deps.Add(deps.NewGenericDependencyStr(dep + "::" + string(language)))
+			}
+		}
+		sort.Strings(a.Unknown)
+		a.Deps.RemoveExactDuplicates()
+	} else if stackI, ok := yml[string(Stack)]; ok {
+		a.Format = Stack
+		if _, ok := stackI.([]interface{}); !ok && stackI != nil {
+			return nil, errors.New("Stack type not []interface{}")
+		}
+		list, err := stringList(stackI)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range list {
+			a.Deps.Add(deps.NewGenericDependencyStr(dep))
+		}
+	}
+	return a, nil
+}
+
+// stringMap takes the maps of yaml.v2 as well as those of decoders with string keys.
+func stringMap(mapI interface{}) (map[string]interface{}, error) {
+	switch m := mapI.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			name, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("Stack name [%v] not string", k)
+			}
+			res[name] = v
+		}
+		return res, nil
+	}
+	return nil, errors.New("Stacks type not map[interface{}]interface{}")
+}
+
+func stringList(listI interface{}) ([]string, error) {
+	if listI == nil {
+		return []string{}, nil
+	}
+	list, ok := listI.([]interface{})
+	if !ok {
+		return nil, errors.New("Dependency list not []interface{}")
+	}
+	res := make([]string, len(list))
+	for i, depI := range list {
+		dep, ok := depI.(string)
+		if !ok {
+			return nil, fmt.Errorf("Dep [%v] not string", depI)
+		}
+		res[i] = dep
+	}
+	return res, nil
+}
+
+// listable are the dependencies the format can hold, without duplicates.
+func listable(format Format, ds deps.GenericDependencies) deps.GenericDependencies {
+	res := deps.GenericDependencies{}
+	for _, d := range ds {
+		if format == Stacks && d.GetLanguage() == lan.Unknown {
+			continue
+		}
+		res.Add(deps.NewGenericDependency(d.GetName(), d.GetVersion(), "", d.GetLanguage()))
+	}
+	res.RemoveExactDuplicates()
+	return res
+}
+
+// Generate rewrites the dependency list of the about yaml to hold ds, in the
+// format it already uses or stacks when it has none. The rest of the file is
+// kept as written, so the result makes a small patch.
+func (a *About) Generate(ds deps.GenericDependencies) []byte {
+	format := a.Format
+	if format == None {
+		format = Stacks
+	}
+	ds = listable(format, ds)
+	buf := bytes.NewBufferString(string(format) + ":\n")
+	if format == Stacks {
+		byLanguage := map[string][]string{}
+		for _, d := range ds {
+			key, ok := a.Sections[d.GetLanguage()]
+			if !ok {
+				key = string(d.GetLanguage())
+			}
+			byLanguage[key] = append(byLanguage[key], d.String())
+		}
+		keys := make([]string, 0, len(byLanguage))
+		for k := range byLanguage {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString("  " + scalar(k) + ":\n")
+			writeList(buf, "  ", byLanguage[k])
+		}
+	} else {
+		list := make([]string, len(ds))
+		for i, d := range ds {
+			list[i] = d.String()
+		}
+		writeList(buf, "", list)
+	}
+	if len(bytes.TrimSpace(a.dat)) == 0 {
+		return append([]byte(Header), buf.Bytes()...)
+	}
+	return replaceSection(a.dat, string(format), buf.Bytes())
+}
+
+func writeList(buf *bytes.Buffer, indent string, list []string) {
+	sort.Strings(list)
+	for _, s := range list {
+		buf.WriteString(indent + "- " + scalar(s) + "\n")
+	}
+}
+
+var plainRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:/@+~^=<>*,-]*$`)
+
+// scalar writes s as a yaml string, quoting it only when it would not read back as itself.
+func scalar(s string) string {
+	if plainRe.MatchString(s) && !strings.HasSuffix(s, ":") {
+		switch strings.ToLower(s) {
+		case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		default:
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				return s
+			}
+		}
+	}
+	return strconv.Quote(s)
+}
+
+// replaceSection swaps the top level key of dat, with everything nested under
+// it, for section. The key is added at the end when missing.
+func replaceSection(dat []byte, key string, section []byte) []byte {
+	lines := strings.SplitAfter(string(dat), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+":") {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		res := string(dat)
+		if !strings.HasSuffix(res, "\n") {
+			res += "\n"
+		}
+		return append([]byte(res), section...)
+	}
+	end := start + 1
+	for end < len(lines) && nested(lines[end]) {
+		end++
+	}
+	// blank lines and comments after the section belong to what follows it
+	for end > start+1 && (strings.TrimSpace(lines[end-1]) == "" || strings.HasPrefix(lines[end-1], "#")) {
+		end--
+	}
+	res := strings.Join(lines[:start], "") + string(section) + strings.Join(lines[end:], "")
+	return []byte(res)
+}
+
+func nested(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return true
+	}
+	switch line[0] {
+	case ' ', '\t', '-', '#':
+		return true
+	}
+	return false
+}
+
+// Validation is an about yaml checked against the dependencies of a scan.
+type Validation struct {
+	Format Format
+	// Missing are in the scan but not the about yaml.
+	Missing deps.GenericDependencies
+	// Extra are in the about yaml but not the scan.
+	Extra deps.GenericDependencies
+	// MissingSections are languages of the scan without a stacks section.
+	MissingSections []lan.Language
+	// ExtraSections are stacks sections of languages not in the scan.
+	ExtraSections []lan.Language
+	Unknown       []string
+}
+
+// Validate compares the about yaml with the scanned dependencies, by language
+// for the stacks format and by name and version for the stack format.
+func (a *About) Validate(scanned deps.GenericDependencies) *Validation {
+	format := a.Format
+	if format == None {
+		format = Stacks
+	}
+	scanned = listable(format, scanned)
+	v := &Validation{Format: a.Format, MissingSections: []lan.Language{}, ExtraSections: []lan.Language{}, Unknown: a.Unknown}
+	var missing, extra *deps.GenericDependencies
+	if format == Stacks {
+		missing, extra, _ = deps.CompareByLanguage(&a.Deps, &scanned)
+		inScan := map[lan.Language]bool{}
+		for _, d := range scanned {
+			inScan[d.GetLanguage()] = true
+		}
+		for language := range inScan {
+			if _, ok := a.Sections[language]; !ok {
+				v.MissingSections = append(v.MissingSections, language)
+			}
+		}
+		for language := range a.Sections {
+			if !inScan[language] {
+				v.ExtraSections = append(v.ExtraSections, language)
+			}
+		}
+		sortLanguages(v.MissingSections)
+		sortLanguages(v.ExtraSections)
+	} else {
+		missing, extra, _ = deps.CompareSimple(&a.Deps, &scanned)
+	}
+	v.Missing, v.Extra = sorted(*missing), sorted(*extra)
+	return v
+}
+
+func sorted(ds deps.GenericDependencies) deps.GenericDependencies {
+	sort.Slice(ds, func(i, j int) bool { return ds[i].FullString() < ds[j].FullString() })
+	return ds
+}
+
+func sortLanguages(l []lan.Language) {
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+}
+
+// OK is whether the about yaml lists exactly what was scanned.
+func (v *Validation) OK() bool {
+	return v.Format != None && len(v.Missing) == 0 && len(v.Extra) == 0 &&
+		len(v.MissingSections) == 0 && len(v.ExtraSections) == 0 && len(v.Unknown) == 0
+}
+
+func (v *Validation) String() string {
+	buf := bytes.NewBufferString("")
+	if v.Format == None {
+		buf.WriteString("No dependency list, expected stacks or stack\n")
+	}
+	languages := func(title string, l []lan.Language) {
+		if len(l) == 0 {
+			return
+		}
+		strs := make([]string, len(l))
+		for i, language := range l {
+			strs[i] = string(language)
+		}
+		buf.WriteString(title + ": " + strings.Join(strs, ", ") + "\n")
+	}
+	languages("Missing sections", v.MissingSections)
+	languages("Extra sections", v.ExtraSections)
+	if len(v.Unknown) > 0 {
+		buf.WriteString("Unknown sections: " + strings.Join(v.Unknown, ", ") + "\n")
+	}
+	list := func(title string, ds deps.GenericDependencies) {
+		if len(ds) == 0 {
+			return
+		}
+		buf.WriteString(title + ":\n")
+		for _, d := range ds {
+			if v.Format == Stack {
+				buf.WriteString("  " + d.String() + "\n")
+			} else {
+				buf.WriteString("  " + d.FullString() + "\n")
+			}
+		}
+	}
+	list("Missing", v.Missing)
+	list("Extra", v.Extra)
+	if v.OK() {
+		buf.WriteString("Matches the scan\n")
+	}
+	return buf.String()
+}
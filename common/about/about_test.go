@@ -0,0 +1,152 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package about
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+// json is a subset of yaml, so the tests need no yaml package
+var unmarshal = json.Unmarshal
+
+func scanned() deps.GenericDependencies {
+	return deps.GenericDependencies{
+		deps.NewGenericDependency("junit", "4.12", "", lan.Java),
+		deps.NewGenericDependency("express", "4.16.0", "", lan.JavaScript),
+		deps.NewGenericDependency("express", "4.16.0", "", lan.JavaScript),
+		deps.NewGenericDependency("mystery", "1", "", lan.Unknown),
+	}
+}
+
+func TestParse(t *testing.T) {
+	a, err := Parse([]byte(`{"name": "x", "stacks": {"javastack": ["junit:4.12:test", "spring:5"], "cobol": ["x:1"]}}`), unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != Stacks || a.Sections[lan.Java] != "javastack" || len(a.Unknown) != 1 || a.Unknown[0] != "cobol" {
+		t.Fatalf("Wrong stacks %#v", a)
+	}
+	if len(a.Deps) != 2 || a.Deps[0].FullString() != "junit:4.12:java" {
+		t.Fatalf("Wrong deps %v", a.Deps)
+	}
+
+	if a, err = Parse([]byte(`{"stack": ["junit:4.12:proj:java"]}`), unmarshal); err != nil {
+		t.Fatal(err)
+	} else if a.Format != Stack || len(a.Deps) != 1 || a.Deps[0].GetProject() != "proj" {
+		t.Fatalf("Wrong stack %#v", a)
+	}
+
+	if a, err = Parse([]byte(`{}`), unmarshal); err != nil || a.Format != None {
+		t.Fatal("Expected no format", err)
+	}
+	if _, err = Parse([]byte(`{"stacks": ["x"]}`), unmarshal); err == nil {
+		t.Fatal("Expected an error for a stacks list")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	a, _ := Parse([]byte(`{"stacks": {"java": ["junit:4.11"], "python": ["six:1"]}}`), unmarshal)
+	v := a.Validate(scanned())
+	if v.OK() {
+		t.Fatal("Expected differences")
+	}
+	if len(v.Missing) != 2 || v.Missing[0].FullString() != "express:4.16.0:javascript" || v.Missing[1].FullString() != "junit:4.12:java" {
+		t.Fatalf("Wrong missing %v", v.Missing)
+	}
+	if len(v.Extra) != 2 || v.Extra[0].FullString() != "junit:4.11:java" {
+		t.Fatalf("Wrong extra %v", v.Extra)
+	}
+	if len(v.MissingSections) != 1 || v.MissingSections[0] != lan.JavaScript || len(v.ExtraSections) != 1 || v.ExtraSections[0] != lan.Python {
+		t.Fatalf("Wrong sections %v %v", v.MissingSections, v.ExtraSections)
+	}
+
+	a, _ = Parse([]byte(`{"stack": ["junit:4.12", "express:4.16.0", "mystery:1"]}`), unmarshal)
+	if v = a.Validate(scanned()); !v.OK() {
+		t.Fatal("Expected a match", v)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dat := "# about\nname: x\nstacks:\n  javastack:\n    - junit:4.11\n\n# contact\ncontact: me\n"
+	a, err := Parse([]byte(dat), func(b []byte, v interface{}) error {
+		return json.Unmarshal([]byte(`{"stacks": {"javastack": ["junit:4.11"]}}`), v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# about\nname: x\nstacks:\n  javascript:\n  - express:4.16.0\n  javastack:\n  - junit:4.12\n\n# contact\ncontact: me\n"
+	if res := string(a.Generate(scanned())); res != expected {
+		t.Fatalf("Wrong about yaml\n%s", res)
+	}
+
+	a, _ = Parse(nil, unmarshal)
+	if res := string(a.Generate(deps.GenericDependencies{deps.NewGenericDependency("@angular/core", "5.0.0", "", lan.JavaScript), deps.NewGenericDependency("six", "1.0", "", lan.Python)})); res != Header+"stacks:\n  javascript:\n  - \"@angular/core:5.0.0\"\n  python:\n  - six:1.0\n" {
+		t.Fatalf("Wrong new about yaml\n%s", res)
+	}
+
+	a, _ = Parse([]byte(`{"stack": []}`), unmarshal)
+	a.dat = []byte("name: x\nstack:\n- a:1\n")
+	if res := string(a.Generate(scanned())); res != "name: x\nstack:\n- express:4.16.0\n- junit:4.12\n- mystery:1\n" {
+		t.Fatalf("Wrong stack about yaml\n%s", res)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	old := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+	new := []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl")
+	if Patch(FileName, old, old) != "" {
+		t.Fatal("Expected no patch")
+	}
+	expected := "diff --git a/.about.yml b/.about.yml\n--- a/.about.yml\n+++ b/.about.yml\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -9,3 +9,4 @@\n i\n j\n k\n+l\n\\ No newline at end of file\n"
+	if p := Patch(FileName, old, new); p != expected {
+		t.Fatalf("Wrong patch\n%s", p)
+	}
+
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("No git to apply patches with")
+	}
+	dir, err := ioutil.TempDir("", "about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	apply := func(patch string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "p"), []byte(patch), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(git, "apply", "p")
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out))
+		}
+	}
+	apply(Patch(FileName, nil, old))
+	apply(Patch(FileName, old, new))
+	if dat, err := ioutil.ReadFile(filepath.Join(dir, FileName)); err != nil || string(dat) != string(new) {
+		t.Fatal("Patches did not apply", err, string(dat))
+	}
+}
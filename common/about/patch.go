@@ -0,0 +1,158 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package about
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const patchContext = 3
+
+// Patch is a git style unified diff that turns old into new for the file at
+// path, ready for git apply. A nil old is a file that does not exist yet.
+// The patch is empty when nothing changed.
+func Patch(path string, old, new []byte) string {
+	if old != nil && bytes.Equal(old, new) {
+		return ""
+	}
+	a, b := splitLines(old), splitLines(new)
+	ops := diffLines(a, b)
+
+	buf := bytes.NewBufferString(fmt.Sprintf("diff --git a/%s b/%s\n", path, path))
+	if old == nil {
+		buf.WriteString("new file mode 100644\n--- /dev/null\n")
+	} else {
+		buf.WriteString(fmt.Sprintf("--- a/%s\n", path))
+	}
+	buf.WriteString(fmt.Sprintf("+++ b/%s\n", path))
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - patchContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			if j == len(ops) || j-end > 2*patchContext {
+				if end+patchContext < j {
+					j = end + patchContext
+				}
+				end = j
+				break
+			}
+			end = j
+		}
+		writeHunk(buf, ops[start:end])
+		i = end
+	}
+	return buf.String()
+}
+
+type lineOp struct {
+	kind       byte
+	line       string
+	aIdx, bIdx int
+}
+
+func writeHunk(buf *bytes.Buffer, ops []lineOp) {
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+	// a range of no lines starts at the line before it
+	aStart, bStart := ops[0].aIdx, ops[0].bIdx
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	buf.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount))
+	for _, op := range ops {
+		buf.WriteByte(op.kind)
+		buf.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func splitLines(dat []byte) []string {
+	if len(dat) == 0 {
+		return []string{}
+	}
+	lines := strings.SplitAfter(string(dat), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines is the longest common subsequence edit of a into b. Each op holds
+// the index in a and b it happens at.
+func diffLines(a, b []string) []lineOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	ops := []lineOp{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i], i, j})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, lineOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, lineOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
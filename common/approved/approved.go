@@ -0,0 +1,148 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package approved
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+// Columns are the zero based columns of the fields of an approved item in a
+// sheet. A column of -1 is not in the sheet.
+type Columns struct {
+	Name      int
+	Version   int
+	Component int
+	Language  int
+}
+
+// ColumnsFromCode reads four base 36 digits, the columns of the name, version,
+// component and language.
+func ColumnsFromCode(code string) (Columns, error) {
+	if len(code) != 4 {
+		return Columns{}, fmt.Errorf("Column code [%s] is not four digits", code)
+	}
+	indices := [4]int{}
+	for i := range indices {
+		index, err := strconv.ParseInt(code[i:i+1], 36, 64)
+		if err != nil {
+			return Columns{}, err
+		}
+		indices[i] = int(index)
+	}
+	return Columns{indices[0], indices[1], indices[2], indices[3]}, nil
+}
+
+// MapColumns finds each field by the name of its column in the header, ignoring
+// case, or else by its column letter. Only the name is required, fields given
+// as empty are not in the sheet.
+func MapColumns(header []string, name, version, component, language string) (Columns, error) {
+	find := func(field, ref string) (int, error) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return -1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), ref) {
+				return i, nil
+			}
+		}
+		if col, ok := columnIndex(ref); ok {
+			return col, nil
+		}
+		return -1, fmt.Errorf("No column [%s] for the %s", ref, field)
+	}
+	var cols Columns
+	var err error
+	if strings.TrimSpace(name) == "" {
+		return cols, fmt.Errorf("The name column is required")
+	}
+	if cols.Name, err = find("name", name); err != nil {
+		return cols, err
+	}
+	if cols.Version, err = find("version", version); err != nil {
+		return cols, err
+	}
+	if cols.Component, err = find("component", component); err != nil {
+		return cols, err
+	}
+	if cols.Language, err = find("language", language); err != nil {
+		return cols, err
+	}
+	return cols, nil
+}
+
+// columnIndex reads a column letter like A or AB, up to the three letters of
+// the last column of a sheet.
+func columnIndex(letters string) (int, bool) {
+	if len(letters) > 3 {
+		return 0, false
+	}
+	letters = strings.ToUpper(letters)
+	col := 0
+	for _, r := range letters {
+		if r < 'A' || r > 'Z' {
+			return 0, false
+		}
+		col = col*26 + int(r-'A') + 1
+	}
+	return col - 1, col > 0
+}
+
+// Parse reads the approved items from the rows after the header. An item is
+// listed once for each of its comma separated components. Items of a language
+// that is not known are left out and their languages returned.
+func Parse(rows [][]string, cols Columns) (deps.GenericDependencies, []string) {
+	cell := func(row []string, col int) string {
+		if col < 0 || col >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[col])
+	}
+	res := deps.GenericDependencies{}
+	unknown := []string{}
+	seen := map[string]bool{}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+	for _, row := range rows {
+		name := cell(row, cols.Name)
+		if name == "" {
+			continue
+		}
+		language := lan.Unknown
+		if cols.Language >= 0 {
+			str := cell(row, cols.Language)
+			if language = lan.GetLanguage(str); language == lan.Unknown {
+				str = strings.TrimSuffix(str, "stack")
+				if !seen[str] {
+					seen[str] = true
+					unknown = append(unknown, str)
+				}
+				continue
+			}
+		}
+		for _, component := range strings.Split(strings.ToLower(cell(row, cols.Component)), ",") {
+			res.Add(deps.NewGenericDependency(name, cell(row, cols.Version), strings.TrimSpace(component), language))
+		}
+	}
+	res.RemoveExactDuplicates()
+	return res, unknown
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package approved
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+const listCsv = `Package,Version,Used By,Stack
+junit,4.12,"pz-gateway, pz-workflow",java
+express,4.16.0,pz-ui,javascript
+spring-core,5.0.0,pz-gateway,java
+cobol-lib,1,pz-old,cobol
+,,,
+six,1.11.0,bf-ia,python
+`
+
+func TestParse(t *testing.T) {
+	rows, err := ReadSheet("list.csv", []byte(listCsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := MapColumns(rows[0], "package", "B", "used by", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols != (Columns{0, 1, 2, 3}) {
+		t.Fatalf("Wrong columns %v", cols)
+	}
+	if code, _ := ColumnsFromCode("0123"); code != cols {
+		t.Fatalf("Wrong columns from code %v", code)
+	}
+	list, unknown := Parse(rows, cols)
+	if len(unknown) != 1 || unknown[0] != "cobol" {
+		t.Fatalf("Wrong unknown languages %v", unknown)
+	}
+	if len(list) != 5 || list[1].GetProject() != "pz-workflow" || list[4].FullString() != "six:1.11.0:python" {
+		t.Fatalf("Wrong list %v", list)
+	}
+
+	if _, err = MapColumns(rows[0], "", "B", "", ""); err == nil {
+		t.Fatal("Expected the name to be required")
+	}
+	if _, err = MapColumns(rows[0], "name", "", "", ""); err == nil {
+		t.Fatal("Expected no column called name")
+	}
+	cols, _ = MapColumns(rows[0], "A", "B", "", "")
+	if list, _ = Parse(rows, cols); len(list) != 5 || list[3].GetLanguage() != lan.Unknown {
+		t.Fatalf("Wrong list without languages %v", list)
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	files := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="List" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId3" Target="worksheets/list.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>Package</t></si><si><r><t>ju</t></r><r><t>nit</t></r></si></sst>`,
+		"xl/worksheets/list.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="inlineStr"><is><t>Version</t></is></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="C2"><v>4.12</v></c></row>` +
+			`<row r="3"><c r="B3" t="str"><v></v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	buf := bytes.NewBuffer([]byte{})
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	rows, err := ReadSheet("List.XLSX", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || strings.Join(rows[0], ",") != "Package,,Version" || strings.Join(rows[1], ",") != "junit,,4.12" {
+		t.Fatalf("Wrong rows %q", rows)
+	}
+	if _, err = ReadSheet("list.xlsx", []byte(listCsv)); err == nil {
+		t.Fatal("Expected a CSV to not read as XLSX")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	list := deps.GenericDependencies{
+		deps.NewGenericDependency("junit", "4.12", "pz-gateway", lan.Java),
+		deps.NewGenericDependency("junit", "4.12", "pz-workflow", lan.Java),
+		deps.NewGenericDependency("spring", "5.0.0", "pz-gateway", lan.Java),
+		deps.NewGenericDependency("six", "1.11.0", "", lan.Unknown),
+		deps.NewGenericDependency("express", "4.16.0", "pz-ui", lan.JavaScript),
+	}
+	inUse := deps.GenericDependencies{
+		deps.NewGenericDependency("junit", "4.12", "venicegeo/pz-gateway", lan.Java),
+		deps.NewGenericDependency("junit", "4.12", "venicegeo/pz-idam", lan.Java),
+		deps.NewGenericDependency("spring-core", "5.0.0", "venicegeo/pz-gateway", lan.Java),
+		deps.NewGenericDependency("spring-beans", "5.0.0", "venicegeo/pz-gateway", lan.Java),
+		deps.NewGenericDependency("six", "1.11.0", "venicegeo/bf-ia", lan.Python),
+		deps.NewGenericDependency("express", "4.15.0", "venicegeo/pz-ui", lan.JavaScript),
+	}
+	r := Reconcile(list, inUse, map[string][]string{"spring": {"spring-core", "spring-beans"}})
+	names := func(entries []*Entry) string {
+		strs := []string{}
+		for _, e := range entries {
+			strs = append(strs, e.Name+":"+e.Version+"["+strings.Join(e.Repos, " ")+"|"+strings.Join(e.Components, " ")+"]")
+		}
+		return strings.Join(strs, ",")
+	}
+	if res := names(r.Approved); res != "junit:4.12[venicegeo/pz-gateway venicegeo/pz-idam|pz-gateway pz-workflow],six:1.11.0[venicegeo/bf-ia|],spring:5.0.0[venicegeo/pz-gateway|pz-gateway]" {
+		t.Fatal("Wrong approved", res)
+	}
+	if res := names(r.Unapproved); res != "express:4.15.0[venicegeo/pz-ui|]" {
+		t.Fatal("Wrong unapproved", res)
+	}
+	if res := names(r.Unused); res != "express:4.16.0[|pz-ui]" {
+		t.Fatal("Wrong unused", res)
+	}
+	if inUse[2].GetName() != "spring-core" {
+		t.Fatal("Reconcile changed its input")
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := r.WriteCSV(csv.NewWriter(buf)); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 6 || lines[5] != "unused,express,4.16.0,javascript,,pz-ui" {
+		t.Fatalf("Wrong csv\n%s", buf.String())
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package approved
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+	"github.com/venicegeo/vzutil-versioning/common/table"
+)
+
+// Entry is one name and version of a reconciliation, with the repositories
+// using it and the components it is approved for.
+type Entry struct {
+	Name       string
+	Version    string
+	Language   lan.Language
+	Repos      []string
+	Components []string
+}
+
+type Reconciliation struct {
+	// Approved are in use and on the list.
+	Approved []*Entry
+	// Unapproved are in use but not on the list.
+	Unapproved []*Entry
+	// Unused are on the list but not in use.
+	Unused []*Entry
+}
+
+// Reconcile matches the dependencies in use, with the repository using each as
+// its project, against the approved list, with the component as the project.
+// Both sides have their bundles condensed first. Items match by name and
+// version, and by language when both know theirs.
+func Reconcile(list, inUse deps.GenericDependencies, bundles map[string][]string) *Reconciliation {
+	list, inUse = list.Clone(), inUse.Clone()
+	list.CondenseBundles(bundles)
+	inUse.CondenseBundles(bundles)
+
+	matches := func(a, b *deps.GenericDependency) bool {
+		return a.SimpleEquals(b) && (a.GetLanguage() == b.GetLanguage() || a.GetLanguage() == lan.Unknown || b.GetLanguage() == lan.Unknown)
+	}
+	approved, unapproved, unused := map[string]*Entry{}, map[string]*Entry{}, map[string]*Entry{}
+	entry := func(m map[string]*Entry, d *deps.GenericDependency) *Entry {
+		key := d.FullString()
+		if e, ok := m[key]; ok {
+			return e
+		}
+		e := &Entry{Name: d.GetName(), Version: d.GetVersion(), Language: d.GetLanguage(), Repos: []string{}, Components: []string{}}
+		m[key] = e
+		return e
+	}
+	used := make([]bool, len(list))
+	for _, d := range inUse {
+		var e *Entry
+		for i, item := range list {
+			if !matches(d, item) {
+				continue
+			}
+			if e == nil {
+				e = entry(approved, d)
+				e.Repos = appendNew(e.Repos, d.GetProject())
+			}
+			used[i] = true
+			e.Components = appendNew(e.Components, item.GetProject())
+		}
+		if e == nil {
+			e = entry(unapproved, d)
+			e.Repos = appendNew(e.Repos, d.GetProject())
+		}
+	}
+	for i, item := range list {
+		if !used[i] {
+			e := entry(unused, item)
+			e.Components = appendNew(e.Components, item.GetProject())
+		}
+	}
+	return &Reconciliation{sortedEntries(approved), sortedEntries(unapproved), sortedEntries(unused)}
+}
+
+func appendNew(list []string, str string) []string {
+	if str == "" {
+		return list
+	}
+	for _, s := range list {
+		if s == str {
+			return list
+		}
+	}
+	list = append(list, str)
+	sort.Strings(list)
+	return list
+}
+
+func sortedEntries(m map[string]*Entry) []*Entry {
+	res := make([]*Entry, 0, len(m))
+	for _, e := range m {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		if res[i].Version != res[j].Version {
+			return res[i].Version < res[j].Version
+		}
+		return res[i].Language < res[j].Language
+	})
+	return res
+}
+
+func (r *Reconciliation) sections() []struct {
+	title   string
+	entries []*Entry
+} {
+	return []struct {
+		title   string
+		entries []*Entry
+	}{{"approved", r.Approved}, {"unapproved", r.Unapproved}, {"unused", r.Unused}}
+}
+
+// WriteCSV writes a row for each entry, after a header.
+func (r *Reconciliation) WriteCSV(w *csv.Writer) error {
+	w.Write([]string{"status", "name", "version", "language", "repositories", "components"})
+	for _, section := range r.sections() {
+		for _, e := range section.entries {
+			w.Write([]string{section.title, e.Name, e.Version, e.Language.String(), strings.Join(e.Repos, " "), strings.Join(e.Components, " ")})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Section is one status of the reconciliation, titled with its count.
+type Section struct {
+	Title string
+	Table *table.Table
+}
+
+// Sections are the approved, unapproved and unused entries as tables.
+func (r *Reconciliation) Sections() []Section {
+	titles := map[string]string{"approved": "Approved", "unapproved": "Unapproved in use", "unused": "Approved but unused"}
+	res := []Section{}
+	for _, section := range r.sections() {
+		t := table.New("Name", "Version", "Language", "Where").NoRowBorders().SpaceColumn(1)
+		for _, e := range section.entries {
+			where := strings.Join(e.Repos, " ")
+			if section.title == "unused" {
+				where = strings.Join(e.Components, " ")
+			}
+			t.Row(e.Name, e.Version, e.Language.String(), where)
+		}
+		res = append(res, Section{fmt.Sprintf("%s (%d)", titles[section.title], len(section.entries)), t})
+	}
+	return res
+}
+
+func (r *Reconciliation) String() string {
+	buf := bytes.NewBufferString("")
+	for _, section := range r.Sections() {
+		buf.WriteString(section.Title + "\n")
+		if len(section.Table.Body()) == 0 {
+			buf.WriteString("\n")
+			continue
+		}
+		buf.WriteString(section.Table.String())
+		buf.WriteString("\n\n")
+	}
+	return buf.String()
+}
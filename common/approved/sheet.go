@@ -0,0 +1,180 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package approved
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ReadSheet reads the rows of a CSV file, or of the first sheet of an XLSX
+// file when the name ends in .xlsx.
+func ReadSheet(fileName string, dat []byte) ([][]string, error) {
+	if strings.HasSuffix(strings.ToLower(fileName), ".xlsx") {
+		return readXLSX(dat)
+	}
+	reader := csv.NewReader(bytes.NewReader(dat))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	res := t.T
+	for _, r := range t.Runs {
+		res += r.T
+	}
+	return res
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Rel string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX reads the cell values of the first sheet. Empty rows are left out.
+func readXLSX(dat []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(dat), int64(len(dat)))
+	if err != nil {
+		return nil, fmt.Errorf("Not an XLSX file: %s", err.Error())
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v interface{}) (bool, error) {
+		f, ok := files[name]
+		if !ok {
+			return false, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return true, err
+		}
+		defer rc.Close()
+		content, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return true, err
+		}
+		return true, xml.Unmarshal(content, v)
+	}
+
+	var workbook xlsxWorkbook
+	var rels xlsxRels
+	if found, err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	} else if !found || len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("The XLSX file has no sheets")
+	}
+	if _, err = decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheetPath := "xl/worksheets/sheet1.xml"
+	for _, rel := range rels.Rels {
+		if rel.Id == workbook.Sheets[0].Rel {
+			if strings.HasPrefix(rel.Target, "/") {
+				sheetPath = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", rel.Target)
+			}
+		}
+	}
+	var shared xlsxSharedStrings
+	if _, err = decode("xl/sharedStrings.xml", &shared); err != nil {
+		return nil, err
+	}
+	var sheet xlsxWorksheet
+	if found, err := decode(sheetPath, &sheet); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("The XLSX file is missing its sheet %s", sheetPath)
+	}
+
+	res := [][]string{}
+	for _, row := range sheet.Rows {
+		values := []string{}
+		empty := true
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				letters := strings.TrimRight(c.Ref, "0123456789")
+				if index, ok := columnIndex(letters); ok {
+					col = index
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			value := c.Value
+			switch c.Type {
+			case "s":
+				index, err := strconv.Atoi(c.Value)
+				if err != nil || index < 0 || index >= len(shared.Items) {
+					return nil, fmt.Errorf("Cell %s has no shared string %s", c.Ref, c.Value)
+				}
+				value = shared.Items[index].String()
+			case "inlineStr":
+				value = c.Inline.String()
+			case "b":
+				value = map[string]string{"0": "FALSE", "1": "TRUE"}[c.Value]
+			}
+			values[col] = value
+			empty = empty && strings.TrimSpace(value) == ""
+		}
+		if !empty {
+			res = append(res, values)
+		}
+	}
+	return res, nil
+}
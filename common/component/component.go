@@ -0,0 +1,161 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package component
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+)
+
+// OverrideFile is read from the root of a repository to name its components.
+const OverrideFile = ".components.yml"
+
+// Root is the component of the manifests at the root of a repository.
+const Root = "."
+
+const NameField = `name`
+const FilesField = `files`
+const DependenciesField = `dependencies`
+
+const ComponentMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"name":{"type":"keyword"},
+		"files":{"type":"keyword"},
+		"dependencies":` + d.DependencyMapping + `
+	}
+}`
+
+// Component is a part of a repository, such as one service of a monorepo, with
+// the manifests found in it and their dependencies.
+type Component struct {
+	Name  string         `json:"name"`
+	Files []string       `json:"files"`
+	Deps  []d.Dependency `json:"dependencies"`
+}
+
+// Overrides are the directories of each named component. A manifest belongs to
+// the override with the longest directory holding it, and otherwise to the
+// component named after its own directory.
+type Overrides map[string][]string
+
+// Of returns the component of a manifest path.
+func Of(file string, overrides Overrides) string {
+	best, bestLen := "", -1
+	for name, dirs := range overrides {
+		for _, dir := range dirs {
+			dir = strings.Trim(path.Clean("/"+dir), "/")
+			if dir != "" && file != dir && !strings.HasPrefix(file, dir+"/") {
+				continue
+			}
+			if len(dir) > bestLen || len(dir) == bestLen && name < best {
+				best, bestLen = name, len(dir)
+			}
+		}
+	}
+	if bestLen >= 0 {
+		return best
+	}
+	return path.Dir(file)
+}
+
+// Group puts the dependencies of each manifest into its component, sorted by name.
+func Group(files map[string]d.Dependencies, overrides Overrides) []Component {
+	byName := map[string]*Component{}
+	for file, deps := range files {
+		name := Of(file, overrides)
+		comp, ok := byName[name]
+		if !ok {
+			comp = &Component{Name: name, Files: []string{}, Deps: []d.Dependency{}}
+			byName[name] = comp
+		}
+		comp.Files = append(comp.Files, file)
+		comp.Deps = append(comp.Deps, deps...)
+	}
+	res := make([]Component, 0, len(byName))
+	for _, comp := range byName {
+		deps := d.Dependencies(comp.Deps)
+		d.RemoveExactDuplicates(&deps)
+		sort.Sort(deps)
+		comp.Deps = deps
+		sort.Strings(comp.Files)
+		res = append(res, *comp)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Names returns the names of the components.
+func Names(components []Component) []string {
+	res := make([]string, len(components))
+	for i, comp := range components {
+		res[i] = comp.Name
+	}
+	return res
+}
+
+// Find returns the component with the name, or nil.
+func Find(components []Component, name string) *Component {
+	for i := range components {
+		if components[i].Name == name {
+			return &components[i]
+		}
+	}
+	return nil
+}
+
+// Diff lists the changes within each component, labelled with its name. The
+// changes of a repository that was one component before and after are left
+// unlabelled, as they are for scans without components.
+func Diff(old, new []Component) []d.Change {
+	if len(old) <= 1 && len(new) <= 1 && (len(old) == 0 || len(new) == 0 || old[0].Name == new[0].Name) {
+		var oldDeps, newDeps []d.Dependency
+		if len(old) == 1 {
+			oldDeps = old[0].Deps
+		}
+		if len(new) == 1 {
+			newDeps = new[0].Deps
+		}
+		return d.Diff(oldDeps, newDeps)
+	}
+	names := map[string]struct{}{}
+	for _, comp := range append(append([]Component{}, old...), new...) {
+		names[comp.Name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	res := []d.Change{}
+	for _, name := range sorted {
+		var oldDeps, newDeps []d.Dependency
+		if comp := Find(old, name); comp != nil {
+			oldDeps = comp.Deps
+		}
+		if comp := Find(new, name); comp != nil {
+			newDeps = comp.Deps
+		}
+		for _, change := range d.Diff(oldDeps, newDeps) {
+			change.Component = name
+			res = append(res, change)
+		}
+	}
+	return res
+}
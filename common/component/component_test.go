@@ -0,0 +1,83 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package component
+
+import (
+	"reflect"
+	"testing"
+
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+func TestOf(t *testing.T) {
+	overrides := Overrides{
+		"gateway": {"services/gateway"},
+		"shared":  {"services/", "lib"},
+		"all":     {"."},
+	}
+	tests := map[string]string{
+		"services/gateway/pom.xml":         "gateway",
+		"services/gateway-ui/package.json": "shared",
+		"lib/x/glide.yaml":                 "shared",
+		"glide.yaml":                       "all",
+	}
+	for file, expected := range tests {
+		if actual := Of(file, overrides); actual != expected {
+			t.Errorf("Component of %s is %s, expected %s", file, actual, expected)
+		}
+	}
+	if actual := Of("glide.yaml", nil); actual != Root {
+		t.Errorf("Component of a root file is %s", actual)
+	}
+	if actual := Of("ui/web/package.json", nil); actual != "ui/web" {
+		t.Errorf("Component of a nested file is %s", actual)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	files := map[string]d.Dependencies{
+		"api/glide.yaml":     {d.NewDependency("gin", "1.2.0", lan.Go)},
+		"api/sub/glide.yaml": {d.NewDependency("yaml", "2.0", lan.Go), d.NewDependency("gin", "1.2.0", lan.Go)},
+		"ui/package.json":    {d.NewDependency("express", "4.16.2", lan.JavaScript)},
+		"requirements.txt":   {},
+	}
+	res := Group(files, Overrides{"api": {"api"}})
+	if names := Names(res); !reflect.DeepEqual(names, []string{".", "api", "ui"}) {
+		t.Fatalf("Wrong components %v", names)
+	}
+	api := Find(res, "api")
+	if !reflect.DeepEqual(api.Files, []string{"api/glide.yaml", "api/sub/glide.yaml"}) || len(api.Deps) != 2 || api.Deps[0].Name != "gin" {
+		t.Errorf("Wrong api component %#v", api)
+	}
+	if Find(res, "missing") != nil {
+		t.Error("Found a missing component")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	gin := func(version string) d.Dependency { return d.NewDependency("gin", version, lan.Go) }
+	old := []Component{{Name: "api", Deps: []d.Dependency{gin("1.1.0")}}, {Name: "worker", Deps: []d.Dependency{gin("1.1.0")}}}
+	new := []Component{{Name: "api", Deps: []d.Dependency{gin("1.2.0")}}, {Name: "worker", Deps: []d.Dependency{gin("1.1.0")}}, {Name: "ui", Deps: []d.Dependency{gin("1.1.0")}}}
+	res := Diff(old, new)
+	if len(res) != 2 || res[0].Component != "api" || res[0].Type != d.Upgraded || res[1].Component != "ui" || res[1].Type != d.Added {
+		t.Errorf("Wrong changes %v", res)
+	}
+	res = Diff(old[:1], new[:1])
+	if len(res) != 1 || res[0].Component != "" {
+		t.Errorf("Changes of a single component are labelled %v", res)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package com
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/venicegeo/vzutil-versioning/common/component"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
+)
+
+// ScanConfigFile is read from the root of a scanned repository to configure its scan.
+const ScanConfigFile = ".vzutil.yml"
+
+const ConfigField = `config`
+
+// ScanConfigMapping stores the configuration with the scan without indexing it,
+// since language overrides and components are keyed by name.
+const ScanConfigMapping string = `{
+	"type":"object",
+	"enabled":false
+}`
+
+// ScanConfig is the scan configuration a repository commits, along with what
+// it left out of the scan. The methods are safe to call on a nil config, which
+// changes nothing.
+type ScanConfig struct {
+	// Include and Exclude are globs, as in a .gitignore, of the manifests found
+	// to scan. Without Include every manifest found is scanned.
+	Include []string `json:"include,omitempty" yaml:"include"`
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude"`
+	// Manifests are scanned as well as the ones found, such as ones in vendor folders.
+	Manifests []string `json:"manifests,omitempty" yaml:"manifests"`
+	// Ignore are globs of dependency names to leave out.
+	Ignore []string `json:"ignore,omitempty" yaml:"ignore"`
+	// Languages sets the language of dependencies by name.
+	Languages map[string]string `json:"languages,omitempty" yaml:"languages"`
+	// Components name the components of the repository by their directories.
+	Components component.Overrides `json:"components,omitempty" yaml:"components"`
+	// Suppress leaves out the issues containing any of the texts.
+	Suppress []string `json:"suppress,omitempty" yaml:"suppress"`
+
+	Excluded   []string `json:"excluded,omitempty" yaml:"-"`
+	Ignored    []string `json:"ignored,omitempty" yaml:"-"`
+	Suppressed []string `json:"suppressed,omitempty" yaml:"-"`
+}
+
+// Validate checks the globs and languages of the configuration.
+func (c *ScanConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, globs := range [][]string{c.Include, c.Exclude, c.Ignore} {
+		for _, glob := range globs {
+			for _, part := range strings.Split(strings.Trim(glob, "/ "), "/") {
+				if _, err := path.Match(part, ""); err != nil {
+					return fmt.Errorf("Invalid glob [%s] in %s", glob, ScanConfigFile)
+				}
+			}
+		}
+	}
+	for name, language := range c.Languages {
+		if lan.GetLanguage(language) == lan.Unknown {
+			return fmt.Errorf("Unknown language [%s] for [%s] in %s", language, name, ScanConfigFile)
+		}
+	}
+	return nil
+}
+
+// Files keeps the manifests found that are included and not excluded, and adds
+// the extra manifests.
+func (c *ScanConfig) Files(found []string) []string {
+	if c == nil {
+		return found
+	}
+	res := []string{}
+	for _, f := range found {
+		if (len(c.Include) == 0 || repocheck.MatchAny(c.Include, f)) && !repocheck.MatchAny(c.Exclude, f) {
+			res = append(res, f)
+		} else {
+			c.Excluded = addSorted(c.Excluded, f)
+		}
+	}
+	for _, f := range c.Manifests {
+		f = strings.TrimPrefix(path.Clean("/"+f), "/")
+		if !contains(res, f) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Dependencies sets the languages of the dependencies and leaves out the
+// ignored ones, keeping them sorted.
+func (c *ScanConfig) Dependencies(deps d.Dependencies) d.Dependencies {
+	if c == nil {
+		return deps
+	}
+	res := make(d.Dependencies, 0, len(deps))
+	// deduped here rather than by RemoveExactDuplicates, which drops the shas
+	seen := map[string]bool{}
+	for _, dep := range deps {
+		if language, ok := c.Languages[dep.Name]; ok {
+			dep.Language = lan.GetLanguage(language)
+		}
+		if repocheck.MatchAny(c.Ignore, dep.Name) {
+			c.Ignored = addSorted(c.Ignored, dep.FullString())
+			continue
+		}
+		if !seen[dep.FullString()] {
+			seen[dep.FullString()] = true
+			res = append(res, dep)
+		}
+	}
+	sort.Sort(res)
+	return res
+}
+
+// Issues leaves out the suppressed issues.
+func (c *ScanConfig) Issues(issues []string) []string {
+	if c == nil {
+		return issues
+	}
+	res := []string{}
+	for _, issue := range issues {
+		suppressed := false
+		for _, text := range c.Suppress {
+			suppressed = suppressed || text != "" && strings.Contains(issue, text)
+		}
+		if suppressed {
+			c.Suppressed = addSorted(c.Suppressed, issue)
+		} else {
+			res = append(res, issue)
+		}
+	}
+	return res
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func addSorted(list []string, str string) []string {
+	if contains(list, str) {
+		return list
+	}
+	list = append(list, str)
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package com
+
+import (
+	"reflect"
+	"testing"
+
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+func TestScanConfig(t *testing.T) {
+	cfg := &ScanConfig{
+		Include:   []string{"services/"},
+		Exclude:   []string{"services/legacy/"},
+		Manifests: []string{"/vendor/lib/glide.yaml"},
+		Ignore:    []string{"github.com/org/*", "junit"},
+		Languages: map[string]string{"numpy": "conda"},
+		Suppress:  []string{"unused variable"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	files := cfg.Files([]string{"services/api/glide.yaml", "services/legacy/pom.xml", "docs/package.json"})
+	if !reflect.DeepEqual(files, []string{"services/api/glide.yaml", "vendor/lib/glide.yaml"}) {
+		t.Errorf("Wrong files %v", files)
+	}
+	deps := cfg.Dependencies(d.Dependencies{
+		d.NewDependency("github.com/org/lib", "1.0.0", lan.Go),
+		d.NewDependency("junit", "4.12", lan.Java),
+		d.NewDependency("numpy", "1.14.0", lan.Python),
+		d.NewDependency("gin", "1.2.0", lan.Go),
+	})
+	if len(deps) != 2 || deps[0].Name != "numpy" || deps[0].Language != lan.Conda || deps[1].Name != "gin" {
+		t.Errorf("Wrong dependencies %v", deps)
+	}
+	pinned := d.NewDependency("github.com/other/lib", "v1.0.0", lan.Go)
+	pinned.Sha = "0123456789abcdef0123456789abcdef01234567"
+	if deps = cfg.Dependencies(d.Dependencies{pinned, pinned}); len(deps) != 1 || deps[0].Sha != pinned.Sha {
+		t.Errorf("Lost the sha of %v", deps)
+	}
+	issues := cfg.Issues([]string{"Found unused variable x", "Missing version for y"})
+	if !reflect.DeepEqual(issues, []string{"Missing version for y"}) {
+		t.Errorf("Wrong issues %v", issues)
+	}
+	if !reflect.DeepEqual(cfg.Excluded, []string{"docs/package.json", "services/legacy/pom.xml"}) ||
+		!reflect.DeepEqual(cfg.Ignored, []string{"github.com/org/lib:1.0.0:go", "junit:4.12:java"}) || len(cfg.Suppressed) != 1 {
+		t.Errorf("Wrong record %#v", cfg)
+	}
+
+	if err := (&ScanConfig{Languages: map[string]string{"x": "cobol"}}).Validate(); err == nil {
+		t.Error("Expected an unknown language")
+	}
+	var none *ScanConfig
+	if files := none.Files([]string{"pom.xml"}); len(files) != 1 {
+		t.Errorf("A missing config changed the files %v", files)
+	}
+}
@@ -26,6 +26,7 @@ import (
 const NameField = `name`
 const VersionField = `version`
 const LanguageField = `language`
+const ShaField = `sha`
 
 const DependencyMapping string = `{
 	"type":"nested",
@@ -33,7 +34,8 @@ const DependencyMapping string = `{
 	"properties":{
 		"name":{"type":"keyword"},
 		"version":{"type":"keyword"},
-		"language":{"type":"keyword"}
+		"language":{"type":"keyword"},
+		"sha":{"type":"keyword"}
 	}
 }`
 
@@ -41,10 +43,12 @@ type Dependency struct {
 	Name     string       `json:"name"`
 	Version  string       `json:"version"`
 	Language lan.Language `json:"language"`
+	// Sha is the commit a version was resolved from, when the manifest named a sha.
+	Sha string `json:"sha,omitempty"`
 }
 
 func NewDependency(name, version string, language lan.Language) Dependency {
-	return Dependency{strings.ToLower(name), strings.ToLower(version), language}
+	return Dependency{Name: strings.ToLower(name), Version: strings.ToLower(version), Language: language}
 }
 func NewDependencyStr(dep string) Dependency {
 	parts := strings.Split(dep, ":")
@@ -53,18 +57,18 @@ func NewDependencyStr(dep string) Dependency {
 	}
 	switch len(parts) {
 	case 1:
-		return Dependency{parts[0], "unknown", lan.Unknown}
+		return Dependency{Name: parts[0], Version: "unknown", Language: lan.Unknown}
 	case 2:
-		return Dependency{parts[0], parts[1], lan.Unknown}
+		return Dependency{Name: parts[0], Version: parts[1], Language: lan.Unknown}
 	case 3:
-		return Dependency{parts[0], parts[1], lan.GetLanguage(parts[2])}
+		return Dependency{Name: parts[0], Version: parts[1], Language: lan.GetLanguage(parts[2])}
 	default:
 		panic(fmt.Sprintf("Bad dep split. Line %s was split into %#v", dep, parts))
 	}
 	if len(parts) == 1 {
 		parts = append(parts, "Unknown")
 	}
-	return Dependency{parts[0], parts[1], lan.Unknown}
+	return Dependency{Name: parts[0], Version: parts[1], Language: lan.Unknown}
 }
 
 func (d *Dependency) SimpleEquals(dep *Dependency) bool {
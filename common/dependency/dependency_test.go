@@ -19,7 +19,7 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/venicegeo/vzutil-versioning/project/language"
+	"github.com/venicegeo/vzutil-versioning/common/language"
 )
 
 var testName, testVersion, testProject, testUnknown = "foo", "1.0.0", "bar", "Unknown"
@@ -0,0 +1,279 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dependency
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+type ChangeType string
+
+const Added ChangeType = "added"
+const Removed ChangeType = "removed"
+const Upgraded ChangeType = "upgraded"
+const Downgraded ChangeType = "downgraded"
+
+// Reformatted is the same version written another way, like 1.0 and 1.0.0 or v2 and 2.
+const Reformatted ChangeType = "reformatted"
+const LanguageChanged ChangeType = "language_changed"
+
+// VersionLevel is the most significant part of a version that changed.
+type VersionLevel string
+
+const Major VersionLevel = "major"
+const Minor VersionLevel = "minor"
+const Patch VersionLevel = "patch"
+const Qualifier VersionLevel = "qualifier"
+
+// Unclassified is used when the versions are not dotted numbers.
+const Unclassified VersionLevel = ""
+
+type Change struct {
+	Type        ChangeType   `json:"type"`
+	Name        string       `json:"name"`
+	OldVersion  string       `json:"old_version"`
+	NewVersion  string       `json:"new_version"`
+	OldLanguage lan.Language `json:"old_language"`
+	NewLanguage lan.Language `json:"new_language"`
+	Level       VersionLevel `json:"level"`
+	// Component is the part of a monorepo the change is in, when it has several.
+	Component string `json:"component,omitempty"`
+}
+
+const ChangeMapping string = `{
+	"type":"nested",
+	"dynamic":"strict",
+	"properties":{
+		"type":{"type":"keyword"},
+		"name":{"type":"keyword"},
+		"old_version":{"type":"keyword"},
+		"new_version":{"type":"keyword"},
+		"old_language":{"type":"keyword"},
+		"new_language":{"type":"keyword"},
+		"level":{"type":"keyword"},
+		"component":{"type":"keyword"}
+	}
+}`
+
+func (c *Change) String() string {
+	switch c.Type {
+	case Added:
+		return string(c.Type) + " " + c.Name + ":" + c.NewVersion + ":" + c.NewLanguage.String()
+	case Removed:
+		return string(c.Type) + " " + c.Name + ":" + c.OldVersion + ":" + c.OldLanguage.String()
+	case LanguageChanged:
+		return string(c.Type) + " " + c.Name + ":" + c.OldVersion + ":" + c.OldLanguage.String() + " -> " + c.NewVersion + ":" + c.NewLanguage.String()
+	}
+	res := string(c.Type) + " " + c.Name + " " + c.OldVersion + " -> " + c.NewVersion
+	if c.Level != Unclassified {
+		res += " (" + string(c.Level) + ")"
+	}
+	return res
+}
+
+// Diff lists how the dependencies changed between two scans. A name whose
+// version changed in the same language is an upgrade or downgrade, or a
+// reformatting when the versions compare equal, a name that
+// moved to another language is a language change, and the rest are additions
+// and removals. The result is sorted by name.
+func Diff(old, new []Dependency) []Change {
+	oldCount, newCount := map[string]int{}, map[string]int{}
+	for _, dep := range old {
+		oldCount[dep.FullString()]++
+	}
+	for _, dep := range new {
+		newCount[dep.FullString()]++
+	}
+	oldLeft, newLeft := []Dependency{}, []Dependency{}
+	for _, dep := range new {
+		if oldCount[dep.FullString()] > 0 {
+			oldCount[dep.FullString()]--
+		} else {
+			newLeft = append(newLeft, dep)
+		}
+	}
+	for _, dep := range old {
+		if newCount[dep.FullString()] > 0 {
+			newCount[dep.FullString()]--
+		} else {
+			oldLeft = append(oldLeft, dep)
+		}
+	}
+
+	byVersion := func(deps []Dependency) {
+		sort.SliceStable(deps, func(i, j int) bool { return CompareVersions(deps[i].Version, deps[j].Version) < 0 })
+	}
+	byVersion(oldLeft)
+	byVersion(newLeft)
+
+	res := []Change{}
+	// Pair within the same language first, then across languages.
+	pair := func(key func(Dependency) string, change func(o, n Dependency) Change) {
+		olds := map[string][]int{}
+		for i, dep := range oldLeft {
+			olds[key(dep)] = append(olds[key(dep)], i)
+		}
+		usedOld := map[int]bool{}
+		keptNew := []Dependency{}
+		for _, dep := range newLeft {
+			if idx := olds[key(dep)]; len(idx) > 0 {
+				olds[key(dep)] = idx[1:]
+				usedOld[idx[0]] = true
+				res = append(res, change(oldLeft[idx[0]], dep))
+			} else {
+				keptNew = append(keptNew, dep)
+			}
+		}
+		keptOld := []Dependency{}
+		for i, dep := range oldLeft {
+			if !usedOld[i] {
+				keptOld = append(keptOld, dep)
+			}
+		}
+		oldLeft, newLeft = keptOld, keptNew
+	}
+	pair(func(d Dependency) string { return d.Name + ":" + d.Language.String() }, func(o, n Dependency) Change {
+		typ := Upgraded
+		switch c := CompareVersions(n.Version, o.Version); {
+		case c == 0:
+			return Change{Reformatted, n.Name, o.Version, n.Version, o.Language, n.Language, Unclassified, ""}
+		case c < 0:
+			typ = Downgraded
+		}
+		return Change{typ, n.Name, o.Version, n.Version, o.Language, n.Language, ClassifyVersions(o.Version, n.Version), ""}
+	})
+	pair(func(d Dependency) string { return d.Name }, func(o, n Dependency) Change {
+		return Change{LanguageChanged, n.Name, o.Version, n.Version, o.Language, n.Language, ClassifyVersions(o.Version, n.Version), ""}
+	})
+	for _, dep := range oldLeft {
+		res = append(res, Change{Type: Removed, Name: dep.Name, OldVersion: dep.Version, OldLanguage: dep.Language})
+	}
+	for _, dep := range newLeft {
+		res = append(res, Change{Type: Added, Name: dep.Name, NewVersion: dep.Version, NewLanguage: dep.Language})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		if res[i].Type != res[j].Type {
+			return res[i].Type < res[j].Type
+		}
+		if c := CompareVersions(res[i].OldVersion, res[j].OldVersion); c != 0 {
+			return c < 0
+		}
+		return CompareVersions(res[i].NewVersion, res[j].NewVersion) < 0
+	})
+	return res
+}
+
+// versionParts splits a version such as ^1.2.3-rc1 into 1, 2, 3 and rc1.
+func versionParts(version string) []string {
+	version = strings.TrimLeft(strings.ToLower(strings.TrimSpace(version)), "^~=<>v ")
+	return strings.FieldsFunc(version, func(r rune) bool {
+		return r == '.' || r == '-' || r == '+' || r == '_'
+	})
+}
+
+func isNumber(part string) bool {
+	_, err := strconv.ParseUint(part, 10, 64)
+	return err == nil
+}
+
+// CompareVersions orders two versions, returning -1, 0 or 1. Numeric parts
+// compare as numbers and rank above qualifiers, so 1.10 > 1.9 > 1.9-SNAPSHOT.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if c := comparePart(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func comparePart(x, y string) int {
+	xNum, yNum := isNumber(x), isNumber(y)
+	switch {
+	case x == y:
+		return 0
+	case xNum && yNum:
+		xi, _ := strconv.ParseUint(x, 10, 64)
+		yi, _ := strconv.ParseUint(y, 10, 64)
+		if xi < yi {
+			return -1
+		} else if xi > yi {
+			return 1
+		}
+		return 0
+	case x == "":
+		// A release is newer than its qualifiers, and 1.0 is the same as 1.0.0.
+		if yNum {
+			return comparePart("0", y)
+		}
+		return 1
+	case y == "":
+		return -comparePart(y, x)
+	case xNum:
+		return 1
+	case yNum:
+		return -1
+	}
+	return strings.Compare(x, y)
+}
+
+// ClassifyVersions names the most significant part that differs between two
+// versions, as long as both start with a number.
+func ClassifyVersions(old, new string) VersionLevel {
+	po, pn := versionParts(old), versionParts(new)
+	if len(po) == 0 || len(pn) == 0 || !isNumber(po[0]) || !isNumber(pn[0]) {
+		return Unclassified
+	}
+	for i := 0; i < len(po) || i < len(pn); i++ {
+		var x, y string
+		if i < len(po) {
+			x = po[i]
+		}
+		if i < len(pn) {
+			y = pn[i]
+		}
+		if comparePart(x, y) == 0 {
+			continue
+		}
+		if !isNumber(x) && x != "" || !isNumber(y) && y != "" {
+			return Qualifier
+		}
+		switch i {
+		case 0:
+			return Major
+		case 1:
+			return Minor
+		default:
+			return Patch
+		}
+	}
+	return Unclassified
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dependency
+
+import (
+	"reflect"
+	"testing"
+
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		res  int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.9", "1.10", -1},
+		{"^2.0.0", "1.99.0", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.0-SNAPSHOT", "1.0.0", -1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+		{"v3.1.0", "3.0.9", 1},
+	}
+	for _, test := range tests {
+		if res := CompareVersions(test.a, test.b); res != test.res {
+			t.Errorf("CompareVersions(%s, %s) = %d, expected %d", test.a, test.b, res, test.res)
+		}
+	}
+}
+
+func TestClassifyVersions(t *testing.T) {
+	tests := []struct {
+		old, new string
+		level    VersionLevel
+	}{
+		{"1.2.3", "2.0.0", Major},
+		{"1.2.3", "1.3.0", Minor},
+		{"1.2.3", "1.2.4", Patch},
+		{"~1.2.3", "^1.2.10", Patch},
+		{"1.2", "1.2.1", Patch},
+		{"1.2.3-rc1", "1.2.3", Qualifier},
+		{"latest", "1.0.0", Unclassified},
+		{"1.0.0", "1.0.0", Unclassified},
+	}
+	for _, test := range tests {
+		if level := ClassifyVersions(test.old, test.new); level != test.level {
+			t.Errorf("ClassifyVersions(%s, %s) = %s, expected %s", test.old, test.new, level, test.level)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := []Dependency{
+		NewDependency("gin", "1.1.0", lan.Go),
+		NewDependency("express", "4.16.2", lan.JavaScript),
+		NewDependency("numpy", "1.14.0", lan.Python),
+		NewDependency("lodash", "4.17.4", lan.JavaScript),
+		NewDependency("junit", "4.12", lan.Java),
+		NewDependency("click", "6.6", lan.Conda),
+	}
+	new := []Dependency{
+		NewDependency("gin", "1.2.0", lan.Go),
+		NewDependency("express", "5.0.0", lan.JavaScript),
+		NewDependency("numpy", "1.13.3", lan.Python),
+		NewDependency("lodash", "4.17.4", lan.JavaScript),
+		NewDependency("click", "6.7", lan.Python),
+		NewDependency("yaml", "2.0", lan.Go),
+	}
+	expected := []Change{
+		{LanguageChanged, "click", "6.6", "6.7", lan.Conda, lan.Python, Minor, ""},
+		{Upgraded, "express", "4.16.2", "5.0.0", lan.JavaScript, lan.JavaScript, Major, ""},
+		{Upgraded, "gin", "1.1.0", "1.2.0", lan.Go, lan.Go, Minor, ""},
+		{Removed, "junit", "4.12", "", lan.Java, "", Unclassified, ""},
+		{Downgraded, "numpy", "1.14.0", "1.13.3", lan.Python, lan.Python, Minor, ""},
+		{Added, "yaml", "", "2.0", "", lan.Go, Unclassified, ""},
+	}
+	if res := Diff(old, new); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Diff returned\n%v\nexpected\n%v", res, expected)
+	}
+	if res := Diff(old, old); len(res) != 0 {
+		t.Errorf("Diff of identical lists returned %v", res)
+	}
+}
+
+func TestDiffReformatted(t *testing.T) {
+	old := []Dependency{NewDependency("gin", "v2", lan.Go), NewDependency("numpy", "1.0", lan.Python)}
+	new := []Dependency{NewDependency("gin", "2", lan.Go), NewDependency("numpy", "1.0.0", lan.Python)}
+	expected := []Change{
+		{Reformatted, "gin", "v2", "2", lan.Go, lan.Go, Unclassified, ""},
+		{Reformatted, "numpy", "1.0", "1.0.0", lan.Python, lan.Python, Unclassified, ""},
+	}
+	if res := Diff(old, new); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Diff returned %v, expected %v", res, expected)
+	}
+}
+
+func TestDiffSameNameTwice(t *testing.T) {
+	old := []Dependency{NewDependency("pip", "1.2", lan.Conda), NewDependency("pip", "1.3", lan.Conda)}
+	new := []Dependency{NewDependency("pip", "1.3", lan.Conda), NewDependency("pip", "1.4", lan.Conda)}
+	expected := []Change{{Upgraded, "pip", "1.2", "1.4", lan.Conda, lan.Conda, Minor, ""}}
+	if res := Diff(old, new); !reflect.DeepEqual(res, expected) {
+		t.Errorf("Diff returned %v, expected %v", res, expected)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dependency
+
+import (
+	"regexp"
+	"strings"
+
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+const unknown = "Unknown"
+
+// GenericDependency is a dependency along with the project that uses it, as
+// listed in about yamls and software lists.
+type GenericDependency struct {
+	name     string
+	version  string
+	project  string
+	language lan.Language
+}
+
+func NewGenericDependency(name, version, project string, language lan.Language) *GenericDependency {
+	return &GenericDependency{strings.ToLower(name), strings.ToLower(version), strings.ToLower(project), language}
+}
+
+// NewGenericDependencyStr reads name:version:project:language, where everything
+// after the name is optional.
+func NewGenericDependencyStr(dep string) *GenericDependency {
+	parts := strings.SplitN(dep, ":", 4)
+	for i, p := range parts {
+		parts[i] = strings.ToLower(p)
+	}
+	for len(parts) < 3 {
+		parts = append(parts, unknown)
+	}
+	language := lan.Unknown
+	if len(parts) == 4 {
+		language = lan.GetLanguage(parts[3])
+	}
+	return &GenericDependency{parts[0], parts[1], parts[2], language}
+}
+
+func NewGenericDependencyFrom(dep Dependency, project string) *GenericDependency {
+	return NewGenericDependency(dep.Name, dep.Version, project, dep.Language)
+}
+
+func (g *GenericDependency) GetName() string           { return g.name }
+func (g *GenericDependency) GetVersion() string        { return g.version }
+func (g *GenericDependency) GetProject() string        { return g.project }
+func (g *GenericDependency) GetLanguage() lan.Language { return g.language }
+func (g *GenericDependency) SetVersion(version string) { g.version = strings.ToLower(version) }
+func (g *GenericDependency) Dependency() Dependency {
+	return Dependency{Name: g.name, Version: g.version, Language: g.language}
+}
+func (g *GenericDependency) Clone() *GenericDependency {
+	res := *g
+	return &res
+}
+func (g *GenericDependency) SimpleEquals(dep *GenericDependency) bool {
+	return strings.EqualFold(g.name, dep.name) && strings.EqualFold(g.version, dep.version)
+}
+func (g *GenericDependency) LanguageEquals(dep *GenericDependency) bool {
+	return g.SimpleEquals(dep) && g.language == dep.language
+}
+func (g *GenericDependency) ProjectEquals(dep *GenericDependency) bool {
+	return g.SimpleEquals(dep) && strings.EqualFold(g.project, dep.project)
+}
+func (g *GenericDependency) String() string {
+	return g.name + ":" + g.version
+}
+func (g *GenericDependency) FullString() string {
+	return g.name + ":" + g.version + ":" + g.language.String()
+}
+
+type GenericDependencies []*GenericDependency
+
+func (g *GenericDependencies) Add(deps ...*GenericDependency) {
+	*g = append(*g, deps...)
+}
+
+func (g *GenericDependencies) Clone() GenericDependencies {
+	res := make(GenericDependencies, len(*g), len(*g))
+	for i, dep := range *g {
+		res[i] = dep.Clone()
+	}
+	return res
+}
+
+func (g *GenericDependencies) removeDuplicates(key func(*GenericDependency) string) {
+	found := map[string]bool{}
+	res := GenericDependencies{}
+	for _, dep := range *g {
+		if k := key(dep); !found[k] {
+			found[k] = true
+			res = append(res, dep)
+		}
+	}
+	*g = res
+}
+
+func (g *GenericDependencies) RemoveExactDuplicates() {
+	g.removeDuplicates(func(dep *GenericDependency) string { return dep.FullString() + ":" + dep.project })
+}
+
+// RemoveDuplicatesByProject keeps one of each dependency used by several projects.
+func (g *GenericDependencies) RemoveDuplicatesByProject() {
+	g.removeDuplicates((*GenericDependency).FullString)
+}
+
+// RemoveExceptions drops the dependencies whose name matches any of the patterns.
+func (g *GenericDependencies) RemoveExceptions(patterns []*regexp.Regexp) {
+	res := GenericDependencies{}
+	for _, dep := range *g {
+		keep := true
+		for _, re := range patterns {
+			if re.MatchString(dep.name) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			res = append(res, dep)
+		}
+	}
+	*g = res
+}
+
+// CondenseBundles renames the packages of each bundle to the bundle, so a
+// library shipped as several packages is listed once per version.
+func (g *GenericDependencies) CondenseBundles(bundles map[string][]string) {
+	for _, dep := range *g {
+		for bundle, packages := range bundles {
+			for _, pkg := range packages {
+				if strings.EqualFold(dep.name, pkg) {
+					dep.name = strings.ToLower(bundle)
+				}
+			}
+		}
+	}
+	g.RemoveExactDuplicates()
+}
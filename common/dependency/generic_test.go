@@ -0,0 +1,67 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package dependency
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+func fullStrings(deps GenericDependencies) []string {
+	res := []string{}
+	for _, dep := range deps {
+		res = append(res, dep.FullString()+":"+dep.GetProject())
+	}
+	return res
+}
+
+func TestGenericDependencies(t *testing.T) {
+	deps := GenericDependencies{
+		NewGenericDependency("org.geotools:gt-main", "17.0", "a", lan.Java),
+		NewGenericDependency("org.geotools:gt-api", "17.0", "a", lan.Java),
+		NewGenericDependency("org.geotools:gt-main", "17.0", "b", lan.Java),
+		NewGenericDependency("github.com/venicegeo/pz-gocommon", "1.0.0", "a", lan.Go),
+		NewGenericDependency("github.com/venicegeo/pz-gocommon", "1.0.0", "a", lan.Go),
+	}
+
+	exact := deps.Clone()
+	exact.RemoveExactDuplicates()
+	if len(exact) != 4 {
+		t.Errorf("Exact duplicates left %v", fullStrings(exact))
+	}
+
+	exact.RemoveExceptions([]*regexp.Regexp{regexp.MustCompile(`^github\.com/venicegeo/.+$`)})
+	if len(exact) != 3 {
+		t.Errorf("Exceptions left %v", fullStrings(exact))
+	}
+
+	exact.CondenseBundles(map[string][]string{"geotools": {"org.geotools:gt-main", "org.geotools:gt-api"}})
+	expected := []string{"geotools:17.0:java:a", "geotools:17.0:java:b"}
+	if got := fullStrings(exact); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Condensed to %v, expected %v", got, expected)
+	}
+
+	exact.RemoveDuplicatesByProject()
+	if len(exact) != 1 {
+		t.Errorf("Duplicates by project left %v", fullStrings(exact))
+	}
+	if deps[0].GetName() != "org.geotools:gt-main" {
+		t.Error("Clone shared dependencies with the original")
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package match
+
+import (
+	"math"
+	"sort"
+)
+
+// Matcher pairs the items of two lists so the total similarity of the pairs is
+// as high as it can be. Pairs scoring below the threshold are left unmatched.
+type Matcher struct {
+	Similarity Similarity
+	Threshold  float64
+}
+
+func NewMatcher(sim Similarity, threshold float64) Matcher {
+	return Matcher{sim, threshold}
+}
+
+// Pair is an index into each list, or -1 for an item with no match.
+type Pair struct {
+	A     int
+	B     int
+	Score float64
+}
+
+// Match assigns the items of a to the items of b. Every item is in exactly one
+// pair. Matched pairs come first, most similar first, followed by the
+// unmatched items of a and then of b, each in their order. The result only
+// depends on the order of the lists when assignments tie.
+func (m Matcher) Match(a, b []string) []Pair {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	scores := make([][]float64, n)
+	for i := range scores {
+		scores[i] = make([]float64, n)
+		if i >= len(a) {
+			continue
+		}
+		for j := range b {
+			if score := m.Similarity(a[i], b[j]); score >= m.Threshold && score > 0 {
+				scores[i][j] = score
+			}
+		}
+	}
+	assigned := assign(scores)
+
+	matched, unmatchedA, unmatchedB := []Pair{}, []Pair{}, []Pair{}
+	usedB := make([]bool, len(b))
+	for i := range a {
+		if j := assigned[i]; j < len(b) && scores[i][j] > 0 {
+			matched = append(matched, Pair{i, j, scores[i][j]})
+			usedB[j] = true
+		} else {
+			unmatchedA = append(unmatchedA, Pair{i, -1, 0})
+		}
+	}
+	for j := range b {
+		if !usedB[j] {
+			unmatchedB = append(unmatchedB, Pair{-1, j, 0})
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Score > matched[j].Score })
+	return append(append(matched, unmatchedA...), unmatchedB...)
+}
+
+// Align matches the lists and lines them up, padding the unmatched items with
+// blanks, so the same row of each holds a pair.
+func (m Matcher) Align(a, b []string) ([]string, []string) {
+	pairs := m.Match(a, b)
+	resA, resB := make([]string, len(pairs)), make([]string, len(pairs))
+	for i, p := range pairs {
+		if p.A >= 0 {
+			resA[i] = a[p.A]
+		}
+		if p.B >= 0 {
+			resB[i] = b[p.B]
+		}
+	}
+	return resA, resB
+}
+
+// assign solves the assignment problem on a square matrix of scores with the
+// Hungarian method, returning the column given to each row so that the sum of
+// the scores is largest.
+func assign(scores [][]float64) []int {
+	n := len(scores)
+	// u and v are the potentials of the rows and columns, p the row given to
+	// each column, all one based with 0 as the row being added.
+	u, v := make([]float64, n+1), make([]float64, n+1)
+	p, way := make([]int, n+1), make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for p[j0] != 0 {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := -scores[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	res := make([]int, n)
+	for j := 1; j <= n; j++ {
+		if p[j] != 0 {
+			res[p[j]-1] = j - 1
+		}
+	}
+	return res
+}
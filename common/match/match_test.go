@@ -0,0 +1,99 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package match
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSimilarities(t *testing.T) {
+	tests := []struct {
+		sim  string
+		a, b string
+		res  float64
+	}{
+		{"levenshtein", "kitten", "sitting", 4.0 / 7},
+		{"levenshtein", "Junit", "junit", 1},
+		{"levenshtein", "", "", 0},
+		{"levenshtein", "abc", "", 0},
+		{"jarowinkler", "martha", "marhta", 0.9611},
+		{"jarowinkler", "dixon", "dicksonx", 0.8133},
+		{"jarowinkler", "abc", "xyz", 0},
+		{"tokens", "pz-gateway", "gateway-pz", 1},
+		{"tokens", "spring-boot-starter", "spring-boot", 2.0 / 3},
+		{"tokens", "", "", 0},
+		{"purl", "pkg:maven/org.geotools/gt-main@19.0", "pkg:maven/org.geotools/gt-main@19.0", 1},
+		{"purl", "pkg:npm/express@4.16.0", "pkg:pypi/express@4.16.0", 0},
+		{"purl", "junit:4.12:java", "junit:4.11:java", 0.75 + 0.25*0.75},
+		{"purl", "org.geotools:gt-main:19.0:java", "pkg:java/org.geotools:gt-main@19.0", 1},
+		{"purl", "six:1.11.0:python", "six:1.11.0:java", 0},
+	}
+	for _, test := range tests {
+		sim, err := SimilarityByName(test.sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := sim(test.a, test.b); math.Abs(res-test.res) > 0.0001 {
+			t.Errorf("%s(%s, %s) = %f, expected %f", test.sim, test.a, test.b, res, test.res)
+		}
+	}
+	if _, err := SimilarityByName("soundex"); err == nil {
+		t.Error("Expected an unknown similarity")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []string
+		threshold float64
+		pairs     string
+	}{
+		{"empty", nil, nil, 0.5, ""},
+		{"exact", []string{"a", "b"}, []string{"b", "a"}, 0.5, "a=a b=b"},
+		// Greedy takes the first 0.9 and leaves XbcdefghiX below the threshold.
+		{"optimal over greedy", []string{"abcdefghij", "XbcdefghiX"}, []string{"abcdefghiX", "abcdefghXY"}, 0.75, "XbcdefghiX=abcdefghiX abcdefghij=abcdefghXY"},
+		{"threshold", []string{"pz-ui", "bf-api"}, []string{"pz-uix", "zzz"}, 0.5, "pz-ui=pz-uix bf-api= =zzz"},
+		{"more on the right", []string{"junit:4.12"}, []string{"mockito:2.0", "junit:4.11"}, 0, "junit:4.12=junit:4.11 =mockito:2.0"},
+		{"nothing alike", []string{"abc"}, []string{"xyz"}, 0, "abc= =xyz"},
+	}
+	for _, test := range tests {
+		pairs := NewMatcher(Levenshtein, test.threshold).Match(test.a, test.b)
+		strs := []string{}
+		for _, p := range pairs {
+			switch {
+			case p.A >= 0 && p.B >= 0:
+				strs = append(strs, test.a[p.A]+"="+test.b[p.B])
+			case p.A >= 0:
+				strs = append(strs, test.a[p.A]+"=")
+			default:
+				strs = append(strs, "="+test.b[p.B])
+			}
+		}
+		if res := strings.Join(strs, " "); res != test.pairs {
+			t.Errorf("%s: got [%s], expected [%s]", test.name, res, test.pairs)
+		}
+	}
+}
+
+func TestAlign(t *testing.T) {
+	a, b := NewMatcher(Levenshtein, 0.5).Align([]string{"express:4.15.0", "six:1.11.0"}, []string{"junit:4.12", "express:4.16.0"})
+	if strings.Join(a, ",") != "express:4.15.0,six:1.11.0," || strings.Join(b, ",") != "express:4.16.0,,junit:4.12" {
+		t.Fatalf("Wrong alignment %q %q", a, b)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package match
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Similarity scores two strings from 0, nothing alike, to 1, the same.
+type Similarity func(a, b string) float64
+
+// Similarities are the similarities by the names used in flags and configs.
+var Similarities = map[string]Similarity{
+	"levenshtein": Levenshtein,
+	"jarowinkler": JaroWinkler,
+	"tokens":      Tokens,
+	"purl":        Purl,
+}
+
+// SimilarityByName looks up one of Similarities, ignoring case.
+func SimilarityByName(name string) (Similarity, error) {
+	if sim, ok := Similarities[strings.ToLower(name)]; ok {
+		return sim, nil
+	}
+	names := make([]string, 0, len(Similarities))
+	for n := range Similarities {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("Unknown similarity [%s], expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Levenshtein is one minus the edit distance over the length of the longer
+// string, ignoring case. Two blank strings score 0.
+func Levenshtein(a, b string) float64 {
+	if strings.TrimSpace(a) == "" && strings.TrimSpace(b) == "" {
+		return 0
+	}
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	longer := len(ra)
+	if len(rb) > longer {
+		longer = len(rb)
+	}
+	return float64(longer-levenshteinDistance(ra, rb)) / float64(longer)
+}
+
+func levenshteinDistance(a, b []rune) int {
+	costs := make([]int, len(b)+1)
+	for j := range costs {
+		costs[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		last := costs[0]
+		costs[0] = i
+		for j := 1; j <= len(b); j++ {
+			current := costs[j]
+			if a[i-1] == b[j-1] {
+				costs[j] = last
+			} else {
+				costs[j] = 1 + min(last, min(costs[j], costs[j-1]))
+			}
+			last = current
+		}
+	}
+	return costs[len(b)]
+}
+
+// JaroWinkler is the Jaro similarity raised for a common prefix of up to four
+// characters, ignoring case. It favours names that differ at the end.
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA, matchedB := make([]bool, len(ra)), make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions/2))/m) / 3
+	prefix := 0
+	for prefix < min(4, min(len(ra), len(rb))) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// Tokens is the overlap of the words of the strings, split at anything that is
+// not a letter or digit, over all of their words.
+func Tokens(a, b string) float64 {
+	ta, tb := tokenSet(a), tokenSet(b)
+	if len(ta) == 0 && len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func tokenSet(s string) map[string]bool {
+	res := map[string]bool{}
+	for _, t := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		res[t] = true
+	}
+	return res
+}
+
+// Purl compares package coordinates, either package urls like
+// pkg:maven/org.geotools/gt-main@19.0 or dependencies as name:version:language.
+// Packages of different types never match. Otherwise the names weigh three
+// times as much as the versions.
+func Purl(a, b string) float64 {
+	pa, pb := parseCoordinate(a), parseCoordinate(b)
+	if pa.name == "" && pb.name == "" {
+		return 0
+	}
+	if pa.typ != "" && pb.typ != "" && pa.typ != pb.typ {
+		return 0
+	}
+	version := 1.0
+	if pa.version != pb.version {
+		version = Levenshtein(pa.version, pb.version)
+	}
+	return 0.75*Levenshtein(pa.name, pb.name) + 0.25*version
+}
+
+type coordinate struct {
+	typ, name, version string
+}
+
+func parseCoordinate(s string) coordinate {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "pkg:") {
+		s = strings.TrimPrefix(s, "pkg:")
+		if i := strings.IndexAny(s, "?#"); i >= 0 {
+			s = s[:i]
+		}
+		res := coordinate{}
+		if i := strings.Index(s, "/"); i >= 0 {
+			res.typ, s = s[:i], s[i+1:]
+		}
+		if i := strings.LastIndex(s, "@"); i >= 0 {
+			res.name, res.version = s[:i], s[i+1:]
+		} else {
+			res.name = s
+		}
+		return res
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 {
+		res := coordinate{name: parts[0]}
+		if len(parts) == 2 {
+			res.version = parts[1]
+		}
+		return res
+	}
+	n := len(parts)
+	return coordinate{typ: parts[n-1], name: strings.Join(parts[:n-2], ":"), version: parts[n-2]}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package repocheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	i "github.com/venicegeo/vzutil-versioning/common/issue"
+)
+
+// Rules are the hygiene checks run against a repository. Every path in them
+// is a glob, see Match.
+type Rules struct {
+	// Exclude leaves paths out of every other rule.
+	Exclude        []string       `json:"exclude"`
+	RequiredFiles  []RequiredFile `json:"required_files"`
+	Headers        []Header       `json:"headers"`
+	ForbiddenFiles []string       `json:"forbidden_files"`
+	Gitignore      *Gitignore     `json:"gitignore,omitempty"`
+	// MaxFileSize is in bytes, zero for no limit.
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// RequiredFile is met by any file matching one of its patterns. Anchor the
+// patterns with a leading slash to only look at the root.
+type RequiredFile struct {
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+}
+
+// Header is text that must be near the top of the matching files. Each line
+// of the template must appear as written, except that {year} stands for a
+// year or a range of years like 2016-2018, all of which must fall between
+// FirstYear and LastYear. A LastYear of zero means the current year.
+type Header struct {
+	Files     []string `json:"files"`
+	Template  []string `json:"template"`
+	FirstYear int      `json:"first_year"`
+	LastYear  int      `json:"last_year"`
+	// Bytes is how far into each file to look, 2048 when zero.
+	Bytes int `json:"bytes"`
+}
+
+type Gitignore struct {
+	// Required raises an issue when there is no .gitignore at the root.
+	Required bool `json:"required"`
+	// MustIgnore are entries that must be in the root .gitignore.
+	MustIgnore []string `json:"must_ignore"`
+	// Committed raises an issue for each file in the tree that the root .gitignore ignores.
+	Committed bool `json:"committed"`
+}
+
+type Rule string
+
+const RequiredFileRule Rule = "required_file"
+const HeaderRule Rule = "header"
+const ForbiddenFileRule Rule = "forbidden_file"
+const GitignoreRule Rule = "gitignore"
+const MaxFileSizeRule Rule = "max_file_size"
+
+// Issue is a rule a repository broke, and where.
+type Issue struct {
+	Rule    Rule   `json:"rule"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+const IssueMapping = `{
+	"type":"nested",
+	"dynamic":"strict",
+	"properties":{
+		"rule":{"type":"keyword"},
+		"path":{"type":"keyword"},
+		"message":{"type":"text"}
+	}
+}`
+
+func (is Issue) String() string {
+	if is.Path == "" {
+		return fmt.Sprintf("[%s] %s", is.Rule, is.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s", is.Rule, is.Path, is.Message)
+}
+
+// ToIssues turns the results of a check into plain issues.
+func ToIssues(issues []Issue) i.Issues {
+	res := make(i.Issues, len(issues), len(issues))
+	for c, is := range issues {
+		res[c] = i.NewIssue("%s", is.String())
+	}
+	return res
+}
+
+var yearPattern = `(\d{4})(?:\s*[-,]\s*(\d{4}))?`
+
+// Default is the set of checks the auditor has always run: a README, LICENSE and
+// .about.yml, and the RadiantBlue Apache header on source files.
+func Default() *Rules {
+	return &Rules{
+		Exclude: []string{"/vendor/", "node_modules/"},
+		RequiredFiles: []RequiredFile{
+			{Name: "README", Patterns: []string{"/README", "/README.md", "/README.txt"}},
+			{Name: "LICENSE", Patterns: []string{"/LICENSE", "/LICENSE.md", "/LICENSE.txt"}},
+			{Name: ".about.yml", Patterns: []string{"/.about.yml"}},
+		},
+		Headers: []Header{{
+			Files: []string{"*.go", "*.java", "*.js", "*.py", "*.ts", "*.tsx"},
+			Template: []string{
+				"Copyright {year}, RadiantBlue Technologies, Inc.",
+				"Apache License, Version 2.0",
+				"WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND",
+			},
+			FirstYear: 2016,
+		}},
+		ForbiddenFiles: []string{".env", "*.pem", "*.key", "id_rsa", "id_dsa"},
+	}
+}
+
+// Load reads rules from json and checks them.
+func Load(dat []byte) (*Rules, error) {
+	rules := &Rules{}
+	if err := json.Unmarshal(dat, rules); err != nil {
+		return nil, err
+	}
+	return rules, rules.Validate()
+}
+
+// Validate checks every glob and header template of the rules.
+func (r *Rules) Validate() error {
+	globs := append(append([]string{}, r.Exclude...), r.ForbiddenFiles...)
+	for _, req := range r.RequiredFiles {
+		if len(req.Patterns) == 0 {
+			return fmt.Errorf("Required file [%s] has no patterns", req.Name)
+		}
+		globs = append(globs, req.Patterns...)
+	}
+	for _, header := range r.Headers {
+		globs = append(globs, header.Files...)
+		if _, err := header.compile(); err != nil {
+			return err
+		}
+	}
+	for _, g := range globs {
+		if !validGlob(g) {
+			return fmt.Errorf("Bad glob [%s]", g)
+		}
+	}
+	return nil
+}
+
+// WithExcludes is a copy of the rules that also leaves out the given globs.
+func (r *Rules) WithExcludes(globs ...string) *Rules {
+	cpy := *r
+	cpy.Exclude = append(append([]string{}, r.Exclude...), globs...)
+	return &cpy
+}
+
+// Check runs the rules against a tree. The issues are sorted by rule and path.
+func Check(tree Tree, rules *Rules) ([]Issue, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	all, err := tree.Files()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]int64{}
+	for f, size := range all {
+		if !MatchAny(rules.Exclude, f) {
+			files[f] = size
+		}
+	}
+	names := make([]string, 0, len(files))
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	res := []Issue{}
+	for _, req := range rules.RequiredFiles {
+		found := false
+		for _, f := range names {
+			if MatchAny(req.Patterns, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, Issue{Rule: RequiredFileRule, Message: fmt.Sprintf("Missing %s", req.Name)})
+		}
+	}
+	for _, f := range names {
+		if MatchAny(rules.ForbiddenFiles, f) {
+			res = append(res, Issue{Rule: ForbiddenFileRule, Path: f, Message: "File should not be committed"})
+		}
+		if rules.MaxFileSize > 0 && files[f] > rules.MaxFileSize {
+			res = append(res, Issue{Rule: MaxFileSizeRule, Path: f, Message: fmt.Sprintf("File is %d bytes, over the limit of %d", files[f], rules.MaxFileSize)})
+		}
+	}
+	now := time.Now().Year()
+	for _, header := range rules.Headers {
+		lines, _ := header.compile()
+		for _, f := range names {
+			if !MatchAny(header.Files, f) {
+				continue
+			}
+			dat, err := tree.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if is := header.check(lines, f, dat, now); is != nil {
+				res = append(res, *is)
+			}
+		}
+	}
+	if rules.Gitignore != nil {
+		gitignore, err := checkGitignore(tree, rules.Gitignore, names, files)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, gitignore...)
+	}
+	sort.SliceStable(res, func(a, b int) bool {
+		if res[a].Rule != res[b].Rule {
+			return res[a].Rule < res[b].Rule
+		}
+		return res[a].Path < res[b].Path
+	})
+	return res, nil
+}
+
+func (h *Header) compile() ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(h.Template), len(h.Template))
+	for l, line := range h.Template {
+		quoted := strings.Replace(regexp.QuoteMeta(line), regexp.QuoteMeta("{year}"), yearPattern, -1)
+		re, err := regexp.Compile(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("Bad header line [%s]: %s", line, err.Error())
+		}
+		res[l] = re
+	}
+	return res, nil
+}
+
+func (h *Header) check(lines []*regexp.Regexp, f string, dat []byte, now int) *Issue {
+	size := h.Bytes
+	if size <= 0 {
+		size = 2048
+	}
+	if len(dat) > size {
+		dat = dat[:size]
+	}
+	last := h.LastYear
+	if last == 0 {
+		last = now
+	}
+	missing := []string{}
+	for l, re := range lines {
+		match := re.FindStringSubmatch(string(dat))
+		if match == nil {
+			missing = append(missing, h.Template[l])
+			continue
+		}
+		for _, y := range match[1:] {
+			if y == "" {
+				continue
+			}
+			if year, _ := strconv.Atoi(y); year < h.FirstYear || year > last {
+				return &Issue{Rule: HeaderRule, Path: f, Message: fmt.Sprintf("Header year %d is outside %d-%d", year, h.FirstYear, last)}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return &Issue{Rule: HeaderRule, Path: f, Message: fmt.Sprintf("Header is missing %q", missing)}
+	}
+	return nil
+}
+
+func checkGitignore(tree Tree, rule *Gitignore, names []string, files map[string]int64) ([]Issue, error) {
+	res := []Issue{}
+	if _, ok := files[".gitignore"]; !ok {
+		if rule.Required {
+			res = append(res, Issue{Rule: GitignoreRule, Message: "Missing .gitignore"})
+		}
+		return res, nil
+	}
+	dat, err := tree.ReadFile(".gitignore")
+	if err != nil {
+		return nil, err
+	}
+	patterns := []string{}
+	negated := []string{}
+	for _, line := range strings.Split(string(dat), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			negated = append(negated, strings.TrimPrefix(line, "!"))
+		} else {
+			patterns = append(patterns, line)
+		}
+	}
+	for _, must := range rule.MustIgnore {
+		found := false
+		for _, p := range patterns {
+			if p == must || strings.TrimPrefix(p, "/") == strings.TrimPrefix(must, "/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, Issue{Rule: GitignoreRule, Path: ".gitignore", Message: fmt.Sprintf("Does not ignore %s", must)})
+		}
+	}
+	if rule.Committed {
+		for _, f := range names {
+			if validIgnore(patterns, f) && !MatchAny(negated, f) {
+				res = append(res, Issue{Rule: GitignoreRule, Path: f, Message: "File is committed but ignored"})
+			}
+		}
+	}
+	return res, nil
+}
+
+// validIgnore matches the usable patterns of a .gitignore, skipping any that are not valid globs.
+func validIgnore(patterns []string, f string) bool {
+	for _, p := range patterns {
+		if validGlob(p) && Match(p, f) {
+			return true
+		}
+	}
+	return false
+}
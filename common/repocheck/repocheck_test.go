@@ -0,0 +1,124 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package repocheck
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type memTree map[string]string
+
+func (m memTree) Files() (map[string]int64, error) {
+	res := map[string]int64{}
+	for f, dat := range m {
+		res[f] = int64(len(dat))
+	}
+	return res, nil
+}
+
+func (m memTree) ReadFile(path string) ([]byte, error) {
+	dat, ok := m[path]
+	if !ok {
+		return nil, fmt.Errorf("No file %s", path)
+	}
+	return []byte(dat), nil
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "a/b/main.go", true},
+		{"/README*", "README.md", true},
+		{"/README*", "docs/README.md", false},
+		{"vendor/", "vendor/gopkg.in/yaml.v2/yaml.go", true},
+		{"/vendor/", "web/vendor/a.go", false},
+		{"node_modules/", "web/node_modules/a/index.js", true},
+		{"src/**/*.java", "src/Main.java", true},
+		{"src/**/*.java", "src/a/b/Main.java", true},
+		{"src/**/*.java", "test/Main.java", false},
+		{"", "main.go", false},
+	}
+	for _, test := range tests {
+		if got := Match(test.pattern, test.name); got != test.match {
+			t.Errorf("Match(%q, %q) = %t", test.pattern, test.name, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	year := time.Now().Year()
+	rules := Default()
+	rules.MaxFileSize = 150
+	rules.Gitignore = &Gitignore{Required: true, MustIgnore: []string{".env"}, Committed: true}
+	tree := memTree{
+		"README.md":        "readme",
+		".gitignore":       "# build\nbin/\n*.log\n!keep.log\n",
+		"main.go":          fmt.Sprintf("/*\nCopyright 2016-%d, RadiantBlue Technologies, Inc.\nApache License, Version 2.0\nWITHOUT WARRANTIES OR CONDITIONS OF ANY KIND\n*/", year),
+		"old.go":           "// Copyright 2015, RadiantBlue Technologies, Inc.\n// Apache License, Version 2.0\n// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND",
+		"bare.py":          "print('hi')",
+		"vendor/lib/a.go":  "package lib",
+		"certs/server.pem": "secret",
+		"bin/tool":         string(make([]byte, 200)),
+		"keep.log":         "kept",
+	}
+	res, err := Check(tree, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, is := range res {
+		got = append(got, is.String())
+	}
+	expected := []string{
+		"[forbidden_file] certs/server.pem: File should not be committed",
+		"[gitignore] .gitignore: Does not ignore .env",
+		"[gitignore] bin/tool: File is committed but ignored",
+		`[header] bare.py: Header is missing ["Copyright {year}, RadiantBlue Technologies, Inc." "Apache License, Version 2.0" "WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND"]`,
+		fmt.Sprintf("[header] old.go: Header year 2015 is outside 2016-%d", year),
+		"[max_file_size] bin/tool: File is 200 bytes, over the limit of 150",
+		"[required_file] Missing LICENSE",
+		"[required_file] Missing .about.yml",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got\n%q\nexpected\n%q", got, expected)
+	}
+	if issues := ToIssues(res); len(issues) != len(res) {
+		t.Errorf("Expected %d issues, got %d", len(res), len(issues))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	rules, err := Load([]byte(`{"exclude":["docs/"],"forbidden_files":["*.bak"],"headers":[{"files":["*.go"],"template":["(c) {year} Example"],"first_year":2018,"last_year":2019}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Check(memTree{"a.go": "// (c) 2018-2019 Example", "b.go": "// (c) 2020 Example", "c.bak": "", "docs/d.bak": ""}, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Path != "c.bak" || res[1].Path != "b.go" {
+		t.Errorf("Unexpected issues %v", res)
+	}
+	if _, err = Load([]byte(`{"forbidden_files":["[a-"]}`)); err == nil {
+		t.Error("Expected a bad glob to fail")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package repocheck
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tree is the files of a repository, either checked out or in a commit.
+type Tree interface {
+	// Files maps the slash separated path of every file to its size.
+	Files() (map[string]int64, error)
+	ReadFile(path string) ([]byte, error)
+}
+
+type dirTree string
+
+// DirTree is the files in a folder, leaving out .git.
+func DirTree(dir string) Tree {
+	return dirTree(dir)
+}
+
+func (d dirTree) Files() (map[string]int64, error) {
+	root := string(d)
+	res := map[string]int64{}
+	err := filepath.Walk(root, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if f.IsDir() {
+			if rel == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		res[rel] = f.Size()
+		return nil
+	})
+	return res, err
+}
+
+func (d dirTree) ReadFile(p string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(d), filepath.FromSlash(strings.TrimPrefix(p, "/"))))
+}
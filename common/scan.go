@@ -18,7 +18,9 @@ package com
 import (
 	"time"
 
+	"github.com/venicegeo/vzutil-versioning/common/component"
 	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
 )
 
 const FullNameField = `full_name`
@@ -29,6 +31,9 @@ const TimestampField = `timestamp`
 const DependenciesField = `dependencies`
 const IssuesField = `issues`
 const FilesField = `files`
+const ResolverVersionField = `resolver_version`
+const ChecksField = `checks`
+const ComponentsField = `components`
 
 const DependencyScanMapping string = `{
 	"dynamic":"strict",
@@ -37,10 +42,14 @@ const DependencyScanMapping string = `{
 		"name":{"type":"keyword"},
 		"refs":{"type":"keyword"},
 		"sha":{"type":"keyword"},
-		"timestamp":{"type":"keyword"},
+		"timestamp":{"type":"date"},
 		"dependencies":` + d.DependencyMapping + `,
 		"issues":{"type":"keyword"},
-		"files":{"type":"keyword"}
+		"files":{"type":"keyword"},
+		"resolver_version":{"type":"integer"},
+		"checks":` + repocheck.IssueMapping + `,
+		"components":` + component.ComponentMapping + `,
+		"config":` + ScanConfigMapping + `
 	}
 }`
 
@@ -53,6 +62,48 @@ type DependencyScan struct {
 	Issues    []string       `json:"issues"`
 	Files     []string       `json:"files"`
 	Timestamp time.Time      `json:"timestamp"`
+	// ResolverVersion is the version of the resolving that produced the scan.
+	ResolverVersion int `json:"resolver_version"`
+	// Checks are the repository hygiene issues found, when the scan ran them.
+	Checks []repocheck.Issue `json:"checks,omitempty"`
+	// Components break the dependencies down by the part of the repository
+	// their manifests are in.
+	Components []component.Component `json:"components,omitempty"`
+	// Config is the scan configuration committed in the repository, when it has one.
+	Config *ScanConfig `json:"config,omitempty"`
 }
 
 type DependencyScans map[string]DependencyScan
+
+// ComponentsOrRoot returns the components of the scan, or for scans made
+// before components were found, one root component of every dependency.
+func (s *DependencyScan) ComponentsOrRoot() []component.Component {
+	if s.Components != nil {
+		return s.Components
+	}
+	return []component.Component{{Name: component.Root, Files: s.Files, Deps: s.Deps}}
+}
+
+// DiffScans lists how the dependencies changed between two scans, either of
+// which may be nil. When the scans have components the changes are made within
+// each component.
+func DiffScans(old, new *DependencyScan) []d.Change {
+	if (old == nil || old.Components != nil) && (new == nil || new.Components != nil) {
+		var oldComps, newComps []component.Component
+		if old != nil {
+			oldComps = old.Components
+		}
+		if new != nil {
+			newComps = new.Components
+		}
+		return component.Diff(oldComps, newComps)
+	}
+	var oldDeps, newDeps []d.Dependency
+	if old != nil {
+		oldDeps = old.Deps
+	}
+	if new != nil {
+		newDeps = new.Deps
+	}
+	return d.Diff(oldDeps, newDeps)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package shas
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	i "github.com/venicegeo/vzutil-versioning/common/issue"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// Override pins a sha of a package to a version, for shas that are not tagged.
+type Override struct {
+	Name    string `json:"name"`
+	Sha     string `json:"sha"`
+	Version string `json:"version"`
+}
+
+const OverrideMapping = `{
+	"dynamic":"strict",
+	"properties":{
+		"name":{"type":"keyword"},
+		"sha":{"type":"keyword"},
+		"version":{"type":"keyword"}
+	}
+}`
+
+// TagSource returns the commit sha of every tag of the repository that a
+// package comes from, keyed by tag name.
+type TagSource func(ctx context.Context, name string) (map[string]string, error)
+
+// Resolver swaps versions that are commit shas for the tag or override that
+// names them. Tags are looked up once per package.
+type Resolver struct {
+	tags      TagSource
+	overrides []Override
+
+	mux   sync.Mutex
+	cache map[string]map[string]string
+}
+
+func NewResolver(tags TagSource, overrides []Override) *Resolver {
+	return &Resolver{tags: tags, overrides: overrides, cache: map[string]map[string]string{}}
+}
+
+func IsSha(version string) bool {
+	return shaPattern.MatchString(version)
+}
+
+// Swap rewrites the version of each Go dependency that is a sha, keeping the
+// sha on the dependency. A sha that no override or tag matches is left alone
+// and reported.
+func (r *Resolver) Swap(ctx context.Context, deps d.Dependencies) (d.Dependencies, i.Issues) {
+	res := make(d.Dependencies, len(deps), len(deps))
+	issues := i.Issues{}
+	for c, dep := range deps {
+		res[c] = dep
+		if dep.Language != lan.Go || !IsSha(dep.Version) {
+			continue
+		}
+		if version, ok := r.Lookup(ctx, dep.Name, dep.Version); ok {
+			res[c].Sha = dep.Version
+			res[c].Version = version
+		} else {
+			issues = append(issues, i.NewUnknownSha(dep.Name, dep.Version))
+		}
+	}
+	return res, issues
+}
+
+// Lookup finds the version of a package at a sha, preferring an override to a
+// tag, and the highest tag when several point at the sha.
+func (r *Resolver) Lookup(ctx context.Context, name, sha string) (string, bool) {
+	for _, o := range r.overrides {
+		if strings.EqualFold(o.Name, name) && o.Sha != "" && (strings.HasPrefix(o.Sha, sha) || strings.HasPrefix(sha, o.Sha)) {
+			return strings.ToLower(o.Version), true
+		}
+	}
+	if r.tags == nil {
+		return "", false
+	}
+	best := ""
+	for tag, tagSha := range r.tagsOf(ctx, name) {
+		if strings.HasPrefix(tagSha, sha) && (best == "" || d.CompareVersions(tag, best) > 0) {
+			best = tag
+		}
+	}
+	return strings.ToLower(best), best != ""
+}
+
+func (r *Resolver) tagsOf(ctx context.Context, name string) map[string]string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if tags, ok := r.cache[name]; ok {
+		return tags
+	}
+	tags, err := r.tags(ctx, name)
+	if err != nil {
+		tags = map[string]string{}
+	}
+	r.cache[name] = tags
+	return tags
+}
+
+// GithubRepo returns the org/repo of a Go package hosted on github.
+func GithubRepo(name string) (string, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", false
+	}
+	return parts[1] + "/" + parts[2], true
+}
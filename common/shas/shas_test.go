@@ -0,0 +1,85 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package shas
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	i "github.com/venicegeo/vzutil-versioning/common/issue"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+)
+
+const gocommonOld = "1111111111111111111111111111111111111111"
+const gocommonNew = "2222222222222222222222222222222222222222"
+const untagged = "3333333333333333333333333333333333333333"
+
+func testTags(ctx context.Context, name string) (map[string]string, error) {
+	if name != "github.com/venicegeo/pz-gocommon" {
+		return nil, errors.New("not mirrored")
+	}
+	return map[string]string{"v1.0.0": gocommonOld, "v1.1.0": gocommonNew, "1.1.0-rc1": gocommonNew}, nil
+}
+
+func TestSwap(t *testing.T) {
+	resolver := NewResolver(testTags, []Override{{"github.com/venicegeo/pz-logger", untagged[:8], "v0.9.0"}})
+	deps := d.Dependencies{
+		d.NewDependency("github.com/venicegeo/pz-gocommon", gocommonNew, lan.Go),
+		d.NewDependency("github.com/venicegeo/pz-gocommon", gocommonOld[:10], lan.Go),
+		d.NewDependency("github.com/venicegeo/pz-logger", untagged, lan.Go),
+		d.NewDependency("github.com/venicegeo/pz-workflow", untagged, lan.Go),
+		d.NewDependency("github.com/gin-gonic/gin", "^1.2.0", lan.Go),
+		d.NewDependency("deadbeef", "1234567", lan.JavaScript),
+	}
+	expected := d.Dependencies{
+		{Name: "github.com/venicegeo/pz-gocommon", Version: "v1.1.0", Language: lan.Go, Sha: gocommonNew},
+		{Name: "github.com/venicegeo/pz-gocommon", Version: "v1.0.0", Language: lan.Go, Sha: gocommonOld[:10]},
+		{Name: "github.com/venicegeo/pz-logger", Version: "v0.9.0", Language: lan.Go, Sha: untagged},
+		deps[3],
+		deps[4],
+		deps[5],
+	}
+	res, issues := resolver.Swap(context.Background(), deps)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("Swap returned\n%v\nexpected\n%v", res, expected)
+	}
+	if !reflect.DeepEqual(issues, i.Issues{i.NewUnknownSha("github.com/venicegeo/pz-workflow", untagged)}) {
+		t.Errorf("Unexpected issues %v", issues)
+	}
+	if deps[0].Version != gocommonNew {
+		t.Error("Swap modified its input")
+	}
+}
+
+func TestGithubRepo(t *testing.T) {
+	tests := []struct {
+		name, repo string
+		ok         bool
+	}{
+		{"github.com/venicegeo/pz-gocommon", "venicegeo/pz-gocommon", true},
+		{"github.com/venicegeo/pz-gocommon/gocommon", "venicegeo/pz-gocommon", true},
+		{"gopkg.in/yaml.v2", "", false},
+		{"github.com/venicegeo", "", false},
+	}
+	for _, test := range tests {
+		if repo, ok := GithubRepo(test.name); repo != test.repo || ok != test.ok {
+			t.Errorf("GithubRepo(%s) = %s %t", test.name, repo, ok)
+		}
+	}
+}
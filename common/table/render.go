@@ -0,0 +1,266 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"html"
+	"io"
+	"strings"
+)
+
+// Renderer draws a table in some format.
+type Renderer interface {
+	Render(w io.Writer, t *Table) error
+}
+
+var (
+	// ASCII draws boxes of pipes and dashes, sizing columns by rune width and
+	// applying truncation and wrapping.
+	ASCII Renderer = asciiRenderer{}
+	// Markdown draws a GitHub table. Tables without a header get an empty one.
+	Markdown Renderer = markdownRenderer{}
+	// HTML draws a <table> with thead, tbody and tfoot.
+	HTML Renderer = htmlRenderer{}
+	// CSV writes the header, rows and footer as records.
+	CSV Renderer = csvRenderer{}
+	// JSON writes an object of the header, rows and footer, each row an array
+	// in column order, so that blank or repeated headers lose nothing.
+	JSON Renderer = jsonRenderer{}
+)
+
+// Renderers are the renderers by name.
+var Renderers = map[string]Renderer{"ascii": ASCII, "markdown": Markdown, "html": HTML, "csv": CSV, "json": JSON}
+
+type asciiRenderer struct{}
+
+// lines splits a row into the lines of its cells, truncated or wrapped.
+func (t *Table) lines(row []string) [][]string {
+	cells := make([][]string, len(row))
+	height := 1
+	for c, cell := range row {
+		col := t.columns[c]
+		switch {
+		case col.maxWidth > 0 && col.wrap:
+			cells[c] = wrap(cell, col.maxWidth)
+		case col.maxWidth > 0:
+			cells[c] = []string{truncate(cell, col.maxWidth)}
+		default:
+			cells[c] = strings.Split(cell, "\n")
+		}
+		if len(cells[c]) > height {
+			height = len(cells[c])
+		}
+	}
+	res := make([][]string, height)
+	for i := range res {
+		res[i] = make([]string, len(row))
+		for c := range row {
+			if i < len(cells[c]) {
+				res[i][c] = cells[c][i]
+			}
+		}
+	}
+	return res
+}
+
+func (asciiRenderer) Render(w io.Writer, t *Table) error {
+	head, body, foot := t.Head(), t.Body(), t.Foot()
+	var headLines, footLines [][]string
+	bodyLines := make([][][]string, len(body))
+	widths := make([]int, len(t.columns))
+	measure := func(lines [][]string) {
+		for _, line := range lines {
+			for c, cell := range line {
+				if cw := Width(cell); cw > widths[c] {
+					widths[c] = cw
+				}
+			}
+		}
+	}
+	if head != nil {
+		headLines = t.lines(head)
+		measure(headLines)
+	}
+	for i, row := range body {
+		bodyLines[i] = t.lines(row)
+		measure(bodyLines[i])
+	}
+	if foot != nil {
+		footLines = t.lines(foot)
+		measure(footLines)
+	}
+
+	pipe := "|"
+	if !t.drawColumnBorder {
+		pipe = ""
+	}
+	format := func(line []string) string {
+		buf := bytes.NewBufferString(pipe)
+		for c, cell := range line {
+			cell = pad(cell, widths[c], t.columns[c].align)
+			if t.columns[c].spaced {
+				buf.WriteString(" " + cell + " " + pipe)
+			} else {
+				buf.WriteString(cell + pipe)
+			}
+		}
+		return buf.String()
+	}
+	lineWidth := Width(format(make([]string, len(t.columns))))
+	if lineWidth == 0 {
+		lineWidth = 10
+	}
+	top, sep := strings.Repeat("_", lineWidth), strings.Repeat("-", lineWidth)
+
+	buf := bytes.NewBufferString(top + "\n")
+	if head != nil {
+		for _, line := range headLines {
+			buf.WriteString(format(line) + "\n")
+		}
+		buf.WriteString(sep + "\n")
+	}
+	for _, lines := range bodyLines {
+		for _, line := range lines {
+			buf.WriteString(format(line) + "\n")
+		}
+		if t.drawRowBorder {
+			buf.WriteString(sep + "\n")
+		}
+	}
+	if foot != nil {
+		if !t.drawRowBorder || len(body) == 0 {
+			buf.WriteString(sep + "\n")
+		}
+		for _, line := range footLines {
+			buf.WriteString(format(line) + "\n")
+		}
+	}
+	buf.WriteString(top)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, t *Table) error {
+	cell := func(s string) string {
+		s = strings.Replace(s, "|", `\|`, -1)
+		return strings.Replace(s, "\n", "<br>", -1)
+	}
+	line := func(row []string) string {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = cell(c)
+		}
+		return "| " + strings.Join(cells, " | ") + " |\n"
+	}
+	head := t.Head()
+	if head == nil {
+		head = make([]string, len(t.columns))
+	}
+	buf := bytes.NewBufferString(line(head))
+	buf.WriteString("|")
+	for _, col := range t.columns {
+		switch col.align {
+		case Right:
+			buf.WriteString("---:|")
+		case Center:
+			buf.WriteString(":---:|")
+		default:
+			buf.WriteString("---|")
+		}
+	}
+	buf.WriteString("\n")
+	for _, row := range t.Body() {
+		buf.WriteString(line(row))
+	}
+	if foot := t.Foot(); foot != nil {
+		for i := range foot {
+			if foot[i] != "" {
+				foot[i] = "**" + foot[i] + "**"
+			}
+		}
+		buf.WriteString(line(foot))
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type htmlRenderer struct{}
+
+func (htmlRenderer) Render(w io.Writer, t *Table) error {
+	aligns := map[Alignment]string{Left: "", Right: ` style="text-align:right"`, Center: ` style="text-align:center"`}
+	row := func(buf *bytes.Buffer, cells []string, tag string) {
+		buf.WriteString("<tr>")
+		for c, cell := range cells {
+			buf.WriteString("<" + tag + aligns[t.columns[c].align] + ">")
+			buf.WriteString(strings.Replace(html.EscapeString(cell), "\n", "<br>", -1))
+			buf.WriteString("</" + tag + ">")
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf := bytes.NewBufferString("<table>\n")
+	if head := t.Head(); head != nil {
+		buf.WriteString("<thead>\n")
+		row(buf, head, "th")
+		buf.WriteString("</thead>\n")
+	}
+	buf.WriteString("<tbody>\n")
+	for _, r := range t.Body() {
+		row(buf, r, "td")
+	}
+	buf.WriteString("</tbody>\n")
+	if foot := t.Foot(); foot != nil {
+		buf.WriteString("<tfoot>\n")
+		row(buf, foot, "td")
+		buf.WriteString("</tfoot>\n")
+	}
+	buf.WriteString("</table>")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+type csvRenderer struct{}
+
+func (csvRenderer) Render(w io.Writer, t *Table) error {
+	writer := csv.NewWriter(w)
+	if head := t.Head(); head != nil {
+		writer.Write(head)
+	}
+	for _, row := range t.Body() {
+		writer.Write(row)
+	}
+	if foot := t.Foot(); foot != nil {
+		writer.Write(foot)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, t *Table) error {
+	res := struct {
+		Header []string   `json:"header,omitempty"`
+		Rows   [][]string `json:"rows"`
+		Footer []string   `json:"footer,omitempty"`
+	}{t.Head(), t.Body(), t.Foot()}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
@@ -16,93 +16,153 @@ package table
 
 import (
 	"bytes"
-	"fmt"
-	"strings"
+	"io"
 )
 
+type Alignment int
+
+const (
+	Left Alignment = iota
+	Right
+	Center
+)
+
+type column struct {
+	spaced   bool
+	align    Alignment
+	maxWidth int
+	wrap     bool
+}
+
+// Table is rows of cells with an optional header and footer. It grows as rows
+// are added and is drawn by a Renderer, as ASCII boxes by String.
 type Table struct {
-	table            [][]string
-	spaceColum       []bool
-	nextRow          int
-	nextColumn       int
+	header  []string
+	rows    [][]string
+	footer  []string
+	columns []column
+	// fillWidth is the row length of Fill, zero for tables made by New.
+	fillWidth        int
 	drawRowBorder    bool
 	drawColumnBorder bool
 	hasHeading       bool
-	fillCount        int
 }
 
+// New makes a table with the header, which may be empty.
+func New(header ...string) *Table {
+	t := &Table{drawRowBorder: true, drawColumnBorder: true}
+	t.Header(header...)
+	return t
+}
+
+// NewTable makes a table that Fill fills width cells to a row. The height is
+// only the rows to make room for, more are added as they are filled.
 func NewTable(width, height int) *Table {
-	table := &Table{[][]string{}, []bool{}, 0, 0, true, true, false, 0}
-	for i := 0; i < height; i++ {
-		temp := make([]string, width)
-		for j := 0; j < width; j++ {
-			temp[j] = ""
-		}
-		table.table = append(table.table, temp)
-	}
-	for i := 0; i < width; i++ {
-		table.spaceColum = append(table.spaceColum, false)
+	t := New()
+	t.fillWidth = width
+	t.rows = make([][]string, 0, height)
+	t.grow(width)
+	return t
+}
+
+func (t *Table) grow(width int) {
+	for len(t.columns) < width {
+		t.columns = append(t.columns, column{})
 	}
-	return table
 }
 
+func (t *Table) Header(cells ...string) *Table {
+	t.header = cells
+	t.grow(len(cells))
+	return t
+}
+
+func (t *Table) Footer(cells ...string) *Table {
+	t.footer = cells
+	t.grow(len(cells))
+	return t
+}
+
+// Row adds a row, widening the table if it has more cells than columns.
+func (t *Table) Row(cells ...string) *Table {
+	t.rows = append(t.rows, cells)
+	t.grow(len(cells))
+	return t
+}
+
+// HasHeading makes the first filled row the header.
 func (t *Table) HasHeading() *Table {
 	t.hasHeading = true
 	return t
 }
+
+// Fill fills cells left to right, starting a new row every width cells.
+func (t *Table) Fill(toFill ...string) *Table {
+	width := t.fillWidth
+	if width == 0 {
+		width = len(t.columns)
+	}
+	for _, f := range toFill {
+		if len(t.rows) == 0 || len(t.rows[len(t.rows)-1]) >= width {
+			t.rows = append(t.rows, make([]string, 0, width))
+		}
+		last := len(t.rows) - 1
+		t.rows[last] = append(t.rows[last], f)
+	}
+	t.grow(width)
+	return t
+}
+
 func (t *Table) SpaceColumn(i int) *Table {
-	t.spaceColum[i] = true
+	t.grow(i + 1)
+	t.columns[i].spaced = true
 	return t
 }
 func (t *Table) UnspaceColumn(i int) *Table {
-	t.spaceColum[i] = false
+	t.grow(i + 1)
+	t.columns[i].spaced = false
 	return t
 }
 func (t *Table) SpaceAllColumns() *Table {
-	for i := 0; i < len(t.spaceColum); i++ {
-		t.spaceColum[i] = true
+	for i := range t.columns {
+		t.columns[i].spaced = true
 	}
 	return t
 }
 func (t *Table) UnspaceAllColumns() *Table {
-	for i := 0; i < len(t.spaceColum); i++ {
-		t.spaceColum[i] = false
+	for i := range t.columns {
+		t.columns[i].spaced = false
 	}
 	return t
 }
-func (t *Table) Fill(toFill ...string) *Table {
-	for _, f := range toFill {
-		t.fill(f)
-	}
+
+func (t *Table) Align(i int, align Alignment) *Table {
+	t.grow(i + 1)
+	t.columns[i].align = align
 	return t
 }
-func (t *Table) fill(toFill string) {
-	t.table[t.nextRow][t.nextColumn] = toFill
-	if t.nextColumn == len(t.table[0])-1 {
-		t.nextRow++
-		t.nextColumn = 0
-	} else {
-		t.nextColumn++
-	}
-	t.fillCount++
+
+// Truncate cuts the cells of a column longer than the width, ending them in an
+// ellipsis. The width is in terminal columns.
+func (t *Table) Truncate(i, width int) *Table {
+	t.grow(i + 1)
+	t.columns[i].maxWidth, t.columns[i].wrap = width, false
+	return t
 }
+
+// Wrap breaks the cells of a column longer than the width onto more lines, at
+// spaces where it can.
+func (t *Table) Wrap(i, width int) *Table {
+	t.grow(i + 1)
+	t.columns[i].maxWidth, t.columns[i].wrap = width, true
+	return t
+}
+
+// Format is kept for older callers, cells are padded when they are drawn.
 func (t *Table) Format() *Table {
-	if len(t.table) == 0 {
-		return t
-	}
-	for c := 0; c < len(t.table[0]); c++ {
-		max := 0
-		for r := 0; r < len(t.table); r++ {
-			max = t.max(max, len(t.table[r][c]))
-		}
-		for r := 0; r < len(t.table); r++ {
-			for len(t.table[r][c]) < max {
-				t.table[r][c] += " "
-			}
-		}
-	}
 	return t
 }
+
 func (t *Table) DrawRowBorders() *Table {
 	t.drawRowBorder = true
 	return t
@@ -119,55 +179,60 @@ func (t *Table) NoColumnBorders() *Table {
 	t.drawColumnBorder = false
 	return t
 }
-func (t *Table) String() string {
-	res := bytes.NewBufferString("")
-	pipe := "|"
-	if !t.drawColumnBorder {
-		pipe = ""
-	}
-	for r := 0; r < len(t.table); r++ {
-		line := bytes.NewBufferString("")
-		for c := 0; c < len(t.table[r]); c++ {
-			if t.spaceColum[c] {
-				line.WriteString(fmt.Sprintf(" %s %s", t.table[r][c], pipe))
-			} else {
-				line.WriteString(fmt.Sprintf("%s%s", t.table[r][c], pipe))
-			}
-		}
-		res.WriteString(fmt.Sprintf("%s%s\n", pipe, line.String()))
-		if t.drawRowBorder {
-			for i := 0; i < line.Len()+1; i++ {
-				res.WriteString("-")
-			}
-			res.WriteString("\n")
-		}
+
+// Columns is the number of columns.
+func (t *Table) Columns() int {
+	return len(t.columns)
+}
+
+// Head returns the header, or nil when there is none.
+func (t *Table) Head() []string {
+	if t.header == nil && t.hasHeading && len(t.rows) > 0 {
+		return t.padded(t.rows[0])
 	}
-	temp := bytes.NewBufferString("")
-	tmpS := len(strings.SplitN(res.String(), "\n", 2)[0])
-	if tmpS == 0 {
-		tmpS = 10
+	if len(t.header) == 0 {
+		return nil
 	}
-	for i := 0; i < tmpS; i++ {
-		temp.WriteString("_")
+	return t.padded(t.header)
+}
+
+// Body returns the rows, each padded to the number of columns.
+func (t *Table) Body() [][]string {
+	rows := t.rows
+	if t.header == nil && t.hasHeading && len(rows) > 0 {
+		rows = rows[1:]
 	}
-	tempS := temp.String()
-	temp.WriteString("\n")
-	if t.hasHeading {
-		parts := strings.SplitN(res.String(), "\n", 2)
-		temp.WriteString(parts[0])
-		temp.WriteString("\n")
-		temp.WriteString(strings.Replace(tempS, "_", "-", -1))
-		temp.WriteString("\n")
-		temp.WriteString(parts[1])
-	} else {
-		temp.WriteString(res.String())
+	res := make([][]string, len(rows))
+	for i, row := range rows {
+		res[i] = t.padded(row)
 	}
-	temp.WriteString(tempS)
-	return temp.String()
+	return res
 }
-func (t *Table) max(a, b int) int {
-	if a > b {
-		return a
+
+// Foot returns the footer, or nil when there is none.
+func (t *Table) Foot() []string {
+	if len(t.footer) == 0 {
+		return nil
 	}
-	return b
+	return t.padded(t.footer)
+}
+
+func (t *Table) padded(row []string) []string {
+	res := make([]string, len(t.columns))
+	copy(res, row)
+	return res
+}
+
+func (t *Table) Render(w io.Writer, r Renderer) error {
+	return r.Render(w, t)
+}
+
+func (t *Table) RenderString(r Renderer) string {
+	buf := bytes.NewBufferString("")
+	r.Render(buf, t)
+	return buf.String()
+}
+
+func (t *Table) String() string {
+	return t.RenderString(ASCII)
 }
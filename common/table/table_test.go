@@ -15,7 +15,10 @@
 package table
 
 import (
+	"encoding/json"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +31,99 @@ func TestTable(t *testing.T) {
 	table.Fill("a", "b", "c", "d")
 	log.Println("\n" + table.Format().String())
 }
+
+func TestFillGrows(t *testing.T) {
+	table := NewTable(2, 1).NoRowBorders()
+	table.Fill("a", "b", "c")
+	expected := "_____\n|a|b|\n|c| |\n_____"
+	if res := table.String(); res != expected {
+		t.Fatalf("Wrong table\n%s\nexpected\n%s", res, expected)
+	}
+}
+
+func TestASCII(t *testing.T) {
+	table := New("Name", "Version", "Notes").NoRowBorders().SpaceAllColumns().Align(1, Right).Wrap(2, 10).Truncate(0, 6)
+	table.Row("日本語", "1.0", "short")
+	table.Row("gt-main-long", "19.0", "wraps onto more lines")
+	table.Footer("total", "2")
+	expected := strings.Join([]string{
+		"_________________________________",
+		"| Name   | Version | Notes      |",
+		"---------------------------------",
+		"| 日本語 |     1.0 | short      |",
+		"| gt-ma… |    19.0 | wraps onto |",
+		"|        |         | more lines |",
+		"---------------------------------",
+		"| total  |       2 |            |",
+		"_________________________________",
+	}, "\n")
+	if res := table.String(); res != expected {
+		t.Fatalf("Wrong table\n%s\nexpected\n%s", res, expected)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	tests := []struct {
+		str   string
+		width int
+	}{
+		{"abc", 3},
+		{"日本", 4},
+		{"é", 1},
+		{"ｆｕｌｌ", 8},
+		{"", 0},
+	}
+	for _, test := range tests {
+		if w := Width(test.str); w != test.width {
+			t.Errorf("Width(%q) = %d, expected %d", test.str, w, test.width)
+		}
+	}
+}
+
+func TestWrapNarrowerThanRune(t *testing.T) {
+	if lines := wrap("日本 abc", 1); !reflect.DeepEqual(lines, []string{"日", "本", "a", "b", "c"}) {
+		t.Errorf("Wrong lines %q", lines)
+	}
+	table := New("Name").Wrap(0, 1)
+	table.Row("日本")
+	if str := table.String(); !strings.Contains(str, "|日|\n|本|") {
+		t.Errorf("Wrong table\n%s", str)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	table := New("Name", "Version").Align(1, Center)
+	table.Row("junit", "4.12")
+	table.Row("a|b<c>", "1")
+	tests := []struct {
+		renderer string
+		expected string
+	}{
+		{"markdown", "| Name | Version |\n|---|:---:|\n| junit | 4.12 |\n| a\\|b<c> | 1 |\n"},
+		{"html", "<table>\n<thead>\n<tr><th>Name</th><th style=\"text-align:center\">Version</th></tr>\n</thead>\n<tbody>\n" +
+			"<tr><td>junit</td><td style=\"text-align:center\">4.12</td></tr>\n<tr><td>a|b&lt;c&gt;</td><td style=\"text-align:center\">1</td></tr>\n</tbody>\n</table>"},
+		{"csv", "Name,Version\njunit,4.12\na|b<c>,1\n"},
+		{"json", "{\n  \"header\": [\n    \"Name\",\n    \"Version\"\n  ],\n  \"rows\": [\n    [\n      \"junit\",\n      \"4.12\"\n    ],\n" +
+			"    [\n      \"a|b\\u003cc\\u003e\",\n      \"1\"\n    ]\n  ]\n}\n"},
+	}
+	for _, test := range tests {
+		if res := table.RenderString(Renderers[test.renderer]); res != test.expected {
+			t.Errorf("Wrong %s\n%s\nexpected\n%s", test.renderer, res, test.expected)
+		}
+	}
+	if res := New().Row("x", "y").RenderString(JSON); res != "{\n  \"rows\": [\n    [\n      \"x\",\n      \"y\"\n    ]\n  ]\n}\n" {
+		t.Errorf("Wrong json without a header\n%s", res)
+	}
+	var res struct {
+		Header []string
+		Rows   [][]string
+		Footer []string
+	}
+	dup := New().Header("Name", "Name", "").Row("a", "b", "c").Footer("1", "2", "3").RenderString(JSON)
+	if err := json.Unmarshal([]byte(dup), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Header, []string{"Name", "Name", ""}) || !reflect.DeepEqual(res.Rows, [][]string{{"a", "b", "c"}}) || !reflect.DeepEqual(res.Footer, []string{"1", "2", "3"}) {
+		t.Errorf("Repeated and blank headers lost cells\n%s", dup)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wide are the ranges of East Asian wide and fullwidth runes, and emoji, that
+// take two columns of a terminal.
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1},
+		{0x2e80, 0x303e, 1},
+		{0x3041, 0x33ff, 1},
+		{0x3400, 0x4dbf, 1},
+		{0x4e00, 0x9fff, 1},
+		{0xa000, 0xa4cf, 1},
+		{0xac00, 0xd7a3, 1},
+		{0xf900, 0xfaff, 1},
+		{0xfe30, 0xfe4f, 1},
+		{0xff00, 0xff60, 1},
+		{0xffe0, 0xffe6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f300, 0x1f64f, 1},
+		{0x1f900, 0x1f9ff, 1},
+		{0x20000, 0x2fffd, 1},
+		{0x30000, 0x3fffd, 1},
+	},
+}
+
+// RuneWidth is the number of terminal columns a rune takes, zero for combining
+// marks and formatting characters.
+func RuneWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc):
+		return 0
+	case unicode.Is(wide, r):
+		return 2
+	}
+	return 1
+}
+
+// Width is the number of terminal columns a string takes.
+func Width(s string) int {
+	w := 0
+	for _, r := range s {
+		w += RuneWidth(r)
+	}
+	return w
+}
+
+const ellipsis = "…"
+
+// truncate shortens the string to the width, ending it in an ellipsis.
+func truncate(s string, width int) string {
+	if width <= 0 || Width(s) <= width {
+		return s
+	}
+	w := 0
+	for i, r := range s {
+		if w+RuneWidth(r) > width-1 {
+			return s[:i] + ellipsis
+		}
+		w += RuneWidth(r)
+	}
+	return s
+}
+
+// wrap breaks the string into lines no wider than the width, at spaces where a
+// word fits and inside the word where it does not.
+func wrap(s string, width int) []string {
+	if width <= 0 || Width(s) <= width {
+		return []string{s}
+	}
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && Width(line)+1+Width(word) <= width {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+		for Width(word) > width && utf8.RuneCountInString(word) > 1 {
+			w := 0
+			for i, r := range word {
+				// at least one rune a line, even when it is wider than the column
+				if w+RuneWidth(r) > width && i > 0 {
+					lines = append(lines, word[:i])
+					word = word[i:]
+					break
+				}
+				w += RuneWidth(r)
+			}
+		}
+		line = word
+	}
+	return append(lines, line)
+}
+
+// pad fills the string with spaces to the width, placing it by the alignment.
+func pad(s string, width int, align Alignment) string {
+	n := width - Width(s)
+	if n <= 0 {
+		return s
+	}
+	switch align {
+	case Right:
+		return strings.Repeat(" ", n) + s
+	case Center:
+		return strings.Repeat(" ", n/2) + s + strings.Repeat(" ", n-n/2)
+	}
+	return s + strings.Repeat(" ", n)
+}
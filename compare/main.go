@@ -16,22 +16,21 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"regexp"
-	"sort"
+	"os"
 	"strings"
 
 	com "github.com/venicegeo/vzutil-versioning/common"
-	"github.com/venicegeo/vzutil-versioning/common/table"
+	"github.com/venicegeo/vzutil-versioning/common/match"
 	c "github.com/venicegeo/vzutil-versioning/compare/pub"
 )
 
-var re = regexp.MustCompile(``)
-
 func readFile(filename string) (com.DependencyScans, error) {
 	var fileDat []byte
 	var err error
@@ -43,22 +42,35 @@ func readFile(filename string) (com.DependencyScans, error) {
 	return fileDeps, err
 }
 
-func NewCompareStruct(actualName, expectedName string) *c.CompareStruct {
-	return &c.CompareStruct{actualName, expectedName, []string{}, []string{}, []string{}, []string{}, []string{}}
-}
-
 func main() {
 	var file1, file2, outFile, string1, string2, format string
+	var projectSim, depSim, failOnStr string
+	opts := c.DefaultOptions()
 	flag.StringVar(&file1, "a", "", "Actual File")
 	flag.StringVar(&file2, "e", "", "Expected File")
 	flag.StringVar(&outFile, "o", "", "Output File")
 	flag.StringVar(&string1, "as", "", "Actual String")
 	flag.StringVar(&string2, "es", "", "Expected String")
-	flag.StringVar(&format, "f", "", "Format, only option is json")
+	flag.StringVar(&format, "f", "", "Format: "+strings.Join(c.Formats, ", ")+", or empty for text tables")
+	flag.StringVar(&failOnStr, "fail-on", "", "Exit with 1 when dependencies are missing, extra or any")
+	flag.StringVar(&projectSim, "ps", "levenshtein", "Similarity of project names: levenshtein, jarowinkler, tokens or purl")
+	flag.Float64Var(&opts.Projects.Threshold, "pt", opts.Projects.Threshold, "Least similarity of paired projects")
+	flag.StringVar(&depSim, "ds", "purl", "Similarity of missing and extra dependencies")
+	flag.Float64Var(&opts.Dependencies.Threshold, "dt", opts.Dependencies.Threshold, "Least similarity of lined up dependencies")
 	flag.Parse()
 
+	failOn, err := c.ParseFailOn(failOnStr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if opts.Projects.Similarity, err = match.SimilarityByName(projectSim); err != nil {
+		log.Fatalln(err)
+	}
+	if opts.Dependencies.Similarity, err = match.SimilarityByName(depSim); err != nil {
+		log.Fatalln(err)
+	}
+
 	var expected, actual com.DependencyScans
-	var err error
 
 	if file1 == "" && string1 == "" {
 		log.Fatalln("Either the actual file or string must be provided.")
@@ -90,143 +102,22 @@ func main() {
 		}
 	}
 
-	compares := []*c.CompareStruct{}
-	for projectName, project := range actual {
-		var maxSim float64 = 0.0
-		var temp float64 = 0.0
-		var maxKey string = ""
-		for k2, _ := range expected {
-			temp = similarity(projectName, k2)
-			if temp >= 0.5 && maxSim < temp {
-				maxSim = temp
-				maxKey = k2
-			}
-		}
-		str := NewCompareStruct(projectName, maxKey)
-		for _, s := range project.Deps {
-			str.ActualDeps = append(str.ActualDeps, s.FullString())
-		}
-		if str.ExpectedName != "" {
-			if proj, ok := expected[str.ExpectedName]; ok {
-				for _, s := range proj.Deps {
-					str.ExpectedDeps = append(str.ExpectedDeps, s.FullString())
-				}
-			}
-			delete(expected, str.ExpectedName)
-		}
-		compares = append(compares, str)
-	}
-	for projectName, project := range expected {
-		str := NewCompareStruct("", projectName)
-		for _, s := range project.Deps {
-			str.ExpectedDeps = append(str.ExpectedDeps, s.FullString())
-		}
-		compares = append(compares, str)
-	}
-
-	for _, comp := range compares {
-		actual := map[string]bool{}
-		expected := map[string]bool{}
-		for _, s := range comp.ActualDeps {
-			actual[s] = false
-		}
-		for _, s := range comp.ExpectedDeps {
-			expected[s] = false
-		}
-		comp.ActualDeps = []string{}
-		comp.ExpectedDeps = []string{}
-		for k, _ := range actual {
-			comp.ActualDeps = append(comp.ActualDeps, k)
-		}
-		for k, _ := range expected {
-			comp.ExpectedDeps = append(comp.ExpectedDeps, k)
-		}
-	}
-
-	done := make(chan bool, len(compares))
-	searchList := func(a, b, found, notfound *[]string) {
-		var f = false
-		for _, dep := range *a {
-			f = false
-			for _, exp := range *b {
-				if dep == exp {
-					f = true
-					if found != nil {
-						*found = append(*found, dep)
-					}
-					break
-				}
-			}
-			if !f {
-				*notfound = append(*notfound, dep)
-			}
-		}
-	}
-	findDifs := func(cmp *c.CompareStruct) {
-		searchList(&cmp.ActualDeps, &cmp.ExpectedDeps, &cmp.Agreed, &cmp.ExpectedMissing)
-		searchList(&cmp.ExpectedDeps, &cmp.ActualDeps, nil, &cmp.ExpectedExtra)
-		cmp.ExpectedMissing, cmp.ExpectedExtra = similaritySort(cmp.ExpectedMissing, cmp.ExpectedExtra)
-		sort.Strings(cmp.Agreed)
-		done <- true
-	}
-	for _, cmp := range compares {
-		go findDifs(cmp)
-	}
-	for i := 0; i < len(compares); i++ {
-		<-done
+	compares, err := c.CompareWith(context.Background(), actual, expected, opts)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	output := ""
-	if format == "json" {
-		dat, _ := json.MarshalIndent(compares, " ", "   ")
-		output = string(dat)
-	} else {
-		for _, cmp := range compares {
-			m := max(len(cmp.Agreed), len(cmp.ExpectedMissing), len(cmp.ExpectedExtra))
-			if m == 0 {
-				continue
-			}
-			t := table.NewTable(3, m+1)
-			agreed := ""
-			missing := ""
-			extra := ""
-			output += fmt.Sprintf("Comparing actual in [%s] to list [%s]\n", cmp.ActualName, cmp.ExpectedName)
-			t.Fill("Agreed", "Missing in List", "Extra in List")
-			for i := 0; i < m; i++ {
-				agreed = ""
-				missing = ""
-				extra = ""
-				if len(cmp.Agreed) > i {
-					agreed = cmp.Agreed[i]
-				}
-				if len(cmp.ExpectedMissing) > i {
-					missing = cmp.ExpectedMissing[i]
-				}
-				if len(cmp.ExpectedExtra) > i {
-					extra = cmp.ExpectedExtra[i]
-				}
-				t.Fill(agreed, missing, extra)
-			}
-			output += t.SpaceAllColumns().NoRowBorders().Format().String()
-			output += "\n\n\n\n"
-		}
+	buf := bytes.NewBuffer([]byte{})
+	if err = c.Write(buf, format, compares); err != nil {
+		log.Fatalln(err)
 	}
 	if outFile == "" {
-		fmt.Println(output)
-	} else {
-		ioutil.WriteFile(outFile, []byte(output), 0644)
-	}
-}
-
-func max(a ...int) int {
-	if len(a) == 0 {
-		return 0
+		fmt.Println(buf.String())
+	} else if err = ioutil.WriteFile(outFile, buf.Bytes(), 0644); err != nil {
+		log.Fatalln(err)
 	}
-	max := a[0]
-	for _, b := range a {
-		if b > max {
-			max = b
-		}
+	if failures := c.Failures(compares, failOn); failures > 0 {
+		fmt.Fprintf(os.Stderr, "%d dependencies fail on %s\n", failures, failOn)
+		os.Exit(1)
 	}
-	return max
 }
@@ -0,0 +1,179 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compare
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	com "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/match"
+	"github.com/venicegeo/vzutil-versioning/common/table"
+)
+
+func NewCompareStruct(actualName, expectedName string) *CompareStruct {
+	return &CompareStruct{actualName, expectedName, []string{}, []string{}, []string{}, []string{}, []string{}}
+}
+
+// Options are how Compare pairs projects by name and lines up the missing and
+// extra dependencies of each pair.
+type Options struct {
+	Projects     match.Matcher
+	Dependencies match.Matcher
+}
+
+// DefaultOptions pair projects by Levenshtein similarity and dependencies as
+// package coordinates, both at 0.5 or more.
+func DefaultOptions() Options {
+	return Options{match.NewMatcher(match.Levenshtein, 0.5), match.NewMatcher(match.Purl, 0.5)}
+}
+
+// Compare compares with the default options.
+func Compare(ctx context.Context, actual, expected com.DependencyScans) ([]*CompareStruct, error) {
+	return CompareWith(ctx, actual, expected, DefaultOptions())
+}
+
+// CompareWith pairs the actual scans with the expected scans so their names are
+// as similar as they can be overall, and sorts the dependencies of each pair
+// into agreed, missing and extra.
+func CompareWith(ctx context.Context, actual, expected com.DependencyScans, opts Options) ([]*CompareStruct, error) {
+	sortedNames := func(scans com.DependencyScans) []string {
+		names := make([]string, 0, len(scans))
+		for k := range scans {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return names
+	}
+	actualNames, expectedNames := sortedNames(actual), sortedNames(expected)
+
+	compares := []*CompareStruct{}
+	for _, pair := range opts.Projects.Match(actualNames, expectedNames) {
+		str := NewCompareStruct("", "")
+		if pair.A >= 0 {
+			str.ActualName = actualNames[pair.A]
+			for _, s := range actual[str.ActualName].Deps {
+				str.ActualDeps = append(str.ActualDeps, s.FullString())
+			}
+		}
+		if pair.B >= 0 {
+			str.ExpectedName = expectedNames[pair.B]
+			for _, s := range expected[str.ExpectedName].Deps {
+				str.ExpectedDeps = append(str.ExpectedDeps, s.FullString())
+			}
+		}
+		compares = append(compares, str)
+	}
+	sort.SliceStable(compares, func(i, j int) bool {
+		if (compares[i].ActualName == "") != (compares[j].ActualName == "") {
+			return compares[j].ActualName == ""
+		}
+		return compares[i].ActualName < compares[j].ActualName
+	})
+
+	for _, cmp := range compares {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cmp.ActualDeps = unique(cmp.ActualDeps)
+		cmp.ExpectedDeps = unique(cmp.ExpectedDeps)
+		searchList(cmp.ActualDeps, cmp.ExpectedDeps, &cmp.Agreed, &cmp.ExpectedMissing)
+		searchList(cmp.ExpectedDeps, cmp.ActualDeps, nil, &cmp.ExpectedExtra)
+		cmp.ExpectedMissing, cmp.ExpectedExtra = opts.Dependencies.Align(cmp.ExpectedMissing, cmp.ExpectedExtra)
+		sort.Strings(cmp.Agreed)
+	}
+	return compares, nil
+}
+
+func unique(list []string) []string {
+	set := map[string]bool{}
+	res := []string{}
+	for _, s := range list {
+		if !set[s] {
+			set[s] = true
+			res = append(res, s)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func searchList(a, b []string, found, notfound *[]string) {
+	for _, dep := range a {
+		f := false
+		for _, exp := range b {
+			if dep == exp {
+				f = true
+				if found != nil {
+					*found = append(*found, dep)
+				}
+				break
+			}
+		}
+		if !f {
+			*notfound = append(*notfound, dep)
+		}
+	}
+}
+
+// Report renders the comparisons as text tables, skipping any with nothing to show.
+func Report(compares []*CompareStruct) string {
+	output := ""
+	for _, cmp := range compares {
+		m := max(len(cmp.Agreed), len(cmp.ExpectedMissing), len(cmp.ExpectedExtra))
+		if m == 0 {
+			continue
+		}
+		t := table.NewTable(3, m+1)
+		agreed := ""
+		missing := ""
+		extra := ""
+		output += fmt.Sprintf("Comparing actual in [%s] to list [%s]\n", cmp.ActualName, cmp.ExpectedName)
+		t.Fill("Agreed", "Missing in List", "Extra in List")
+		for i := 0; i < m; i++ {
+			agreed = ""
+			missing = ""
+			extra = ""
+			if len(cmp.Agreed) > i {
+				agreed = cmp.Agreed[i]
+			}
+			if len(cmp.ExpectedMissing) > i {
+				missing = cmp.ExpectedMissing[i]
+			}
+			if len(cmp.ExpectedExtra) > i {
+				extra = cmp.ExpectedExtra[i]
+			}
+			t.Fill(agreed, missing, extra)
+		}
+		output += t.SpaceAllColumns().NoRowBorders().Format().String()
+		output += "\n\n\n\n"
+	}
+	return output
+}
+
+func max(a ...int) int {
+	if len(a) == 0 {
+		return 0
+	}
+	max := a[0]
+	for _, b := range a {
+		if b > max {
+			max = b
+		}
+	}
+	return max
+}
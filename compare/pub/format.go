@@ -0,0 +1,305 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Formats are the output formats of Write. An empty format is the text of Report.
+var Formats = []string{"json", "markdown", "html", "junit", "sarif"}
+
+// Write writes the comparisons in one of Formats.
+func Write(w io.Writer, format string, compares []*CompareStruct) error {
+	switch format {
+	case "":
+		_, err := io.WriteString(w, Report(compares))
+		return err
+	case "json":
+		dat, err := json.MarshalIndent(compares, " ", "   ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(dat)
+		return err
+	case "markdown":
+		return WriteMarkdown(w, compares)
+	case "html":
+		return WriteHTML(w, compares)
+	case "junit":
+		return WriteJUnit(w, compares)
+	case "sarif":
+		return WriteSARIF(w, compares)
+	}
+	return fmt.Errorf("Unknown format [%s], expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// FailOn is which differences make a comparison fail.
+type FailOn string
+
+const FailNever FailOn = ""
+const FailMissing FailOn = "missing"
+const FailExtra FailOn = "extra"
+const FailAny FailOn = "any"
+
+func ParseFailOn(str string) (FailOn, error) {
+	switch f := FailOn(str); f {
+	case FailNever, FailMissing, FailExtra, FailAny:
+		return f, nil
+	}
+	return FailNever, fmt.Errorf("Unknown fail mode [%s], expected missing, extra or any", str)
+}
+
+// Failures counts the dependencies that fail the comparisons.
+func Failures(compares []*CompareStruct, failOn FailOn) int {
+	count := 0
+	for _, cmp := range compares {
+		if failOn == FailMissing || failOn == FailAny {
+			count += len(nonBlank(cmp.ExpectedMissing))
+		}
+		if failOn == FailExtra || failOn == FailAny {
+			count += len(nonBlank(cmp.ExpectedExtra))
+		}
+	}
+	return count
+}
+
+// nonBlank leaves out the padding that lines up missing and extra dependencies.
+func nonBlank(list []string) []string {
+	res := []string{}
+	for _, s := range list {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func (c *CompareStruct) title() string {
+	name := func(n string) string {
+		if n == "" {
+			return "(none)"
+		}
+		return n
+	}
+	return fmt.Sprintf("%s to %s", name(c.ActualName), name(c.ExpectedName))
+}
+
+// WriteMarkdown writes a GitHub table per comparison, for pull request comments.
+func WriteMarkdown(w io.Writer, compares []*CompareStruct) error {
+	cell := func(str string) string {
+		if str == "" {
+			return ""
+		}
+		return "`" + strings.Replace(str, "|", `\|`, -1) + "`"
+	}
+	buf := bytes.NewBufferString("# Dependency comparison\n\n")
+	for _, cmp := range compares {
+		buf.WriteString(fmt.Sprintf("## %s\n\n", cmp.title()))
+		m := max(len(cmp.Agreed), len(cmp.ExpectedMissing), len(cmp.ExpectedExtra))
+		if m == 0 {
+			buf.WriteString("No dependencies.\n\n")
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%d agreed, %d missing in list, %d extra in list.\n\n",
+			len(cmp.Agreed), len(nonBlank(cmp.ExpectedMissing)), len(nonBlank(cmp.ExpectedExtra))))
+		buf.WriteString("| Agreed | Missing in List | Extra in List |\n|---|---|---|\n")
+		for i := 0; i < m; i++ {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s |\n", cell(at(cmp.Agreed, i)), cell(at(cmp.ExpectedMissing, i)), cell(at(cmp.ExpectedExtra, i))))
+		}
+		buf.WriteString("\n")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func at(list []string, i int) string {
+	if i < len(list) {
+		return list[i]
+	}
+	return ""
+}
+
+var htmlReport = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Dependency comparison</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; font-family: monospace; text-align: left; }
+td.missing { background: #fdd; }
+td.extra { background: #ffd; }
+</style>
+</head>
+<body>
+<h1>Dependency comparison</h1>
+{{ range . }}<h2>{{ .Title }}</h2>
+{{ if .Rows }}<table>
+<tr><th>Agreed</th><th>Missing in List</th><th>Extra in List</th></tr>
+{{ range .Rows }}<tr><td>{{ index . 0 }}</td><td class="missing">{{ index . 1 }}</td><td class="extra">{{ index . 2 }}</td></tr>
+{{ end }}</table>
+{{ else }}<p>No dependencies.</p>
+{{ end }}{{ end }}</body>
+</html>
+`))
+
+// WriteHTML writes a standalone page with a table per comparison.
+func WriteHTML(w io.Writer, compares []*CompareStruct) error {
+	type section struct {
+		Title string
+		Rows  [][3]string
+	}
+	sections := make([]section, len(compares))
+	for i, cmp := range compares {
+		sections[i].Title = cmp.title()
+		for j := 0; j < max(len(cmp.Agreed), len(cmp.ExpectedMissing), len(cmp.ExpectedExtra)); j++ {
+			sections[i].Rows = append(sections[i].Rows, [3]string{at(cmp.Agreed, j), at(cmp.ExpectedMissing, j), at(cmp.ExpectedExtra, j)})
+		}
+	}
+	return htmlReport.Execute(w, sections)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// WriteJUnit writes a test suite per comparison, with every agreed dependency
+// as a passing test case and every missing or extra one as a failing one.
+func WriteJUnit(w io.Writer, compares []*CompareStruct) error {
+	suites := junitSuites{Name: "compare"}
+	for _, cmp := range compares {
+		suite := junitSuite{Name: cmp.title()}
+		add := func(deps []string, failure string) {
+			for _, dep := range nonBlank(deps) {
+				c := junitCase{Name: dep, Classname: suite.Name}
+				if failure != "" {
+					c.Failure = &junitFailure{fmt.Sprintf("%s is %s", dep, failure), failure}
+					suite.Failures++
+				}
+				suite.Cases = append(suite.Cases, c)
+			}
+		}
+		add(cmp.Agreed, "")
+		add(cmp.ExpectedMissing, "missing in list")
+		add(cmp.ExpectedExtra, "extra in list")
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+// WriteSARIF writes every missing or extra dependency as a SARIF result, located
+// at the project it was found in.
+func WriteSARIF(w io.Writer, compares []*CompareStruct) error {
+	rules := []sarifRule{
+		{"missing-in-list", sarifMessage{"A dependency in use is missing from the expected list"}},
+		{"extra-in-list", sarifMessage{"A dependency on the expected list is not in use"}},
+	}
+	results := []sarifResult{}
+	for _, cmp := range compares {
+		add := func(deps []string, rule, project, text string) {
+			for _, dep := range nonBlank(deps) {
+				loc := sarifLocation{[]sarifLogicalLocation{{project, "module"}}}
+				results = append(results, sarifResult{rule, "error", sarifMessage{fmt.Sprintf("%s is %s comparing %s", dep, text, cmp.title())}, []sarifLocation{loc}})
+			}
+		}
+		add(cmp.ExpectedMissing, rules[0].Id, cmp.ActualName, "missing in list")
+		add(cmp.ExpectedExtra, rules[1].Id, cmp.ExpectedName, "extra in list")
+	}
+	log := map[string]interface{}{
+		"$schema": sarifSchema,
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":           "vzutil-versioning compare",
+				"informationUri": "https://github.com/venicegeo/vzutil-versioning",
+				"rules":          rules,
+			}},
+			"results": results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func testCompares() []*CompareStruct {
+	cmp := NewCompareStruct("pz-gateway", "gateway")
+	cmp.Agreed = []string{"junit:4.12:java"}
+	cmp.ExpectedMissing = []string{"spring:5.0.1:java", "guava|x:1:java"}
+	cmp.ExpectedExtra = []string{"spring:5.0.0:java", ""}
+	return []*CompareStruct{cmp, NewCompareStruct("", "pz-old")}
+}
+
+func TestFailures(t *testing.T) {
+	tests := []struct {
+		failOn string
+		count  int
+	}{
+		{"", 0},
+		{"missing", 2},
+		{"extra", 1},
+		{"any", 3},
+	}
+	for _, test := range tests {
+		failOn, err := ParseFailOn(test.failOn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := Failures(testCompares(), failOn); count != test.count {
+			t.Errorf("Failures on [%s] = %d, expected %d", test.failOn, count, test.count)
+		}
+	}
+	if _, err := ParseFailOn("some"); err == nil {
+		t.Error("Expected an unknown fail mode")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	write := func(format string) string {
+		buf := bytes.NewBuffer([]byte{})
+		if err := Write(buf, format, testCompares()); err != nil {
+			t.Fatal(format, err)
+		}
+		return buf.String()
+	}
+
+	md := write("markdown")
+	if !strings.Contains(md, "| `junit:4.12:java` | `spring:5.0.1:java` | `spring:5.0.0:java` |") ||
+		!strings.Contains(md, "|  | `guava\\|x:1:java` |  |") || !strings.Contains(md, "## (none) to pz-old\n\nNo dependencies.") {
+		t.Errorf("Wrong markdown\n%s", md)
+	}
+
+	page := write("html")
+	if !strings.Contains(page, `<td class="missing">guava|x:1:java</td>`) || !strings.Contains(page, "<h2>(none) to pz-old</h2>") {
+		t.Errorf("Wrong html\n%s", page)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal([]byte(write("junit")), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 4 || suites.Failures != 3 || len(suites.Suites) != 2 || suites.Suites[0].Cases[0].Failure != nil ||
+		suites.Suites[0].Cases[3].Failure.Type != "extra in list" {
+		t.Errorf("Wrong junit %+v", suites)
+	}
+
+	var sarif struct {
+		Version string
+		Runs    []struct {
+			Results []sarifResult
+		}
+	}
+	if err := json.Unmarshal([]byte(write("sarif")), &sarif); err != nil {
+		t.Fatal(err)
+	}
+	if sarif.Version != "2.1.0" || len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 3 ||
+		sarif.Runs[0].Results[2].RuleId != "extra-in-list" || sarif.Runs[0].Results[2].Locations[0].LogicalLocations[0].Name != "gateway" {
+		t.Errorf("Wrong sarif %+v", sarif)
+	}
+
+	if err := Write(bytes.NewBuffer([]byte{}), "pdf", nil); err == nil {
+		t.Error("Expected an unknown format")
+	}
+}
@@ -17,145 +17,198 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 
-	proj "github.com/venicegeo/vzutil-versioning/plural/project"
-	deps "github.com/venicegeo/vzutil-versioning/plural/project/dependency"
-	"github.com/venicegeo/vzutil-versioning/plural/project/reporting"
-	"github.com/venicegeo/vzutil-versioning/plural/project/states"
-	"github.com/venicegeo/vzutil-versioning/plural/project/util"
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	proj "github.com/venicegeo/vzutil-versioning/extended/project"
+	"github.com/venicegeo/vzutil-versioning/extended/project/reporting"
 )
 
-var projects *proj.Projects
-var extendedProjects *proj.Projects
-var targetFolder string
-var projectName string
+// running holds the target folders of the configs being run, for the interrupt handler.
+var running = struct {
+	sync.Mutex
+	folders map[string]bool
+}{folders: map[string]bool{}}
 
 func main() {
 	runInterruptHandler()
 
 	async := flag.Bool("async", false, "Set async mode")
+	parallel := flag.Bool("parallel", false, "Run the configs at the same time")
 	cat := flag.Bool("cat", false, "Print all the things")
 	makeAbout := flag.Bool("about", false, "Create example about yml")
 	cloneLists := flag.Bool("cloneLists", true, "Clone lists specified in config")
 	flag.Parse()
-	state.CloneLists = *cloneLists
-	state.Async = *async
-	run(*makeAbout, *cat)
+	run(proj.RunContext{CloneLists: *cloneLists, Async: *async}, *parallel, *makeAbout, *cat)
 }
 
-func run(makeAbout bool, cat bool) {
+func run(options proj.RunContext, parallel, makeAbout, cat bool) {
 	configFiles, err := proj.GetConfigs()
 	handleError(err)
 	if len(configFiles) == 0 {
 		handleError(fmt.Errorf("No config files found"))
 	}
+	errs := make(chan error, len(configFiles))
 	for _, configFile := range configFiles {
-		configResults, err := proj.RunConfig(configFile)
-		handleError(err)
-		projects = configResults.Projects
-		extendedProjects = configResults.ProjectsExtended
-		targetFolder = configResults.TargetFolder
-		projectName = configResults.ProjectName
-
-		handleError(util.RunCommand("mkdir", targetFolder))
-
-		cloneChan := make(chan error, len(*projects)+len(*extendedProjects))
-		cloneAndMove := func(ps *proj.Projects, prefix string) {
-			for k, v := range *ps {
-				fmt.Println(prefix + " " + k + "...")
-				if state.Async {
-					go v.CloneAndMove(cloneChan)
-				} else {
-					v.CloneAndMove(cloneChan)
-				}
-			}
+		if parallel {
+			go func(configFile string) { errs <- runConfig(configFile, options, makeAbout, cat) }(configFile)
+		} else {
+			errs <- runConfig(configFile, options, makeAbout, cat)
 		}
-		cloneAndMove(projects, "Cloning")
-		cloneAndMove(extendedProjects, "Cloning e")
+	}
+	for range configFiles {
+		handleError(<-errs)
+	}
+}
+
+func runConfig(configFile string, options proj.RunContext, makeAbout, cat bool) error {
+	configResults, err := proj.RunConfig(configFile, options)
+	if err != nil {
+		return err
+	}
+	rc := configResults.Context
+	projects := configResults.Projects
+	extendedProjects := configResults.ProjectsExtended
+	running.Lock()
+	running.folders[configResults.TargetFolder] = true
+	running.Unlock()
+	defer func() {
+		fmt.Println("Cleaning...", os.RemoveAll(configResults.TargetFolder))
+		running.Lock()
+		delete(running.folders, configResults.TargetFolder)
+		running.Unlock()
+	}()
 
-		for i := 0; i < len(*projects)+len(*extendedProjects); i++ {
-			handleError(<-cloneChan)
+	cloneChan := make(chan error, len(*projects)+len(*extendedProjects))
+	cloneAndMove := func(ps *proj.Projects, prefix string) {
+		for k, v := range *ps {
+			fmt.Println(prefix + " " + k + "...")
+			if rc.Async {
+				go v.CloneAndMove(cloneChan)
+			} else {
+				v.CloneAndMove(cloneChan)
+			}
 		}
+	}
+	cloneAndMove(projects, "Cloning")
+	cloneAndMove(extendedProjects, "Cloning e")
 
-		handleError(proj.RepoCheck(projects, configResults.RepoCheckPathExceptions))
-		handleError(proj.RepoCheck(extendedProjects, configResults.RepoCheckPathExceptions))
+	for i := 0; i < len(*projects)+len(*extendedProjects); i++ {
+		if err = <-cloneChan; err != nil {
+			return err
+		}
+	}
 
-		fmt.Println()
+	if err = proj.RepoCheck(rc, projects, configResults.RepoCheck, configResults.RepoCheckPathExceptions); err != nil {
+		return err
+	}
+	if err = proj.RepoCheck(rc, extendedProjects, configResults.RepoCheck, configResults.RepoCheckPathExceptions); err != nil {
+		return err
+	}
 
-		ingester := proj.Ingester{projects}
-		if err = ingester.IngestAll(true); err != nil {
-			fmt.Println("Ingest error:", err.Error())
-		}
-		ingester = proj.Ingester{extendedProjects}
-		if err = ingester.IngestAll(true); err != nil {
-			fmt.Println("Ingest e error:", err.Error())
-		}
+	fmt.Println()
 
-		fmt.Println()
-		fmt.Println("Cleaning...", cleanup())
-		fmt.Println()
+	ingester := proj.Ingester{Context: rc, Projects: projects}
+	if err = ingester.IngestAll(true); err != nil {
+		fmt.Println("Ingest error:", err.Error())
+	}
+	ingester = proj.Ingester{Context: rc, Projects: extendedProjects}
+	if err = ingester.IngestAll(true); err != nil {
+		fmt.Println("Ingest e error:", err.Error())
+	}
+	fmt.Println()
 
-		generatedDepList := projects.GetAllDependencies()
-		generatedExtendedDepList := extendedProjects.GetAllDependencies()
-		aboutDepList := configResults.AboutDepList
-		softwareDepList := configResults.SoftwareDepList
+	generatedDepList := projects.GetAllDependencies()
+	generatedExtendedDepList := extendedProjects.GetAllDependencies()
+	aboutDepList := configResults.AboutDepList
+	softwareDepList := configResults.SoftwareDepList
 
-		deps.RemoveExactDuplicates(&generatedDepList, &generatedExtendedDepList, &aboutDepList, &softwareDepList)
+	shaResolver := shas.NewResolver(nil, configResults.ShaStore)
+	for _, list := range []*deps.GenericDependencies{&generatedDepList, &generatedExtendedDepList, &aboutDepList, &softwareDepList} {
+		list.RemoveExactDuplicates()
+		swapShaVersions(shaResolver, list)
+	}
 
-		deps.SwapShaVersions(configResults.ShaStore, &generatedDepList, &generatedExtendedDepList, &aboutDepList, &softwareDepList)
+	generatedDepList.RemoveExceptions(configResults.DepExceptions)
+	generatedExtendedDepList.RemoveExceptions(configResults.DepExceptions)
 
-		deps.RemoveExceptions(configResults.DepExceptions, &generatedDepList, &generatedExtendedDepList)
+	condGeneratedDepList := generatedDepList.Clone()
 
-		condGeneratedDepList := generatedDepList.Clone()
+	condGeneratedDepList.CondenseBundles(configResults.Bundles)
 
-		deps.CondenseBundles(configResults.Bundles, &condGeneratedDepList)
+	var aboutMissing, aboutExtra, aboutGood, softwareMissing, softwareExtra, softwareGood *deps.GenericDependencies
+	if rc.ComparingAbout {
+		aboutMissing, aboutExtra, aboutGood = deps.CompareSimple(&aboutDepList, &condGeneratedDepList)
+		aboutMissing.RemoveDuplicatesByProject()
+	}
 
-		var aboutMissing, aboutExtra, aboutGood, softwareMissing, softwareExtra, softwareGood *deps.GenericDependencies
-		if state.ComparingAbout {
-			aboutMissing, aboutExtra, aboutGood = deps.CompareSimple(&aboutDepList, &condGeneratedDepList)
-			aboutMissing.RemoveDuplicatesByProject()
-		}
+	combined := append(generatedDepList, generatedExtendedDepList...)
+	if rc.ComparingSoftwareList {
+		softwareMissing, softwareExtra, softwareGood = deps.CompareByProject(&softwareDepList, &combined)
+	}
 
-		combined := append(generatedDepList, generatedExtendedDepList...)
-		if state.ComparingSoftwareList {
-			softwareMissing, softwareExtra, softwareGood = deps.CompareByProject(&softwareDepList, &combined)
+	if err = os.MkdirAll("report", 0755); err != nil {
+		return err
+	}
+	name := configResults.ProjectName
+	if makeAbout {
+		aboutList := condGeneratedDepList.Clone()
+		aboutList.RemoveDuplicatesByProject()
+		if err = writeAboutYML(name, &aboutList); err != nil {
+			return err
 		}
-
-		exist, err := util.Exists("report")
-		handleError(err)
-		if !exist {
-			handleError(util.RunCommand("mkdir", "report"))
+	}
+	if err = writeIssuesYML(name, projects, cat); err != nil {
+		return err
+	}
+	if err = writeDependenciesYML(name, &combined, cat); err != nil {
+		return err
+	}
+	if rc.ComparingAbout {
+		if err = writeAboutCompare(rc, name, aboutMissing, aboutExtra, aboutGood); err != nil {
+			return err
 		}
-		if makeAbout {
-			aboutList := condGeneratedDepList.Clone()
-			aboutList.RemoveDuplicatesByProject()
-			writeAboutYML(&aboutList)
+	}
+	if rc.ComparingSoftwareList {
+		if err = writeSoftwareCompare(name, softwareMissing, softwareExtra, softwareGood); err != nil {
+			return err
 		}
-		writeIssuesYML(cat)
-		writeDependenciesYML(&combined, cat)
-		if state.ComparingAbout {
-			writeAboutCompare(aboutMissing, aboutExtra, aboutGood)
+	}
+	return nil
+}
+
+// swapShaVersions replaces go versions that are shas with the versions the sha store gives them.
+func swapShaVersions(resolver *shas.Resolver, list *deps.GenericDependencies) {
+	for _, dep := range *list {
+		if !shas.IsSha(dep.GetVersion()) {
+			continue
 		}
-		if state.ComparingSoftwareList {
-			writeSoftwareCompare(softwareMissing, softwareExtra, softwareGood)
+		if version, ok := resolver.Lookup(context.Background(), dep.GetName(), dep.GetVersion()); ok {
+			dep.SetVersion(version)
 		}
 	}
 }
 
-func writeFile(str string, path string) {
+func writeFile(str string, path string) error {
 	file, err := os.Create(path)
-	handleError(err)
+	if err != nil {
+		return err
+	}
 	defer file.Close()
 	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-	writer.WriteString(str)
+	if _, err = writer.WriteString(str); err != nil {
+		return err
+	}
+	return writer.Flush()
 }
 
 func runInterruptHandler() {
@@ -168,53 +221,59 @@ func runInterruptHandler() {
 	}()
 }
 
-func writeAboutCompare(aboutMissing, aboutExtra, aboutGood *deps.GenericDependencies) {
+func writeAboutCompare(rc *proj.RunContext, name string, aboutMissing, aboutExtra, aboutGood *deps.GenericDependencies) error {
 	fmt.Println("Writing about compare file...")
 	var splitBy func(d *deps.GenericDependency) string
-	if state.AboutByLanguage {
+	if rc.AboutByLanguage {
 		splitBy = func(d *deps.GenericDependency) string { return string(d.GetLanguage()) }
-	} else if state.AboutSimple {
+	} else if rc.AboutSimple {
 		splitBy = func(d *deps.GenericDependency) string { return "" }
 	}
 	combined := append(*aboutExtra, *aboutMissing...)
-	writeFile(string(report.GenerateDiffFileDat(report.GenerateDiffMap(aboutMissing, aboutExtra, aboutGood, splitBy), "Extra in about yaml", "Missing in about yaml", "good", combined, true)), generateFileName("report/about-diff.txt"))
+	return writeFile(string(report.GenerateDiffFileDat(report.GenerateDiffMap(aboutMissing, aboutExtra, aboutGood, splitBy), "Extra in about yaml", "Missing in about yaml", "good", combined, true)), generateFileName(name, "report/about-diff.txt"))
 }
 
-func writeSoftwareCompare(listMissing, listExtra, listGood *deps.GenericDependencies) {
+func writeSoftwareCompare(name string, listMissing, listExtra, listGood *deps.GenericDependencies) error {
 	fmt.Println("Writing software compare file...")
 	splitBy := func(d *deps.GenericDependency) string { return d.GetProject() }
 	combined := append(*listExtra, *listMissing...)
-	writeFile(string(report.GenerateDiffFileDat(report.GenerateDiffMap(listMissing, listExtra, listGood, splitBy), "Extra in spreadsheet", "Missing in spreadsheet", "good", combined, false)), generateFileName("report/list-diff.txt"))
+	return writeFile(string(report.GenerateDiffFileDat(report.GenerateDiffMap(listMissing, listExtra, listGood, splitBy), "Extra in spreadsheet", "Missing in spreadsheet", "good", combined, false)), generateFileName(name, "report/list-diff.txt"))
 }
 
-func writeIssuesYML(cat bool) {
+func writeIssuesYML(name string, projects *proj.Projects, cat bool) error {
 	fmt.Println("Writing issues file...")
 	ymlDat, err := report.GenerateIssuesYaml(projects)
-	handleError(err)
+	if err != nil {
+		return err
+	}
 	if cat {
 		fmt.Printf("#####################\n%s#####################\n", report.YmlIssuesHeader+string(ymlDat))
 	}
-	writeFile(report.YmlIssuesHeader+string(ymlDat), generateFileName("report/issues.yml"))
+	return writeFile(report.YmlIssuesHeader+string(ymlDat), generateFileName(name, "report/issues.yml"))
 }
 
-func writeDependenciesYML(depens *deps.GenericDependencies, cat bool) {
+func writeDependenciesYML(name string, depens *deps.GenericDependencies, cat bool) error {
 	fmt.Println("Writing dependecies file...")
 	ymlDat, err := report.GenerateDependenciesYaml(depens)
-	handleError(err)
+	if err != nil {
+		return err
+	}
 	if cat {
 		fmt.Printf("#####################\n%s#####################\n", report.YmlDependenciesHeader+string(ymlDat))
 	}
-	writeFile(report.YmlDependenciesHeader+string(ymlDat), generateFileName("report/dependencies.yml"))
+	return writeFile(report.YmlDependenciesHeader+string(ymlDat), generateFileName(name, "report/dependencies.yml"))
 }
 
-func writeAboutYML(depens *deps.GenericDependencies) {
+func writeAboutYML(name string, depens *deps.GenericDependencies) error {
 	fmt.Println("Writing about file...")
 	ymlDat, err := report.GenerateStacksYaml(depens)
-	handleError(err)
-	writeFile(report.YmlAboutHeader+string(ymlDat), generateFileName("report/about.yml"))
+	if err != nil {
+		return err
+	}
+	return writeFile(report.YmlAboutHeader+string(ymlDat), generateFileName(name, "report/about.yml"))
 }
 
-func generateFileName(fileName string) string {
+func generateFileName(projectName, fileName string) string {
 	if strings.TrimSpace(fileName) == "" {
 		return fileName
 	}
@@ -227,8 +286,12 @@ func generateFileName(fileName string) string {
 	return res + parts[len(parts)-1]
 }
 
-func cleanup() error {
-	return os.RemoveAll(targetFolder)
+func cleanup() {
+	running.Lock()
+	defer running.Unlock()
+	for folder := range running.folders {
+		os.RemoveAll(folder)
+	}
 }
 
 func handleError(err error) {
@@ -25,15 +25,14 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
-	deps "github.com/venicegeo/vzutil-versioning/plural/project/dependency"
-	lan "github.com/venicegeo/vzutil-versioning/plural/project/language"
-	sha "github.com/venicegeo/vzutil-versioning/plural/project/shastore"
-	"github.com/venicegeo/vzutil-versioning/plural/project/states"
-	"github.com/venicegeo/vzutil-versioning/plural/project/util"
+	"github.com/venicegeo/vzutil-versioning/common/about"
+	"github.com/venicegeo/vzutil-versioning/common/approved"
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	"github.com/venicegeo/vzutil-versioning/single/util"
 
 	"gopkg.in/yaml.v2"
 )
@@ -52,42 +51,52 @@ type Config struct {
 	ProjectsExtended        map[string]ProjectInfo `json:"projects_extended"`
 	DepExecptions           []string               `json:"deps_exception_regexs"`
 	RepoCheckPathExceptions []string               `json:"repo_check_exceptions"`
+	RepoCheck               *repocheck.Rules       `json:"repo_check,omitempty"`
 	Bundles                 map[string][]string    `json:"package_bundles"`
 }
 
 type ConfigResults struct {
+	Context                 *RunContext
 	ProjectName             string
 	AboutDepList            deps.GenericDependencies
 	SoftwareDepList         deps.GenericDependencies
-	ShaStore                sha.ShaStore
+	ShaStore                []shas.Override
 	Projects                *Projects
 	ProjectsExtended        *Projects
-	DepExceptions           []string
+	DepExceptions           []*regexp.Regexp
 	TargetFolder            string
 	RepoCheckPathExceptions []string
+	RepoCheck               *repocheck.Rules
 	Bundles                 map[string][]string
 }
 
+var configRe = regexp.MustCompile(`^config(?:_([^.]+))*.json$`)
+
 func GetConfigs() ([]string, error) {
 	files, err := ioutil.ReadDir("./")
 	if err != nil {
 		return nil, err
 	}
-	re := regexp.MustCompile("^config(?:_([^.]+))*.json$")
 	res := []string{}
 	for _, f := range files {
-		if re.MatchString(f.Name()) {
+		if configRe.MatchString(f.Name()) {
 			res = append(res, f.Name())
 		}
 	}
 	return res, nil
 }
 
-func RunConfig(fileName string) (*ConfigResults, error) {
-	projectName := re.FindStringSubmatch(fileName)[1]
+// RunConfig reads a config and the lists it names. The context starts as a copy
+// of options and is returned on the results.
+func RunConfig(fileName string, options RunContext) (*ConfigResults, error) {
+	rc := &options
 	if fileName == "" {
 		return nil, doesntExist()
 	}
+	projectName := ""
+	if match := configRe.FindStringSubmatch(fileName); match != nil {
+		projectName = match[1]
+	}
 	dat, err := ioutil.ReadFile(fileName)
 	if err != nil {
 		return nil, err
@@ -96,59 +105,59 @@ func RunConfig(fileName string) (*ConfigResults, error) {
 	if err = json.Unmarshal(dat, &config); err != nil {
 		return nil, err
 	}
-	now := time.Now().Unix()
-	folderName := fmt.Sprintf("%d/", now)
-	if err = util.RunCommand("mkdir", folderName); err != nil {
+	folderName, err := ioutil.TempDir("", "vzutil-lists-")
+	if err != nil {
 		return nil, err
 	}
-	state.ComparingAbout = config.AboutYmlCloneUrl != "" && state.CloneLists
-	state.ComparingSoftwareList = config.SoftwareListCloneUrl != "" && state.CloneLists
+	folderName += "/"
+	rc.ComparingAbout = config.AboutYmlCloneUrl != "" && rc.CloneLists
+	rc.ComparingSoftwareList = config.SoftwareListCloneUrl != "" && rc.CloneLists
 	isShaStore := config.PathToShaStore != ""
 	defer os.RemoveAll(folderName)
-	if state.ComparingAbout {
+	if rc.ComparingAbout {
 		aboutNameA := strings.Split(strings.Replace(config.AboutYmlCloneUrl, ".git", "", -1), "/")
 		aboutName := aboutNameA[len(aboutNameA)-1]
 		fmt.Println("Cloning about yaml...")
 		if config.AboutYmlBranch != "" {
-			if err = util.RunCommand("git", "clone", "-b", config.AboutYmlBranch, config.AboutYmlCloneUrl, folderName+aboutName); err != nil {
+			if err = runCommand("git", "clone", "-b", config.AboutYmlBranch, config.AboutYmlCloneUrl, folderName+aboutName); err != nil {
 				return nil, err
 			}
-		} else if err = util.RunCommand("git", "clone", config.AboutYmlCloneUrl, folderName+aboutName); err != nil {
+		} else if err = runCommand("git", "clone", config.AboutYmlCloneUrl, folderName+aboutName); err != nil {
 			return nil, err
 		}
 	}
-	if state.ComparingSoftwareList {
+	if rc.ComparingSoftwareList {
 		listNameA := strings.Split(strings.Replace(config.SoftwareListCloneUrl, ".git", "", -1), "/")
 		listName := listNameA[len(listNameA)-1]
 		fmt.Println("Cloning deps list...")
 		if config.SoftwareListBranch != "" {
-			if err = util.RunCommand("git", "clone", "-b", config.SoftwareListBranch, config.SoftwareListCloneUrl, folderName+listName); err != nil {
+			if err = runCommand("git", "clone", "-b", config.SoftwareListBranch, config.SoftwareListCloneUrl, folderName+listName); err != nil {
 				return nil, err
 			}
-		} else if err = util.RunCommand("git", "clone", config.SoftwareListCloneUrl, folderName+listName); err != nil {
+		} else if err = runCommand("git", "clone", config.SoftwareListCloneUrl, folderName+listName); err != nil {
 			return nil, err
 		}
 	}
 	var aboutDat, listDat, shaStoreDat []byte = nil, nil, nil
 	var aboutDepList, softwareDepList deps.GenericDependencies = nil, nil
-	var shaStore sha.ShaStore = nil
-	if state.ComparingAbout {
+	var shaStore []shas.Override = nil
+	if rc.ComparingAbout {
 		if aboutDat, err = ioutil.ReadFile(folderName + config.PathToAbout); err != nil {
 			return nil, err
 		}
-		if aboutDepList, err = getDepsFromAboutYaml(aboutDat); err != nil {
+		if aboutDepList, err = getDepsFromAboutYaml(rc, aboutDat); err != nil {
 			return nil, err
 		}
 	}
-	if state.ComparingSoftwareList {
+	if rc.ComparingSoftwareList {
 		if listDat, err = ioutil.ReadFile(folderName + config.PathToList); err != nil {
 			return nil, err
 		}
-		if softwareDepList, err = getDepsFromSoftwareList(listDat, config.ListIndicesCode); err != nil {
+		if softwareDepList, err = getDepsFromSoftwareList(config.PathToList, listDat, config.ListIndicesCode); err != nil {
 			return nil, err
 		}
 	}
-	if state.ComparingSoftwareList && isShaStore {
+	if rc.ComparingSoftwareList && isShaStore {
 		if shaStoreDat, err = ioutil.ReadFile(folderName + config.PathToShaStore); err != nil {
 			return nil, err
 		}
@@ -156,7 +165,11 @@ func RunConfig(fileName string) (*ConfigResults, error) {
 			return nil, err
 		}
 	}
-	targetFolder := fmt.Sprintf("%d/", now)
+	targetFolder, err := ioutil.TempDir("", "vzutil-"+projectName+"-")
+	if err != nil {
+		return nil, err
+	}
+	targetFolder += "/"
 	projects := Projects{}
 	extendedProjects := Projects{}
 	for k, v := range config.Projects {
@@ -171,11 +184,26 @@ func RunConfig(fileName string) (*ConfigResults, error) {
 			return nil, err
 		}
 	}
-	for i, v := range config.RepoCheckPathExceptions {
-		config.RepoCheckPathExceptions[i] = targetFolder + v
+	depExceptions := make([]*regexp.Regexp, len(config.DepExecptions), len(config.DepExecptions))
+	for i, v := range config.DepExecptions {
+		if depExceptions[i], err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("Bad dependency exception [%s]: %s", v, err.Error())
+		}
 	}
-	return &ConfigResults{projectName, aboutDepList, softwareDepList, shaStore,
-		&projects, &extendedProjects, config.DepExecptions, targetFolder, config.RepoCheckPathExceptions, config.Bundles}, nil
+	if config.RepoCheck == nil {
+		config.RepoCheck = repocheck.Default()
+	} else if err = config.RepoCheck.Validate(); err != nil {
+		return nil, err
+	}
+	return &ConfigResults{rc, projectName, aboutDepList, softwareDepList, shaStore,
+		&projects, &extendedProjects, depExceptions, targetFolder, config.RepoCheckPathExceptions, config.RepoCheck, config.Bundles}, nil
+}
+
+func runCommand(name string, arg ...string) error {
+	if ret := util.RunCommand(name, arg...); ret.IsError() {
+		return ret.Error()
+	}
+	return nil
 }
 
 func doesntExist() error {
@@ -199,6 +227,7 @@ func doesntExist() error {
 		ProjectCloneUrl:      "github.com/ORG/",
 		Projects:             map[string]ProjectInfo{"repo_name": ProjectInfo{ComponentName: "project_name"}},
 		DepExecptions:        []string{`^github.com\/ORG\/.+$`},
+		RepoCheck:            repocheck.Default(),
 		Bundles:              map[string][]string{"package": []string{"sub_packageA", "sub_packageB"}}}, " ", "   ")
 	if err != nil {
 		return errMaking(err)
@@ -210,113 +239,47 @@ func doesntExist() error {
 	return errors.New("Config file not found. A default config was generated")
 }
 
-func getDepsFromAboutYaml(aboutDat []byte) (resultDepList deps.GenericDependencies, err error) {
-	yml := map[string]interface{}{}
-	if err = yaml.Unmarshal(aboutDat, &yml); err != nil {
+func getDepsFromAboutYaml(rc *RunContext, aboutDat []byte) (deps.GenericDependencies, error) {
+	a, err := about.Parse(aboutDat, yaml.Unmarshal)
+	if err != nil {
 		return nil, err
 	}
-	re := regexp.MustCompile(`^[^:]+:[^:]+(:[^:]*)$`)
-	stacksByLanguage, simpleStack := map[interface{}]interface{}{}, []interface{}{}
-	ok := false
-	if _, state.AboutByLanguage = yml["stacks"]; state.AboutByLanguage {
-		if stacksByLanguage, ok = yml["stacks"].(map[interface{}]interface{}); !ok {
-			return nil, errors.New("Stacks type not map[interface{}]interface{}")
-		}
+	for _, name := range a.Unknown {
+		fmt.Println("About yaml contains unknown language:", strings.TrimSuffix(name, "stack"))
 	}
-	if _, state.AboutSimple = yml["stack"]; state.AboutSimple {
-		if simpleStack, ok = yml["stack"].([]interface{}); !ok {
-			return nil, errors.New("Stack type not []interface{}")
-		}
-	}
-	if state.AboutByLanguage {
-		for stackNameI, stackDepListI := range stacksByLanguage {
-			stackName, ok := stackNameI.(string)
-			if !ok {
-				return nil, fmt.Errorf("Stack name [%v] not string", stackNameI)
-			}
-			stackDepList, ok := stackDepListI.([]interface{})
-			if !ok {
-				return nil, errors.New("Dependency list not []interface{}")
-			}
-			stackLanguage := lan.GetLanguage(stackName)
-			if stackLanguage == lan.Unknown {
-				fmt.Println("About yaml contains unknown language:", strings.TrimSuffix(stackName, "stack"))
-				continue
-			}
-			for _, stackDepI := range stackDepList {
-				stackDep, ok := stackDepI.(string)
-				if !ok {
-					return nil, fmt.Errorf("Dep [%v] not string", stackDepI)
-				}
-				if re.MatchString(stackDep) {
-					stackDep = strings.TrimSuffix(stackDep, re.FindStringSubmatch(stackDep)[1])
-				}
-				resultDepList.Add(deps.NewGenericDependencyStr(stackDep + "::" + string(stackLanguage)))
-			}
-		}
-		resultDepList.RemoveExactDuplicates()
-	} else if state.AboutSimple {
-		for _, idep := range simpleStack {
-			dep, ok := idep.(string)
-			if !ok {
-				return nil, fmt.Errorf("Dep [%v] not string", idep)
-			}
-			resultDepList.Add(deps.NewGenericDependencyStr(dep))
-		}
-	}
-	return resultDepList, err
+	rc.AboutByLanguage = a.Format == about.Stacks
+	rc.AboutSimple = a.Format == about.Stack
+	return a.Deps, nil
 }
 
-func getDepsFromSoftwareList(listDat []byte, indicesCode string) (deps.GenericDependencies, error) {
-	name, version, component, language := 0, 1, 2, 3
-	indices := [4]int64{}
-	for i := range indices {
-		tempi, err := strconv.ParseInt(indicesCode[i:i+1], 36, 64)
-		if err != nil {
-			return nil, err
-		}
-		indices[i] = tempi
-	}
-	reader := csv.NewReader(bytes.NewReader(listDat))
-	records, err := reader.ReadAll()
+func getDepsFromSoftwareList(fileName string, listDat []byte, indicesCode string) (deps.GenericDependencies, error) {
+	cols, err := approved.ColumnsFromCode(indicesCode)
 	if err != nil {
 		return nil, err
 	}
-	if len(records) > 0 {
-		records = records[1:]
-	}
-
-	resultDepList := deps.GenericDependencies{}
-	unknownLangs := map[string]bool{}
-	for _, record := range records {
-		itemLanguage := lan.GetLanguage(record[indices[language]])
-		if itemLanguage == lan.Unknown {
-			unknownLangs[strings.TrimSuffix(record[indices[language]], "stack")] = true
-			continue
-		}
-		components := strings.Split(strings.ToLower(record[indices[component]]), ",")
-		for _, componentName := range components {
-			componentName = strings.TrimSpace(componentName)
-			resultDepList.Add(deps.NewGenericDependency(record[indices[name]], record[indices[version]], componentName, itemLanguage))
-		}
+	rows, err := approved.ReadSheet(fileName, listDat)
+	if err != nil {
+		return nil, err
 	}
-	for k, _ := range unknownLangs {
-		fmt.Println("Software list contains unknown language:", k)
-
+	list, unknown := approved.Parse(rows, cols)
+	for _, name := range unknown {
+		fmt.Println("Software list contains unknown language:", name)
 	}
-	resultDepList.RemoveExactDuplicates()
-	return resultDepList, nil
+	return list, nil
 }
 
-func getShaStore(storeDat []byte) (sha.ShaStore, error) {
-	store := sha.ShaStore{}
+func getShaStore(storeDat []byte) ([]shas.Override, error) {
+	store := []shas.Override{}
 	reader := csv.NewReader(bytes.NewReader(storeDat))
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, err
 	}
 	for _, record := range records {
-		store = append(store, &sha.ShaStoreEntry{record[0], record[1], record[2]})
+		if len(record) < 3 {
+			return nil, fmt.Errorf("Sha store line %v needs a name, sha and version", record)
+		}
+		store = append(store, shas.Override{Name: record[0], Sha: record[1], Version: record[2]})
 	}
 	return store, nil
 }
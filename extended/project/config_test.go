@@ -0,0 +1,67 @@
+/*
+Copyright 2017, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAboutStyleIsPerContext(t *testing.T) {
+	byLanguage, simple := &RunContext{}, &RunContext{}
+	deps, err := getDepsFromAboutYaml(byLanguage, []byte("stacks:\n  gostack:\n  - github.com/foo/bar:1.0.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || deps[0].GetVersion() != "1.0.0" {
+		t.Errorf("Unexpected deps %v", deps)
+	}
+	if _, err = getDepsFromAboutYaml(simple, []byte("stack:\n- foo:1.0.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	if !byLanguage.AboutByLanguage || byLanguage.AboutSimple {
+		t.Errorf("Stacks by language read as %#v", byLanguage)
+	}
+	if simple.AboutByLanguage || !simple.AboutSimple {
+		t.Errorf("Simple stack read as %#v", simple)
+	}
+}
+
+func TestRunConfigCopiesOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config_test.json")
+	if err = ioutil.WriteFile(file, []byte(`{"about_clone_url":"github.com/org/about","projects":{"repo":{}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options := RunContext{Async: true}
+	res, err := RunConfig(file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(res.TargetFolder)
+	if res.Context == &options || !res.Context.Async || res.Context.ComparingAbout {
+		t.Errorf("Unexpected context %#v", res.Context)
+	}
+	if len(*res.Projects) != 1 {
+		t.Errorf("Expected one project, got %d", len(*res.Projects))
+	}
+}
@@ -13,12 +13,18 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
-package shastore
+package project
 
-type ShaStore []*ShaStoreEntry
+// RunContext holds the switches of one run of one config. Each config gets its
+// own, so configs never see each others about yaml style and can run side by side.
+type RunContext struct {
+	// CloneLists and Async come from the command line.
+	CloneLists bool
+	Async      bool
 
-type ShaStoreEntry struct {
-	Name    string
-	Sha     string
-	Version string
+	// The rest are worked out by RunConfig from the config and its lists.
+	ComparingAbout        bool
+	ComparingSoftwareList bool
+	AboutByLanguage       bool
+	AboutSimple           bool
 }
@@ -0,0 +1,61 @@
+/*
+Copyright 2017, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIngestAllAsync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ingest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	projects := Projects{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		folder := filepath.Join(dir, name)
+		if err = os.MkdirAll(folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(folder, "requirements.txt"), []byte("requests==2.18.4\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		projects[name] = &Project{ProjectInfo: ProjectInfo{ComponentName: name}, FolderLocation: folder + "/"}
+	}
+	// The missing folders fail to find their files, alongside the ones that ingest.
+	for _, name := range []string{"missing1", "missing2"} {
+		projects[name] = &Project{ProjectInfo: ProjectInfo{ComponentName: name}, FolderLocation: filepath.Join(dir, name) + "/"}
+	}
+
+	ingester := &Ingester{&RunContext{Async: true}, &projects}
+	err = ingester.IngestAll(false)
+	if err == nil {
+		t.Fatal("The missing folders were not reported")
+	}
+	if lines := strings.Split(strings.TrimSpace(err.Error()), "\n"); len(lines) != 2 {
+		t.Errorf("Expected an error for each missing folder, got %q", err.Error())
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if deps := projects[name].Dependencies; len(deps) != 1 {
+			t.Errorf("Project %s ingested %v", name, deps)
+		}
+	}
+}
@@ -16,16 +16,15 @@ limitations under the License.
 package project
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/venicegeo/vzutil-versioning/plural/project/dependency"
-	"github.com/venicegeo/vzutil-versioning/plural/project/issue"
-	lan "github.com/venicegeo/vzutil-versioning/plural/project/language"
-	"github.com/venicegeo/vzutil-versioning/plural/project/util"
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/issue"
+	"github.com/venicegeo/vzutil-versioning/single/scan"
 )
 
 type Projects map[string]*Project
@@ -44,7 +43,7 @@ type Project struct {
 	FolderLocation string
 	Dependencies   dependency.GenericDependencies
 	DepLocations   []string
-	Issues         []*issue.Issue `json:"issues,omitempty"`
+	Issues         issue.Issues `json:"issues,omitempty"`
 }
 
 type ProjectInfo struct {
@@ -97,27 +96,28 @@ func (p *Project) CloneAndMove(cloneChan chan<- error) {
 	cloneChan <- err
 }
 
-func (p *Project) findDepFiles() (err error) {
-	visit := func(path string, f os.FileInfo, err error) error {
-		if f.IsDir() {
-			return nil
-		}
-		if util.IsVendorPath(path, p.FolderLocation) || util.IsDotGitPath(path, p.FolderLocation) {
-			return nil
-		}
+// findDepFiles lists the dependency files of the project, relative to its folder.
+func (p *Project) findDepFiles() error {
+	files, err := scan.Find(context.Background(), p.FolderLocation, true)
+	if err != nil {
+		return err
+	}
+	p.DepLocations = []string{}
+	for _, file := range files {
+		ignored := false
 		for _, ignore := range p.WalkIgnore {
-			if strings.HasPrefix(path, p.FolderLocation+fixLocation(ignore)) {
-				return nil
+			if strings.HasPrefix(file, fixLocation(ignore)) {
+				ignored = true
+				break
 			}
 		}
-		if _, ok := lan.FileToLang[f.Name()]; ok {
-			p.DepLocations = append(p.DepLocations, path)
+		if !ignored {
+			p.DepLocations = append(p.DepLocations, file)
 		}
-		return nil
 	}
-	return filepath.Walk(p.FolderLocation, visit)
+	return nil
 }
 
-func (p *Project) AddIssue(issue ...*issue.Issue) {
-	p.Issues = append(p.Issues, issue...)
+func (p *Project) AddIssue(format string, a ...interface{}) {
+	p.Issues = append(p.Issues, issue.NewIssue(format, a...))
 }
@@ -17,114 +17,33 @@ package project
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
 
-	"github.com/venicegeo/vzutil-versioning/plural/project/issue"
-	"github.com/venicegeo/vzutil-versioning/plural/project/states"
-	"github.com/venicegeo/vzutil-versioning/plural/project/util"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
 )
 
-func RepoCheck(projects *Projects, pathExceptions []string) error {
+// RepoCheck runs the hygiene rules against each project. Path exceptions that
+// start with the name of a project are only excluded from that project.
+func RepoCheck(rc *RunContext, projects *Projects, rules *repocheck.Rules, pathExceptions []string) error {
 	checkChan := make(chan error, len(*projects))
 	combErr := ""
-	copyrightFileExtentions := []string{
-		".go",
-		".java",
-		".js",
-		".py",
-		".ts",
-		".tsx",
-	}
-	copyrightStatements := []*regexp.Regexp{
-		regexp.MustCompile(`Copyright 201[6789], RadiantBlue Technologies, Inc\.`),
-		regexp.MustCompile(`WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND`),
-		regexp.MustCompile(`Apache License, Version 2\.0`),
-	}
-	reReadMe := regexp.MustCompile(`^README(?:(?:.txt)|(?:.md))?$`)
-	reLiscence := regexp.MustCompile(`^LICENSE(?:(?:.txt)|(?:.md))?$`)
 
 	check := func(p *Project) {
-		folder, err := os.Open(p.FolderLocation)
-		if err != nil {
-			checkChan <- err
-			return
+		excludes := []string{}
+		for _, exception := range pathExceptions {
+			exception = strings.Trim(strings.TrimPrefix(exception, p.repoName+"/"), "/")
+			excludes = append(excludes, "/"+exception, "/"+exception+"/")
 		}
-		files, err := folder.Readdirnames(0)
+		res, err := repocheck.Check(repocheck.DirTree(p.FolderLocation), rules.WithExcludes(excludes...))
 		if err != nil {
-			checkChan <- err
-			return
-		}
-		hasReadme, hasLiscence, hasAbout := false, false, false
-		for _, f := range files {
-			if reReadMe.MatchString(f) {
-				hasReadme = true
-			} else if reLiscence.MatchString(f) {
-				hasLiscence = true
-			} else if f == `.about.yml` {
-				hasAbout = true
-			}
-		}
-		if !hasReadme {
-			p.AddIssue(issue.NewIssue("Missing README"))
-		}
-		if !hasLiscence {
-			p.AddIssue(issue.NewIssue("Missing LISCENCE"))
-		}
-		if !hasAbout {
-			p.AddIssue(issue.NewIssue("Missing .about"))
-		}
-
-		visit := func(path string, f os.FileInfo, err error) error {
-			if f.IsDir() {
-				return nil
-			}
-			if util.IsVendorPath(path, p.FolderLocation) || util.IsDotGitPath(path, p.FolderLocation) {
-				return nil
-			}
-			for _, exception := range pathExceptions {
-				//TODO not perfect, like many things
-				if strings.Contains(path, exception) {
-					return nil
-				}
-			}
-			correctType := false
-			for _, v := range copyrightFileExtentions {
-				if strings.HasSuffix(path, v) {
-					correctType = true
-					break
-				}
-			}
-			if !correctType {
-				return nil
-			}
-			dat, err := ioutil.ReadFile(path)
-			if err != nil {
-				return err
-			}
-			str := string(dat)
-			matchedStatements := []string{}
-			for _, statement := range copyrightStatements {
-				if !statement.MatchString(str) {
-					matchedStatements = append(matchedStatements, statement.String())
-				}
-			}
-			if len(matchedStatements) != 0 {
-				p.AddIssue(issue.NewIssue("File [%s] does not contain copyright statement(s) %v", path, matchedStatements))
-			}
-			return nil
-		}
-		if err := filepath.Walk(p.FolderLocation, visit); err != nil {
-			checkChan <- err
+			checkChan <- fmt.Errorf("%s: %s", p.repoName, err.Error())
 			return
 		}
+		p.Issues = append(p.Issues, repocheck.ToIssues(res)...)
 		checkChan <- nil
 	}
 	for _, p := range *projects {
-		if state.Async {
+		if rc.Async {
 			go check(p)
 		} else {
 			check(p)
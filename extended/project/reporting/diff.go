@@ -20,9 +20,14 @@ import (
 	"sort"
 	"strings"
 
-	deps "github.com/venicegeo/vzutil-versioning/plural/project/dependency"
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/match"
 )
 
+// depMatcher lines up each extra dependency with the missing one it most
+// likely replaced.
+var depMatcher = match.NewMatcher(match.Levenshtein, 0.5)
+
 const diff_missing, diff_extra, diff_good = "diff_missing", "diff_extra", "diff_good"
 
 //             proj/stack  miss/ext  deps
@@ -110,6 +115,7 @@ func GenerateDiffFileDat(diff *diffMap, extraString, missingString, goodString s
 			if _, ok := group[diff_good]; !ok {
 				group[diff_good] = []string{}
 			}
+			group[diff_extra], group[diff_missing] = depMatcher.Align(group[diff_extra], group[diff_missing])
 			length := max(len(group[diff_missing]), max(len(group[diff_extra]), len(group[diff_good])))
 			for len(group[diff_missing]) < length {
 				group[diff_missing] = append(group[diff_missing], "")
@@ -124,12 +130,6 @@ func GenerateDiffFileDat(diff *diffMap, extraString, missingString, goodString s
 		}
 	}
 
-	for groupName, group := range *diff {
-		extra := group[diff_extra]
-		missing := group[diff_missing]
-		(*diff)[groupName][diff_extra], (*diff)[groupName][diff_missing] = similaritySort(extra, missing)
-	}
-
 	spaceString := func(str string, size int) string {
 		spacesToAdd := max(0, size-len(str))
 		leftSpace := spacesToAdd / 2
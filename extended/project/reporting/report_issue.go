@@ -18,31 +18,26 @@ package report
 import (
 	"sort"
 
-	"github.com/venicegeo/vzutil-versioning/plural/project"
-	"github.com/venicegeo/vzutil-versioning/plural/project/issue"
+	"github.com/venicegeo/vzutil-versioning/extended/project"
 	"gopkg.in/yaml.v2"
 )
 
 const YmlIssuesHeader = "#\n# Issues generated from current piazza versions\n#\n"
 
 type YmlIssuesWrapper struct {
-	issue.IssuesMap `yaml:"issues"`
+	YmlMap `yaml:"issues"`
 }
 
 func GenerateIssuesYaml(projects *project.Projects) ([]byte, error) {
-	issuesMap := issue.IssuesMap{}
+	issuesMap := YmlMap{}
 	for k, v := range *projects {
 		if len(v.Issues) == 0 {
 			continue
 		}
-		issues := issue.Issues{}
-		for _, issue := range v.Issues {
-			issues = append(issues, issue.String())
-		}
-		issuesMap[k] = &issues
+		issuesMap[k] = v.Issues.SSlice()
 	}
 	for _, v := range issuesMap {
-		sort.Strings(*v)
+		sort.Strings(v)
 	}
 	return yaml.Marshal(YmlIssuesWrapper{issuesMap})
 }
@@ -18,7 +18,7 @@ package report
 import (
 	"sort"
 
-	deps "github.com/venicegeo/vzutil-versioning/plural/project/dependency"
+	deps "github.com/venicegeo/vzutil-versioning/common/dependency"
 
 	"gopkg.in/yaml.v2"
 )
@@ -16,279 +16,182 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/csv"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"regexp"
-	"sort"
-	"strings"
 	"syscall"
-	"time"
 
-	com "github.com/venicegeo/vzutil-versioning/common"
-	d "github.com/venicegeo/vzutil-versioning/common/dependency"
-	i "github.com/venicegeo/vzutil-versioning/common/issue"
-	r "github.com/venicegeo/vzutil-versioning/single/resolve"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
+	"github.com/venicegeo/vzutil-versioning/single/scan"
 	"github.com/venicegeo/vzutil-versioning/single/util"
 )
 
 type stringarr []string
 
-var scan bool
-var all bool
-var includeTest bool
-var files stringarr
-var full_name string
-var name string
-var localMode bool
-
-var cleanup func()
-
-var resolver *r.Resolver
-
 func main() {
-	var err error
-	timestamp := time.Now()
-
-	runInterruptHandler()
+	var scanMode, all, includeTest, localMode, objectRead, swapShas bool
+	var files stringarr
+	var cacheDir, overridesFile, checksFile string
+	var checks, noConfig bool
+	var cacheBudget int64
 
 	flag.BoolVar(&localMode, "local", false, "Run in local mode")
-	flag.BoolVar(&scan, "scan", false, "Scan for dependency files")
+	flag.BoolVar(&scanMode, "scan", false, "Scan for dependency files")
 	flag.BoolVar(&all, "all", false, "Run against all found dependency files")
 	flag.BoolVar(&includeTest, "testing", true, "Include testing dependencies")
 	flag.Var(&files, "f", "Add file to scan")
+	flag.BoolVar(&objectRead, "objects", false, "Read files from git objects instead of checking out")
+	flag.StringVar(&cacheDir, "cache", "", "Keep repository mirrors in this folder between runs")
+	flag.Int64Var(&cacheBudget, "cache-budget", 0, "Size in MB past which unused mirrors are removed from the cache")
+	flag.BoolVar(&swapShas, "shas", false, "Swap Go dependency shas for the tags that point at them, needs -cache")
+	flag.StringVar(&overridesFile, "sha-overrides", "", "CSV of name,sha,version to use for shas that are not tagged")
+	flag.BoolVar(&checks, "checks", false, "Run the repository checks against the scanned tree")
+	flag.StringVar(&checksFile, "check-rules", "", "JSON file of repository check rules, defaults to the built in rules")
+	flag.BoolVar(&noConfig, "no-config", false, "Ignore the .vzutil.yml scan configuration of the repository")
 	flag.Parse()
 	info := flag.Args()
 
-	if scan && all {
+	if scanMode && all {
 		fmt.Println("Cannot run in scan and resolve mode")
 		os.Exit(1)
 	} else if all && len(files) != 0 {
 		fmt.Println("Cannot scan all and certain files")
 		os.Exit(1)
-	} else if len(files) == 0 && !(scan || all) {
+	} else if len(files) == 0 && !(scanMode || all) {
 		fmt.Println("Must give a run paramater")
 		os.Exit(1)
 	} else if localMode && len(info) != 1 || !localMode && len(info) != 2 {
 		fmt.Println("The program arguments were incorrect. Usage: single [options] [org/repo] [sha]")
 		os.Exit(1)
+	} else if swapShas && cacheDir == "" {
+		fmt.Println("Swapping shas needs a mirror cache")
+		os.Exit(1)
 	}
 
-	resolver = r.NewResolver(ioutil.ReadFile)
-	genFileToFunc()
+	ctx := runInterruptHandler()
+	req := &scan.Request{
+		FullName:    info[0],
+		Files:       files,
+		All:         all,
+		IncludeTest: includeTest,
+		WorkDir:     ".",
+		ObjectRead:  objectRead,
 
-	var location, sha string
-	var refs []string
-
-	full_name = info[0]
-	if !localMode {
-		name = strings.SplitN(info[0], "/", 2)[1]
-		location, sha, refs, err = cloneAndCheckout(info[0], info[1], name)
-	} else {
-		name = ""
-		location = full_name
-		sha = "Local"
-		refs = []string{}
-	}
-	cleanup = func() {
-		if !localMode {
-			util.RunCommand("rm", "-rf", strings.TrimSuffix(location, name))
-		}
+		IgnoreConfig: noConfig,
 	}
-	defer cleanup()
-	if err != nil {
-		cleanup()
-		fmt.Println("Error checking out:", err)
-		os.Exit(1)
+	if !localMode {
+		req.Checkout = info[1]
 	}
-
-	if scan {
-		files, err := modeScan(location, name, includeTest)
-		cleanup()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		str, err := util.GetJson(map[string]interface{}{"files": files})
+	if cacheDir != "" {
+		cache, err := mirror.NewCache(cacheDir, cacheBudget*1024*1024)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		fmt.Println(str)
-	} else {
-		if all {
-			if files, err = modeScan(location, name, includeTest); err != nil {
-				cleanup()
-				fmt.Println(err)
-				os.Exit(1)
-			}
-		}
-		deps, issues, err := modeResolve(location, name, files, includeTest)
-		cleanup()
+		req.Cache = cache
+		defer cache.Collect()
+	}
+	if swapShas || overridesFile != "" {
+		overrides, err := readOverrides(overridesFile)
 		if err != nil {
 			fmt.Println(err)
-			os.Exit(1)
+			exit(req, 1)
 		}
-		if dat, err := util.GetJson(com.DependencyScan{full_name, name, sha, refs, deps, issues.SSlice(), files, timestamp}); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		} else {
-			fmt.Println(string(dat))
+		var tags shas.TagSource
+		if swapShas {
+			tags = scan.RemoteTags(req.Cache)
 		}
+		req.Shas = shas.NewResolver(tags, overrides)
 	}
-}
 
-func modeScan(location, name string, test bool) ([]string, error) {
-	fullLocation := fmt.Sprintf("%s/%s", location, name)
-	fileLocations := []string{}
-	knownFiles := []string{"pom.xml", "glide.yaml", "package.json", "environment.yml", "requirements.txt", "meta.yaml"}
-	knownTestFiles := []string{"requirements-dev.txt", "environment-dev.yml"}
-	visit := func(path string, f os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if f.IsDir() {
-			return nil
-		}
-		if util.IsVendorPath(path, fullLocation) || util.IsDotGitPath(path, fullLocation) {
-			return nil
-		}
-		found := false
-		for _, k := range knownFiles {
-			if k == f.Name() {
-				fileLocations = append(fileLocations, path)
-				found = true
-				break
+	if checks || checksFile != "" {
+		rules := repocheck.Default()
+		if checksFile != "" {
+			dat, err := ioutil.ReadFile(checksFile)
+			if err == nil {
+				rules, err = repocheck.Load(dat)
 			}
-		}
-		if test && !found {
-			for _, k := range knownTestFiles {
-				if k == f.Name() {
-					fileLocations = append(fileLocations, path)
-					break
-				}
+			if err != nil {
+				fmt.Println(err)
+				exit(req, 1)
 			}
 		}
-		return nil
+		req.Checks = rules
 	}
-	if err := filepath.Walk(location, visit); err != nil {
-		return nil, err
+
+	var res interface{}
+	var err error
+	switch {
+	case scanMode && localMode:
+		var found []string
+		found, err = scan.FindLocal(ctx, info[0], req)
+		res = map[string]interface{}{"files": found}
+	case scanMode:
+		var found []string
+		found, err = scan.ListFiles(ctx, req)
+		res = map[string]interface{}{"files": found}
+	case localMode:
+		res, err = scan.RunLocal(ctx, info[0], req)
+	default:
+		res, err = scan.Run(ctx, req)
 	}
-	for i, f := range fileLocations {
-		fileLocations[i] = strings.TrimPrefix(strings.TrimPrefix(f, fullLocation), "/")
+	if err != nil {
+		fmt.Println(err)
+		exit(req, 1)
 	}
-	return fileLocations, nil
-}
-
-var getFile = regexp.MustCompile(`^\/?(?:[^\/]+\/)*(.+)$`)
-
-var fileToFunc map[string]func(string, bool) (d.Dependencies, i.Issues, error)
-
-func genFileToFunc() {
-	fileToFunc = map[string]func(string, bool) (d.Dependencies, i.Issues, error){
-		"glide.yaml":           resolver.ResolveGlideYaml,
-		"package.json":         resolver.ResolvePackageJson,
-		"environment.yml":      resolver.ResolveEnvironmentYml,
-		"environment-dev.yml":  resolver.ResolveEnvironmentYml,
-		"requirements.txt":     resolver.ResolveRequirementsTxt,
-		"requirements-dev.txt": resolver.ResolveRequirementsTxt,
-		"meta.yaml":            resolver.ResolveMetaYaml,
-		"pom.xml":              resolver.ResolvePomXml,
+	if dat, err := util.GetJson(res); err != nil {
+		fmt.Println(err)
+		exit(req, 1)
+	} else {
+		fmt.Println(dat)
 	}
 }
 
-func modeResolve(location, name string, files []string, test bool) (d.Dependencies, i.Issues, error) {
-	var deps d.Dependencies
-	var issues i.Issues
-	for _, f := range files {
-		matches := getFile.FindStringSubmatch(f)
-		if len(matches) != 2 {
-			fmt.Printf("File [%f] could not be parsed\n", f)
-			cleanup()
-			os.Exit(1)
-		}
-		full := fmt.Sprintf("%s/%s/%s", location, name, f)
-		funcc, ok := fileToFunc[matches[1]]
-		if !ok {
-			fmt.Printf("Could not scan file [%s]\n", f)
-			cleanup()
-			os.Exit(1)
-		}
-		d, i, e := funcc(full, test)
-		if e != nil {
-			return nil, nil, fmt.Errorf("%s: %s", f, e)
-		}
-		deps = append(deps, d...)
-		issues = append(issues, i...)
+// exit trims the cache before leaving, since deferred calls do not run on os.Exit.
+func exit(req *scan.Request, code int) {
+	if req.Cache != nil {
+		req.Cache.Collect()
 	}
-	d.RemoveExactDuplicates(&deps)
-	sort.Sort(deps)
-	return deps, issues, nil
+	os.Exit(code)
 }
 
-func cloneAndCheckout(full_name, checkout, name string) (string, string, []string, error) {
-	t := fmt.Sprintf("%d", time.Now().UnixNano())
-	var err error
-	var cmdRet util.CmdRet
-	if cmdRet = util.RunCommand("mkdir", t); cmdRet.IsError() {
-		return t, "", nil, cmdRet.Error()
-	}
-	if t, err = filepath.Abs(t); err != nil {
-		return t, "", nil, err
-	}
-	rest := t
-	t = fmt.Sprintf("%s/%s", t, name)
-	if cmdRet = util.RunCommand("git", "clone", "https://github.com/"+full_name, t); cmdRet.IsError() {
-		return t, "", nil, cmdRet.Error()
-	}
-
-	util.RunCommand("bash", "-c", fmt.Sprintf(`git -C %s branch -r | grep -v '\->' | while read remote; do git -C %s branch --track "${remote#origin/}" "$remote"; done`, t, t))
-	util.RunCommand("git", "-C", t, "fetch", "--all")
-	util.RunCommand("git", "-C", t, "pull", "--all")
-
-	if cmdRet = util.RunCommand("git", "-C", t, "checkout", checkout); cmdRet.IsError() {
-		return t, "", nil, cmdRet.Error()
+func readOverrides(path string) ([]shas.Override, error) {
+	if path == "" {
+		return nil, nil
 	}
-	if cmdRet = util.RunCommand("git", "-C", t, "rev-parse", "HEAD"); cmdRet.IsError() {
-		return t, "", nil, cmdRet.Error()
-	}
-	sha := strings.TrimSpace(cmdRet.Stdout)
-	if cmdRet = util.RunCommand("git", "-C", t, "show-ref", "-d"); cmdRet.IsError() {
-		return t, "", nil, cmdRet.Error()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	tmp := map[string]string{}
-	lines := strings.Split(cmdRet.Stdout, "\n")
-	for _, l := range lines {
-		l = strings.TrimSpace(l)
-		if l == "" {
-			continue
-		}
-		parts := strings.Split(strings.TrimSpace(l), " ")
-		sha := strings.TrimSuffix(parts[1], `^{}`)
-		if !strings.HasSuffix(sha, "/HEAD") {
-			tmp[strings.Replace(sha, "remotes/origin", "heads", -1)] = parts[0]
-		}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
 	}
-	refs := []string{}
-	for k, v := range tmp {
-		if v == sha {
-			refs = append(refs, k)
-		}
+	res := make([]shas.Override, len(records), len(records))
+	for i, record := range records {
+		res[i] = shas.Override{Name: record[0], Sha: record[1], Version: record[2]}
 	}
-
-	return strings.TrimSuffix(rest, "/"), sha, refs, nil
+	return res, nil
 }
 
-func runInterruptHandler() {
+func runInterruptHandler() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		os.Exit(1)
+		cancel()
 	}()
+	return ctx
 }
 
 func (stringarr) String() string {
@@ -0,0 +1,66 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mirror
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileLock is an flock on a file next to a mirror. It coordinates both the
+// goroutines of one process and separate processes sharing a cache folder.
+type fileLock struct {
+	file *os.File
+}
+
+func openLock(path string) (*fileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLock{file}, nil
+}
+
+func (l *fileLock) lock(how int) error {
+	for {
+		err := syscall.Flock(int(l.file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func (l *fileLock) Exclusive() error {
+	return l.lock(syscall.LOCK_EX)
+}
+
+func (l *fileLock) Shared() error {
+	return l.lock(syscall.LOCK_SH)
+}
+
+// TryExclusive reports false without waiting if someone else holds the lock.
+func (l *fileLock) TryExclusive() (bool, error) {
+	err := l.lock(syscall.LOCK_EX | syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *fileLock) Close() error {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return l.file.Close()
+}
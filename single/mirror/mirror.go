@@ -0,0 +1,527 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/single/util"
+)
+
+// Cache keeps a bare mirror of each repository under one folder. Mirrors are
+// fetched incrementally and checked out through worktrees, and the least
+// recently used ones are removed once the cache grows past its budget.
+type Cache struct {
+	root   string
+	budget int64
+	remote func(fullName string) string
+}
+
+var fullSha = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// NewCache creates a cache in root. A budget of zero or less never removes mirrors.
+func NewCache(root string, budget int64) (*Cache, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Join(root, "worktrees"), 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{root, budget, func(fullName string) string { return "https://github.com/" + fullName }}, nil
+}
+
+// SetRemote changes how a repository name is turned into the url it is fetched from.
+func (c *Cache) SetRemote(remote func(fullName string) string) {
+	c.remote = remote
+}
+
+func (c *Cache) Root() string {
+	return c.root
+}
+
+func (c *Cache) path(fullName string) string {
+	return filepath.Join(c.root, "repos", filepath.FromSlash(fullName)+".git")
+}
+
+func (c *Cache) git(ctx context.Context, fullName string, args ...string) (string, error) {
+	cmdRet := util.RunCommandContext(ctx, "git", append([]string{"--git-dir", c.path(fullName)}, args...)...)
+	if cmdRet.IsError() {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", cmdRet.Error()
+	}
+	return cmdRet.Stdout, nil
+}
+
+// LsRemote asks the remote of a repository which commit each of its branches and
+// tags points at, keyed by refs/heads/x and refs/tags/y, without fetching.
+// Annotated tags are given as the commit they point at.
+func (c *Cache) LsRemote(ctx context.Context, fullName string) (map[string]string, error) {
+	cmdRet := util.RunCommandContext(ctx, "git", "ls-remote", "--heads", "--tags", c.remote(fullName))
+	if cmdRet.IsError() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, cmdRet.Error()
+	}
+	res := map[string]string{}
+	peeled := map[string]bool{}
+	for _, l := range strings.Split(cmdRet.Stdout, "\n") {
+		if l == "" {
+			continue
+		}
+		shaRef := strings.Split(l, "\t")
+		if len(shaRef) != 2 {
+			return nil, fmt.Errorf("Problem parsing this line [%s]", l)
+		}
+		ref := strings.TrimSuffix(shaRef[1], "^{}")
+		if ref != shaRef[1] {
+			peeled[ref] = true
+		} else if peeled[ref] {
+			continue
+		}
+		res[ref] = shaRef[0]
+	}
+	return res, nil
+}
+
+// Mirror is a locked handle on one repository in the cache. While it is open
+// the mirror will not be garbage collected.
+type Mirror struct {
+	cache    *Cache
+	fullName string
+	use      *fileLock
+}
+
+// Open creates the mirror if needed and fetches it if rev is not already a
+// commit in it. An empty rev always fetches.
+func (c *Cache) Open(ctx context.Context, fullName, rev string) (*Mirror, error) {
+	if strings.Count(fullName, "/") != 1 || strings.Contains(fullName, "..") {
+		return nil, fmt.Errorf("Repository name [%s] is not of the form org/repo", fullName)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path(fullName)), 0755); err != nil {
+		return nil, err
+	}
+	use, err := openLock(c.path(fullName) + ".use")
+	if err != nil {
+		return nil, err
+	}
+	if err = use.Shared(); err != nil {
+		use.Close()
+		return nil, err
+	}
+	now := time.Now()
+	os.Chtimes(use.file.Name(), now, now)
+	m := &Mirror{c, fullName, use}
+	if err = m.update(ctx, rev); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Mirror) Close() error {
+	return m.use.Close()
+}
+
+func (m *Mirror) Path() string {
+	return m.cache.path(m.fullName)
+}
+
+func (m *Mirror) git(ctx context.Context, args ...string) (string, error) {
+	return m.cache.git(ctx, m.fullName, args...)
+}
+
+// withUpdateLock serializes changes to a mirror's refs and worktree list.
+func (m *Mirror) withUpdateLock(f func() error) error {
+	lock, err := openLock(m.Path() + ".lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err = lock.Exclusive(); err != nil {
+		return err
+	}
+	return f()
+}
+
+func (m *Mirror) update(ctx context.Context, rev string) error {
+	return m.withUpdateLock(func() error {
+		exists, err := util.Exists(filepath.Join(m.Path(), "HEAD"))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err = m.create(ctx); err != nil {
+				os.RemoveAll(m.Path())
+				return err
+			}
+		} else if fullSha.MatchString(rev) {
+			if _, err = m.git(ctx, "cat-file", "-e", rev+"^{commit}"); err == nil {
+				return nil
+			}
+		}
+		_, err = m.git(ctx, "fetch", "--prune", "--quiet", "origin")
+		return err
+	})
+}
+
+func (m *Mirror) create(ctx context.Context) error {
+	cmdRet := util.RunCommandContext(ctx, "git", "init", "--bare", "--quiet", m.Path())
+	if cmdRet.IsError() {
+		return cmdRet.Error()
+	}
+	for _, args := range [][]string{
+		{"remote", "add", "origin", m.cache.remote(m.fullName)},
+		{"config", "--replace-all", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"},
+		{"config", "--add", "remote.origin.fetch", "+refs/tags/*:refs/tags/*"},
+		{"config", "gc.auto", "0"},
+	} {
+		if _, err := m.git(ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevParse returns the commit sha that rev names in the mirror.
+func (m *Mirror) RevParse(ctx context.Context, rev string) (string, error) {
+	out, err := m.git(ctx, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("Could not find [%s] in %s", rev, m.fullName)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// RefsAt returns the branches and tags that point at the sha, as refs/heads/x and refs/tags/y.
+func (m *Mirror) RefsAt(ctx context.Context, sha string) ([]string, error) {
+	out, err := m.git(ctx, "for-each-ref", "--points-at", sha, "--format=%(refname)", "refs/heads", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	return util.StringSliceTrimSpaceRemoveEmpty(strings.Split(out, "\n")), nil
+}
+
+// Tags returns the commit sha of every tag, keyed by refs/tags/name.
+func (m *Mirror) Tags(ctx context.Context) (map[string]string, error) {
+	out, err := m.git(ctx, "show-ref", "--tags", "-d")
+	if err != nil {
+		// show-ref fails when there are no tags at all
+		if _, e := m.git(ctx, "rev-parse", "--git-dir"); e == nil {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	res := map[string]string{}
+	for _, l := range strings.Split(out, "\n") {
+		if l == "" {
+			continue
+		}
+		shaRef := strings.Split(l, " ")
+		if len(shaRef) != 2 {
+			return nil, fmt.Errorf("Problem parsing this line [%s]", l)
+		}
+		// peeled lines follow their tag, so annotated tags end up at their commit
+		res[strings.TrimSuffix(shaRef[1], "^{}")] = shaRef[0]
+	}
+	return res, nil
+}
+
+// LogEntry is a commit and the time it was committed.
+type LogEntry struct {
+	Sha  string
+	Time time.Time
+}
+
+// Log lists the commits reachable from rev that were committed between since
+// and until, oldest first. Zero times leave that end of the range open.
+func (m *Mirror) Log(ctx context.Context, rev string, since, until time.Time, firstParent bool) ([]LogEntry, error) {
+	args := []string{"log", "--reverse", "--format=%H %cI"}
+	if firstParent {
+		args = append(args, "--first-parent")
+	}
+	if !since.IsZero() {
+		args = append(args, "--since="+since.Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		args = append(args, "--until="+until.Format(time.RFC3339))
+	}
+	out, err := m.git(ctx, append(args, rev, "--")...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(out)
+}
+
+// CommitTimes returns the commit time of each sha.
+func (m *Mirror) CommitTimes(ctx context.Context, shas ...string) (map[string]time.Time, error) {
+	res := map[string]time.Time{}
+	if len(shas) == 0 {
+		return res, nil
+	}
+	out, err := m.git(ctx, append([]string{"log", "--no-walk=unsorted", "--format=%H %cI"}, shas...)...)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseLog(out)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		res[e.Sha] = e.Time
+	}
+	return res, nil
+}
+
+func parseLog(out string) ([]LogEntry, error) {
+	res := []LogEntry{}
+	for _, l := range strings.Split(out, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Problem parsing this line [%s]", l)
+		}
+		t, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, LogEntry{parts[0], t})
+	}
+	return res, nil
+}
+
+// Commit reads the files of one commit straight from the mirror's objects,
+// without checking it out. The context it was made with bounds every read.
+type Commit struct {
+	Sha string
+
+	ctx    context.Context
+	mirror *Mirror
+}
+
+func (m *Mirror) Commit(ctx context.Context, rev string) (*Commit, error) {
+	sha, err := m.RevParse(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+	return &Commit{sha, ctx, m}, nil
+}
+
+// Files lists the path of every file in the commit's tree.
+func (c *Commit) Files() ([]string, error) {
+	out, err := c.mirror.git(c.ctx, "ls-tree", "-r", "-z", "--name-only", c.Sha)
+	if err != nil {
+		return nil, err
+	}
+	res := []string{}
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+// Sizes maps the path of every file in the commit's tree to its size in bytes.
+func (c *Commit) Sizes() (map[string]int64, error) {
+	out, err := c.mirror.git(c.ctx, "ls-tree", "-r", "-z", "-l", c.Sha)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]int64{}
+	for _, entry := range strings.Split(out, "\x00") {
+		parts := strings.SplitN(entry, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[0])
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		res[parts[1]] = size
+	}
+	return res, nil
+}
+
+// ReadFile returns the contents of the file at path in the commit. It can be used as a resolve.FileReader.
+func (c *Commit) ReadFile(path string) ([]byte, error) {
+	out, err := c.mirror.git(c.ctx, "cat-file", "blob", c.Sha+":"+strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read [%s] at %s", path, c.Sha)
+	}
+	return []byte(out), nil
+}
+
+// Worktree is a checkout of a single commit of a mirror.
+type Worktree struct {
+	Dir string
+	Sha string
+
+	mirror *Mirror
+}
+
+// Worktree checks rev out into a new folder. The worktree holds the mirror
+// open until it is removed.
+func (c *Cache) Worktree(ctx context.Context, fullName, rev string) (*Worktree, error) {
+	m, err := c.Open(ctx, fullName, rev)
+	if err != nil {
+		return nil, err
+	}
+	sha, err := m.RevParse(ctx, rev)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	parent, err := ioutil.TempDir(filepath.Join(c.root, "worktrees"), "wt")
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	dir := filepath.Join(parent, filepath.Base(fullName))
+	err = m.withUpdateLock(func() error {
+		_, err := m.git(ctx, "worktree", "add", "--detach", "--quiet", dir, sha)
+		return err
+	})
+	if err != nil {
+		os.RemoveAll(parent)
+		m.Close()
+		return nil, err
+	}
+	return &Worktree{dir, sha, m}, nil
+}
+
+func (w *Worktree) Mirror() *Mirror {
+	return w.mirror
+}
+
+// Remove deletes the worktree and releases the mirror.
+func (w *Worktree) Remove() error {
+	err := os.RemoveAll(filepath.Dir(w.Dir))
+	w.mirror.withUpdateLock(func() error {
+		_, err := w.mirror.git(context.Background(), "worktree", "prune")
+		return err
+	})
+	w.mirror.Close()
+	return err
+}
+
+type mirrorUsage struct {
+	fullName string
+	size     int64
+	lastUsed time.Time
+}
+
+// Collect removes the least recently used mirrors that are not open until the
+// cache fits in its budget. It returns the names of the removed repositories.
+func (c *Cache) Collect() ([]string, error) {
+	removed := []string{}
+	if c.budget <= 0 {
+		return removed, nil
+	}
+	usages, err := c.usages()
+	if err != nil {
+		return removed, err
+	}
+	var total int64
+	for _, usage := range usages {
+		total += usage.size
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].lastUsed.Before(usages[j].lastUsed) })
+	for _, usage := range usages {
+		if total <= c.budget {
+			break
+		}
+		ok, err := c.remove(usage.fullName)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			total -= usage.size
+			removed = append(removed, usage.fullName)
+		}
+	}
+	return removed, nil
+}
+
+func (c *Cache) remove(fullName string) (bool, error) {
+	use, err := openLock(c.path(fullName) + ".use")
+	if err != nil {
+		return false, err
+	}
+	defer use.Close()
+	if ok, err := use.TryExclusive(); !ok || err != nil {
+		return false, err
+	}
+	if err = os.RemoveAll(c.path(fullName)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Cache) usages() ([]*mirrorUsage, error) {
+	repos := filepath.Join(c.root, "repos")
+	orgs, err := ioutil.ReadDir(repos)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	res := []*mirrorUsage{}
+	for _, org := range orgs {
+		if !org.IsDir() {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(repos, org.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+				continue
+			}
+			usage := &mirrorUsage{fullName: org.Name() + "/" + strings.TrimSuffix(entry.Name(), ".git"), lastUsed: entry.ModTime()}
+			if info, err := os.Stat(c.path(usage.fullName) + ".use"); err == nil {
+				usage.lastUsed = info.ModTime()
+			}
+			filepath.Walk(filepath.Join(repos, org.Name(), entry.Name()), func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					usage.size += info.Size()
+				}
+				return nil
+			})
+			res = append(res, usage)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@test"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setup makes an upstream repository org/repo with two commits, tagging the first.
+func setup(t *testing.T) (string, *Cache, string, string) {
+	tmp, err := ioutil.TempDir("", "mirrortest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := filepath.Join(tmp, "upstream", "org", "repo")
+	if err = os.MkdirAll(upstream, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git(t, upstream, "init", "--quiet")
+	git(t, upstream, "checkout", "--quiet", "-b", "master")
+	ioutil.WriteFile(filepath.Join(upstream, "glide.yaml"), []byte("one"), 0644)
+	git(t, upstream, "add", ".")
+	git(t, upstream, "commit", "--quiet", "-m", "one")
+	first := git(t, upstream, "rev-parse", "HEAD")
+	git(t, upstream, "tag", "-a", "1.0.0", "-m", "1.0.0")
+	ioutil.WriteFile(filepath.Join(upstream, "glide.yaml"), []byte("two"), 0644)
+	git(t, upstream, "commit", "--quiet", "-am", "two")
+	second := git(t, upstream, "rev-parse", "HEAD")
+
+	cache, err := NewCache(filepath.Join(tmp, "cache"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetRemote(func(fullName string) string { return filepath.Join(tmp, "upstream", fullName) })
+	return tmp, cache, first, second
+}
+
+func TestWorktree(t *testing.T) {
+	tmp, cache, first, second := setup(t)
+	defer os.RemoveAll(tmp)
+	ctx := context.Background()
+
+	wt, err := cache.Worktree(ctx, "org/repo", "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wt.Sha != second {
+		t.Errorf("Expected %s Actual %s", second, wt.Sha)
+	}
+	if dat, _ := ioutil.ReadFile(filepath.Join(wt.Dir, "glide.yaml")); string(dat) != "two" {
+		t.Errorf("Unexpected contents %s", dat)
+	}
+
+	old, err := cache.Worktree(ctx, "org/repo", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dat, _ := ioutil.ReadFile(filepath.Join(old.Dir, "glide.yaml")); string(dat) != "one" {
+		t.Errorf("Unexpected contents %s", dat)
+	}
+	refs, err := old.Mirror().RefsAt(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0] != "refs/tags/1.0.0" {
+		t.Errorf("Unexpected refs %v", refs)
+	}
+	tags, err := old.Mirror().Tags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags["refs/tags/1.0.0"] != first {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+
+	if removed, err := cache.Collect(); err != nil || len(removed) != 0 {
+		t.Errorf("Collected a mirror in use: %v %v", removed, err)
+	}
+	wt.Remove()
+	old.Remove()
+	if _, err := os.Stat(wt.Dir); !os.IsNotExist(err) {
+		t.Errorf("Worktree was not removed")
+	}
+	if removed, err := cache.Collect(); err != nil || len(removed) != 1 || removed[0] != "org/repo" {
+		t.Errorf("Expected the mirror to be collected: %v %v", removed, err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	tmp, cache, first, second := setup(t)
+	defer os.RemoveAll(tmp)
+	ctx := context.Background()
+
+	m, err := cache.Open(ctx, "org/repo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	entries, err := m.Log(ctx, "refs/heads/master", time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Sha != first || entries[1].Sha != second {
+		t.Errorf("Unexpected log %v", entries)
+	}
+	if entries, err = m.Log(ctx, "refs/heads/master", time.Now().Add(time.Hour), time.Time{}, false); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no commits in the future: %v %v", entries, err)
+	}
+	times, err := m.CommitTimes(ctx, second, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 || times[first].IsZero() {
+		t.Errorf("Unexpected times %v", times)
+	}
+}
+
+func TestIncrementalFetch(t *testing.T) {
+	tmp, cache, _, second := setup(t)
+	defer os.RemoveAll(tmp)
+	ctx := context.Background()
+
+	m, err := cache.Open(ctx, "org/repo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	upstream := filepath.Join(tmp, "upstream", "org", "repo")
+	git(t, upstream, "checkout", "--quiet", "-b", "feature")
+	ioutil.WriteFile(filepath.Join(upstream, "glide.yaml"), []byte("three"), 0644)
+	git(t, upstream, "commit", "--quiet", "-am", "three")
+	third := git(t, upstream, "rev-parse", "HEAD")
+
+	if m, err = cache.Open(ctx, "org/repo", second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.RevParse(ctx, third); err == nil {
+		t.Errorf("A known sha should not have fetched")
+	}
+	m.Close()
+
+	if m, err = cache.Open(ctx, "org/repo", third); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if sha, err := m.RevParse(ctx, "refs/heads/feature"); err != nil || sha != third {
+		t.Errorf("Expected %s Actual %s %v", third, sha, err)
+	}
+}
+
+func TestLsRemote(t *testing.T) {
+	tmp, cache, first, second := setup(t)
+	defer os.RemoveAll(tmp)
+
+	refs, err := cache.LsRemote(context.Background(), "org/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"refs/heads/master": second, "refs/tags/1.0.0": first}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("Expected %v Actual %v", expected, refs)
+	}
+	if _, err := os.Stat(cache.path("org/repo")); !os.IsNotExist(err) {
+		t.Errorf("ls-remote created a mirror")
+	}
+}
+
+func TestCommitSizes(t *testing.T) {
+	tmp, cache, first, _ := setup(t)
+	defer os.RemoveAll(tmp)
+	ctx := context.Background()
+
+	m, err := cache.Open(ctx, "org/repo", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	commit, err := m.Commit(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes, err := commit.Sizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sizes, map[string]int64{"glide.yaml": 3}) {
+		t.Errorf("Unexpected sizes %v", sizes)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
+)
+
+// Checkout is a worktree of a repository checked out at a single commit.
+type Checkout struct {
+	Dir  string
+	Sha  string
+	Refs []string
+
+	worktree  *mirror.Worktree
+	tempCache string
+}
+
+// Remove deletes the worktree from disk.
+func (c *Checkout) Remove() error {
+	err := c.worktree.Remove()
+	if c.tempCache != "" {
+		os.RemoveAll(c.tempCache)
+	}
+	return err
+}
+
+// Clone checks out the sha or ref of github.com/fullName from the mirror cache.
+// If cache is nil a temporary one is made under workDir, or the system
+// temporary folder if workDir is empty, and is removed with the checkout.
+func Clone(ctx context.Context, cache *mirror.Cache, workDir, fullName, checkout string) (*Checkout, error) {
+	if len(strings.SplitN(fullName, "/", 2)) != 2 {
+		return nil, newError(StageClone, "", fmt.Errorf("Repository name [%s] is not of the form org/repo", fullName))
+	}
+	res := &Checkout{}
+	if cache == nil {
+		var err error
+		if cache, res.tempCache, err = tempCache(workDir); err != nil {
+			return nil, newError(StageClone, "", err)
+		}
+	}
+	fail := func(err error) (*Checkout, error) {
+		if res.worktree != nil {
+			res.worktree.Remove()
+		}
+		if res.tempCache != "" {
+			os.RemoveAll(res.tempCache)
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, newError(StageClone, "", err)
+	}
+	var err error
+	if res.worktree, err = cache.Worktree(ctx, fullName, checkout); err != nil {
+		return fail(err)
+	}
+	res.Dir = res.worktree.Dir
+	res.Sha = res.worktree.Sha
+	if res.Refs, err = res.worktree.Mirror().RefsAt(ctx, res.Sha); err != nil {
+		return fail(err)
+	}
+	return res, nil
+}
+
+func tempCache(workDir string) (*mirror.Cache, string, error) {
+	dir, err := ioutil.TempDir(workDir, "single")
+	if err != nil {
+		return nil, "", err
+	}
+	cache, err := mirror.NewCache(dir, 0)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return cache, dir, nil
+}
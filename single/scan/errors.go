@@ -0,0 +1,43 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package scan
+
+import "fmt"
+
+type Stage string
+
+const StageClone Stage = "clone"
+const StageFind Stage = "find"
+const StageResolve Stage = "resolve"
+const StageCheck Stage = "check"
+
+// Error is returned by every step of a scan so callers can tell which part failed.
+type Error struct {
+	Stage Stage
+	File  string
+	Err   error
+}
+
+func newError(stage Stage, file string, err error) *Error {
+	return &Error{stage, file, err}
+}
+
+func (e *Error) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s [%s]: %s", e.Stage, e.File, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Stage, e.Err)
+}
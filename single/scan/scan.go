@@ -0,0 +1,539 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	com "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/component"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	i "github.com/venicegeo/vzutil-versioning/common/issue"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
+	r "github.com/venicegeo/vzutil-versioning/single/resolve"
+	"github.com/venicegeo/vzutil-versioning/single/util"
+	"gopkg.in/yaml.v2"
+)
+
+type Request struct {
+	FullName    string
+	Checkout    string
+	Files       []string
+	All         bool
+	IncludeTest bool
+	WorkDir     string
+	Cache       *mirror.Cache
+
+	// ObjectRead reads the manifests straight out of the mirror instead of
+	// checking out a worktree. Scans of maven projects still check out,
+	// since mvn needs the files on disk.
+	ObjectRead bool
+
+	// Shas, when set, swaps dependency versions that are commit shas for the
+	// tag or override that names them.
+	Shas *shas.Resolver
+
+	// Checks, when set, are run against the whole repository and recorded on the scan.
+	Checks *repocheck.Rules
+
+	// IgnoreConfig skips the scan configuration committed in the repository.
+	IgnoreConfig bool
+}
+
+// ResolverVersion is recorded on every scan. Increase it whenever a change to
+// finding or resolving files would change the result of a scan, so that stored
+// scans can be redone.
+const ResolverVersion = 4
+
+var knownFiles = []string{"pom.xml", "glide.yaml", "package.json", "environment.yml", "requirements.txt", "meta.yaml"}
+var knownTestFiles = []string{"requirements-dev.txt", "environment-dev.yml"}
+
+var getFile = regexp.MustCompile(`^\/?(?:[^\/]+\/)*(.+)$`)
+
+var errNeedsCheckout = errors.New("This scan needs a checkout")
+
+// Run resolves the dependency files of the requested repository at a sha or ref.
+func Run(ctx context.Context, req *Request) (*com.DependencyScan, error) {
+	req, cleanup, err := withCache(req)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	timestamp := time.Now()
+	name := strings.SplitN(req.FullName, "/", 2)[1]
+	if req.ObjectRead {
+		if res, err := runObjects(ctx, req, name, timestamp); err != errNeedsCheckout {
+			return res, err
+		}
+	}
+	checkout, err := Clone(ctx, req.Cache, req.WorkDir, req.FullName, req.Checkout)
+	if err != nil {
+		return nil, err
+	}
+	defer checkout.Remove()
+	return scanDir(ctx, checkout.Dir, req, name, checkout.Sha, checkout.Refs, timestamp)
+}
+
+// RunLocal resolves the dependency files of a folder that is already on disk.
+func RunLocal(ctx context.Context, dir string, req *Request) (*com.DependencyScan, error) {
+	return scanDir(ctx, dir, req, "", "Local", []string{}, time.Now())
+}
+
+// ListFiles returns the dependency files found in the requested repository at a sha or ref.
+func ListFiles(ctx context.Context, req *Request) ([]string, error) {
+	req, cleanup, err := withCache(req)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if req.ObjectRead {
+		m, commit, err := openCommit(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		defer m.Close()
+		cfg, err := readConfig(commitFiles(commit), req)
+		if err != nil {
+			return nil, err
+		}
+		found, err := findInCommit(commit, req.IncludeTest)
+		return cfg.Files(found), err
+	}
+	checkout, err := Clone(ctx, req.Cache, req.WorkDir, req.FullName, req.Checkout)
+	if err != nil {
+		return nil, err
+	}
+	defer checkout.Remove()
+	cfg, err := readConfig(dirFiles(checkout.Dir), req)
+	if err != nil {
+		return nil, err
+	}
+	found, err := Find(ctx, checkout.Dir, req.IncludeTest)
+	return cfg.Files(found), err
+}
+
+// withCache gives a request without a cache a temporary one for the length of the call.
+func withCache(req *Request) (*Request, func(), error) {
+	if len(strings.SplitN(req.FullName, "/", 2)) != 2 {
+		return nil, nil, newError(StageClone, "", fmt.Errorf("Repository name [%s] is not of the form org/repo", req.FullName))
+	}
+	if req.Cache != nil {
+		return req, func() {}, nil
+	}
+	cache, dir, err := tempCache(req.WorkDir)
+	if err != nil {
+		return nil, nil, newError(StageClone, "", err)
+	}
+	cpy := *req
+	cpy.Cache = cache
+	return &cpy, func() { os.RemoveAll(dir) }, nil
+}
+
+func openCommit(ctx context.Context, req *Request) (*mirror.Mirror, *mirror.Commit, error) {
+	wrap := func(err error) error {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return newError(StageClone, "", err)
+	}
+	m, err := req.Cache.Open(ctx, req.FullName, req.Checkout)
+	if err != nil {
+		return nil, nil, wrap(err)
+	}
+	commit, err := m.Commit(ctx, req.Checkout)
+	if err != nil {
+		m.Close()
+		return nil, nil, wrap(err)
+	}
+	return m, commit, nil
+}
+
+func runObjects(ctx context.Context, req *Request, name string, timestamp time.Time) (*com.DependencyScan, error) {
+	m, commit, err := openCommit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	root := commitFiles(commit)
+	cfg, err := readConfig(root, req)
+	if err != nil {
+		return nil, err
+	}
+	files := req.Files
+	if req.All {
+		if files, err = findInCommit(commit, req.IncludeTest); err != nil {
+			return nil, err
+		}
+	}
+	// the file rules apply to the files asked for as well as the ones found
+	files = cfg.Files(files)
+	for _, f := range files {
+		if path.Base(f) == "pom.xml" {
+			return nil, errNeedsCheckout
+		}
+	}
+	refs, err := m.RefsAt(ctx, commit.Sha)
+	if err != nil {
+		return nil, newError(StageClone, "", err)
+	}
+	byFile, issues, err := resolveFiles(ctx, r.NewResolver(commit.ReadFile), "", files, req.IncludeTest)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readComponents(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	deps, components, issues := swapShas(ctx, req, merge(byFile), component.Group(byFile, overrides), issues)
+	res := newScan(req, cfg, name, commit.Sha, refs, deps, components, issues, files, timestamp)
+	if err = runChecks(req, commitTree{commit}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// commitTree is a commit as a repocheck.Tree.
+type commitTree struct {
+	*mirror.Commit
+}
+
+func (c commitTree) Files() (map[string]int64, error) {
+	return c.Sizes()
+}
+
+func findInCommit(commit *mirror.Commit, test bool) ([]string, error) {
+	tree, err := commit.Files()
+	if err != nil {
+		return nil, newError(StageFind, "", err)
+	}
+	return Filter(tree, test), nil
+}
+
+func scanDir(ctx context.Context, dir string, req *Request, name, sha string, refs []string, timestamp time.Time) (*com.DependencyScan, error) {
+	root := dirFiles(dir)
+	cfg, err := readConfig(root, req)
+	if err != nil {
+		return nil, err
+	}
+	files := req.Files
+	if req.All {
+		if files, err = Find(ctx, dir, req.IncludeTest); err != nil {
+			return nil, err
+		}
+	}
+	// the file rules apply to the files asked for as well as the ones found
+	files = cfg.Files(files)
+	byFile, issues, err := resolveFiles(ctx, r.NewResolver(ioutil.ReadFile), dir, files, req.IncludeTest)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readComponents(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	deps, components, issues := swapShas(ctx, req, merge(byFile), component.Group(byFile, overrides), issues)
+	res := newScan(req, cfg, name, sha, refs, deps, components, issues, files, timestamp)
+	if err = runChecks(req, repocheck.DirTree(dir), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func runChecks(req *Request, tree repocheck.Tree, res *com.DependencyScan) error {
+	if req.Checks == nil {
+		return nil
+	}
+	checks, err := repocheck.Check(tree, req.Checks)
+	if err != nil {
+		return newError(StageCheck, "", err)
+	}
+	res.Checks = checks
+	return nil
+}
+
+// rootFiles reads the files at the root of the repository being scanned.
+type rootFiles struct {
+	read   func(string) ([]byte, error)
+	exists func(string) bool
+}
+
+func dirFiles(dir string) rootFiles {
+	return rootFiles{
+		func(name string) ([]byte, error) { return ioutil.ReadFile(filepath.Join(dir, name)) },
+		func(name string) bool {
+			_, err := os.Stat(filepath.Join(dir, name))
+			return err == nil
+		},
+	}
+}
+
+func commitFiles(commit *mirror.Commit) rootFiles {
+	return rootFiles{commit.ReadFile, func(name string) bool {
+		tree, _ := commit.Files()
+		for _, f := range tree {
+			if f == name {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// readConfig reads the scan configuration at the root of the repository, when
+// it has one and the request does not ignore it.
+func readConfig(root rootFiles, req *Request) (*com.ScanConfig, error) {
+	if req.IgnoreConfig || !root.exists(com.ScanConfigFile) {
+		return nil, nil
+	}
+	dat, err := root.read(com.ScanConfigFile)
+	if err != nil {
+		return nil, newError(StageFind, com.ScanConfigFile, err)
+	}
+	cfg := new(com.ScanConfig)
+	if err = yaml.Unmarshal(dat, cfg); err != nil {
+		return nil, newError(StageFind, com.ScanConfigFile, err)
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, newError(StageFind, com.ScanConfigFile, err)
+	}
+	return cfg, nil
+}
+
+// readComponents returns the components named by the scan configuration, or
+// else by the component overrides at the root of the repository.
+func readComponents(root rootFiles, cfg *com.ScanConfig) (component.Overrides, error) {
+	if cfg != nil && len(cfg.Components) > 0 {
+		return cfg.Components, nil
+	}
+	if !root.exists(component.OverrideFile) {
+		return nil, nil
+	}
+	dat, err := root.read(component.OverrideFile)
+	if err != nil {
+		return nil, newError(StageFind, component.OverrideFile, err)
+	}
+	overrides := component.Overrides{}
+	if err = yaml.Unmarshal(dat, &overrides); err != nil {
+		return nil, newError(StageFind, component.OverrideFile, err)
+	}
+	return overrides, nil
+}
+
+// swapShas swaps the shas of the dependencies and of each component. Unknown
+// shas are reported once, from the dependencies.
+func swapShas(ctx context.Context, req *Request, deps d.Dependencies, components []component.Component, issues i.Issues) (d.Dependencies, []component.Component, i.Issues) {
+	if req.Shas == nil {
+		return deps, components, issues
+	}
+	deps, shaIssues := req.Shas.Swap(ctx, deps)
+	issues = append(issues, shaIssues...)
+	sort.Sort(deps)
+	sort.Sort(issues)
+	for c := range components {
+		swapped, _ := req.Shas.Swap(ctx, components[c].Deps)
+		sort.Sort(swapped)
+		components[c].Deps = swapped
+	}
+	return deps, components, issues
+}
+
+// RemoteTags looks up the tags of Go packages hosted on github with ls-remote,
+// without mirroring the packages.
+func RemoteTags(cache *mirror.Cache) shas.TagSource {
+	return func(ctx context.Context, name string) (map[string]string, error) {
+		fullName, ok := shas.GithubRepo(name)
+		if !ok {
+			return nil, fmt.Errorf("Package [%s] is not hosted on github", name)
+		}
+		refs, err := cache.LsRemote(ctx, fullName)
+		if err != nil {
+			return nil, err
+		}
+		tags := map[string]string{}
+		for ref, sha := range refs {
+			if strings.HasPrefix(ref, "refs/tags/") {
+				tags[strings.TrimPrefix(ref, "refs/tags/")] = sha
+			}
+		}
+		return tags, nil
+	}
+}
+
+// newScan records the results, after the scan configuration has set languages
+// and left out ignored dependencies and suppressed issues.
+func newScan(req *Request, cfg *com.ScanConfig, name, sha string, refs []string, deps d.Dependencies, components []component.Component, issues i.Issues, files []string, timestamp time.Time) *com.DependencyScan {
+	for c := range components {
+		components[c].Deps = cfg.Dependencies(components[c].Deps)
+	}
+	return &com.DependencyScan{
+		Fullname:  req.FullName,
+		Name:      name,
+		Sha:       sha,
+		Refs:      refs,
+		Deps:      cfg.Dependencies(deps),
+		Issues:    cfg.Issues(issues.SSlice()),
+		Files:     files,
+		Timestamp: timestamp,
+
+		ResolverVersion: ResolverVersion,
+		Components:      components,
+		Config:          cfg,
+	}
+}
+
+// Filter returns the paths, relative to the root of a repository, of the dependency files it knows how to resolve.
+func Filter(paths []string, test bool) []string {
+	res := []string{}
+	for _, p := range paths {
+		if p == "vendor" || strings.HasPrefix(p, "vendor/") || p == ".git" || strings.HasPrefix(p, ".git/") {
+			continue
+		}
+		if isKnownFile(path.Base(p), test) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// FindLocal returns the dependency files of a folder that is already on disk,
+// as its scan configuration includes them.
+func FindLocal(ctx context.Context, dir string, req *Request) ([]string, error) {
+	cfg, err := readConfig(dirFiles(dir), req)
+	if err != nil {
+		return nil, err
+	}
+	found, err := Find(ctx, dir, req.IncludeTest)
+	return cfg.Files(found), err
+}
+
+// Find walks dir and returns the paths, relative to dir, of the dependency files it knows how to resolve.
+func Find(ctx context.Context, dir string, test bool) ([]string, error) {
+	dir = strings.TrimSuffix(dir, "/")
+	fileLocations := []string{}
+	visit := func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return nil
+		}
+		if util.IsVendorPath(path, dir) || util.IsDotGitPath(path, dir) {
+			return nil
+		}
+		if isKnownFile(f.Name(), test) {
+			fileLocations = append(fileLocations, path)
+		}
+		return nil
+	}
+	if err := filepath.Walk(dir, visit); err != nil {
+		return nil, newError(StageFind, "", err)
+	}
+	for i, f := range fileLocations {
+		fileLocations[i] = strings.TrimPrefix(strings.TrimPrefix(f, dir), "/")
+	}
+	return fileLocations, nil
+}
+
+func isKnownFile(name string, test bool) bool {
+	for _, k := range knownFiles {
+		if k == name {
+			return true
+		}
+	}
+	if test {
+		for _, k := range knownTestFiles {
+			if k == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fileToFunc(resolver *r.Resolver) map[string]func(string, bool) (d.Dependencies, i.Issues, error) {
+	return map[string]func(string, bool) (d.Dependencies, i.Issues, error){
+		"glide.yaml":           resolver.ResolveGlideYaml,
+		"package.json":         resolver.ResolvePackageJson,
+		"environment.yml":      resolver.ResolveEnvironmentYml,
+		"environment-dev.yml":  resolver.ResolveEnvironmentYml,
+		"requirements.txt":     resolver.ResolveRequirementsTxt,
+		"requirements-dev.txt": resolver.ResolveRequirementsTxt,
+		"meta.yaml":            resolver.ResolveMetaYaml,
+		"pom.xml":              resolver.ResolvePomXml,
+	}
+}
+
+// Resolve reads each of the files, relative to dir, with the resolver and merges their dependencies.
+func Resolve(ctx context.Context, resolver *r.Resolver, dir string, files []string, test bool) (d.Dependencies, i.Issues, error) {
+	byFile, issues, err := resolveFiles(ctx, resolver, dir, files, test)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merge(byFile), issues, nil
+}
+
+// resolveFiles reads each of the files, relative to dir, with the resolver.
+func resolveFiles(ctx context.Context, resolver *r.Resolver, dir string, files []string, test bool) (map[string]d.Dependencies, i.Issues, error) {
+	funcs := fileToFunc(resolver)
+	byFile := map[string]d.Dependencies{}
+	var issues i.Issues
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, newError(StageResolve, f, err)
+		}
+		matches := getFile.FindStringSubmatch(f)
+		if len(matches) != 2 {
+			return nil, nil, newError(StageResolve, f, fmt.Errorf("File could not be parsed"))
+		}
+		funcc, ok := funcs[matches[1]]
+		if !ok {
+			return nil, nil, newError(StageResolve, f, fmt.Errorf("Could not scan file"))
+		}
+		d, i, e := funcc(filepath.Join(dir, f), test)
+		if e != nil {
+			return nil, nil, newError(StageResolve, f, e)
+		}
+		byFile[f] = append(byFile[f], d...)
+		issues = append(issues, i...)
+	}
+	return byFile, issues, nil
+}
+
+// merge puts the dependencies of every file into one sorted list.
+func merge(byFile map[string]d.Dependencies) d.Dependencies {
+	var deps d.Dependencies
+	for _, fileDeps := range byFile {
+		deps = append(deps, fileDeps...)
+	}
+	d.RemoveExactDuplicates(&deps)
+	sort.Sort(deps)
+	return deps
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2018, RadiantBlue Technologies, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package scan
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
+	r "github.com/venicegeo/vzutil-versioning/single/resolve"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "scantest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFind(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"glide.yaml":                    "",
+		"ui/package.json":               "",
+		"requirements-dev.txt":          "",
+		"vendor/a/glide.yaml":           "",
+		".git/package.json":             "",
+		"docs/README.md":                "",
+		"python/nested/environment.yml": "",
+	})
+	defer os.RemoveAll(dir)
+
+	actual, err := Find(context.Background(), dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"glide.yaml", "python/nested/environment.yml", "requirements-dev.txt", "ui/package.json"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %#v Actual: %#v", expected, actual)
+	}
+
+	if actual, err = Find(context.Background(), dir, false); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"glide.yaml", "python/nested/environment.yml", "ui/package.json"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %#v Actual: %#v", expected, actual)
+	}
+}
+
+func TestFindCancelled(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"glide.yaml": ""})
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Find(ctx, dir, true)
+	if e, ok := err.(*Error); !ok || e.Stage != StageFind || e.Err != context.Canceled {
+		t.Errorf("Expected a cancelled find error, got %#v", err)
+	}
+}
+
+func TestResolveUnknownFile(t *testing.T) {
+	_, _, err := Resolve(context.Background(), r.NewResolver(ioutil.ReadFile), "", []string{"docs/README.md"}, true)
+	if e, ok := err.(*Error); !ok || e.Stage != StageResolve || e.File != "docs/README.md" {
+		t.Errorf("Expected an unknown file error, got %#v", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	actual := Filter([]string{"glide.yaml", "vendor/x/glide.yaml", "ui/package.json", "requirements-dev.txt", "vendorless/pom.xml", "README.md"}, false)
+	expected := []string{"glide.yaml", "ui/package.json", "vendorless/pom.xml"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %#v Actual: %#v", expected, actual)
+	}
+}
+
+func TestRunObjectRead(t *testing.T) {
+	upstream := writeFiles(t, map[string]string{
+		"org/repo/ui/package.json": `{"dependencies":{"left-pad":"1.1.0"}}`,
+		"org/repo/README.md":       "",
+	})
+	defer os.RemoveAll(upstream)
+	repo := filepath.Join(upstream, "org", "repo")
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "."}, {"commit", "--quiet", "-m", "one"}} {
+		if out, err := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@test"}, args...)...).CombinedOutput(); err != nil {
+			t.Fatal(err, string(out))
+		}
+	}
+	cacheDir, err := ioutil.TempDir("", "scancache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+	cache, err := mirror.NewCache(cacheDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetRemote(func(fullName string) string { return filepath.Join(upstream, fullName) })
+
+	res, err := Run(context.Background(), &Request{FullName: "org/repo", Checkout: "HEAD", All: true, Cache: cache, ObjectRead: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Files, []string{"ui/package.json"}) {
+		t.Errorf("Unexpected files %#v", res.Files)
+	}
+	if len(res.Deps) != 1 || res.Deps[0].Name != "left-pad" || res.Deps[0].Version != "1.1.0" {
+		t.Errorf("Unexpected dependencies %#v", res.Deps)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Join(cacheDir, "worktrees")); len(entries) != 0 {
+		t.Errorf("A worktree was checked out")
+	}
+}
+
+func TestRunSwapsShas(t *testing.T) {
+	upstream := writeFiles(t, map[string]string{
+		"org/lib/lib.go":     "package lib",
+		"org/app/glide.yaml": "package: github.com/org/app\nimport:\n- package: github.com/org/lib\n",
+		"org/app/glide.lock": "imports:\n- name: github.com/org/lib\n  version: LIBSHA\n",
+	})
+	defer os.RemoveAll(upstream)
+	git := func(repo string, args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", filepath.Join(upstream, repo), "-c", "user.name=test", "-c", "user.email=test@test"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatal(err, string(out))
+		}
+		return strings.TrimSpace(string(out))
+	}
+	for _, repo := range []string{"org/lib", "org/app"} {
+		git(repo, "init", "--quiet")
+	}
+	git("org/lib", "add", ".")
+	git("org/lib", "commit", "--quiet", "-m", "one")
+	git("org/lib", "tag", "v1.0.0")
+	libSha := git("org/lib", "rev-parse", "HEAD")
+	lock := filepath.Join(upstream, "org/app/glide.lock")
+	dat, _ := ioutil.ReadFile(lock)
+	ioutil.WriteFile(lock, []byte(strings.Replace(string(dat), "LIBSHA", libSha, 1)), 0644)
+	git("org/app", "add", ".")
+	git("org/app", "commit", "--quiet", "-m", "one")
+
+	cacheDir, err := ioutil.TempDir("", "scancache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+	cache, err := mirror.NewCache(cacheDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetRemote(func(fullName string) string { return filepath.Join(upstream, fullName) })
+
+	res, err := Run(context.Background(), &Request{FullName: "org/app", Checkout: "HEAD", All: true, Cache: cache, ObjectRead: true, Shas: shas.NewResolver(RemoteTags(cache), nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Deps) != 1 || res.Deps[0].Version != "v1.0.0" || res.Deps[0].Sha != libSha {
+		t.Errorf("Unexpected dependencies %#v", res.Deps)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "repos", "org", "lib.git")); !os.IsNotExist(err) {
+		t.Errorf("Looking up tags mirrored the dependency")
+	}
+}
+
+func TestRunLocalComponents(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"services/api/package.json":    `{"dependencies":{"express":"4.16.2"}}`,
+		"services/worker/package.json": `{"dependencies":{"left-pad":"1.1.0"}}`,
+		"ui/package.json":              `{"dependencies":{"left-pad":"1.1.0"}}`,
+		".components.yml":              "backend:\n- services\n",
+	})
+	defer os.RemoveAll(dir)
+	res, err := RunLocal(context.Background(), dir, &Request{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Components) != 2 || res.Components[0].Name != "backend" || len(res.Components[0].Deps) != 2 ||
+		res.Components[1].Name != "ui" || len(res.Components[1].Deps) != 1 {
+		t.Errorf("Unexpected components %#v", res.Components)
+	}
+	if len(res.Deps) != 2 {
+		t.Errorf("Unexpected dependencies %#v", res.Deps)
+	}
+}
+
+func TestRunLocalConfig(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"api/package.json":    `{"dependencies":{"express":"4.16.2","left-pad":"1.1.0"}}`,
+		"legacy/package.json": `{"dependencies":{"jquery":"1.0.0"}}`,
+		".vzutil.yml":         "exclude:\n- legacy/\nignore:\n- left-pad\ncomponents:\n  api:\n  - api\n",
+	})
+	defer os.RemoveAll(dir)
+	res, err := RunLocal(context.Background(), dir, &Request{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Files, []string{"api/package.json"}) || len(res.Deps) != 1 || res.Deps[0].Name != "express" {
+		t.Errorf("Unexpected scan %#v", res)
+	}
+	if len(res.Components) != 1 || res.Components[0].Name != "api" || len(res.Components[0].Deps) != 1 {
+		t.Errorf("Unexpected components %#v", res.Components)
+	}
+	if res.Config == nil || !reflect.DeepEqual(res.Config.Excluded, []string{"legacy/package.json"}) || len(res.Config.Ignored) != 1 {
+		t.Errorf("Unexpected config %#v", res.Config)
+	}
+
+	res, err = RunLocal(context.Background(), dir, &Request{Files: []string{"api/package.json", "legacy/package.json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Files, []string{"api/package.json"}) || !reflect.DeepEqual(res.Config.Excluded, []string{"legacy/package.json"}) {
+		t.Errorf("The config was not applied to the files asked for %#v", res)
+	}
+
+	res, err = RunLocal(context.Background(), dir, &Request{All: true, IgnoreConfig: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Config != nil || len(res.Files) != 2 {
+		t.Errorf("The config was not ignored %#v", res)
+	}
+}
@@ -17,6 +17,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -48,7 +49,11 @@ func Exists(path string) (bool, error) {
 }
 
 func RunCommand(name string, arg ...string) CmdRet {
-	cmd := exec.Command(name, arg...)
+	return RunCommandContext(context.Background(), name, arg...)
+}
+
+func RunCommandContext(ctx context.Context, name string, arg ...string) CmdRet {
+	cmd := exec.CommandContext(ctx, name, arg...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout, cmd.Stderr = &stdout, &stderr
 	err := cmd.Run()
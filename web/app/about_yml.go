@@ -0,0 +1,118 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/common/about"
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+	"gopkg.in/yaml.v2"
+)
+
+const aboutTimeout = time.Minute * 2
+
+// AboutCheck is the committed about yaml of a repository checked against the
+// repository's latest scan on a ref, with the about yaml that scan would give.
+type AboutCheck struct {
+	Repo       string
+	Sha        string
+	Exists     bool
+	Validation *about.Validation
+	Generated  []byte
+	// Patch turns the committed about yaml into the generated one, empty when they match.
+	Patch string
+	Error string
+}
+
+func (a *Application) checkAbouts(project *Project, ref string) ([]*AboutCheck, error) {
+	ref = trimRef(ref)
+	repos, err := project.GetAllRepositories()
+	if err != nil {
+		return nil, err
+	}
+	scans, err := project.ScansByRefInProject(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), aboutTimeout)
+	defer cancel()
+
+	res := make([]*AboutCheck, len(repos))
+	wg := sync.WaitGroup{}
+	for i, repo := range repos {
+		check := &AboutCheck{Repo: repo.Fullname}
+		res[i] = check
+		scan, ok := scans[repo.Fullname]
+		if !ok || scan.Scan == nil {
+			check.Error = u.Format("No scan on %s", ref)
+			continue
+		}
+		wg.Add(1)
+		go func(scan *types.Scan) {
+			defer wg.Done()
+			if err := a.checkAbout(ctx, check, scan); err != nil {
+				check.Error = err.Error()
+			}
+		}(scan)
+	}
+	wg.Wait()
+	sort.Slice(res, func(i, j int) bool { return res[i].Repo < res[j].Repo })
+	return res, nil
+}
+
+func (a *Application) checkAbout(ctx context.Context, check *AboutCheck, scan *types.Scan) error {
+	check.Sha = scan.Scan.Sha
+	m, err := a.mirrors.Open(ctx, scan.Scan.Fullname, scan.Scan.Sha)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	commit, err := m.Commit(ctx, scan.Scan.Sha)
+	if err != nil {
+		return err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+	var committed []byte
+	for _, f := range files {
+		if f == about.FileName {
+			check.Exists = true
+			if committed, err = commit.ReadFile(f); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	parsed, err := about.Parse(committed, yaml.Unmarshal)
+	if err != nil {
+		return u.Error("Unable to read %s: %s", about.FileName, err.Error())
+	}
+	scanned := make(dependency.GenericDependencies, len(scan.Scan.Deps))
+	for i, dep := range scan.Scan.Deps {
+		scanned[i] = dependency.NewGenericDependencyFrom(dep, "")
+	}
+	check.Validation = parsed.Validate(scanned)
+	check.Generated = parsed.Generate(scanned)
+	check.Patch = about.Patch(about.FileName, committed, check.Generated)
+	return nil
+}
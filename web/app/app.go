@@ -17,59 +17,57 @@ package app
 import (
 	"crypto/sha512"
 	"errors"
+	"html"
+	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/venicegeo/pz-gocommon/elasticsearch"
-	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	"github.com/venicegeo/vzutil-versioning/common/repocheck"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
+	"github.com/venicegeo/vzutil-versioning/web/notify"
+	"github.com/venicegeo/vzutil-versioning/web/store"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
 
 type Application struct {
-	singleLocation   string
-	compareLocation  string
 	templateLocation string
 	debugMode        bool
 
 	server *u.Server
 
-	wrkr     *Worker
-	rtrvr    *Retriever
-	diffMan  *DifferenceManager
-	ff       *FireAndForget
-	cmprRnnr *CompareRunner
+	wrkr      *Worker
+	jobs      *JobQueue
+	backfills *BackfillManager
+	scheduler *Scheduler
+	rtrvr     *Retriever
+	diffMan   *DifferenceManager
+	ff        *FireAndForget
+	cmprRnnr  *CompareRunner
 
 	killChan chan bool
 
-	index elasticsearch.IIndex
+	store    store.Store
+	mirrors  *mirror.Cache
+	notifier *notify.Notifier
+	checks   *repocheck.Rules
+	// shaTags looks up the tags of Go dependencies pinned to shas, from
+	// VZUTIL_SHA_TAGS. Project sha overrides apply either way.
+	shaTags bool
 }
 
-const ESMapping = `
-{
-	"mappings": {
-		"` + RepositoryEntryType + `": ` + types.ScanMapping + `,
-		"` + DifferenceType + `": ` + DifferenceMapping + `,
-		"` + RepositoryType + `": ` + types.RepositoryMapping + `,
-		"` + ProjectType + `": ` + types.ProjectMapping + `
-	}
-}`
-const RepositoryEntryType = `repository_entry`
-const DifferenceType = `difference`
-const RepositoryType = `repository`
-const ProjectType = `project`
-
 type Back struct {
 	BackButton string `form:"button_back"`
 }
 
-func NewApplication(index elasticsearch.IIndex, singleLocation, compareLocation, templateLocation string, debugMode bool) *Application {
+func NewApplication(st store.Store, templateLocation string, debugMode bool) *Application {
 	return &Application{
-		index:            index,
-		singleLocation:   singleLocation,
-		compareLocation:  compareLocation,
+		store:            st,
 		templateLocation: templateLocation,
 		debugMode:        debugMode,
 		killChan:         make(chan bool),
@@ -83,13 +81,31 @@ func (a *Application) StartInternals() {
 		log.Fatalln(err)
 	}
 
+	if err := a.startMirrors(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := a.loadChecks(); err != nil {
+		log.Fatalln(err)
+	}
+
 	a.diffMan = NewDifferenceManager(a)
+	a.jobs = NewJobQueue(a)
+	a.backfills = NewBackfillManager(a)
+	a.scheduler = NewScheduler(a)
 	a.wrkr = NewWorker(a, 2)
 	a.rtrvr = NewRetriever(a)
 	a.ff = NewFireAndForget(a)
 	a.cmprRnnr = NewCompareRunner(a)
+	a.startNotifier()
 
+	if err := a.jobs.Recover(); err != nil {
+		log.Fatalln(err)
+	}
 	a.wrkr.Start()
+	if err := a.backfills.Recover(); err != nil {
+		log.Fatalln(err)
+	}
+	a.scheduler.Start()
 
 	a.server = u.NewServer()
 	if _, err := os.Stat("localhost.crt"); err == nil {
@@ -114,13 +130,32 @@ func (a *Application) StartInternals() {
 		u.RouteData{"POST", "/addrepo/:proj", a.addRepoToProject, true},
 		u.RouteData{"GET", "/genbranch/:proj/:org/:repo", a.generateBranch, true},
 		u.RouteData{"GET", "/reportref/:proj", a.reportRefOnProject, true},
+		u.RouteData{"GET", "/refdiff/:proj", a.compareRefsInProject, true},
+		u.RouteData{"GET", "/asof/:proj", a.reportAsOf, true},
+		u.RouteData{"GET", "/about/:proj", a.aboutYmlPage, true},
+		u.RouteData{"GET", "/approved/:proj", a.approvedListPage, true},
+		u.RouteData{"POST", "/approved/:proj", a.approvedListPage, true},
+		u.RouteData{"GET", "/depgraph/:proj", a.dependencyGraph, true},
+		u.RouteData{"GET", "/shas/:proj", a.shaOverrides, true},
+		u.RouteData{"GET", "/notify/:proj", a.subscriptionsPage, true},
+		u.RouteData{"POST", "/notify/:proj", a.subscriptionsPage, true},
+		u.RouteData{"GET", "/schedules/:proj", a.schedulesPage, true},
+		u.RouteData{"POST", "/schedules/:proj", a.schedulesPage, true},
+		u.RouteData{"POST", "/shas/:proj", a.shaOverrides, true},
 		u.RouteData{"GET", "/removerepo/:proj", a.removeReposFromProject, true},
 		u.RouteData{"GET", "/depsearch/:proj", a.searchForDepInProject, true},
 		u.RouteData{"GET", "/depsearch", a.searchForDep, true},
 		u.RouteData{"GET", "/diff/:proj", a.differencesInProject, true},
+		u.RouteData{"GET", "/scans/:proj/:org/:repo", a.scansPage, true},
+		u.RouteData{"GET", "/api/scans/:proj/:org/:repo", a.scansApi, true},
 		u.RouteData{"GET", "/reportsha", a.reportSha, true},
 		u.RouteData{"GET", "/cdiff", a.customDiff, true},
 		u.RouteData{"POST", "/cdiff", a.customDiff, true},
+		u.RouteData{"GET", "/jobs", a.jobsPage, true},
+		u.RouteData{"GET", "/api/jobs", a.jobsApi, true},
+		u.RouteData{"GET", "/backfill/:proj", a.backfillProject, true},
+		u.RouteData{"POST", "/backfill/:proj", a.backfillProject, true},
+		u.RouteData{"GET", "/api/backfill/:id", a.backfillApi, true},
 	})
 }
 
@@ -176,6 +211,69 @@ func (a *Application) checkForRedirect(c *gin.Context) bool {
 	return c.Request.Header.Get("Referer") != ""
 }
 
+// pageLink links to the page of a path after the cursor.
+func pageLink(path string, params url.Values, cursor, text string) string {
+	params.Set("after", cursor)
+	return u.Format(`<a href="%s">%s</a>`, html.EscapeString(path+"?"+params.Encode()), html.EscapeString(text))
+}
+
+// startMirrors opens the repository mirror cache, configured by VZUTIL_MIRROR_DIR
+// and VZUTIL_MIRROR_BUDGET_MB, and trims it periodically. VZUTIL_SHA_TAGS turns
+// on asking the remotes of Go dependencies pinned to shas for their tags.
+func (a *Application) startMirrors() error {
+	if str := os.Getenv("VZUTIL_SHA_TAGS"); str != "" {
+		on, err := strconv.ParseBool(str)
+		if err != nil {
+			return u.Error("Invalid VZUTIL_SHA_TAGS: %s", err.Error())
+		}
+		a.shaTags = on
+	}
+	dir := os.Getenv("VZUTIL_MIRROR_DIR")
+	if dir == "" {
+		dir = "mirrors"
+	}
+	var budget int64
+	if str := os.Getenv("VZUTIL_MIRROR_BUDGET_MB"); str != "" {
+		mb, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return u.Error("Invalid VZUTIL_MIRROR_BUDGET_MB: %s", err.Error())
+		}
+		budget = mb * 1024 * 1024
+	}
+	var err error
+	if a.mirrors, err = mirror.NewCache(dir, budget); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(time.Minute * 10) {
+			if removed, err := a.mirrors.Collect(); err != nil {
+				log.Println("[MIRRORS] Unable to collect:", err.Error())
+			} else if len(removed) > 0 {
+				log.Println("[MIRRORS] Removed", removed)
+			}
+		}
+	}()
+	return nil
+}
+
+// loadChecks reads the repository check rules run on every scan from the JSON
+// file named by VZUTIL_REPOCHECK_RULES, or uses the default rules.
+func (a *Application) loadChecks() error {
+	file := os.Getenv("VZUTIL_REPOCHECK_RULES")
+	if file == "" {
+		a.checks = repocheck.Default()
+		return nil
+	}
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if a.checks, err = repocheck.Load(dat); err != nil {
+		return u.Error("Invalid VZUTIL_REPOCHECK_RULES: %s", err.Error())
+	}
+	return nil
+}
+
 func (a *Application) handleMaven() error {
 	_, err := os.Stat("settings.xml")
 	if err != nil {
@@ -0,0 +1,169 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const backfillDateFormat = "2006-01-02"
+
+func (a *Application) backfillProject(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back       string `form:"button_back"`
+		Submit     string `form:"button_submit"`
+		Repo       string `form:"repo"`
+		Ref        string `form:"ref"`
+		Since      string `form:"since"`
+		Until      string `form:"until"`
+		Stride     string `form:"stride"`
+		Nth        string `form:"nth"`
+		TagPattern string `form:"tag_pattern"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	h := gin.H{"result": ""}
+	if form.Submit != "" {
+		req := &BackfillRequest{
+			ProjectId:    projId,
+			RepoFullname: form.Repo,
+			Ref:          strings.TrimSpace(form.Ref),
+			Stride:       types.BackfillStride(form.Stride),
+			TagPattern:   strings.TrimSpace(form.TagPattern),
+		}
+		if req.Since, err = parseBackfillDate(form.Since, false); err == nil {
+			req.Until, err = parseBackfillDate(form.Until, true)
+		}
+		if err == nil && form.Nth != "" {
+			req.Nth, err = strconv.Atoi(form.Nth)
+		}
+		if err != nil {
+			h["result"] = u.Format("Unable to read the form: %s", err.Error())
+		} else if bf, err := a.backfills.Start(req); err != nil {
+			h["result"] = u.Format("Unable to start the backfill: %s", err.Error())
+		} else {
+			h["result"] = u.Format("Started backfill %s", bf.Id)
+		}
+	}
+	repos, err := project.GetAllRepositories()
+	if err != nil {
+		c.String(500, "Unable to retrieve repository list: %s", err.Error())
+		return
+	}
+	options := `<option value="">All repositories</option>`
+	for _, repo := range repos {
+		options += u.Format(`<option value="%s">%s</option>`, html.EscapeString(repo.Fullname), html.EscapeString(repo.Fullname))
+	}
+	h["repos"] = s.NewHtmlString(options).Template()
+
+	backfills, err := a.backfills.ForProject(projId)
+	if err != nil {
+		c.String(500, "Unable to retrieve backfills: %s", err.Error())
+		return
+	}
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Started", "Repository", "Selection", "Status", "Progress", "Differences", "Notes"} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	for i, bf := range backfills {
+		progress := ""
+		if p, err := a.backfills.Progress(bf); err != nil {
+			progress = err.Error()
+		} else {
+			progress = u.Format("%d/%d scanned, %d running, %d failed", p.Succeeded, p.Total, p.Running, p.Failed)
+		}
+		repo := bf.RepoFullname
+		if repo == "" {
+			repo = "All"
+		}
+		table.AddRow()
+		for _, item := range []string{bf.Created.Format(time.RFC3339), repo, describeBackfill(bf), string(bf.Status), progress, strconv.Itoa(bf.Diffs), bf.Error} {
+			table.AddItem(i+1, s.NewHtmlString(html.EscapeString(item)))
+		}
+	}
+	h["backfills"] = table.Template()
+	c.HTML(200, "backfill.html", h)
+}
+
+func (a *Application) backfillApi(c *gin.Context) {
+	bf, err := a.backfills.Get(c.Param("id"))
+	if err != nil {
+		c.String(404, "Unable to get this backfill: %s", err.Error())
+		return
+	}
+	progress, err := a.backfills.Progress(bf)
+	if err != nil {
+		c.String(500, "Unable to get the progress: %s", err.Error())
+		return
+	}
+	c.JSON(200, gin.H{"backfill": bf, "progress": progress})
+}
+
+func parseBackfillDate(str string, endOfDay bool) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(backfillDateFormat, str)
+	if err != nil {
+		return t, u.Error("Dates must look like %s", backfillDateFormat)
+	}
+	if endOfDay {
+		t = t.Add(time.Hour*24 - time.Second)
+	}
+	return t, nil
+}
+
+func describeBackfill(bf *types.Backfill) string {
+	var res string
+	switch bf.Stride {
+	case types.StrideTags:
+		res = u.Format("tags matching %s", bf.TagPattern)
+	case types.StrideNth:
+		res = u.Format("every %d commits on %s", bf.Nth, bf.Ref)
+	case types.StrideFirstParent:
+		res = u.Format("first parents on %s", bf.Ref)
+	default:
+		res = u.Format("every commit on %s", bf.Ref)
+	}
+	if !bf.Since.IsZero() {
+		res += " from " + bf.Since.Format(backfillDateFormat)
+	}
+	if !bf.Until.IsZero() {
+		res += " until " + bf.Until.Format(backfillDateFormat)
+	}
+	return res
+}
@@ -0,0 +1,120 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+type jobsSummary struct {
+	Queued    int          `json:"queued"`
+	Running   int          `json:"running"`
+	Succeeded int64        `json:"succeeded"`
+	Failed    int64        `json:"failed"`
+	Active    []*types.Job `json:"active"`
+	History   []*types.Job `json:"history"`
+	Next      string       `json:"next"`
+}
+
+func (a *Application) jobsSummary(status, cursor string, size int) (*jobsSummary, error) {
+	var err error
+	res := &jobsSummary{Active: a.jobs.Active()}
+	res.Queued, res.Running = a.jobs.Depth()
+	if res.Succeeded, err = a.jobs.CountFinished(types.JobSucceeded); err != nil {
+		return nil, err
+	}
+	if res.Failed, err = a.jobs.CountFinished(types.JobFailed); err != nil {
+		return nil, err
+	}
+	if res.History, res.Next, err = a.jobs.History(status, cursor, size); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *Application) jobsApi(c *gin.Context) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", "50"))
+	if err != nil || size <= 0 {
+		c.String(400, "Invalid size")
+		return
+	}
+	summary, err := a.jobsSummary(c.Query("status"), c.Query("after"), size)
+	if err != nil {
+		c.String(500, "Unable to collect jobs: %s", err.Error())
+		return
+	}
+	c.JSON(200, summary)
+}
+
+func (a *Application) jobsPage(c *gin.Context) {
+	var form struct {
+		Back   string `form:"button_back"`
+		Status string `form:"status"`
+		After  string `form:"after"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/ui")
+		return
+	}
+	summary, err := a.jobsSummary(form.Status, form.After, 100)
+	if err != nil {
+		c.String(500, "Unable to collect jobs: %s", err.Error())
+		return
+	}
+	h := gin.H{}
+	h["depth"] = u.Format("Queued: %d    Running: %d    Succeeded: %d    Failed: %d", summary.Queued, summary.Running, summary.Succeeded, summary.Failed)
+	h["active"] = jobsTable(summary.Active).Template()
+	h["history"] = jobsTable(summary.History).Template()
+	if summary.Next != "" {
+		h["next"] = s.NewHtmlString(pageLink("/jobs", url.Values{"status": {form.Status}}, summary.Next, "Older jobs")).Template()
+	}
+	c.HTML(200, "jobs.html", h)
+}
+
+func jobsTable(jobs []*types.Job) *s.HtmlTable {
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Status", "Project", "Repository", "Sha", "Refs", "Attempts", "Updated", "Next Attempt", "Error"} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	for i, job := range jobs {
+		next := ""
+		if job.Status == types.JobQueued {
+			next = job.NextAttempt.Format(time.RFC3339)
+		}
+		project := job.ProjectId
+		if job.Transient {
+			project = "(ad hoc)"
+		}
+		table.AddRow()
+		for _, item := range []string{string(job.Status), project, job.RepoFullname, job.Sha, u.Format("%v", job.Refs),
+			u.Format("%d/%d", job.Attempts, job.MaxAttempts), job.Updated.Format(time.RFC3339), next, job.Error} {
+			table.AddItem(i+1, s.NewHtmlString(html.EscapeString(item)))
+		}
+	}
+	return table
+}
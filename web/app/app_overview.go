@@ -15,14 +15,12 @@
 package app
 
 import (
-	"encoding/json"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	p "github.com/venicegeo/pz-gocommon/gocommon"
-	"github.com/venicegeo/vzutil-versioning/common/table"
+	t "github.com/venicegeo/vzutil-versioning/common/table"
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
-	"github.com/venicegeo/vzutil-versioning/web/es"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
@@ -46,6 +44,9 @@ func (a *Application) projectsOverview(c *gin.Context) {
 	case "Custom Compare":
 		c.Redirect(303, "/cdiff")
 		return
+	case "Jobs":
+		c.Redirect(303, "/jobs")
+		return
 	}
 	if form.ProjectId == "" {
 		table := s.NewHtmlTable()
@@ -57,7 +58,7 @@ func (a *Application) projectsOverview(c *gin.Context) {
 			c.String(500, "Error collecting projects: %s", err.Error())
 			return
 		}
-		projs = append(projs, &Project{a.index, &types.Project{DisplayName: "Add New"}})
+		projs = append(projs, &Project{a.store, &types.Project{DisplayName: "Add New"}})
 		row := -1
 		for i, proj := range projs {
 			if i%3 == 0 {
@@ -73,24 +74,15 @@ func (a *Application) projectsOverview(c *gin.Context) {
 	} else if form.ProjectId == "Add New" {
 		c.Redirect(303, "/newproj")
 	} else {
-		q := map[string]interface{}{
-			"query": es.NewTerm(types.Project_DisplayNameField, form.ProjectId),
-			"size":  1,
-		}
-		resp, err := a.index.SearchByJSON(ProjectType, q)
+		proj, found, err := a.store.ProjectByName(form.ProjectId)
 		if err != nil {
 			c.String(500, "Error getting this project: %s", err.Error())
 			return
 		}
-		if resp.Hits.TotalHits != 1 {
+		if !found {
 			c.String(400, "This project does not appear to exist")
 			return
 		}
-		var proj types.Project
-		if err = json.Unmarshal(*resp.Hits.Hits[0].Source, &proj); err != nil {
-			c.String(500, "Unable to unmarshal project: %s", err.Error())
-			return
-		}
 		c.Redirect(303, "/project/"+proj.Id)
 	}
 }
@@ -120,7 +112,7 @@ func (a *Application) newProject(c *gin.Context) {
 		displayName := strings.TrimSpace(f.ProjectName)
 		id := p.NewUuid().String()
 		//TODO query for one
-		exists, err := a.index.ItemExists(ProjectType, id)
+		_, exists, err := a.store.GetProject(id)
 		if err != nil {
 			c.String(500, "Error checking exists in db: %s", err.Error())
 			return
@@ -128,12 +120,10 @@ func (a *Application) newProject(c *gin.Context) {
 			c.String(400, "This project already exists")
 			return
 		}
-		if resp, err := a.index.PostData(ProjectType, id, types.NewProject(id, displayName)); err != nil {
+		project := types.NewProject(id, displayName)
+		if err := a.store.PutProject(&project); err != nil {
 			c.String(500, "Error creating project in db: %s", err.Error())
 			return
-		} else if !resp.Created {
-			c.String(500, "Project could not be created for unknown reason")
-			return
 		}
 
 		c.Redirect(303, "/ui")
@@ -162,23 +152,18 @@ func (a *Application) deleteProject(c *gin.Context) {
 		c.String(400, "Stop trying to break this please")
 		return
 	}
-	if exists, err := a.index.ItemExists(ProjectType, projId); err != nil {
+	if _, exists, err := a.store.GetProject(projId); err != nil {
 		c.String(500, "Error checking status of project: %s", err.Error())
 		return
 	} else if !exists {
 		c.String(400, "Why would you give me a project that doesnt exist?")
 		return
 	}
-	a.index.DeleteByID(ProjectType, projId)
-	if hits, err := es.GetAll(a.index, RepositoryType, es.NewTerm(types.Repository_ProjectIdField, projId)); err == nil {
-		for _, hit := range hits.Hits {
-			a.index.DeleteByID(RepositoryType, hit.Id)
-		}
-	}
-	if hits, err := es.GetAll(a.index, RepositoryEntryType, es.NewTerm(types.Scan_ProjectIdField, projId)); err == nil {
-		for _, hit := range hits.Hits {
-			a.index.DeleteByID(RepositoryEntryType, hit.Id)
-		}
+	a.backfills.StopProject(projId)
+	a.jobs.DropProject(projId)
+	if err := a.store.DeleteProject(projId); err != nil {
+		c.String(500, "Unable to delete the project: %s", err.Error())
+		return
 	}
 	c.Redirect(303, "/ui")
 }
@@ -233,7 +218,7 @@ func (a *Application) reportSha(c *gin.Context) {
 		if !a.checkRepoIsReal(form.Org, form.Repo) {
 			setScan("This isnt a real repo")
 		} else {
-			if files, err := a.wrkr.snglRnnr.ScanWithSingle(repoName); err != nil {
+			if files, err := a.wrkr.snglRnnr.ScanWithSingle(c.Request.Context(), repoName); err != nil {
 				setScan(err.Error())
 			} else {
 				for i, f := range files {
@@ -252,7 +237,7 @@ func (a *Application) reportSha(c *gin.Context) {
 		a.wrkr.AddTask(&SingleRunnerRequest{&Repository{nil, nil, repo}, form.Sha, ""}, nil, ret)
 		scan := <-ret
 		if scan == nil {
-			return "Generating this sha resulted in an unknown error"
+			return preformatted("Generating this sha resulted in an unknown error")
 		}
 		return a.reportAtShaWrk(scan)
 	}
@@ -266,7 +251,7 @@ func (a *Application) reportSha(c *gin.Context) {
 		h["hidescan"] = false
 		h["hidereport"] = false
 		h["scan"] = files.Template()
-		h["report"] = getReport()
+		h["report"] = s.NewHtmlString(getReport()).Template()
 	}
 	c.HTML(200, "customsha.html", h)
 }
@@ -327,7 +312,7 @@ func (a *Application) customDiff(c *gin.Context) {
 		if !a.checkRepoIsReal(form.Org, form.Repo) {
 			setScan("This isnt a real repo")
 		} else {
-			if files, err := a.wrkr.snglRnnr.ScanWithSingle(repoName); err != nil {
+			if files, err := a.wrkr.snglRnnr.ScanWithSingle(c.Request.Context(), repoName); err != nil {
 				setScan(err.Error())
 			} else {
 				for i, f := range files {
@@ -357,29 +342,11 @@ func (a *Application) customDiff(c *gin.Context) {
 		h["hidediff"] = false
 		diff, err := a.diffMan.ShaCompare(repoName, form.Files, form.OldSha, form.NewSha)
 		if err != nil {
-			h["diff"] = err.Error()
+			h["diff"] = s.NewHtmlString(preformatted(err.Error())).Template()
 		} else if diff == nil {
-			h["diff"] = "These are identical"
+			h["diff"] = s.NewHtmlString(preformatted("These are identical")).Template()
 		} else {
-			height := len(diff.Added)
-			if height < len(diff.Removed) {
-				height = len(diff.Removed)
-			}
-			t := table.NewTable(2, height+1).NoRowBorders()
-			t.Fill("Removed", "Added")
-			for i := 0; i < height; i++ {
-				if i >= len(diff.Removed) {
-					t.Fill("")
-				} else {
-					t.Fill(diff.Removed[i])
-				}
-				if i >= len(diff.Added) {
-					t.Fill("")
-				} else {
-					t.Fill(diff.Added[i])
-				}
-			}
-			h["diff"] = t.HasHeading().Format().String()
+			h["diff"] = s.NewHtmlString(changesTable(diff.Changes).RenderString(t.HTML)).Template()
 		}
 	}
 	c.HTML(200, "customdiff.html", h)
@@ -15,16 +15,13 @@
 package app
 
 import (
-	"encoding/json"
-	"log"
+	"net/url"
 	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
-	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
 	nt "github.com/venicegeo/pz-gocommon/gocommon"
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
-	"github.com/venicegeo/vzutil-versioning/web/es"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
@@ -49,7 +46,7 @@ func (a *Application) viewProject(c *gin.Context) {
 		c.String(400, "Unable to bind form: %s", err.Error())
 		return
 	}
-	depsStr := "Result info will appear here"
+	depsStr := preformatted("Result info will appear here")
 	if form.Back != "" {
 		c.Redirect(303, "/ui")
 		return
@@ -61,13 +58,40 @@ func (a *Application) viewProject(c *gin.Context) {
 		case "Report By Ref":
 			c.Redirect(303, "/reportref/"+projId)
 			return
+		case "Report As Of":
+			c.Redirect(303, "/asof/"+projId)
+			return
+		case "About Yaml":
+			c.Redirect(303, "/about/"+projId)
+			return
+		case "Approved List":
+			c.Redirect(303, "/approved/"+projId)
+			return
+		case "Compare Refs":
+			c.Redirect(303, "/refdiff/"+projId)
+			return
+		case "Dependency Graph":
+			c.Redirect(303, "/depgraph/"+projId)
+			return
+		case "Sha Overrides":
+			c.Redirect(303, "/shas/"+projId)
+			return
+		case "Notifications":
+			c.Redirect(303, "/notify/"+projId)
+			return
+		case "Schedules":
+			c.Redirect(303, "/schedules/"+projId)
+			return
 		case "Generate All Tags":
 			str, err := a.genTagsWrk(projId)
 			if err != nil {
-				u.Format("Unable to generate all tags: %s", err.Error())
+				depsStr = preformatted(u.Format("Unable to generate all tags: %s", err.Error()))
 			} else {
-				depsStr = str
+				depsStr = preformatted(str)
 			}
+		case "Backfill History":
+			c.Redirect(303, "/backfill/"+projId)
+			return
 		case "Add Repository":
 			c.Redirect(303, "/addrepo/"+projId)
 			return
@@ -124,18 +148,21 @@ func (a *Application) viewProject(c *gin.Context) {
 	accord.Sort()
 	h := gin.H{}
 	h["accordion"] = accord.Template()
-	h["deps"] = depsStr
+	h["deps"] = s.NewHtmlString(depsStr).Template()
 	{
-		diffs, err := a.diffMan.GetAllDiffsInProject(projId)
+		count, err := a.diffMan.CountDiffs(projId)
 		if err != nil {
 			h["diff"] = ""
 		} else {
-			h["diff"] = u.Format(" (%d)", len(*diffs))
+			h["diff"] = u.Format(" (%d)", count)
 		}
 	}
 	c.HTML(200, "project.html", h)
 }
 
+// accordionScans is how many of the latest scans of each repository the project page lists.
+const accordionScans = 25
+
 func (a *Application) generateAccordion(accord *s.HtmlAccordion, repo *Repository, errs chan error, mux sync.Mutex) {
 	refs, err := repo.GetAllRefs()
 	if err != nil {
@@ -143,7 +170,7 @@ func (a *Application) generateAccordion(accord *s.HtmlAccordion, repo *Repositor
 		return
 	}
 	tempAccord := s.NewHtmlAccordion()
-	shas, _, err := repo.MapRefToShas()
+	shas, next, err := repo.MapRefToShas("", accordionScans)
 	if err != nil {
 		errs <- err
 		return
@@ -159,8 +186,12 @@ func (a *Application) generateAccordion(accord *s.HtmlAccordion, repo *Repositor
 		}
 		tempAccord.AddItem(ref, s.NewHtmlForm(c).Post())
 	}
+	item := s.NewHtmlCollection(s.NewHtmlForm(s.NewHtmlSubmitButton2("button_gen", "Generate Branch - "+repo.Fullname)).Post(), tempAccord.Sort())
+	if next != "" {
+		item.Add(s.NewHtmlString(pageLink(u.Format("/scans/%s/%s", repo.ProjectId, repo.Fullname), url.Values{}, next, "Older scans")))
+	}
 	mux.Lock()
-	accord.AddItem(repo.Fullname, s.NewHtmlCollection(s.NewHtmlForm(s.NewHtmlSubmitButton2("button_gen", "Generate Branch - "+repo.Fullname)).Post(), tempAccord.Sort()))
+	accord.AddItem(repo.Fullname, item)
 	mux.Unlock()
 	errs <- nil
 }
@@ -271,7 +302,7 @@ func (a *Application) addRepoToProject(c *gin.Context) {
 		if !a.checkRepoIsReal(form.Org, form.Repo) {
 			setScan("This isnt a real repo")
 		} else {
-			if files, err := a.wrkr.snglRnnr.ScanWithSingle(repoName); err != nil {
+			if files, err := a.wrkr.snglRnnr.ScanWithSingle(c.Request.Context(), repoName); err != nil {
 				setScan(err.Error())
 			} else {
 				setScan(files)
@@ -283,7 +314,7 @@ func (a *Application) addRepoToProject(c *gin.Context) {
 		if !a.checkRepoIsReal(form.AltOrg, form.AltRepo) {
 			setScan("This isnt a real repo")
 		} else {
-			if files, err := a.wrkr.snglRnnr.ScanWithSingle(altRepoName); err != nil {
+			if files, err := a.wrkr.snglRnnr.ScanWithSingle(c.Request.Context(), altRepoName); err != nil {
 				setScan(err.Error())
 			} else {
 				setScan(files)
@@ -299,25 +330,17 @@ func (a *Application) addRepoToProject(c *gin.Context) {
 			Fullname:       repoName,
 			DependencyInfo: depinfo,
 		}
-		boolq := es.NewBool().
-			SetMust(es.NewBoolQ(
-				es.NewTerm(types.Repository_ProjectIdField, entry.ProjectId),
-				es.NewTerm(types.Repository_NameField, entry.Fullname)))
-		resp, err := a.index.SearchByJSON(RepositoryType, map[string]interface{}{
-			"query": map[string]interface{}{"bool": boolq},
-			"size":  1,
-		})
+		_, exists, err := a.store.GetRepository(entry.ProjectId, entry.Fullname)
 		if err != nil {
 			c.String(500, "Error checking database for existing repo: %s", err.Error())
 			return
 		}
-		if resp.Hits.TotalHits == 1 {
+		if exists {
 			c.String(400, "This repo already exists under this project")
 			return
 		}
-		iresp, err := a.index.PostData(RepositoryType, id, entry)
-		if err != nil || !iresp.Created {
-			c.String(500, "Error adding entry to database: ", err)
+		if err = a.store.PutRepository(&entry); err != nil {
+			c.String(500, "Error adding entry to database: %s", err.Error())
 			return
 		}
 		c.Redirect(303, "/project/"+projId)
@@ -377,53 +400,10 @@ func (a *Application) removeReposFromProject(c *gin.Context) {
 		return
 	}
 	if form.Repo != "" {
-		boolq := es.NewBool().
-			SetMust(es.NewBoolQ(
-				es.NewTerm(types.Repository_ProjectIdField, projId),
-				es.NewTerm(types.Repository_NameField, form.Repo)))
-		resp, err := a.index.SearchByJSON(RepositoryType, map[string]interface{}{
-			"query": map[string]interface{}{"bool": boolq},
-			"size":  1,
-		})
-		if err != nil {
-			c.String(400, "Unable to find the project entry: %s", err.Error())
-			return
-		}
-		if resp.Hits.TotalHits != 1 {
-			c.String(400, "Could not find the project entry")
-			return
-		}
-		entry := new(types.Repository)
-		if err = json.Unmarshal(*resp.Hits.Hits[0].Source, entry); err != nil {
-			c.String(500, "Unable to read project entry: %s", err.Error())
-			return
-		}
-		_, err = a.index.DeleteByIDWait(RepositoryType, resp.Hits.Hits[0].Id)
-		if err != nil {
+		if err := a.store.DeleteRepository(projId, form.Repo); err != nil {
 			c.String(500, "Unable to delete project entry: %s", err.Error())
 			return
 		}
-		func() {
-			hits, err := es.GetAll(a.index, RepositoryEntryType, map[string]interface{}{
-				"bool": es.NewBool().
-					SetMust(es.NewBoolQ(
-						es.NewTerm(types.Scan_FullnameField, entry.Fullname),
-						es.NewTerm(types.Scan_ProjectIdField, entry.ProjectId))),
-			})
-			if err != nil {
-				log.Println("Unable to cleanup after repo", entry.Fullname, "in", entry.ProjectId, ":", err.Error())
-				return
-			}
-			wg := sync.WaitGroup{}
-			wg.Add(len(hits.Hits))
-			for _, hit := range hits.Hits {
-				go func(hit *elastic.SearchHit) {
-					a.index.DeleteByID(RepositoryEntryType, hit.Id)
-					wg.Done()
-				}(hit)
-			}
-			wg.Wait()
-		}()
 		c.Redirect(303, "/removerepo/"+projId)
 		return
 	}
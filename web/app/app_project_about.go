@@ -0,0 +1,134 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venicegeo/vzutil-versioning/common/about"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+func (a *Application) aboutYmlPage(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back   string `form:"button_back"`
+		Ref    string `form:"ref"`
+		Repo   string `form:"repo"`
+		Format string `form:"format"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	h := gin.H{"result": ""}
+	refs, err := project.GetAllRefs()
+	if err != nil {
+		h["result"] = u.Format("Unable to retrieve this projects refs: %s", err.Error())
+	}
+	options := ""
+	for _, ref := range refs {
+		sel := ""
+		if ref == trimRef(form.Ref) {
+			sel = " selected"
+		}
+		options += u.Format(`<option value="%s"%s>%s</option>`, html.EscapeString(ref), sel, html.EscapeString(ref))
+	}
+	h["refs"] = s.NewHtmlString(options).Template()
+	if form.Ref == "" {
+		c.HTML(200, "about.html", h)
+		return
+	}
+
+	checks, err := a.checkAbouts(project, form.Ref)
+	if err != nil {
+		if form.Format != "" {
+			c.String(400, "Unable to check the about yamls: %s", err.Error())
+			return
+		}
+		h["result"] = u.Format("Unable to check the about yamls: %s", err.Error())
+		c.HTML(200, "about.html", h)
+		return
+	}
+	switch form.Format {
+	case "":
+		h["result"] = s.NewHtmlString(aboutChecksHtml(c.Request.URL, checks)).Template()
+		c.HTML(200, "about.html", h)
+		return
+	case "yml", "patch":
+	default:
+		c.String(400, "Unknown format [%s]", form.Format)
+		return
+	}
+	var check *AboutCheck
+	for _, ch := range checks {
+		if ch.Repo == form.Repo {
+			check = ch
+		}
+	}
+	if check == nil || check.Error != "" {
+		c.String(400, "No about yaml for [%s]", form.Repo)
+		return
+	}
+	name := strings.Replace(check.Repo, "/", "_", -1)
+	if form.Format == "yml" {
+		c.Header("Content-Disposition", u.Format("attachment; filename=\"%s%s\"", name, about.FileName))
+		c.Data(200, "text/yaml", check.Generated)
+	} else {
+		c.Header("Content-Disposition", u.Format("attachment; filename=\"%s-about.patch\"", name))
+		c.Data(200, "text/x-diff", []byte(check.Patch))
+	}
+}
+
+func aboutChecksHtml(reqUrl *url.URL, checks []*AboutCheck) string {
+	buf := bytes.NewBufferString("")
+	for _, check := range checks {
+		buf.WriteString(u.Format("<h4>%s</h4>\n", html.EscapeString(check.Repo)))
+		if check.Error != "" {
+			buf.WriteString(u.Format("<pre>%s</pre>\n", html.EscapeString(check.Error)))
+			continue
+		}
+		query := reqUrl.Query()
+		query.Set("repo", check.Repo)
+		links := []string{}
+		query.Set("format", "yml")
+		links = append(links, u.Format(`<a href="%s?%s">%s</a>`, reqUrl.Path, html.EscapeString(query.Encode()), about.FileName))
+		if check.Patch != "" {
+			query.Set("format", "patch")
+			links = append(links, u.Format(`<a href="%s?%s">Patch</a>`, reqUrl.Path, html.EscapeString(query.Encode())))
+		}
+		status := "committed"
+		if !check.Exists {
+			status = "not committed"
+		}
+		buf.WriteString(u.Format("At %s, %s is %s. Download %s\n", check.Sha, about.FileName, status, strings.Join(links, " ")))
+		buf.WriteString(u.Format("<pre>%s</pre>\n", html.EscapeString(check.Validation.String())))
+	}
+	return buf.String()
+}
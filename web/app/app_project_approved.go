@@ -0,0 +1,224 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"html"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venicegeo/vzutil-versioning/common/approved"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/table"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// approvedListPage imports new versions of a project's approved-software list
+// and shows the items of each version.
+func (a *Application) approvedListPage(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back      string `form:"button_back"`
+		Import    string `form:"button_import"`
+		Version   string `form:"version"`
+		Name      string `form:"col_name"`
+		DepVer    string `form:"col_version"`
+		Component string `form:"col_component"`
+		Language  string `form:"col_language"`
+		Bundles   string `form:"bundles"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	lists, err := a.store.ApprovedLists(projId)
+	if err != nil {
+		c.String(500, "Unable to get the approved lists: %s", err.Error())
+		return
+	}
+	h := gin.H{"project": project.DisplayName, "result": "", "items": ""}
+	if form.Import != "" {
+		if list, unknown, err := a.importApprovedList(c, projId, lists, form.Name, form.DepVer, form.Component, form.Language, form.Bundles); err != nil {
+			h["result"] = u.Format("Unable to import the list: %s", err.Error())
+		} else {
+			res := u.Format("Saved version %d with %d items.", list.Version, len(list.Items))
+			if len(unknown) > 0 {
+				res += u.Format(" Left out the items of unknown languages %s.", strings.Join(unknown, ", "))
+			}
+			h["result"] = res
+			lists = append([]*types.ApprovedList{list}, lists...)
+		}
+	}
+
+	cols := types.ApprovedColumns{"A", "B", "C", "D"}
+	bundles := ""
+	if len(lists) > 0 {
+		cols = lists[0].Columns
+		bundles = formatBundles(lists[0].Bundles)
+	}
+	h["col_name"], h["col_version"], h["col_component"], h["col_language"] = cols.Name, cols.Version, cols.Component, cols.Language
+	h["bundles"] = bundles
+	h["versions"] = approvedListsTable(lists).Template()
+	if form.Version != "" {
+		for _, list := range lists {
+			if strconv.Itoa(list.Version) == form.Version {
+				h["items"] = u.Format("Version %d from %s\n%s", list.Version, list.FileName, approvedItemsTable(list))
+			}
+		}
+	}
+	c.HTML(200, "approved.html", h)
+}
+
+func (a *Application) importApprovedList(c *gin.Context, projId string, lists []*types.ApprovedList, name, version, component, language, bundles string) (*types.ApprovedList, []string, error) {
+	file, header, err := c.Request.FormFile("list")
+	if err != nil {
+		return nil, nil, u.Error("No file uploaded: %s", err.Error())
+	}
+	defer file.Close()
+	dat, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := approved.ReadSheet(header.Filename, dat)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, u.Error("The sheet is empty")
+	}
+	cols, err := approved.MapColumns(rows[0], name, version, component, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsedBundles, err := parseBundles(bundles)
+	if err != nil {
+		return nil, nil, err
+	}
+	deps, unknown := approved.Parse(rows, cols)
+	next := 1
+	if len(lists) > 0 {
+		next = lists[0].Version + 1
+	}
+	list := &types.ApprovedList{
+		Id:        types.ApprovedListId(projId, next),
+		ProjectId: projId,
+		Version:   next,
+		FileName:  header.Filename,
+		Columns:   types.ApprovedColumns{strings.TrimSpace(name), strings.TrimSpace(version), strings.TrimSpace(component), strings.TrimSpace(language)},
+		Items:     make([]types.ApprovedItem, len(deps), len(deps)),
+		Bundles:   parsedBundles,
+		Created:   time.Now(),
+	}
+	for i, dep := range deps {
+		list.Items[i] = types.ApprovedItem{dep.GetName(), dep.GetVersion(), dep.GetProject(), dep.GetLanguage()}
+	}
+	return list, unknown, a.store.PutApprovedList(list)
+}
+
+// parseBundles reads one bundle per line as bundle: package, package.
+func parseBundles(text string) ([]types.ApprovedBundle, error) {
+	res := []types.ApprovedBundle{}
+	for i, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || len(splitList(parts[1])) == 0 {
+			return nil, u.Error("Line %d: [%s] is not bundle: package, package", i+1, line)
+		}
+		packages := splitList(strings.ToLower(parts[1]))
+		res = append(res, types.ApprovedBundle{strings.ToLower(strings.TrimSpace(parts[0])), packages})
+	}
+	return res, nil
+}
+
+func formatBundles(bundles []types.ApprovedBundle) string {
+	lines := make([]string, len(bundles), len(bundles))
+	for i, b := range bundles {
+		lines[i] = b.Name + ": " + strings.Join(b.Packages, ", ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func approvedListsTable(lists []*types.ApprovedList) *s.HtmlTable {
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Version", "File", "Items", "Bundles", "Imported", ""} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	for i, list := range lists {
+		table.AddRow()
+		for _, item := range []string{strconv.Itoa(list.Version), list.FileName, strconv.Itoa(len(list.Items)),
+			strconv.Itoa(len(list.Bundles)), list.Created.Format(time.RFC3339)} {
+			table.AddItem(i+1, s.NewHtmlString(html.EscapeString(item)))
+		}
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlButton("View", "version", strconv.Itoa(list.Version), "submit")))
+	}
+	return table
+}
+
+func approvedItemsTable(list *types.ApprovedList) string {
+	t := table.NewTable(4, len(list.Items))
+	for _, item := range list.Items {
+		t.Fill(item.Name, item.Version, item.Language.String(), item.Component)
+	}
+	return t.NoRowBorders().SpaceColumn(1).Format().String()
+}
+
+// reconcileRef matches the dependencies of a project's scans on a ref against
+// the latest version of its approved list, which is nil when there is none.
+func (a *Application) reconcileRef(projId string, scans map[string]*types.Scan) (*approved.Reconciliation, *types.ApprovedList, error) {
+	lists, err := a.store.ApprovedLists(projId)
+	if err != nil || len(lists) == 0 {
+		return nil, nil, err
+	}
+	inUse := d.GenericDependencies{}
+	for name, scan := range scans {
+		if scan.Scan == nil {
+			continue
+		}
+		for _, dep := range scan.Scan.Deps {
+			inUse.Add(d.NewGenericDependencyFrom(dep, name))
+		}
+	}
+	return approved.Reconcile(lists[0].Dependencies(), inUse, lists[0].BundleMap()), lists[0], nil
+}
+
+// approvedReport renders the reconciliation as html, a table for each status.
+func approvedReport(rec *approved.Reconciliation, list *types.ApprovedList) string {
+	buf := bytes.NewBufferString(preformatted(u.Format("Approved list version %d from %s", list.Version, list.FileName)))
+	for _, section := range rec.Sections() {
+		buf.WriteString(u.Format("<h4>%s</h4>", html.EscapeString(section.Title)))
+		if len(section.Table.Body()) > 0 {
+			buf.WriteString(section.Table.RenderString(table.HTML))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,133 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+var asOfLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func parseAsOf(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	for i, layout := range asOfLayouts {
+		t, err := time.Parse(layout, str)
+		if err != nil {
+			continue
+		}
+		if i == len(asOfLayouts)-1 {
+			// a bare date means the whole of that day
+			t = t.Add(time.Hour*24 - time.Second)
+		}
+		return t, nil
+	}
+	return time.Time{}, u.Error("Unable to read the time [%s], use 2006-01-02 or 2006-01-02T15:04:05", str)
+}
+
+func (a *Application) reportAsOf(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back       string `form:"button_back"`
+		Ref        string `form:"ref"`
+		AsOf       string `form:"asof"`
+		ReportType string `form:"reporttype"`
+		Format     string `form:"format"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	h := gin.H{"result": "", "downloads": "", "asof": form.AsOf}
+	refs, err := project.GetAllRefs()
+	if err != nil {
+		h["result"] = u.Format("Unable to retrieve this projects refs: %s", err.Error())
+	}
+	options := ""
+	for _, ref := range refs {
+		sel := ""
+		if ref == trimRef(form.Ref) {
+			sel = " selected"
+		}
+		options += u.Format(`<option value="%s"%s>%s</option>`, html.EscapeString(ref), sel, html.EscapeString(ref))
+	}
+	h["refs"] = s.NewHtmlString(options).Template()
+	if form.Ref == "" || form.AsOf == "" {
+		c.HTML(200, "asof.html", h)
+		return
+	}
+
+	asOf, err := parseAsOf(form.AsOf)
+	var inv *Inventory
+	if err == nil {
+		inv, err = inventoryAsOf(project, form.Ref, asOf)
+	}
+	if err != nil {
+		if form.Format != "" {
+			c.String(400, "Unable to generate report: %s", err.Error())
+			return
+		}
+		h["result"] = u.Format("Unable to generate report: %s", err.Error())
+		c.HTML(200, "asof.html", h)
+		return
+	}
+	switch form.Format {
+	case "":
+		h["result"] = inv.String(form.ReportType)
+		query := c.Request.URL.Query()
+		links := []string{}
+		for _, format := range []string{"csv", "json"} {
+			query.Set("format", format)
+			links = append(links, u.Format(`<a href="%s?%s">%s</a>`, c.Request.URL.Path, html.EscapeString(query.Encode()), strings.ToUpper(format)))
+		}
+		h["downloads"] = s.NewHtmlString("Download " + strings.Join(links, " ")).Template()
+		c.HTML(200, "asof.html", h)
+		return
+	case "csv", "json":
+	default:
+		c.String(400, "Unknown format [%s]", form.Format)
+		return
+	}
+	buf := bytes.NewBuffer([]byte{})
+	contentType := "text/csv"
+	if form.Format == "json" {
+		contentType = "application/json"
+		err = inv.WriteJSON(buf)
+	} else {
+		err = inv.WriteCSV(buf, form.ReportType)
+	}
+	if err != nil {
+		c.String(500, "Unable to write the report: %s", err.Error())
+		return
+	}
+	name := strings.Replace(u.Format("asof_%s_%s_%s.%s", project.EscapedName, inv.Ref, asOf.Format("20060102T150405"), form.Format), "/", "_", -1)
+	c.Header("Content-Disposition", u.Format("attachment; filename=\"%s\"", name))
+	c.Data(200, contentType, buf.Bytes())
+}
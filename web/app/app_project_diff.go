@@ -15,10 +15,17 @@
 package app
 
 import (
+	"html"
+	"net/url"
+
 	"github.com/gin-gonic/gin"
+	t "github.com/venicegeo/vzutil-versioning/common/table"
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
 
+const diffPageSize = 50
+
 func (a *Application) differencesInProject(c *gin.Context) {
 	projId := c.Param("proj")
 	var back struct {
@@ -40,7 +47,8 @@ func (a *Application) differencesInProject(c *gin.Context) {
 		c.HTML(500, "differences.html", gh)
 		return
 	}
-	diffs, err := a.diffMan.GetAllDiffsInProject(projId)
+	cursor := c.Query("after")
+	diffs, next, err := a.diffMan.DiffPage(projId, cursor, diffPageSize)
 	if err != nil {
 		gh["buttons"] = "Could not load this.\n" + err.Error()
 		gh["data"] = "Error loading this.\n" + err.Error()
@@ -48,17 +56,24 @@ func (a *Application) differencesInProject(c *gin.Context) {
 		return
 	}
 	form := map[string][]string(c.Request.Form)
+	delete(form, "after")
 	{
-		buttons := make([]s.HtmlInter, len(*diffs))
-		for i, d := range *diffs {
+		buttons := make([]s.HtmlInter, len(diffs))
+		for i, d := range diffs {
 			buttons[i] = s.NewHtmlSubmitButton2(d.Id, d.SimpleString())
 		}
 		if len(buttons) > 0 {
 			tmp := s.NewHtmlCollection()
+			if cursor != "" {
+				tmp.Add(s.NewHtmlString(u.Format(`<input type="hidden" name="after" value="%s">`, html.EscapeString(cursor))))
+			}
 			for _, b := range buttons {
 				tmp.Add(b)
 				tmp.Add(s.NewHtmlBr())
 			}
+			if next != "" {
+				tmp.Add(s.NewHtmlString(pageLink(c.Request.URL.Path, url.Values{}, next, "Older differences")))
+			}
 			gh["buttons"] = tmp.Template()
 		}
 	}
@@ -71,16 +86,16 @@ func (a *Application) differencesInProject(c *gin.Context) {
 				c.Redirect(303, "/diff/"+projId)
 				return
 			} else {
-				for _, diff := range *diffs {
-					if diff.Id == diffId {
-						res = a.diffMan.GenerateReport(&diff) + "\n"
-						a.diffMan.CurrentDisplay = diffId
-						break
-					}
+				diff, found, err := a.diffMan.GetDiff(diffId)
+				if err != nil {
+					res = preformatted("Unable to load this difference: " + err.Error())
+				} else if found {
+					res = a.diffMan.GenerateReport(diff, t.HTML)
+					a.diffMan.CurrentDisplay = diffId
 				}
 			}
 		}
-		gh["data"] = s.NewHtmlCollection(s.NewHtmlBasic("pre", res), s.NewHtmlBasic("form", s.NewHtmlSubmitButton("Delete").String())).Template()
+		gh["data"] = s.NewHtmlCollection(s.NewHtmlString(res), s.NewHtmlBasic("form", s.NewHtmlSubmitButton("Delete").String())).Template()
 	}
 	c.HTML(200, "differences.html", gh)
 }
@@ -16,8 +16,8 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"log"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	nt "github.com/venicegeo/pz-gocommon/gocommon"
@@ -80,7 +80,7 @@ func (a *Application) generateBranch(c *gin.Context) {
 }
 
 func (a *Application) generateBranchWrk(repoName, fullName, branch, projId string) (string, error) {
-	sha, err := h.GetBranchSha(repoName, fullName, branch)
+	sha, err := h.GetBranchSha(context.Background(), a.mirrors, fullName, branch)
 	if err != nil {
 		return "", err
 	}
@@ -117,8 +117,7 @@ func (a *Application) genTagsWrk(projId string) (string, error) {
 	}
 	go func(repos []*Repository, proj string) {
 		for _, repo := range repos {
-			name := strings.SplitN(repo.Fullname, "/", 2)[1]
-			dat, err := h.NewTagsRunner(name, repo.Fullname).Run()
+			dat, err := h.NewTagsRunner(a.mirrors, repo.Fullname).Run(context.Background())
 			if err != nil {
 				log.Println("[TAG UPDATER] Was unable to run tags against " + repo.Fullname + ": [" + err.Error() + "]")
 				continue
@@ -0,0 +1,111 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"html/template"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+func (a *Application) dependencyGraph(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back   string `form:"button_back"`
+		Ref    string `form:"ref"`
+		Format string `form:"format"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	if form.Ref == "" {
+		form.Ref = "heads/master"
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	graph, err := a.BuildDependencyGraph(project, form.Ref)
+	if err != nil {
+		c.String(500, "Unable to build the dependency graph: %s", err.Error())
+		return
+	}
+	switch form.Format {
+	case "json":
+		c.JSON(200, graph)
+		return
+	case "dot":
+		c.Header("Content-Disposition", u.Format("attachment; filename=\"%s.dot\"", project.EscapedName))
+		c.Data(200, "text/vnd.graphviz", []byte(graph.Dot()))
+		return
+	case "svg":
+		svg, err := graph.Svg(c.Request.Context())
+		if err != nil {
+			c.String(500, err.Error())
+			return
+		}
+		c.Data(200, "image/svg+xml", svg)
+		return
+	case "":
+	default:
+		c.String(400, "Unknown format [%s]", form.Format)
+		return
+	}
+
+	h := gin.H{"ref": form.Ref, "project": project.DisplayName}
+	if svg, err := graph.Svg(c.Request.Context()); err != nil {
+		h["graph"] = s.NewHtmlBasic("pre", graph.Dot()).Template()
+	} else {
+		h["graph"] = template.HTML(svg)
+	}
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Consumer", "Producer", "Uses", "Tag", "Latest Tag", "Behind"} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	latest := map[string]string{}
+	for _, n := range graph.Nodes {
+		latest[n.Repo] = n.LatestTag
+		if n.Error != "" {
+			latest[n.Repo] = "unknown: " + n.Error
+		}
+	}
+	for i, e := range graph.Edges {
+		behind := strconv.Itoa(e.Behind)
+		if e.Lagging && e.Tag == "" {
+			behind = "untagged"
+		}
+		table.AddRow()
+		for _, item := range []string{e.Consumer, e.Producer, e.Version, e.Tag, latest[e.Producer], behind} {
+			str := html.EscapeString(item)
+			if e.Lagging {
+				str = `<font color="red">` + str + `</font>`
+			}
+			table.AddItem(i+1, s.NewHtmlString(str))
+		}
+	}
+	h["edges"] = table.Template()
+	c.HTML(200, "depgraph.html", h)
+}
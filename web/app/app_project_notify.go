@@ -0,0 +1,158 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	nt "github.com/venicegeo/pz-gocommon/gocommon"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	"github.com/venicegeo/vzutil-versioning/web/notify"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+func (a *Application) subscriptionsPage(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back      string   `form:"button_back"`
+		Add       string   `form:"button_add"`
+		Delete    string   `form:"button_delete"`
+		Channel   string   `form:"channel"`
+		Target    string   `form:"target"`
+		Events    []string `form:"events"`
+		Languages string   `form:"languages"`
+		Repos     string   `form:"repos"`
+		Digest    string   `form:"digest"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	h := gin.H{"project": project.DisplayName, "result": ""}
+	if form.Delete != "" {
+		if sub, found, err := a.store.GetSubscription(form.Delete); err != nil || !found || sub.ProjectId != projId {
+			h["result"] = u.Format("Unable to find subscription %s", form.Delete)
+		} else if err = a.store.DeleteSubscription(form.Delete); err != nil {
+			h["result"] = u.Format("Unable to delete the subscription: %s", err.Error())
+		}
+	} else if form.Add != "" {
+		if sub, err := newSubscription(projId, form.Channel, form.Target, form.Events, form.Languages, form.Repos, form.Digest); err != nil {
+			h["result"] = err.Error()
+		} else if err = a.store.PutSubscription(sub); err != nil {
+			h["result"] = u.Format("Unable to save the subscription: %s", err.Error())
+		}
+	}
+	subs, err := a.store.Subscriptions(projId)
+	if err != nil {
+		c.String(500, "Unable to get the subscriptions: %s", err.Error())
+		return
+	}
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Channel", "Target", "Events", "Languages", "Repositories", "Digest", ""} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	for i, sub := range subs {
+		events := make([]string, len(sub.Events), len(sub.Events))
+		for j, e := range sub.Events {
+			events[j] = string(e)
+		}
+		digest := "immediate"
+		if sub.DigestMinutes > 0 {
+			digest = u.Format("every %d minutes", sub.DigestMinutes)
+		}
+		table.AddRow()
+		for _, item := range []string{string(sub.Channel), sub.Target, orAll(events), orAll(sub.Languages), orAll(sub.Repos), digest} {
+			table.AddItem(i+1, s.NewHtmlString(html.EscapeString(item)))
+		}
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlButton("Delete", "button_delete", sub.Id, "submit")).Post())
+	}
+	h["subscriptions"] = table.Template()
+	c.HTML(200, "notify.html", h)
+}
+
+func newSubscription(projId, channel, target string, events []string, languages, repos, digest string) (*types.Subscription, error) {
+	sub := &types.Subscription{
+		Id:        nt.NewUuid().String(),
+		ProjectId: projId,
+		Channel:   types.Channel(channel),
+		Target:    strings.TrimSpace(target),
+		Events:    []types.EventKind{},
+		Languages: splitList(languages),
+		Repos:     splitList(repos),
+		Created:   time.Now(),
+	}
+	switch sub.Channel {
+	case types.ChannelEmail:
+		addr, err := notify.ParseEmail(sub.Target)
+		if err != nil {
+			return nil, u.Error("[%s] is not an email address", sub.Target)
+		}
+		sub.Target = addr
+	case types.ChannelWebhook, types.ChannelSlack:
+		if !strings.HasPrefix(sub.Target, "http://") && !strings.HasPrefix(sub.Target, "https://") {
+			return nil, u.Error("[%s] is not a url", sub.Target)
+		}
+	default:
+		return nil, u.Error("Unknown channel [%s]", channel)
+	}
+	for _, e := range events {
+		switch kind := types.EventKind(e); kind {
+		case types.EventDifference, types.EventScanFailed, types.EventNewIssue:
+			sub.Events = append(sub.Events, kind)
+		default:
+			return nil, u.Error("Unknown event [%s]", e)
+		}
+	}
+	if digest = strings.TrimSpace(digest); digest != "" {
+		minutes, err := strconv.Atoi(digest)
+		if err != nil || minutes < 0 {
+			return nil, u.Error("The digest must be a number of minutes")
+		}
+		sub.DigestMinutes = minutes
+	}
+	return sub, nil
+}
+
+func splitList(str string) []string {
+	res := []string{}
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func orAll(items []string) string {
+	if len(items) == 0 {
+		return "all"
+	}
+	return strings.Join(items, ", ")
+}
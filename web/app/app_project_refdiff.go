@@ -0,0 +1,144 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+func (a *Application) compareRefsInProject(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back      string `form:"button_back"`
+		From      string `form:"from"`
+		To        string `form:"to"`
+		ToProject string `form:"toproj"`
+		Format    string `form:"format"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	toProject := project
+	if form.ToProject != "" && form.ToProject != projId {
+		if toProject, err = a.rtrvr.GetProjectById(form.ToProject); err != nil {
+			c.String(400, "Error getting the project to compare against: %s", err.Error())
+			return
+		}
+	}
+
+	if form.From != "" || form.To != "" {
+		comp, err := compareRefs(project, form.From, toProject, form.To)
+		if err != nil && form.Format != "" {
+			c.String(400, "Unable to compare: %s", err.Error())
+			return
+		}
+		if err == nil && form.Format != "" {
+			a.downloadRefComparison(c, comp, project.EscapedName, form.Format)
+			return
+		}
+		h := a.refDiffForm(project, toProject, form.From, form.To)
+		if err != nil {
+			h["result"] = u.Format("Unable to compare: %s", err.Error())
+		} else {
+			h["result"] = comp.String()
+			query := c.Request.URL.Query()
+			links := []string{}
+			for _, format := range []string{"csv", "json", "md"} {
+				query.Set("format", format)
+				links = append(links, u.Format(`<a href="%s?%s">%s</a>`, c.Request.URL.Path, html.EscapeString(query.Encode()), strings.ToUpper(format)))
+			}
+			h["downloads"] = s.NewHtmlString("Download " + strings.Join(links, " ")).Template()
+		}
+		c.HTML(200, "refdiff.html", h)
+		return
+	}
+	c.HTML(200, "refdiff.html", a.refDiffForm(project, project, "", ""))
+}
+
+func (a *Application) downloadRefComparison(c *gin.Context, comp *RefComparison, projName, format string) {
+	buf := bytes.NewBuffer([]byte{})
+	var err error
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv"
+		err = comp.WriteCSV(buf)
+	case "json":
+		contentType = "application/json"
+		err = comp.WriteJSON(buf)
+	case "md":
+		contentType = "text/markdown"
+		err = comp.WriteMarkdown(buf)
+	default:
+		c.String(400, "Unknown format [%s]", format)
+		return
+	}
+	if err != nil {
+		c.String(500, "Unable to write the comparison: %s", err.Error())
+		return
+	}
+	name := strings.Replace(u.Format("refdiff_%s_%s_%s.%s", projName, comp.FromRef, comp.ToRef, format), "/", "_", -1)
+	c.Header("Content-Disposition", u.Format("attachment; filename=\"%s\"", name))
+	c.Data(200, contentType, buf.Bytes())
+}
+
+func (a *Application) refDiffForm(project, toProject *Project, from, to string) gin.H {
+	h := gin.H{"result": "", "downloads": ""}
+	options := func(project *Project, selected string) string {
+		refs, err := project.GetAllRefs()
+		if err != nil {
+			h["result"] = u.Format("Unable to retrieve the refs of %s: %s", project.DisplayName, err.Error())
+		}
+		res := ""
+		for _, ref := range refs {
+			sel := ""
+			if ref == trimRef(selected) {
+				sel = " selected"
+			}
+			res += u.Format(`<option value="%s"%s>%s</option>`, html.EscapeString(ref), sel, html.EscapeString(ref))
+		}
+		return res
+	}
+	h["from"] = s.NewHtmlString(options(project, from)).Template()
+	h["to"] = s.NewHtmlString(options(toProject, to)).Template()
+	projects := ""
+	if all, err := a.rtrvr.GetAllProjects(); err == nil {
+		for _, p := range all {
+			sel := ""
+			if p.Id == toProject.Id {
+				sel = " selected"
+			}
+			projects += u.Format(`<option value="%s"%s>%s</option>`, html.EscapeString(p.Id), sel, html.EscapeString(p.DisplayName))
+		}
+	}
+	h["projects"] = s.NewHtmlString(projects).Template()
+	return h
+}
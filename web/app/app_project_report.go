@@ -18,9 +18,13 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"html"
 	"sort"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	c "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/component"
 	d "github.com/venicegeo/vzutil-versioning/common/dependency"
 	"github.com/venicegeo/vzutil-versioning/common/table"
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
@@ -35,6 +39,7 @@ func (a *Application) reportRefOnProject(c *gin.Context) {
 		ReportType string `form:"reporttype"`
 		Ref        string `form:"button_submit"`
 		Download   string `form:"download_csv"`
+		Approved   string `form:"download_approved"`
 	}
 	if err := c.Bind(&form); err != nil {
 		c.String(400, "Unable to bind form: %s", err.Error())
@@ -49,6 +54,10 @@ func (a *Application) reportRefOnProject(c *gin.Context) {
 		a.reportRefOnProjectDownloadCSV(c)
 		return
 	}
+	if form.Approved != "" {
+		a.reportRefOnProjectApprovedCSV(c)
+		return
+	}
 
 	h := gin.H{"report": ""}
 	project, err := a.rtrvr.GetProjectById(projId)
@@ -70,7 +79,14 @@ func (a *Application) reportRefOnProject(c *gin.Context) {
 				h["report"] = u.Format("Unable to generate report: %s", err.Error())
 			} else {
 				report := a.reportAtRefWrk(form.Ref, scans, form.ReportType)
-				h["report"] = s.NewHtmlCollection(s.NewHtmlButton("Download CSV", "download_csv", form.Ref, "submit").Style("float:right;"), s.NewHtmlBr(), s.NewHtmlBasic("pre", report)).Template()
+				buttons := s.NewHtmlCollection(s.NewHtmlButton("Download CSV", "download_csv", form.Ref, "submit").Style("float:right;"))
+				if rec, list, err := a.reconcileRef(projId, scans); err != nil {
+					report += preformatted(u.Format("Unable to reconcile the approved list: %s", err.Error()))
+				} else if rec != nil {
+					report += approvedReport(rec, list)
+					buttons.Add(s.NewHtmlButton("Download Approved CSV", "download_approved", form.Ref, "submit").Style("float:right;"))
+				}
+				h["report"] = s.NewHtmlCollection(buttons, s.NewHtmlBr(), s.NewHtmlString(report)).Template()
 			}
 		}
 	}
@@ -125,6 +141,44 @@ func (a *Application) reportRefOnProjectDownloadCSV(c *gin.Context) {
 	}
 }
 
+// reportRefOnProjectApprovedCSV downloads the reconciliation of a ref against
+// the project's latest approved list.
+func (a *Application) reportRefOnProjectApprovedCSV(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Ref string `form:"download_approved"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	scans, err := project.ScansByRefInProject(form.Ref)
+	if err != nil {
+		c.String(500, "Unable to generate report: %s", err.Error())
+		return
+	}
+	rec, _, err := a.reconcileRef(projId, scans)
+	if err != nil {
+		c.String(500, "Unable to reconcile the approved list: %s", err.Error())
+		return
+	} else if rec == nil {
+		c.String(404, "This project has no approved list")
+		return
+	}
+	buf := bytes.NewBuffer([]byte{})
+	if err = rec.WriteCSV(csv.NewWriter(buf)); err != nil {
+		c.String(500, "Unable to write the csv: %s", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"approved_%s_%s.csv\"", project.EscapedName, form.Ref))
+	c.Data(200, "text/csv", buf.Bytes())
+}
+
 func (a *Application) reportAtRefWrkCSV(w *csv.Writer, ref string, deps map[string]*types.Scan, typ string) {
 	switch typ {
 	case "seperate":
@@ -162,11 +216,22 @@ func (a *Application) reportAtRefWrkCSV(w *csv.Writer, ref string, deps map[stri
 		for _, dep := range sorted {
 			w.Write([]string{dep.Name, dep.Version, dep.Language.String()})
 		}
+	case "components":
+		w.Write([]string{"Repository", "Component", "Dependency", "Version", "Language"})
+		for _, name := range sortedScanNames(deps) {
+			for _, comp := range deps[name].Scan.ComponentsOrRoot() {
+				for _, dep := range comp.Deps {
+					w.Write([]string{name, comp.Name, dep.Name, dep.Version, dep.Language.String()})
+				}
+			}
+		}
 	default:
 		w.Write([]string{"Unknown report type", typ})
 	}
 }
 
+// reportAtRefWrk renders the dependencies of the scans at the ref as html,
+// a table for each repository or one table for them all.
 func (a *Application) reportAtRefWrk(ref string, deps map[string]*types.Scan, typ string) string {
 	buf := bytes.NewBufferString("")
 	switch typ {
@@ -176,19 +241,15 @@ func (a *Application) reportAtRefWrk(ref string, deps map[string]*types.Scan, ty
 			if proj, err := a.rtrvr.GetProjectById(depss.ProjectId); err == nil {
 				projName = proj.DisplayName
 			}
-			buf.WriteString(u.Format("%s at %s in %s\n%s\nFrom %s %s", name, ref, projName, depss.Sha, depss.Scan.Fullname, depss.Scan.Sha))
-			t := table.NewTable(3, len(depss.Scan.Deps))
-			for _, dep := range depss.Scan.Deps {
-				t.Fill(dep.Name, dep.Version, dep.Language.String())
-			}
-			buf.WriteString(u.Format("\n%s\n\n", t.NoRowBorders().SpaceColumn(1).Format().String()))
+			buf.WriteString(preformatted(u.Format("%s at %s in %s\n%s\nFrom %s %s", name, ref, projName, depss.Sha, depss.Scan.Fullname, depss.Scan.Sha)))
+			buf.WriteString(depsTable(depss.Scan.Deps).RenderString(table.HTML))
 		}
 	case "grouped":
-		buf.WriteString(u.Format("All repos at %s\n", ref))
+		names := bytes.NewBufferString(u.Format("All repos at %s\n", ref))
 		noDups := map[string]d.Dependency{}
 		for name, depss := range deps {
-			buf.WriteString(name)
-			buf.WriteString("\n")
+			names.WriteString(name)
+			names.WriteString("\n")
 			for _, dep := range depss.Scan.Deps {
 				noDups[dep.String()] = dep
 			}
@@ -198,33 +259,86 @@ func (a *Application) reportAtRefWrk(ref string, deps map[string]*types.Scan, ty
 			sorted = append(sorted, dep)
 		}
 		sort.Sort(sorted)
-		t := table.NewTable(3, len(sorted))
-		for _, dep := range sorted {
-			t.Fill(dep.Name, dep.Version, dep.Language.String())
+		buf.WriteString(preformatted(names.String()))
+		buf.WriteString(depsTable(sorted).RenderString(table.HTML))
+	case "components":
+		for _, name := range sortedScanNames(deps) {
+			buf.WriteString(preformatted(u.Format("%s at %s\n%s", name, ref, deps[name].Sha)))
+			buf.WriteString(componentsTable(deps[name].Scan.ComponentsOrRoot()).RenderString(table.HTML))
 		}
-		buf.WriteString(u.Format("\n%s", t.NoRowBorders().SpaceColumn(1).Format().String()))
 	default:
 	}
 	return buf.String()
 }
 
+func sortedScanNames(scans map[string]*types.Scan) []string {
+	names := make([]string, 0, len(scans))
+	for name := range scans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// componentsTable lists the dependencies of each component, the component
+// named on its first row.
+func componentsTable(components []component.Component) *table.Table {
+	t := table.New("Component", "Name", "Version", "Language").NoRowBorders().SpaceColumn(2)
+	for _, comp := range components {
+		for i, dep := range comp.Deps {
+			name := ""
+			if i == 0 {
+				name = comp.Name
+			}
+			t.Row(name, dep.Name, dep.Version, dep.Language.String())
+		}
+	}
+	return t
+}
+
+// reportAtShaWrk renders the files and dependencies of a scan as html.
 func (a *Application) reportAtShaWrk(scan *types.Scan) string {
 	buf := bytes.NewBufferString("")
 	projName := "[Error finding project name]"
 	if proj, err := a.rtrvr.GetProjectById(scan.ProjectId); err == nil {
 		projName = proj.DisplayName
 	}
-	buf.WriteString(u.Format("%s at %s in %s\n", scan.RepoFullname, scan.Sha, projName))
-	buf.WriteString(u.Format("Dependencies from %s at %s\n", scan.Scan.Fullname, scan.Scan.Sha))
-	buf.WriteString("Files scanned:\n")
+	head := bytes.NewBufferString(u.Format("%s at %s in %s\n", scan.RepoFullname, scan.Sha, projName))
+	head.WriteString(u.Format("Dependencies from %s at %s\n", scan.Scan.Fullname, scan.Scan.Sha))
+	head.WriteString("Files scanned:\n")
 	for _, f := range scan.Scan.Files {
-		buf.WriteString(f)
-		buf.WriteString("\n")
+		head.WriteString(f)
+		head.WriteString("\n")
 	}
-	t := table.NewTable(3, len(scan.Scan.Deps))
-	for _, dep := range scan.Scan.Deps {
-		t.Fill(dep.Name, dep.Version, dep.Language.String())
+	if cfg := scan.Scan.Config; cfg != nil {
+		head.WriteString(u.Format("Configured by %s\n", c.ScanConfigFile))
+		for _, left := range []struct {
+			title string
+			items []string
+		}{{"Manifests excluded", cfg.Excluded}, {"Dependencies ignored", cfg.Ignored}, {"Issues suppressed", cfg.Suppressed}} {
+			if len(left.items) > 0 {
+				head.WriteString(u.Format("%s:\n  %s\n", left.title, strings.Join(left.items, "\n  ")))
+			}
+		}
+	}
+	buf.WriteString(preformatted(head.String()))
+	if len(scan.Scan.Components) > 1 {
+		buf.WriteString(componentsTable(scan.Scan.Components).RenderString(table.HTML))
+	} else {
+		buf.WriteString(depsTable(scan.Scan.Deps).RenderString(table.HTML))
+	}
+	if len(scan.Scan.Checks) > 0 {
+		checks := bytes.NewBufferString("Repository checks:\n")
+		for _, c := range scan.Scan.Checks {
+			checks.WriteString(c.String())
+			checks.WriteString("\n")
+		}
+		buf.WriteString(preformatted(checks.String()))
 	}
-	buf.WriteString(t.NoRowBorders().SpaceColumn(1).Format().String())
 	return buf.String()
 }
+
+// preformatted escapes text to show as it is in a page.
+func preformatted(text string) string {
+	return "<pre>" + html.EscapeString(text) + "</pre>"
+}
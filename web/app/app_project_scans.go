@@ -0,0 +1,84 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+)
+
+const scansPageSize = 50
+
+func (a *Application) scansPage(c *gin.Context) {
+	projId := c.Param("proj")
+	fullname := c.Param("org") + "/" + c.Param("repo")
+	var form struct {
+		Back  string `form:"button_back"`
+		After string `form:"after"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	scans, next, err := a.store.ScanPage(projId, fullname, form.After, scansPageSize)
+	if err != nil {
+		c.String(500, "Unable to get the scans: %s", err.Error())
+		return
+	}
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Sha", "Refs", "Timestamp"} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	for i, scan := range scans {
+		refs := make([]string, len(scan.Refs), len(scan.Refs))
+		for j, ref := range scan.Refs {
+			refs[j] = strings.TrimPrefix(ref, "refs/")
+		}
+		table.AddRow()
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlSubmitButton2("button_sha", scan.Sha)).Post().Action("/project/"+projId))
+		table.AddItem(i+1, s.NewHtmlString(html.EscapeString(strings.Join(refs, ", "))))
+		table.AddItem(i+1, s.NewHtmlString(scan.Timestamp.Format(time.RFC3339)))
+	}
+	h := gin.H{"repo": fullname, "scans": table.Template()}
+	if next != "" {
+		h["next"] = s.NewHtmlString(pageLink(c.Request.URL.Path, url.Values{}, next, "Older scans")).Template()
+	}
+	c.HTML(200, "scans.html", h)
+}
+
+func (a *Application) scansApi(c *gin.Context) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(scansPageSize)))
+	if err != nil || size <= 0 {
+		c.String(400, "Invalid size")
+		return
+	}
+	scans, next, err := a.store.ScanPage(c.Param("proj"), c.Param("org")+"/"+c.Param("repo"), c.Query("after"), size)
+	if err != nil {
+		c.String(500, "Unable to get the scans: %s", err.Error())
+		return
+	}
+	c.JSON(200, gin.H{"scans": scans, "next": next})
+}
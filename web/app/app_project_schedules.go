@@ -0,0 +1,148 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	nt "github.com/venicegeo/pz-gocommon/gocommon"
+	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+func (a *Application) schedulesPage(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back   string `form:"button_back"`
+		Add    string `form:"button_add"`
+		Delete string `form:"button_delete"`
+		Toggle string `form:"button_toggle"`
+		Run    string `form:"button_run"`
+		Repo   string `form:"repo"`
+		Cron   string `form:"cron"`
+		Refs   string `form:"refs"`
+		Rescan string `form:"rescan"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	repos, err := project.GetAllRepositories()
+	if err != nil {
+		c.String(500, "Unable to get the repositories: %s", err.Error())
+		return
+	}
+	h := gin.H{"project": project.DisplayName, "result": ""}
+	switch {
+	case form.Delete != "":
+		if err := a.store.DeleteSchedule(form.Delete); err != nil {
+			h["result"] = u.Format("Unable to delete the schedule: %s", err.Error())
+		}
+	case form.Toggle != "", form.Run != "":
+		id := form.Toggle + form.Run
+		sched, found, err := a.store.GetSchedule(id)
+		if err != nil || !found || sched.ProjectId != projId {
+			h["result"] = u.Format("Unable to find schedule %s", id)
+		} else if form.Toggle != "" {
+			sched.Enabled = !sched.Enabled
+			if sched.Enabled {
+				err = a.scheduler.Prepare(sched)
+			}
+			if err == nil {
+				err = a.store.PutSchedule(sched)
+			}
+			if err != nil {
+				h["result"] = u.Format("Unable to save the schedule: %s", err.Error())
+			}
+		} else if run, err := a.scheduler.RunNow(sched); err != nil {
+			h["result"] = u.Format("Unable to run the schedule: %s", err.Error())
+		} else {
+			h["result"] = u.Format("Queued %d new scans and %d rescans\n%s", run.New, run.Rescans, strings.Join(run.Errors, "\n"))
+		}
+	case form.Add != "":
+		sched := &types.Schedule{
+			Id:           nt.NewUuid().String(),
+			ProjectId:    projId,
+			RepoFullname: form.Repo,
+			Cron:         strings.TrimSpace(form.Cron),
+			Refs:         splitList(form.Refs),
+			Rescan:       form.Rescan != "",
+			Enabled:      true,
+			Created:      time.Now(),
+		}
+		if len(sched.Refs) == 0 {
+			sched.Refs = []string{"refs/heads/master"}
+		}
+		if err := a.scheduler.Prepare(sched); err != nil {
+			h["result"] = err.Error()
+		} else if err = a.store.PutSchedule(sched); err != nil {
+			h["result"] = u.Format("Unable to save the schedule: %s", err.Error())
+		}
+	}
+	scheds, err := a.store.Schedules(projId)
+	if err != nil {
+		c.String(500, "Unable to get the schedules: %s", err.Error())
+		return
+	}
+	options := `<option value="">All repositories</option>`
+	for _, repo := range repos {
+		options += u.Format(`<option value="%s">%s</option>`, html.EscapeString(repo.Fullname), html.EscapeString(repo.Fullname))
+	}
+	h["repos"] = s.NewHtmlString(options).Template()
+	h["schedules"] = schedulesTable(scheds).Template()
+	c.HTML(200, "schedules.html", h)
+}
+
+func schedulesTable(scheds []*types.Schedule) *s.HtmlTable {
+	table := s.NewHtmlTable()
+	table.AddRow()
+	for _, head := range []string{"Repository", "Cron", "Refs", "Rescan", "Enabled", "Last Run", "Next Run", "Last Error", "", "", ""} {
+		table.AddItem(0, s.NewHtmlBasic("b", head))
+	}
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	for i, sched := range scheds {
+		toggle := "Disable"
+		if !sched.Enabled {
+			toggle = "Enable"
+		}
+		table.AddRow()
+		for _, item := range []string{orAll(splitList(sched.RepoFullname)), sched.Cron, strings.Join(sched.Refs, ", "),
+			u.Format("%t", sched.Rescan), u.Format("%t", sched.Enabled), stamp(sched.LastRun), stamp(sched.NextRun), sched.LastError} {
+			table.AddItem(i+1, s.NewHtmlString(html.EscapeString(item)))
+		}
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlButton("Run Now", "button_run", sched.Id, "submit")).Post())
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlButton(toggle, "button_toggle", sched.Id, "submit")).Post())
+		table.AddItem(i+1, s.NewHtmlForm(s.NewHtmlButton("Delete", "button_delete", sched.Id, "submit")).Post())
+	}
+	return table
+}
@@ -16,13 +16,11 @@ package app
 
 import (
 	"bytes"
-	"encoding/json"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/venicegeo/vzutil-versioning/common/component"
 	d "github.com/venicegeo/vzutil-versioning/common/dependency"
-	"github.com/venicegeo/vzutil-versioning/web/es"
-	"github.com/venicegeo/vzutil-versioning/web/es/types"
 )
 
 func (a *Application) searchForDep(c *gin.Context) {
@@ -30,6 +28,7 @@ func (a *Application) searchForDep(c *gin.Context) {
 		Back         string `form:"button_back"`
 		DepName      string `form:"depsearchname"`
 		DepVersion   string `form:"depsearchversion"`
+		Component    string `form:"depsearchcomponent"`
 		ButtonSearch string `form:"button_depsearch"`
 	}
 	if err := c.Bind(&form); err != nil {
@@ -38,10 +37,12 @@ func (a *Application) searchForDep(c *gin.Context) {
 	}
 	form.DepName = strings.TrimSpace(form.DepName)
 	form.DepVersion = strings.TrimSpace(form.DepVersion)
+	form.Component = strings.TrimSpace(form.Component)
 	h := gin.H{
-		"data":             "Search Results will appear here",
-		"depsearchname":    form.DepName,
-		"depsearchversion": form.DepVersion,
+		"data":               "Search Results will appear here",
+		"depsearchname":      form.DepName,
+		"depsearchversion":   form.DepVersion,
+		"depsearchcomponent": form.Component,
 	}
 	if form.Back != "" {
 		c.Redirect(303, "ui")
@@ -51,7 +52,7 @@ func (a *Application) searchForDep(c *gin.Context) {
 			c.String(400, "Unable to retrieve the projects repositories: %s", err.Error())
 			return
 		}
-		code, dat := a.searchForDepWrk(form.DepName, form.DepVersion, repos)
+		code, dat := a.searchForDepWrk(form.DepName, form.DepVersion, form.Component, repos)
 		h["data"] = dat
 		c.HTML(code, "depsearch.html", h)
 	} else {
@@ -65,6 +66,7 @@ func (a *Application) searchForDepInProject(c *gin.Context) {
 		Back         string `form:"button_back"`
 		DepName      string `form:"depsearchname"`
 		DepVersion   string `form:"depsearchversion"`
+		Component    string `form:"depsearchcomponent"`
 		ButtonSearch string `form:"button_depsearch"`
 	}
 	if err := c.Bind(&form); err != nil {
@@ -73,10 +75,12 @@ func (a *Application) searchForDepInProject(c *gin.Context) {
 	}
 	form.DepName = strings.TrimSpace(form.DepName)
 	form.DepVersion = strings.TrimSpace(form.DepVersion)
+	form.Component = strings.TrimSpace(form.Component)
 	h := gin.H{
-		"data":             "Search Results will appear here",
-		"depsearchname":    form.DepName,
-		"depsearchversion": form.DepVersion,
+		"data":               "Search Results will appear here",
+		"depsearchname":      form.DepName,
+		"depsearchversion":   form.DepVersion,
+		"depsearchcomponent": form.Component,
 	}
 	if form.Back != "" {
 		c.Redirect(303, "/project/"+projId)
@@ -95,7 +99,7 @@ func (a *Application) searchForDepInProject(c *gin.Context) {
 		for i, repo := range repos {
 			reposStr[i] = repo.Fullname
 		}
-		code, dat := a.searchForDepWrk(form.DepName, form.DepVersion, reposStr)
+		code, dat := a.searchForDepWrk(form.DepName, form.DepVersion, form.Component, reposStr)
 		h["data"] = dat
 		c.HTML(code, "depsearch.html", h)
 	} else {
@@ -103,46 +107,37 @@ func (a *Application) searchForDepInProject(c *gin.Context) {
 	}
 }
 
-func (a *Application) searchForDepWrk(depName, depVersion string, repos []string) (int, string) {
+// searchForDepWrk lists the scans of the repositories with the dependency,
+// only those where a component of the name uses it when one is given.
+func (a *Application) searchForDepWrk(depName, depVersion, comp string, repos []string) (int, string) {
 	buf := bytes.NewBufferString("Searching for:\n")
-	nested := es.NewNestedQuery(types.Scan_SubDependenciesField)
-	must := es.NewBoolQ(
-		es.NewTerm(types.Scan_SubDependenciesField+"."+d.NameField, depName),
-		es.NewWildcard(types.Scan_SubDependenciesField+"."+d.VersionField, depVersion+"*"))
-
-	terms := es.NewTerms(types.Scan_FullnameField, repos...)
-
-	nested.SetInnerQuery(map[string]interface{}{"bool": es.NewBool().SetMust(must)})
-	query := map[string]interface{}{"bool": es.NewBool().SetMust(es.NewBoolQ(nested)).SetFilter(es.NewBoolQ(terms))}
-
-	hits, err := es.GetAllSource(a.index, RepositoryEntryType, query, []string{types.Scan_FullnameField, types.Scan_RefsField})
+	hits, err := a.store.SearchDependency(repos, depName, depVersion)
 	if err != nil {
 		return 500, "Failure executing bool query: " + err.Error()
 	}
 	deps := d.Dependencies{}
 	shas := map[string]map[string]map[string]struct{}{}
+	components := map[string][]string{}
 
-	for _, hit := range hits.Hits {
-		var scan types.Scan
-		if err = json.Unmarshal(*hit.Source, &scan); err != nil {
-			return 500, "Failure retrieving source: " + err.Error()
+	for _, hit := range hits {
+		hitComponents := hit.Components
+		if len(hitComponents) == 0 {
+			hitComponents = []string{component.Root}
 		}
-		if _, ok := shas[scan.RepoFullname]; !ok {
-			shas[scan.RepoFullname] = map[string]map[string]struct{}{}
+		if comp != "" && !containsString(hitComponents, comp) {
+			continue
 		}
-		for _, ref := range scan.Refs {
-			if _, ok := shas[scan.RepoFullname][ref]; !ok {
-				shas[scan.RepoFullname][ref] = map[string]struct{}{}
-			}
-			shas[scan.RepoFullname][ref][hit.Id] = struct{}{}
+		components[hit.Sha] = hitComponents
+		if _, ok := shas[hit.RepoFullname]; !ok {
+			shas[hit.RepoFullname] = map[string]map[string]struct{}{}
 		}
-		for _, innerHit := range hit.InnerHits[types.Scan_SubDependenciesField].Hits.Hits {
-			dep := new(d.Dependency)
-			if err = json.Unmarshal(*innerHit.Source, dep); err != nil {
-				return 500, "Error retrieving dependencies: " + err.Error()
+		for _, ref := range hit.Refs {
+			if _, ok := shas[hit.RepoFullname][ref]; !ok {
+				shas[hit.RepoFullname][ref] = map[string]struct{}{}
 			}
-			deps = append(deps, *dep)
+			shas[hit.RepoFullname][ref][hit.Sha] = struct{}{}
 		}
+		deps = append(deps, hit.Dependencies...)
 	}
 	d.RemoveExactDuplicates(&deps)
 	for _, dep := range deps {
@@ -160,7 +155,9 @@ func (a *Application) searchForDepWrk(depName, depVersion string, repos []string
 			buf.WriteString("\n")
 			for sha, _ := range shas {
 				buf.WriteString("\t\t")
-				buf.WriteString(sha[:40])
+				buf.WriteString(sha)
+				buf.WriteString(" in ")
+				buf.WriteString(strings.Join(components[sha], ", "))
 				buf.WriteString("\n")
 			}
 		}
@@ -0,0 +1,90 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// shaOverrides edits the table of versions used for shas that no tag points at.
+func (a *Application) shaOverrides(c *gin.Context) {
+	projId := c.Param("proj")
+	var form struct {
+		Back      string `form:"button_back"`
+		Save      string `form:"button_save"`
+		Overrides string `form:"overrides"`
+	}
+	if err := c.Bind(&form); err != nil {
+		c.String(400, "Unable to bind form: %s", err.Error())
+		return
+	}
+	if form.Back != "" {
+		c.Redirect(303, "/project/"+projId)
+		return
+	}
+	project, err := a.rtrvr.GetProjectById(projId)
+	if err != nil {
+		c.String(400, "Error getting this project: %s", err.Error())
+		return
+	}
+	h := gin.H{"project": project.DisplayName, "result": ""}
+	if form.Save != "" {
+		if overrides, err := parseOverrides(form.Overrides); err != nil {
+			h["result"] = u.Format("Unable to read the overrides: %s", err.Error())
+		} else {
+			project.ShaOverrides = overrides
+			if err = a.store.PutProject(project.Project); err != nil {
+				h["result"] = u.Format("Unable to save the overrides: %s", err.Error())
+			} else {
+				h["result"] = u.Format("Saved %d overrides. They apply to scans from now on.", len(overrides))
+			}
+		}
+	}
+	buf := bytes.NewBuffer([]byte{})
+	writer := csv.NewWriter(buf)
+	for _, o := range project.ShaOverrides {
+		writer.Write([]string{o.Name, o.Sha, o.Version})
+	}
+	writer.Flush()
+	h["overrides"] = buf.String()
+	c.HTML(200, "shas.html", h)
+}
+
+func parseOverrides(text string) ([]shas.Override, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimSpace(text)))
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]shas.Override, len(records), len(records))
+	for i, record := range records {
+		for j, field := range record {
+			record[j] = strings.TrimSpace(field)
+		}
+		if !shas.IsSha(strings.ToLower(record[1])) {
+			return nil, u.Error("Line %d: [%s] is not a sha", i+1, record[1])
+		}
+		res[i] = shas.Override{Name: strings.ToLower(record[0]), Sha: strings.ToLower(record[1]), Version: record[2]}
+	}
+	return res, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/common/table"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+type AsOfStatus string
+
+const AsOfScanned AsOfStatus = "scanned"
+const AsOfNoScan AsOfStatus = "no_scan"
+const AsOfError AsOfStatus = "error"
+
+// Inventory is what every repository of a project depended on at a ref as of a time.
+type Inventory struct {
+	Project string                  `json:"project"`
+	Ref     string                  `json:"ref"`
+	AsOf    time.Time               `json:"as_of"`
+	Repos   []RepoInventory         `json:"repositories"`
+	Grouped []dependency.Dependency `json:"grouped"`
+}
+
+type RepoInventory struct {
+	Repo    string                  `json:"repository"`
+	Status  AsOfStatus              `json:"status"`
+	Sha     string                  `json:"sha,omitempty"`
+	Scanned time.Time               `json:"scanned"`
+	Error   string                  `json:"error,omitempty"`
+	Deps    []dependency.Dependency `json:"dependencies"`
+}
+
+// inventoryAsOf takes the last scan of each repository on ref at or before asOf.
+// Repositories that had not been scanned on the ref by then are kept as no_scan.
+func inventoryAsOf(project *Project, ref string, asOf time.Time) (*Inventory, error) {
+	if ref = trimRef(ref); ref == "" {
+		return nil, u.Error("A ref is required")
+	}
+	if asOf.IsZero() {
+		return nil, u.Error("A time is required")
+	}
+	repos, err := project.GetAllRepositories()
+	if err != nil {
+		return nil, err
+	}
+	scans, err := project.ScansByRefAsOf(ref, asOf)
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{Project: project.DisplayName, Ref: ref, AsOf: asOf, Repos: make([]RepoInventory, 0, len(repos))}
+	grouped := map[string]dependency.Dependency{}
+	for _, repo := range repos {
+		ri := RepoInventory{Repo: repo.Fullname, Status: AsOfNoScan, Deps: []dependency.Dependency{}}
+		scan, ok := scans[repo.Fullname]
+		switch {
+		case !ok:
+			// not scanned on the ref by then
+		case scan.Scan == nil:
+			ri.Status, ri.Error = AsOfError, scan.Sha
+		default:
+			ri.Status, ri.Sha, ri.Scanned, ri.Deps = AsOfScanned, scan.Sha, scan.Timestamp, scan.Scan.Deps
+			for _, dep := range scan.Scan.Deps {
+				grouped[dep.String()] = dep
+			}
+		}
+		inv.Repos = append(inv.Repos, ri)
+	}
+	sort.Slice(inv.Repos, func(i, j int) bool { return inv.Repos[i].Repo < inv.Repos[j].Repo })
+	sorted := make(dependency.Dependencies, 0, len(grouped))
+	for _, dep := range grouped {
+		sorted = append(sorted, dep)
+	}
+	sort.Sort(sorted)
+	inv.Grouped = sorted
+	return inv, nil
+}
+
+func (inv *Inventory) title() string {
+	return u.Format("%s at %s as of %s", inv.Project, inv.Ref, inv.AsOf.Format(time.RFC3339))
+}
+
+func repoStatus(repo *RepoInventory) string {
+	switch repo.Status {
+	case AsOfNoScan:
+		return "No scan on this ref yet"
+	case AsOfError:
+		return repo.Error
+	}
+	return u.Format("%s scanned %s", repo.Sha, repo.Scanned.Format(time.RFC3339))
+}
+
+func depsTable(deps []dependency.Dependency) *table.Table {
+	t := table.New("Name", "Version", "Language").NoRowBorders().SpaceColumn(1)
+	for _, dep := range deps {
+		t.Row(dep.Name, dep.Version, dep.Language.String())
+	}
+	return t
+}
+
+// String renders the inventory in the grouped or seperate style of the ref report.
+func (inv *Inventory) String(typ string) string {
+	buf := bytes.NewBufferString(inv.title() + "\n")
+	switch typ {
+	case "seperate":
+		for i := range inv.Repos {
+			repo := &inv.Repos[i]
+			buf.WriteString(u.Format("\n%s\n%s\n", repo.Repo, repoStatus(repo)))
+			if repo.Status == AsOfScanned {
+				buf.WriteString(depsTable(repo.Deps).String())
+				buf.WriteString("\n")
+			}
+		}
+	default:
+		for i := range inv.Repos {
+			repo := &inv.Repos[i]
+			buf.WriteString(u.Format("%s: %s\n", repo.Repo, repoStatus(repo)))
+		}
+		buf.WriteString("\n")
+		buf.WriteString(depsTable(inv.Grouped).String())
+	}
+	return buf.String()
+}
+
+// WriteCSV writes the same columns in either style. The seperate style has a
+// row for each dependency of each repository, and the grouped style a row for
+// each repository followed by a row for each dependency of them all.
+func (inv *Inventory) WriteCSV(w io.Writer, typ string) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Repository", "Status", "Sha", "Scanned", "Error", "Dependency", "Version", "Language"})
+	repoCells := func(repo *RepoInventory) []string {
+		scanned := ""
+		if repo.Status == AsOfScanned {
+			scanned = repo.Scanned.Format(time.RFC3339)
+		}
+		return []string{repo.Repo, string(repo.Status), repo.Sha, scanned, repo.Error}
+	}
+	depCells := func(dep *dependency.Dependency) []string {
+		return []string{dep.Name, dep.Version, dep.Language.String()}
+	}
+	switch typ {
+	case "seperate":
+		for i := range inv.Repos {
+			repo := &inv.Repos[i]
+			if len(repo.Deps) == 0 {
+				writer.Write(append(repoCells(repo), "", "", ""))
+			}
+			for j := range repo.Deps {
+				writer.Write(append(repoCells(repo), depCells(&repo.Deps[j])...))
+			}
+		}
+	default:
+		for i := range inv.Repos {
+			writer.Write(append(repoCells(&inv.Repos[i]), "", "", ""))
+		}
+		for i := range inv.Grouped {
+			writer.Write(append([]string{"", "", "", "", ""}, depCells(&inv.Grouped[i])...))
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
@@ -0,0 +1,375 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	nt "github.com/venicegeo/pz-gocommon/gocommon"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const backfillPoll = time.Second * 15
+
+// BackfillManager scans the history of repositories that were just added.
+// A backfill lists the commits or tags to scan from the mirror, queues a job
+// for each, and once they have all finished records the differences between
+// each consecutive pair.
+type BackfillManager struct {
+	app *Application
+
+	mux *sync.Mutex
+	// stopped holds the ids of deleted projects, whose backfills are abandoned.
+	stopped map[string]bool
+}
+
+type BackfillRequest struct {
+	ProjectId    string
+	RepoFullname string
+	Ref          string
+	Since        time.Time
+	Until        time.Time
+	Stride       types.BackfillStride
+	Nth          int
+	TagPattern   string
+}
+
+type BackfillProgress struct {
+	Total     int `json:"total"`
+	Queued    int `json:"queued"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+func NewBackfillManager(app *Application) *BackfillManager {
+	return &BackfillManager{app, &sync.Mutex{}, map[string]bool{}}
+}
+
+func (b *BackfillManager) Start(req *BackfillRequest) (*types.Backfill, error) {
+	switch req.Stride {
+	case types.StrideEvery, types.StrideFirstParent, types.StrideTags:
+	case types.StrideNth:
+		if req.Nth < 1 {
+			return nil, u.Error("Every Nth commit needs N to be at least 1")
+		}
+	default:
+		return nil, u.Error("Unknown stride [%s]", req.Stride)
+	}
+	if req.Stride == types.StrideTags {
+		if req.TagPattern == "" {
+			req.TagPattern = "*"
+		}
+		if _, err := path.Match(req.TagPattern, ""); err != nil {
+			return nil, u.Error("Invalid tag pattern [%s]", req.TagPattern)
+		}
+	} else if req.Ref == "" {
+		return nil, u.Error("A ref is required")
+	} else if !strings.HasPrefix(req.Ref, "refs/") {
+		req.Ref = "refs/heads/" + req.Ref
+	}
+	if !req.Since.IsZero() && !req.Until.IsZero() && req.Until.Before(req.Since) {
+		return nil, u.Error("The end of the range is before the start")
+	}
+	if _, err := b.app.rtrvr.GetProjectById(req.ProjectId); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bf := &types.Backfill{
+		Id:           nt.NewUuid().String(),
+		ProjectId:    req.ProjectId,
+		RepoFullname: req.RepoFullname,
+		Ref:          req.Ref,
+		Since:        req.Since,
+		Until:        req.Until,
+		Stride:       req.Stride,
+		Nth:          req.Nth,
+		TagPattern:   req.TagPattern,
+		Status:       types.BackfillEnqueuing,
+		Items:        []types.BackfillItem{},
+		Created:      now,
+		Updated:      now,
+	}
+	if err := b.persist(bf); err != nil {
+		return nil, err
+	}
+	go b.run(bf)
+	return bf, nil
+}
+
+// Recover picks up the backfills that were in progress when the service stopped.
+func (b *BackfillManager) Recover() error {
+	bfs, err := b.app.store.BackfillsByStatus(types.BackfillEnqueuing, types.BackfillScanning, types.BackfillDiffing)
+	if err != nil {
+		return err
+	}
+	for _, bf := range bfs {
+		log.Println("[BACKFILL] Resuming", bf.Id)
+		go b.run(bf)
+	}
+	return nil
+}
+
+func (b *BackfillManager) Get(id string) (*types.Backfill, error) {
+	bf, found, err := b.app.store.GetBackfill(id)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, u.Error("Backfill %s does not exist", id)
+	}
+	return bf, nil
+}
+
+// ForProject returns the most recent backfills of a project.
+func (b *BackfillManager) ForProject(projectId string) ([]*types.Backfill, error) {
+	return b.app.store.ProjectBackfills(projectId, 25)
+}
+
+func (b *BackfillManager) Progress(bf *types.Backfill) (*BackfillProgress, error) {
+	ids := make([]string, len(bf.Items), len(bf.Items))
+	for i, item := range bf.Items {
+		ids[i] = item.JobId
+	}
+	statuses, err := b.app.jobs.Statuses(ids)
+	if err != nil {
+		return nil, err
+	}
+	res := &BackfillProgress{Total: len(ids)}
+	for _, id := range ids {
+		switch statuses[id] {
+		case types.JobRunning:
+			res.Running++
+		case types.JobSucceeded:
+			res.Succeeded++
+		case types.JobFailed:
+			res.Failed++
+		default:
+			res.Queued++
+		}
+	}
+	return res, nil
+}
+
+// StopProject abandons the backfills of a deleted project. They stop at their
+// next step without queuing, diffing or saving anything more.
+func (b *BackfillManager) StopProject(projectId string) {
+	b.mux.Lock()
+	b.stopped[projectId] = true
+	b.mux.Unlock()
+}
+
+func (b *BackfillManager) isStopped(bf *types.Backfill) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.stopped[bf.ProjectId]
+}
+
+func (b *BackfillManager) run(bf *types.Backfill) {
+	fail := func(err error) {
+		log.Printf("[BACKFILL] %s failed: %s\n", bf.Id, err.Error())
+		bf.Status = types.BackfillFailed
+		bf.Error = strings.TrimSpace(bf.Error + "\n" + err.Error())
+		b.persist(bf)
+	}
+	if bf.Status == types.BackfillEnqueuing {
+		if err := b.enqueue(bf); err != nil {
+			fail(err)
+			return
+		}
+		bf.Status = types.BackfillScanning
+		b.persist(bf)
+	}
+	if bf.Status == types.BackfillScanning {
+		for {
+			if b.isStopped(bf) {
+				log.Println("[BACKFILL] Stopped", bf.Id, "of a deleted project")
+				return
+			}
+			progress, err := b.Progress(bf)
+			if err != nil {
+				log.Printf("[BACKFILL] Unable to check progress of %s: %s\n", bf.Id, err.Error())
+			} else if progress.Queued+progress.Running == 0 {
+				break
+			}
+			time.Sleep(backfillPoll)
+		}
+		bf.Status = types.BackfillDiffing
+		b.persist(bf)
+	}
+	if bf.Status == types.BackfillDiffing {
+		if err := b.diff(bf); err != nil {
+			fail(err)
+			return
+		}
+		bf.Status = types.BackfillDone
+		b.persist(bf)
+		log.Printf("[BACKFILL] %s done with %d differences\n", bf.Id, bf.Diffs)
+	}
+}
+
+func (b *BackfillManager) enqueue(bf *types.Backfill) error {
+	project, err := b.app.rtrvr.GetProjectById(bf.ProjectId)
+	if err != nil {
+		return err
+	}
+	var repos []*Repository
+	if bf.RepoFullname == "" {
+		if repos, err = project.GetAllRepositories(); err != nil {
+			return err
+		}
+	} else {
+		repo, err := project.GetRepository(bf.RepoFullname)
+		if err != nil {
+			return err
+		}
+		repos = []*Repository{repo}
+	}
+	bf.Items = []types.BackfillItem{}
+	for _, repo := range repos {
+		if b.isStopped(bf) {
+			return u.Error("The project was deleted")
+		}
+		switch repo.DependencyInfo.CheckoutType {
+		case types.ExactSha, types.CustomRef:
+			bf.Error = strings.TrimSpace(u.Format("%s\nSkipped %s, it always scans %s", bf.Error, repo.Fullname, repo.DependencyInfo.CustomField))
+			continue
+		}
+		items, err := b.collect(bf, repo)
+		if err != nil {
+			return u.Error("%s: %s", repo.Fullname, err.Error())
+		}
+		for i, item := range items {
+			job := b.app.jobs.Enqueue(&SingleRunnerRequest{repo, item.Sha, item.Ref}, true, bf.Id)
+			items[i].JobId = job.Id
+		}
+		bf.Items = append(bf.Items, items...)
+		log.Printf("[BACKFILL] %s queued %d scans of %s\n", bf.Id, len(items), repo.Fullname)
+	}
+	return nil
+}
+
+// collect lists the commits of a repository that the backfill should scan, oldest first.
+func (b *BackfillManager) collect(bf *types.Backfill, repo *Repository) ([]types.BackfillItem, error) {
+	ctx := context.Background()
+	m, err := b.app.mirrors.Open(ctx, repo.DependencyInfo.RepoFullname, "")
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	res := []types.BackfillItem{}
+	if bf.Stride == types.StrideTags {
+		tags, err := m.Tags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		shas := []string{}
+		for ref, sha := range tags {
+			if ok, _ := path.Match(bf.TagPattern, strings.TrimPrefix(ref, "refs/tags/")); ok {
+				res = append(res, types.BackfillItem{RepoFullname: repo.Fullname, Sha: sha, Ref: ref})
+				shas = append(shas, sha)
+			}
+		}
+		times, err := m.CommitTimes(ctx, shas...)
+		if err != nil {
+			return nil, err
+		}
+		inRange := []types.BackfillItem{}
+		for _, item := range res {
+			item.Time = times[item.Sha]
+			if (bf.Since.IsZero() || !item.Time.Before(bf.Since)) && (bf.Until.IsZero() || !item.Time.After(bf.Until)) {
+				inRange = append(inRange, item)
+			}
+		}
+		sort.Slice(inRange, func(i, j int) bool {
+			if inRange[i].Time.Equal(inRange[j].Time) {
+				return inRange[i].Ref < inRange[j].Ref
+			}
+			return inRange[i].Time.Before(inRange[j].Time)
+		})
+		return inRange, nil
+	}
+	entries, err := m.Log(ctx, bf.Ref, bf.Since, bf.Until, bf.Stride == types.StrideFirstParent)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if bf.Stride == types.StrideNth && i%bf.Nth != 0 && i != len(entries)-1 {
+			continue
+		}
+		res = append(res, types.BackfillItem{RepoFullname: repo.Fullname, Sha: entry.Sha, Ref: bf.Ref, Time: entry.Time})
+	}
+	return res, nil
+}
+
+// diff records the difference between each consecutive pair of scans of a repository.
+func (b *BackfillManager) diff(bf *types.Backfill) error {
+	byRepo := map[string][]types.BackfillItem{}
+	for _, item := range bf.Items {
+		byRepo[item.RepoFullname] = append(byRepo[item.RepoFullname], item)
+	}
+	bf.Diffs = 0
+	for repoName, items := range byRepo {
+		if b.isStopped(bf) {
+			return u.Error("The project was deleted")
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Time.Before(items[j].Time) })
+		var previous *types.Scan
+		for _, item := range items {
+			scan, found, err := b.app.store.GetScan(bf.ProjectId, item.Sha)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if previous != nil && previous.Sha != scan.Sha {
+				exists, err := b.app.store.DifferenceExists(bf.ProjectId, previous.Sha, scan.Sha)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					diff, err := b.app.diffMan.diffCompareWrk(repoName, bf.ProjectId, item.Ref, previous.Scan, scan.Scan, previous.Sha, scan.Sha, scan.Timestamp, true)
+					if err != nil {
+						return err
+					}
+					if diff != nil {
+						bf.Diffs++
+					}
+				}
+			}
+			previous = scan
+		}
+	}
+	return nil
+}
+
+func (b *BackfillManager) persist(bf *types.Backfill) error {
+	if b.isStopped(bf) {
+		return nil
+	}
+	bf.Updated = time.Now()
+	err := b.app.store.PutBackfill(bf)
+	if err != nil {
+		log.Printf("[BACKFILL] Unable to save %s: %s\n", bf.Id, err.Error())
+	}
+	return err
+}
@@ -15,94 +15,57 @@
 package app
 
 import (
-	"encoding/json"
+	"context"
 	"log"
-	"sync"
+	"time"
 
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
 
+const jobTimeout = time.Minute * 30
+
 type Worker struct {
 	app *Application
 
-	snglRnnr        *SingleRunner
-	numWorkers      int
-	mux             *sync.Mutex
-	checkWorking    []bool
-	checkExistQueue chan *existsWork
-	cloneWorking    []bool
-	cloneQueue      chan *scanWork
-}
-
-type existsWork struct {
-	request   *SingleRunnerRequest
-	exists    chan *types.Scan
-	singleRet chan *types.Scan
-}
-type scanWork struct {
-	request   *SingleRunnerRequest
-	singleRet chan *types.Scan
+	snglRnnr   *SingleRunner
+	numWorkers int
 }
 
 func NewWorker(app *Application, numWorkers int) *Worker {
-	wrkr := Worker{app, NewSingleRunner(app), numWorkers, &sync.Mutex{}, make([]bool, numWorkers), make(chan *existsWork, 1000), make([]bool, numWorkers), make(chan *scanWork, 1000)}
+	wrkr := Worker{app, NewSingleRunner(app), numWorkers}
 	return &wrkr
 }
 
 func (w *Worker) JobsInSystem() int {
-	w.mux.Lock()
-	res := len(w.checkExistQueue) + len(w.cloneQueue)
-	for _, b := range w.checkWorking {
-		if b {
-			res++
-		}
-	}
-	for _, b := range w.cloneWorking {
-		if b {
-			res++
-		}
-	}
-	w.mux.Unlock()
-	return res
+	queued, running := w.app.jobs.Depth()
+	return queued + running
 }
 
 func (w *Worker) Start() {
-	w.startCheckExist()
-	w.startClone()
-}
-
-func (w *Worker) startCheckExist() {
 	work := func(worker int) {
 		for {
-			work := <-w.checkExistQueue
-			w.mux.Lock()
-			w.checkWorking[worker] = true
-			w.mux.Unlock()
-			log.Printf("[CHECK-WORKER (%d)] Starting work on %s\n", worker, work.request.sha)
-
-			item, err := w.app.index.GetByID(RepositoryEntryType, work.request.sha+"-"+work.request.repository.ProjectId)
-			if err != nil || !item.Found {
-				log.Printf("[CHECK-WORKER (%d)] Unable to check status of current sha: %s. Continuing\n", worker, err.Error())
+			qj := w.app.jobs.next()
+			request, err := w.app.jobs.requestFor(qj)
+			if err != nil {
+				log.Printf("[SCAN-WORKER (%d)] Unable to load the request for job %s: %s\n", worker, qj.job.Id, err.Error())
+				w.app.jobs.failed(qj, err)
+				continue
 			}
-			if item.Found {
-				repEntry := new(types.Scan)
-				if err = json.Unmarshal(*item.Source, repEntry); err != nil {
-					log.Printf("[CHECK-WORKER (%d)] Unable to unmarshal sha: %s\nReason: %s\n", worker, work.request.sha, err.Error())
-					work.exists <- nil
-					work.singleRet <- nil
+			log.Printf("[SCAN-WORKER (%d)] Starting work on %s\n", worker, request.sha)
+			if qj.job.CheckExists {
+				existing, err := w.findExisting(request)
+				if err != nil {
+					log.Printf("[SCAN-WORKER (%d)] Unable to check status of current sha: %s. Continuing\n", worker, err.Error())
+				}
+				if existing != nil {
+					log.Printf("[SCAN-WORKER (%d)] This sha already exists\n", worker)
+					w.app.jobs.foundExisting(qj, existing)
 					continue
 				}
-				work.exists <- repEntry
-				log.Printf("[CHECK-WORKER (%d)] This sha already exists\n", worker)
-				continue
+				w.app.jobs.notFound(qj)
 			}
-			work.exists <- nil
-			log.Printf("[CHECK-WORKER (%d)] Adding %s to clone queue\n", worker, work.request.sha)
-			w.cloneQueue <- &scanWork{work.request, work.singleRet}
-			w.mux.Lock()
-			w.checkWorking[worker] = false
-			w.mux.Unlock()
+			w.scan(worker, qj, request)
 		}
 	}
 	for i := 0; i < w.numWorkers; i++ {
@@ -110,43 +73,37 @@ func (w *Worker) startCheckExist() {
 	}
 }
 
-func (w *Worker) startClone() {
-	work := func(worker int) {
-		for {
-			done := make(chan bool, 1)
-			toPrint := make(chan string, 6)
-			go func() {
-				for {
-					select {
-					case x := <-toPrint:
-						log.Println(x)
-					case <-done:
-						return
-					}
-				}
-			}()
-			work := <-w.cloneQueue
-			w.mux.Lock()
-			w.cloneWorking[worker] = true
-			w.mux.Unlock()
+func (w *Worker) findExisting(request *SingleRunnerRequest) (*types.Scan, error) {
+	scan, _, err := w.app.store.GetScan(request.repository.ProjectId, request.sha)
+	return scan, err
+}
 
-			singleRet := w.snglRnnr.RunAgainstSingle(u.Format("[CLONE-WORKER (%d)] ", worker), toPrint, work.request)
-			done <- true
-			work.singleRet <- singleRet
-			w.mux.Lock()
-			w.cloneWorking[worker] = false
-			w.mux.Unlock()
+func (w *Worker) scan(worker int, qj *queuedJob, request *SingleRunnerRequest) {
+	done := make(chan bool, 1)
+	toPrint := make(chan string, 6)
+	go func() {
+		for {
+			select {
+			case x := <-toPrint:
+				log.Println(x)
+			case <-done:
+				return
+			}
 		}
-	}
-	for i := 0; i < w.numWorkers; i++ {
-		go work(i)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	scan, err := w.snglRnnr.RunAgainstSingle(ctx, u.Format("[SCAN-WORKER (%d)] ", worker), toPrint, request)
+	cancel()
+	done <- true
+	if err != nil {
+		w.app.jobs.failed(qj, err)
+	} else {
+		w.app.jobs.succeeded(qj, scan)
 	}
 }
 
+// AddTask queues a scan whose result is only returned on the given channels.
+// It does not block, so the channels should be buffered.
 func (w *Worker) AddTask(request *SingleRunnerRequest, exists chan *types.Scan, singleRet chan *types.Scan) {
-	if exists != nil {
-		w.checkExistQueue <- &existsWork{request, exists, singleRet}
-	} else {
-		w.cloneQueue <- &scanWork{request, singleRet}
-	}
+	w.app.jobs.EnqueueTransient(request, exists, singleRet)
 }
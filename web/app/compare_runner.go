@@ -15,10 +15,10 @@
 package app
 
 import (
-	"encoding/json"
-	"os/exec"
+	"context"
 
 	com "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/compare/pub"
 )
 
 type CompareRunner struct {
@@ -29,19 +29,10 @@ func NewCompareRunner(app *Application) *CompareRunner {
 	return &CompareRunner{app}
 }
 
-func (cr *CompareRunner) CompareStrings(actual, expected string) (string, error) {
-	res, err := exec.Command(cr.app.compareLocation, "-as", actual, "-es", expected).Output()
-	return string(res), err
-}
-
-func (cr *CompareRunner) CompareRepositories(actual, expected com.DependencyScans) (string, error) {
-	var adat, bdat []byte
-	var err error
-	if adat, err = json.Marshal(actual); err != nil {
-		return "", err
-	}
-	if bdat, err = json.Marshal(expected); err != nil {
+func (cr *CompareRunner) CompareRepositories(ctx context.Context, actual, expected com.DependencyScans) (string, error) {
+	comp, err := compare.Compare(ctx, actual, expected)
+	if err != nil {
 		return "", err
 	}
-	return cr.CompareStrings(string(adat), string(bdat))
+	return compare.Report(comp), nil
 }
@@ -0,0 +1,231 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const graphTimeout = time.Minute
+
+// DependencyGraph links the repositories of a project to the versions of one
+// another that they consume.
+type DependencyGraph struct {
+	ProjectId string       `json:"project_id"`
+	Ref       string       `json:"ref"`
+	Nodes     []*GraphNode `json:"nodes"`
+	Edges     []*GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	Repo      string `json:"repository"`
+	LatestTag string `json:"latest_tag"`
+	LatestSha string `json:"latest_sha"`
+	Error     string `json:"error,omitempty"`
+
+	tags map[string]string
+}
+
+// GraphEdge is a consumer using a producer. Tag is the producer tag the consumed
+// version maps to, and Behind is how many newer tags the producer has.
+type GraphEdge struct {
+	Consumer string `json:"consumer"`
+	Producer string `json:"producer"`
+	Version  string `json:"version"`
+	Tag      string `json:"tag"`
+	Behind   int    `json:"behind"`
+	Lagging  bool   `json:"lagging"`
+}
+
+// BuildDependencyGraph uses the latest scan of every repository in the project
+// at ref, and the tags of each repository from the mirror cache.
+func (a *Application) BuildDependencyGraph(project *Project, ref string) (*DependencyGraph, error) {
+	ref = trimRef(ref)
+	repos, err := project.GetAllRepositories()
+	if err != nil {
+		return nil, err
+	}
+	scans, err := project.ScansByRefInProject(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), graphTimeout)
+	defer cancel()
+
+	graph := &DependencyGraph{ProjectId: project.Id, Ref: ref, Nodes: make([]*GraphNode, len(repos)), Edges: []*GraphEdge{}}
+	wg := sync.WaitGroup{}
+	for i, repo := range repos {
+		node := &GraphNode{Repo: repo.Fullname}
+		graph.Nodes[i] = node
+		wg.Add(1)
+		go func(repo *Repository) {
+			defer wg.Done()
+			if err := a.loadTags(ctx, node, repo.DependencyInfo.RepoFullname); err != nil {
+				node.Error = err.Error()
+			}
+		}(repo)
+	}
+	wg.Wait()
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].Repo < graph.Nodes[j].Repo })
+
+	for _, consumer := range graph.Nodes {
+		scan, ok := scans[consumer.Repo]
+		if !ok || scan.Scan == nil {
+			continue
+		}
+		for _, dep := range scan.Scan.Deps {
+			for _, producer := range graph.Nodes {
+				if producer.Repo == consumer.Repo || !dependsOn(dep, producer.Repo) {
+					continue
+				}
+				graph.Edges = append(graph.Edges, producer.edgeFrom(consumer.Repo, dep.Version))
+			}
+		}
+	}
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Producer != graph.Edges[j].Producer {
+			return graph.Edges[i].Producer < graph.Edges[j].Producer
+		}
+		return graph.Edges[i].Consumer < graph.Edges[j].Consumer
+	})
+	return graph, nil
+}
+
+func (a *Application) loadTags(ctx context.Context, node *GraphNode, fullname string) error {
+	m, err := a.mirrors.Open(ctx, fullname, "")
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	tags, err := m.Tags(ctx)
+	if err != nil {
+		return err
+	}
+	node.tags = map[string]string{}
+	for ref, sha := range tags {
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		node.tags[name] = sha
+		if node.LatestTag == "" || dependency.CompareVersions(name, node.LatestTag) > 0 {
+			node.LatestTag, node.LatestSha = name, sha
+		}
+	}
+	return nil
+}
+
+// dependsOn reports whether the dependency is the repository, either as a Go
+// package whose github org/repo is the full name or as a package named after the repository.
+func dependsOn(dep dependency.Dependency, fullname string) bool {
+	fullname = strings.ToLower(fullname)
+	if dep.Language == lan.Go {
+		repo, ok := shas.GithubRepo(dep.Name)
+		return ok && strings.ToLower(repo) == fullname
+	}
+	parts := strings.Split(fullname, "/")
+	return dep.Name == parts[len(parts)-1]
+}
+
+func (n *GraphNode) edgeFrom(consumer, version string) *GraphEdge {
+	edge := &GraphEdge{Consumer: consumer, Producer: n.Repo, Version: version}
+	if shas.IsSha(version) {
+		for name, sha := range n.tags {
+			if strings.HasPrefix(sha, version) && (edge.Tag == "" || dependency.CompareVersions(name, edge.Tag) > 0) {
+				edge.Tag = name
+			}
+		}
+	} else if trimmed := strings.TrimLeft(version, "^~=<> "); trimmed != "" {
+		for name := range n.tags {
+			if dependency.CompareVersions(name, trimmed) == 0 {
+				edge.Tag = name
+			}
+		}
+	}
+	if n.LatestTag == "" {
+		return edge
+	}
+	if edge.Tag == "" {
+		// An untagged sha is only current if it is the latest tag itself
+		edge.Lagging = !shas.IsSha(version) || !strings.HasPrefix(n.LatestSha, version)
+		return edge
+	}
+	for name := range n.tags {
+		if dependency.CompareVersions(name, edge.Tag) > 0 {
+			edge.Behind++
+		}
+	}
+	edge.Lagging = edge.Behind > 0
+	return edge
+}
+
+func (e *GraphEdge) Label() string {
+	label := e.Version
+	if e.Tag != "" && e.Tag != e.Version {
+		label = e.Tag
+	}
+	if len(label) > 12 && shas.IsSha(label) {
+		label = label[:12]
+	}
+	if e.Behind > 0 {
+		label += u.Format(" (%d behind)", e.Behind)
+	}
+	return label
+}
+
+// Dot renders the graph in the graphviz language, with lagging consumers in red.
+func (g *DependencyGraph) Dot() string {
+	buf := bytes.NewBufferString("digraph dependencies {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		label := n.Repo
+		if n.LatestTag != "" {
+			label += "\\n" + n.LatestTag
+		}
+		buf.WriteString(u.Format("\t%s [label=%s];\n", dotQuote(n.Repo), dotQuote(label)))
+	}
+	for _, e := range g.Edges {
+		color := "black"
+		if e.Lagging {
+			color = "red"
+		}
+		buf.WriteString(u.Format("\t%s -> %s [label=%s, color=%s, fontcolor=%s];\n", dotQuote(e.Consumer), dotQuote(e.Producer), dotQuote(e.Label()), color, color))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func dotQuote(str string) string {
+	return `"` + strings.Replace(str, `"`, `\"`, -1) + `"`
+}
+
+// Svg renders the graph with the graphviz dot command.
+func (g *DependencyGraph) Svg(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "dot", "-Tsvg")
+	cmd.Stdin = strings.NewReader(g.Dot())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, u.Error("Unable to run dot: %s %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
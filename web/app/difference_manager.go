@@ -15,16 +15,14 @@
 package app
 
 import (
-	"encoding/json"
-	"os/exec"
+	"log"
 	"sort"
 	"strings"
 	"time"
 
 	c "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
 	t "github.com/venicegeo/vzutil-versioning/common/table"
-	"github.com/venicegeo/vzutil-versioning/compare/pub"
-	"github.com/venicegeo/vzutil-versioning/web/es"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
@@ -39,102 +37,83 @@ func NewDifferenceManager(app *Application) *DifferenceManager {
 	return &DifferenceManager{app, ""}
 }
 
-const DifferenceProjectField = "project_name"
-
-type Difference struct {
-	Id          string    `json:"id"`
-	RepoName    string    `json:"repo_name"`
-	ProjectName string    `json:"project_name"`
-	Ref         string    `json:"ref"`
-	OldSha      string    `json:"old_sha"`
-	NewSha      string    `json:"new_sha"`
-	Removed     []string  `json:"removed"`
-	Added       []string  `json:"added"`
-	Timestamp   time.Time `json:"time"`
-}
-
-const DifferenceMapping = `{
-	"dynamic":"strict",
-	"properties":{
-		"id":{"type":"keyword"},
-		"repo_name":{"type":"keyword"},
-		"project_name":{"type":"keyword"},
-		"ref":{"type":"keyword"},
-		"old_sha":{"type":"keyword"},
-		"new_sha":{"type":"keyword"},
-		"removed":{"type":"keyword"},
-		"added":{"type":"keyword"},
-		"time":{"type":"keyword"}
+// GenerateReport renders the difference as a heading and a table of changes,
+// as text or as html.
+func (dm *DifferenceManager) GenerateReport(d *types.Difference, r t.Renderer) string {
+	var table *t.Table
+	if len(d.Changes) > 0 || len(d.Removed)+len(d.Added) == 0 {
+		table = changesTable(d.Changes)
+	} else {
+		table = legacyDiffTable(d)
 	}
-}`
-
-func (d *Difference) SimpleString() string {
-	return u.Format("%s %s %s", d.RepoName, strings.TrimPrefix(d.Ref, "refs/"), d.Timestamp.String())
-}
-
-type diffSort []Difference
-
-func (d diffSort) Len() int {
-	return len(d)
-}
-func (d diffSort) Swap(i, j int) {
-	d[i], d[j] = d[j], d[i]
-}
-func (d diffSort) Less(i, j int) bool {
-	return d[i].Timestamp.After(d[j].Timestamp)
+	heading := u.Format("Repository %s %s from\n%s -> %s", d.RepoName, strings.TrimPrefix(d.Ref, "refs/"), d.OldSha, d.NewSha)
+	if r == t.HTML {
+		return preformatted(heading) + table.RenderString(r)
+	}
+	return heading + "\n" + table.RenderString(r)
 }
 
-func (dm *DifferenceManager) GenerateReport(d *Difference) string {
-	height := len(d.Removed)
-	if height < len(d.Added) {
-		height = len(d.Added)
-	}
-	table := t.NewTable(2, height+1)
-	added := []string{}
-	removed := []string{}
-	table.Fill("Removed")
-	table.Fill("Added")
-	for i := 0; i < height; i++ {
-		if i < len(d.Removed) {
-			removed = append(removed, d.Removed[i])
+// changesTable lists the changes, with the component of each when they are in
+// the components of a monorepo.
+func changesTable(changes []dependency.Change) *t.Table {
+	components := false
+	for _, change := range changes {
+		components = components || change.Component != ""
+	}
+	table := t.New("Change", "Dependency", "Language", "Old", "New", "Level")
+	if components {
+		table.Header("Component", "Change", "Dependency", "Language", "Old", "New", "Level")
+	}
+	for _, change := range changes {
+		lang := change.NewLanguage.String()
+		if change.Type == dependency.Removed {
+			lang = change.OldLanguage.String()
+		} else if change.Type == dependency.LanguageChanged {
+			lang = change.OldLanguage.String() + " -> " + lang
 		}
-		if i < len(d.Added) {
-			added = append(added, d.Added[i])
+		row := []string{string(change.Type), change.Name, lang, change.OldVersion, change.NewVersion, string(change.Level)}
+		if components {
+			row = append([]string{change.Component}, row...)
 		}
+		table.Row(row...)
 	}
+	return table.NoRowBorders().SpaceAllColumns()
+}
+
+// legacyDiffTable renders differences recorded before typed changes existed.
+func legacyDiffTable(d *types.Difference) *t.Table {
+	table := t.New("Removed", "Added")
+	removed := append([]string{}, d.Removed...)
+	added := append([]string{}, d.Added...)
 	sort.Strings(removed)
 	sort.Strings(added)
-	for len(removed) < height {
-		removed = append(removed, "")
-	}
-	for len(added) < height {
-		added = append(added, "")
-	}
-	for i := 0; i < height; i++ {
-		table.Fill(removed[i])
-		table.Fill(added[i])
+	for i := 0; i < len(removed) || i < len(added); i++ {
+		var r, a string
+		if i < len(removed) {
+			r = removed[i]
+		}
+		if i < len(added) {
+			a = added[i]
+		}
+		table.Row(r, a)
 	}
-	return u.Format("Repository %s %s from\n%s -> %s\n%s", d.RepoName, strings.TrimPrefix(d.Ref, "refs/"), d.OldSha, d.NewSha, table.Format().NoRowBorders().SpaceAllColumns().String())
+	return table.NoRowBorders().SpaceAllColumns()
 }
 
-func (d *DifferenceManager) GetAllDiffsInProject(proj string) (*[]Difference, error) {
-	hits, err := es.GetAll(d.app.index, DifferenceType, es.NewTerm(DifferenceProjectField, proj))
-	if err != nil {
-		return nil, err
-	}
-	diffs := make([]Difference, hits.TotalHits)
-	for i, hit := range hits.Hits {
-		var diff Difference
-		if err = json.Unmarshal(*hit.Source, &diff); err != nil {
-			return nil, err
-		}
-		diffs[i] = diff
-	}
-	sort.Sort(diffSort(diffs))
-	return &diffs, nil
+// DiffPage returns a page of the differences in a project, newest first.
+func (d *DifferenceManager) DiffPage(proj, cursor string, size int) ([]*types.Difference, string, error) {
+	return d.app.store.DifferencePage(proj, cursor, size)
 }
 
-func (d *DifferenceManager) ShaCompare(repoName string, files []string, oldSha, newSha string) (*Difference, error) {
+func (d *DifferenceManager) CountDiffs(proj string) (int64, error) {
+	return d.app.store.CountDifferences(proj)
+}
+
+func (d *DifferenceManager) GetDiff(id string) (*types.Difference, bool, error) {
+	return d.app.store.GetDifference(id)
+}
+
+func (d *DifferenceManager) ShaCompare(repoName string, files []string, oldSha, newSha string) (*types.Difference, error) {
 	ret := make(chan *types.Scan, 2)
 	defer func() {
 		close(ret)
@@ -150,57 +129,36 @@ func (d *DifferenceManager) ShaCompare(repoName string, files []string, oldSha,
 	return d.diffCompareWrk(repoName, "", "", oldScan.Scan, newScan.Scan, oldSha, newSha, time.Now(), false)
 }
 
-func (d *DifferenceManager) webhookCompare(repoName, projectName, ref string, oldEntry, newEntry *types.Scan) (*Difference, error) {
+func (d *DifferenceManager) webhookCompare(repoName, projectName, ref string, oldEntry, newEntry *types.Scan) (*types.Difference, error) {
 	return d.diffCompareWrk(repoName, projectName, ref, oldEntry.Scan, newEntry.Scan, oldEntry.Sha, newEntry.Sha, time.Now(), true)
 }
 
-func (d *DifferenceManager) diffCompareWrk(repoName, projectName, ref string, oldScan, newScan *c.DependencyScan, oldSha, newSha string, t time.Time, post bool) (*Difference, error) {
-	oldMap := map[string]*c.DependencyScan{
-		repoName: oldScan,
-	}
-	newMap := map[string]*c.DependencyScan{
-		repoName: newScan,
-	}
-	oldMapDat, err := json.Marshal(oldMap)
-	if err != nil {
-		return nil, err
-	}
-	newMapDat, err := json.Marshal(newMap)
-	if err != nil {
-		return nil, err
-	}
-	dat, err := exec.Command(d.app.compareLocation, "--es", u.Format(`'%s'`, strings.TrimSpace(string(oldMapDat))), "--as", u.Format(`'%s'`, strings.TrimSpace(string(newMapDat))), "--f", "json").CombinedOutput()
-	if err != nil {
-		return nil, u.Error("%s, %s", err.Error(), string(dat))
-	}
-	comp := []*compare.CompareStruct{}
-	if err = json.Unmarshal(dat, &comp); err != nil {
-		return nil, err
-	}
-	if len(comp) != 1 {
-		return nil, u.Error("Length of result was %d", len(comp))
-	}
-	c := comp[0]
-	removed := c.ExpectedExtra
-	added := c.ExpectedMissing
-
-	if len(added) == 0 && len(removed) == 0 {
+func (d *DifferenceManager) diffCompareWrk(repoName, projectName, ref string, oldScan, newScan *c.DependencyScan, oldSha, newSha string, t time.Time, post bool) (*types.Difference, error) {
+	changes := c.DiffScans(oldScan, newScan)
+	if len(changes) == 0 {
 		return nil, nil
 	}
 	id := u.Hash(u.Format("%s%d", repoName, t))
-	diff := Difference{id, repoName, projectName, ref, oldSha, newSha, removed, added, t}
+	diff := types.Difference{
+		Id:          id,
+		RepoName:    repoName,
+		ProjectName: projectName,
+		Ref:         ref,
+		OldSha:      oldSha,
+		NewSha:      newSha,
+		Changes:     changes,
+		Timestamp:   t,
+	}
 	if post {
-		resp, err := d.app.index.PostData("difference", id, diff)
-		if err != nil {
+		if err := d.app.store.PutDifference(&diff); err != nil {
 			return nil, err
 		}
-		if !resp.Created {
-			return nil, u.Error("Diff was not created")
-		}
 	}
 	return &diff, nil
 }
 
 func (d *DifferenceManager) Delete(id string) {
-	d.app.index.DeleteByIDWait("difference", id)
+	if err := d.app.store.DeleteDifference(id); err != nil {
+		log.Println("Unable to delete difference", id, ":", err.Error())
+	}
 }
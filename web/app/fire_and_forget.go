@@ -15,11 +15,9 @@
 package app
 
 import (
-	"encoding/json"
 	"log"
 
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
-	"github.com/venicegeo/vzutil-versioning/web/es"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 )
 
@@ -32,127 +30,118 @@ func NewFireAndForget(app *Application) *FireAndForget {
 }
 
 func (ff *FireAndForget) FireRequest(request *SingleRunnerRequest) {
-	go func(request *SingleRunnerRequest) {
-		exists := make(chan *types.Scan, 1)
-		ret := make(chan *types.Scan, 1)
-		defer func() {
-			close(exists)
-			close(ret)
-		}()
-		ff.app.wrkr.AddTask(request, exists, ret)
-		e := <-exists
-		if e != nil {
-			ff.tryUpdateScan(request.ref, e)
-		} else {
-			r := <-ret
-			if r != nil {
-				ff.postScan(r)
-			}
-		}
-	}(request)
+	ff.app.jobs.Enqueue(request, true, "")
+}
+
+// FireRescan scans a sha again even if it has been scanned before.
+func (ff *FireAndForget) FireRescan(request *SingleRunnerRequest) {
+	ff.app.jobs.Enqueue(request, false, "")
 }
 
 func (ff *FireAndForget) FireGit(git *s.GitWebhook) {
 	go func(git *s.GitWebhook) {
-		fire := func(git *s.GitWebhook, repo *Repository) {
-			ret := make(chan *types.Scan, 1)
-			defer close(ret)
-			request := &SingleRunnerRequest{
-				repository: repo,
-				sha:        git.AfterSha,
-				ref:        git.Ref,
-			}
-			ff.app.wrkr.AddTask(request, nil, ret)
-			r := <-ret
-			if r != nil {
-				ff.postScan(r)
-			}
-		}
-
 		log.Println("[RECIEVED WEBHOOK]", git.Repository.FullName, git.AfterSha, git.Ref)
 		if projects, err := ff.app.rtrvr.GetAllProjectNamesUsingRepository(git.Repository.FullName); err != nil {
 			log.Println("FAILED TO FIND PROJECTS USING REPOSITORY FOR WEBHOOK", git.AfterSha)
 		} else {
 			for _, p := range projects {
-				go func(p string) {
-					if repo, _, err := ff.app.rtrvr.GetRepository(git.Repository.FullName, p); err != nil {
-						log.Println("FAILED TO GET THE REPO INSTANCE UNDER", p)
-					} else {
-						go fire(git, repo)
-					}
-				}(p)
+				if repo, _, err := ff.app.rtrvr.GetRepository(git.Repository.FullName, p); err != nil {
+					log.Println("FAILED TO GET THE REPO INSTANCE UNDER", p)
+				} else {
+					ff.app.jobs.Enqueue(&SingleRunnerRequest{
+						repository: repo,
+						sha:        git.AfterSha,
+						ref:        git.Ref,
+					}, false, "")
+				}
 			}
 		}
 	}(git)
 
 }
 
-func (ff *FireAndForget) tryUpdateScan(ref string, scan *types.Scan) {
-	contains := false
-	for _, r := range scan.Refs {
-		if r == ref {
-			contains = true
-			break
+func (ff *FireAndForget) tryUpdateScan(scan *types.Scan, refs ...string) {
+	added := []string{}
+	for _, ref := range refs {
+		contains := false
+		for _, r := range scan.Refs {
+			if r == ref {
+				contains = true
+				break
+			}
+		}
+		if !contains {
+			scan.Refs = append(scan.Refs, ref)
+			added = append(added, ref)
 		}
 	}
-	if contains {
+	if len(added) == 0 {
 		return
 	}
-	scan.Refs = append(scan.Refs, ref)
 
-	_, err := ff.app.index.PostData(RepositoryEntryType, scan.Sha+"-"+scan.ProjectId, scan)
-	if err != nil {
+	if err := ff.app.store.PutScan(scan); err != nil {
 		log.Printf("[ES-WORKER] Unable to update entry %s: %s\n", scan.Sha, err.Error())
 	} else {
-		log.Println("[ES-WORKER] Updated", scan.Sha, "for", scan.ProjectId, "with ref", ref)
+		log.Println("[ES-WORKER] Updated", scan.Sha, "for", scan.ProjectId, "with refs", added)
 	}
 }
 
-func (ff *FireAndForget) postScan(scan *types.Scan) {
+func (ff *FireAndForget) postScan(scan *types.Scan, diff bool) {
 	log.Println("[ES-WORKER] Starting work on", scan.Sha, "for", scan.ProjectId)
-	var err error
+
+	// A rescan keeps the refs already recorded for the sha, and the refs it was
+	// already on were compared when it was first scanned.
+	known := map[string]bool{}
+	if existing, found, err := ff.app.store.GetScan(scan.ProjectId, scan.Sha); err == nil && found {
+		for _, ref := range existing.Refs {
+			known[ref] = true
+			if !containsString(scan.Refs, ref) {
+				scan.Refs = append(scan.Refs, ref)
+			}
+		}
+	}
 
 	testAgainstEntries := make(map[string]*types.Scan, len(scan.Refs))
 	for _, ref := range scan.Refs {
-		boolq := es.NewBool().
-			SetMust(es.NewBoolQ(
-				es.NewTerm(types.Scan_FullnameField, scan.RepoFullname),
-				es.NewTerm(types.Scan_RefsField, ref),
-				es.NewTerm(types.Scan_ProjectIdField, scan.ProjectId),
-				es.NewRange(types.Scan_TimestampField, "lt", scan.Timestamp)))
-		q := map[string]interface{}{
-			"query": map[string]interface{}{"bool": boolq},
-			"sort": map[string]interface{}{
-				types.Scan_TimestampField: "desc",
-			},
-			"size": 1,
+		if !diff {
+			break
 		}
-		result, err := ff.app.index.SearchByJSON(RepositoryEntryType, q)
-		if err == nil && result.Hits.TotalHits == 1 {
-			entry := new(types.Scan)
-			if err = json.Unmarshal(*result.Hits.Hits[0].Source, entry); err == nil {
-				testAgainstEntries[ref] = entry
-			}
+		if known[ref] {
+			continue
+		}
+		if entry, found, err := ff.app.store.LatestScan(scan.ProjectId, scan.RepoFullname, ref, scan.Timestamp); err == nil && found && entry.Sha != scan.Sha {
+			testAgainstEntries[ref] = entry
 		}
 	}
 
-	resp, err := ff.app.index.PostData(RepositoryEntryType, scan.Sha+"-"+scan.ProjectId, scan)
-	if err != nil {
+	if err := ff.app.store.PutScan(scan); err != nil {
 		log.Printf("[ES-WORKER] Unable to create entry %s: %s\n", scan.Sha, err.Error())
 		return
-	} else if !resp.Created {
-		log.Printf("[ES-WORKER] Unable to create entry %s. No error\n", scan.Sha)
-		return
 	}
 
 	log.Println("[ES-WORKER] Finished work on", scan.RepoFullname, scan.Sha)
+	previous := make([]*types.Scan, 0, len(testAgainstEntries))
+	for _, old := range testAgainstEntries {
+		previous = append(previous, old)
+	}
+	ff.app.notifyNewIssues(scan, previous)
 	for ref, old := range testAgainstEntries {
 		go ff.runDiff(scan.RepoFullname, scan.ProjectId, ref, old, scan)
 	}
 }
 
+// runDiff records and notifies the difference between two scans of a ref,
+// unless it has been recorded before.
 func (w *FireAndForget) runDiff(repoName, projectName, ref string, oldEntry, newEntry *types.Scan) {
-	if _, err := w.app.diffMan.webhookCompare(repoName, projectName, ref, oldEntry, newEntry); err != nil {
+	if exists, err := w.app.store.DifferenceExists(projectName, oldEntry.Sha, newEntry.Sha); err != nil {
+		log.Println("[ES-WORKER] Error checking for a diff:", err.Error())
+		return
+	} else if exists {
+		return
+	}
+	if diff, err := w.app.diffMan.webhookCompare(repoName, projectName, ref, oldEntry, newEntry); err != nil {
 		log.Println("[ES-WORKER] Error creating diff:", err.Error())
+	} else if diff != nil {
+		w.app.notifyDifference(diff)
 	}
 }
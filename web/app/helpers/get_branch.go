@@ -15,35 +15,21 @@
 package helpers
 
 import (
-	"os/exec"
-	"strings"
-	"time"
+	"context"
 
-	nt "github.com/venicegeo/pz-gocommon/gocommon"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
 
-func GetBranchSha(name, fullName, branch string) (string, error) {
-	code, _, _, err := nt.HTTP(nt.HEAD, u.Format("https://github.com/%s/tree/%s", fullName, branch), nt.NewHeaderBuilder().GetHeader(), nil)
+func GetBranchSha(ctx context.Context, cache *mirror.Cache, fullName, branch string) (string, error) {
+	m, err := cache.Open(ctx, fullName, "")
 	if err != nil {
 		return "", err
 	}
-	if code != 200 {
-		return "", u.Error("Could not verify branch [%s] on repo [%s]", branch, fullName)
-	}
-
-	tempFolder := u.Format("%d", time.Now().Unix())
-	defer func() { exec.Command("rm", "-rf", tempFolder).Run() }()
-	targetFolder := u.Format("%s/%s", tempFolder, name)
-	if err = exec.Command("mkdir", tempFolder).Run(); err != nil {
-		return "", err
-	}
-	if err = exec.Command("git", "clone", "https://github.com/"+fullName, targetFolder).Run(); err != nil {
-		return "", err
-	}
-	dat, err := exec.Command("git", "-C", targetFolder, "rev-parse", u.Format("origin/%s", branch)).Output()
+	defer m.Close()
+	sha, err := m.RevParse(ctx, "refs/heads/"+branch)
 	if err != nil {
-		return "", err
+		return "", u.Error("Could not verify branch [%s] on repo [%s]", branch, fullName)
 	}
-	return strings.TrimSpace(string(dat)), nil
+	return sha, nil
 }
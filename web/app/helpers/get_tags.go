@@ -15,22 +15,19 @@
 package helpers
 
 import (
-	"errors"
-	"os/exec"
-	"strings"
-	"time"
+	"context"
 
 	nt "github.com/venicegeo/pz-gocommon/gocommon"
-	u "github.com/venicegeo/vzutil-versioning/web/util"
+	"github.com/venicegeo/vzutil-versioning/single/mirror"
 )
 
 type TagsRunner struct {
-	name     string
+	cache    *mirror.Cache
 	fullName string
 }
 
-func NewTagsRunner(name, fullName string) *TagsRunner {
-	return &TagsRunner{name, fullName}
+func NewTagsRunner(cache *mirror.Cache, fullName string) *TagsRunner {
+	return &TagsRunner{cache, fullName}
 }
 
 func (tr *TagsRunner) CanDo() (bool, error) {
@@ -38,36 +35,19 @@ func (tr *TagsRunner) CanDo() (bool, error) {
 	return code == 200, err
 }
 
-func (tr *TagsRunner) Run() (res map[string]string, err error) {
-	res = map[string]string{}
-	tmp := map[string]string{}
-	tempFolder := u.Format("%d", time.Now().Unix())
-	defer func() { exec.Command("rm", "-rf", tempFolder).Run() }()
-	targetFolder := u.Format("%s/%s", tempFolder, tr.name)
-	if err = exec.Command("mkdir", tempFolder).Run(); err != nil {
+func (tr *TagsRunner) Run(ctx context.Context) (map[string]string, error) {
+	res := map[string]string{}
+	m, err := tr.cache.Open(ctx, tr.fullName, "")
+	if err != nil {
 		return res, err
 	}
-	if err = exec.Command("git", "clone", "https://github.com/"+tr.fullName, targetFolder).Run(); err != nil {
+	defer m.Close()
+	tags, err := m.Tags(ctx)
+	if err != nil {
 		return res, err
 	}
-	var dat []byte
-	if dat, err = exec.Command("git", "-C", targetFolder, "show-ref", "--tags", "-d").Output(); err != nil {
-		return res, err
-	}
-	lines := strings.Split(string(dat), "\n")
-	for _, l := range lines {
-		if l == "" {
-			continue
-		}
-		shaRef := strings.Split(l, " ")
-		if len(shaRef) != 2 {
-			return res, errors.New("Problem parsing this line [" + l + "]")
-		}
-		tmp[strings.TrimSuffix(shaRef[1], "^{}")] = shaRef[0]
-	}
-	for k, v := range tmp {
+	for k, v := range tags {
 		res[v] = k
 	}
 	return res, nil
-
 }
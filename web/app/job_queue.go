@@ -0,0 +1,451 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	nt "github.com/venicegeo/pz-gocommon/gocommon"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const jobMaxAttempts = 3
+const jobBaseBackoff = time.Second * 30
+const jobMaxBackoff = time.Minute * 10
+
+// JobQueue keeps every scan request as a job document so that queued work
+// survives a restart. Active jobs are also held in memory, which is what the
+// workers schedule from, since new documents are not searchable right away.
+type JobQueue struct {
+	app *Application
+
+	mux   *sync.Mutex
+	jobs  map[string]*queuedJob
+	order []string
+	wake  chan bool
+}
+
+type queuedJob struct {
+	job     *types.Job
+	request *SingleRunnerRequest
+	waiters []jobWaiter
+	// dropped is set when the project of a running job is deleted, so that
+	// neither the job nor its scan are saved when it finishes.
+	dropped bool
+}
+
+type jobWaiter struct {
+	exists    chan *types.Scan
+	singleRet chan *types.Scan
+}
+
+func NewJobQueue(app *Application) *JobQueue {
+	return &JobQueue{app, &sync.Mutex{}, map[string]*queuedJob{}, []string{}, make(chan bool, 100)}
+}
+
+func jobId(projectId, repoFullname, sha string) string {
+	return u.Hash(projectId + "/" + repoFullname + "@" + sha)
+}
+
+// Recover reloads the jobs that were queued or running when the service stopped.
+// Transient jobs had someone waiting on them in memory, so they can not be resumed.
+func (q *JobQueue) Recover() error {
+	jobs, err := q.app.store.JobsByStatus(types.JobQueued, types.JobRunning)
+	if err != nil {
+		return err
+	}
+	recovered := []*types.Job{}
+	for _, job := range jobs {
+		if job.Transient {
+			job.Status = types.JobFailed
+			job.Error = "Abandoned during restart"
+			job.Updated = time.Now()
+			q.persist(job)
+			continue
+		}
+		job.Status = types.JobQueued
+		job.NextAttempt = time.Now()
+		recovered = append(recovered, job)
+	}
+	sort.Slice(recovered, func(i, j int) bool { return recovered[i].Created.Before(recovered[j].Created) })
+	q.mux.Lock()
+	for _, job := range recovered {
+		q.jobs[job.Id] = &queuedJob{job, nil, nil, false}
+		q.order = append(q.order, job.Id)
+	}
+	q.mux.Unlock()
+	log.Println("[JOB-QUEUE] Recovered", len(recovered), "jobs")
+	q.signal()
+	return nil
+}
+
+// Enqueue records a scan of a repository in a project. A job for the same
+// project, repository and sha that has not finished yet absorbs the request.
+// Jobs made for a backfill always check out their sha and leave the
+// differences to the backfill.
+func (q *JobQueue) Enqueue(request *SingleRunnerRequest, checkExists bool, backfill string) *types.Job {
+	id := jobId(request.repository.ProjectId, request.repository.Fullname, request.sha)
+	now := time.Now()
+	q.mux.Lock()
+	if qj, ok := q.jobs[id]; ok {
+		if request.ref != "" && !containsString(qj.job.Refs, request.ref) {
+			qj.job.Refs = append(qj.job.Refs, request.ref)
+		}
+		qj.job.CheckExists = qj.job.CheckExists && checkExists
+		qj.job.Updated = now
+		job := *qj.job
+		q.mux.Unlock()
+		q.persist(&job)
+		log.Println("[JOB-QUEUE] Merged request into job", id)
+		return &job
+	}
+	job := &types.Job{
+		Id:           id,
+		ProjectId:    request.repository.ProjectId,
+		RepoFullname: request.repository.Fullname,
+		Sha:          request.sha,
+		Refs:         []string{},
+		Status:       types.JobQueued,
+		CheckExists:  checkExists,
+		Backfill:     backfill,
+		MaxAttempts:  jobMaxAttempts,
+		Created:      now,
+		Updated:      now,
+		NextAttempt:  now,
+	}
+	if request.ref != "" {
+		job.Refs = append(job.Refs, request.ref)
+	}
+	if backfill != "" {
+		request = pinnedRequest(request)
+	}
+	q.jobs[id] = &queuedJob{job, request, nil, false}
+	q.order = append(q.order, id)
+	cpy := *job
+	q.mux.Unlock()
+	q.persist(&cpy)
+	q.signal()
+	return &cpy
+}
+
+// EnqueueTransient records a scan whose result is only handed back to the caller.
+// It is attempted once and is not resumed after a restart.
+func (q *JobQueue) EnqueueTransient(request *SingleRunnerRequest, exists chan *types.Scan, singleRet chan *types.Scan) *types.Job {
+	now := time.Now()
+	job := &types.Job{
+		Id:           nt.NewUuid().String(),
+		ProjectId:    request.repository.ProjectId,
+		RepoFullname: request.repository.Fullname,
+		Sha:          request.sha,
+		Refs:         []string{},
+		Status:       types.JobQueued,
+		CheckExists:  exists != nil,
+		Transient:    true,
+		MaxAttempts:  1,
+		Created:      now,
+		Updated:      now,
+		NextAttempt:  now,
+	}
+	if request.ref != "" {
+		job.Refs = append(job.Refs, request.ref)
+	}
+	q.mux.Lock()
+	q.jobs[job.Id] = &queuedJob{job, request, []jobWaiter{jobWaiter{exists, singleRet}}, false}
+	q.order = append(q.order, job.Id)
+	cpy := *job
+	q.mux.Unlock()
+	q.persist(&cpy)
+	q.signal()
+	return &cpy
+}
+
+// DropProject forgets the jobs of a deleted project. Queued jobs are removed
+// and running ones finish without saving anything.
+func (q *JobQueue) DropProject(projectId string) {
+	q.mux.Lock()
+	removed := []*queuedJob{}
+	order := []string{}
+	for _, id := range q.order {
+		if qj := q.jobs[id]; qj.job.ProjectId == projectId {
+			delete(q.jobs, id)
+			removed = append(removed, qj)
+		} else {
+			order = append(order, id)
+		}
+	}
+	q.order = order
+	for _, qj := range q.jobs {
+		if qj.job.ProjectId == projectId {
+			qj.dropped = true
+		}
+	}
+	q.mux.Unlock()
+	for _, qj := range removed {
+		for _, w := range qj.waiters {
+			if w.exists != nil {
+				w.exists <- nil
+			}
+			if w.singleRet != nil {
+				w.singleRet <- nil
+			}
+		}
+	}
+	log.Println("[JOB-QUEUE] Dropped", len(removed), "queued jobs of project", projectId)
+}
+
+func (q *JobQueue) isDropped(qj *queuedJob) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return qj.dropped
+}
+
+// next blocks until a queued job is due and marks it as running.
+func (q *JobQueue) next() *queuedJob {
+	for {
+		wait := time.Minute
+		q.mux.Lock()
+		now := time.Now()
+		for i, id := range q.order {
+			qj := q.jobs[id]
+			if qj.job.NextAttempt.After(now) {
+				if d := qj.job.NextAttempt.Sub(now); d < wait {
+					wait = d
+				}
+				continue
+			}
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			qj.job.Status = types.JobRunning
+			qj.job.Attempts++
+			qj.job.Updated = now
+			job := *qj.job
+			q.mux.Unlock()
+			q.persist(&job)
+			return qj
+		}
+		q.mux.Unlock()
+		select {
+		case <-q.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// requestFor rebuilds the request of a job that was recovered from the index.
+func (q *JobQueue) requestFor(qj *queuedJob) (*SingleRunnerRequest, error) {
+	if qj.request != nil {
+		return qj.request, nil
+	}
+	repo, _, err := q.app.rtrvr.GetRepository(qj.job.RepoFullname, qj.job.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	ref := ""
+	if len(qj.job.Refs) > 0 {
+		ref = qj.job.Refs[0]
+	}
+	qj.request = &SingleRunnerRequest{repository: repo, sha: qj.job.Sha, ref: ref}
+	if qj.job.Backfill != "" {
+		qj.request = pinnedRequest(qj.request)
+	}
+	return qj.request, nil
+}
+
+// pinnedRequest copies the request with the repository set to check out the incoming sha.
+func pinnedRequest(request *SingleRunnerRequest) *SingleRunnerRequest {
+	repo := *request.repository.Repository
+	repo.DependencyInfo.CheckoutType = types.IncomingSha
+	return &SingleRunnerRequest{&Repository{request.repository.store, request.repository.project, &repo}, request.sha, request.ref}
+}
+
+// foundExisting finishes a job whose sha had already been scanned.
+func (q *JobQueue) foundExisting(qj *queuedJob, existing *types.Scan) {
+	q.mux.Lock()
+	refs := append([]string{}, qj.job.Refs...)
+	q.mux.Unlock()
+	for _, w := range qj.waiters {
+		if w.exists != nil {
+			w.exists <- existing
+		}
+	}
+	if !qj.job.Transient && !q.isDropped(qj) {
+		q.app.ff.tryUpdateScan(existing, refs...)
+	}
+	q.finish(qj, types.JobSucceeded, "")
+}
+
+// notFound tells anyone waiting on the existence check that a scan will be run.
+func (q *JobQueue) notFound(qj *queuedJob) {
+	for _, w := range qj.waiters {
+		if w.exists != nil {
+			w.exists <- nil
+		}
+	}
+}
+
+// succeeded finishes a job with the scan that was produced for it.
+func (q *JobQueue) succeeded(qj *queuedJob, scan *types.Scan) {
+	q.mux.Lock()
+	scan.Refs = append([]string{}, qj.job.Refs...)
+	q.mux.Unlock()
+	for _, w := range qj.waiters {
+		if w.singleRet != nil {
+			w.singleRet <- scan
+		}
+	}
+	if !qj.job.Transient && !q.isDropped(qj) {
+		q.app.ff.postScan(scan, qj.job.Backfill == "")
+	}
+	q.finish(qj, types.JobSucceeded, "")
+}
+
+// failed puts a job back in the queue with a backoff, or gives up on it once
+// it has used all of its attempts.
+func (q *JobQueue) failed(qj *queuedJob, err error) {
+	q.mux.Lock()
+	if qj.job.Attempts < qj.job.MaxAttempts && !qj.dropped {
+		qj.job.Status = types.JobQueued
+		qj.job.Error = err.Error()
+		qj.job.Updated = time.Now()
+		qj.job.NextAttempt = qj.job.Updated.Add(jobBackoff(qj.job.Attempts))
+		q.order = append(q.order, qj.job.Id)
+		job := *qj.job
+		q.mux.Unlock()
+		log.Printf("[JOB-QUEUE] Job %s failed attempt %d, retrying at %s: %s\n", job.Id, job.Attempts, job.NextAttempt.Format(time.RFC3339), err.Error())
+		q.persist(&job)
+		return
+	}
+	q.mux.Unlock()
+	for _, w := range qj.waiters {
+		if w.singleRet != nil {
+			w.singleRet <- nil
+		}
+	}
+	log.Printf("[JOB-QUEUE] Job %s failed: %s\n", qj.job.Id, err.Error())
+	q.finish(qj, types.JobFailed, err.Error())
+	if !qj.job.Transient && !q.isDropped(qj) {
+		q.mux.Lock()
+		job := *qj.job
+		q.mux.Unlock()
+		q.app.notifyScanFailed(&job)
+	}
+}
+
+func (q *JobQueue) finish(qj *queuedJob, status types.JobStatus, errStr string) {
+	q.mux.Lock()
+	delete(q.jobs, qj.job.Id)
+	qj.job.Status = status
+	qj.job.Error = errStr
+	qj.job.Updated = time.Now()
+	job := *qj.job
+	dropped := qj.dropped
+	q.mux.Unlock()
+	if !dropped {
+		q.persist(&job)
+	}
+}
+
+func jobBackoff(attempts int) time.Duration {
+	d := jobBaseBackoff << uint(attempts-1)
+	if d > jobMaxBackoff || d <= 0 {
+		d = jobMaxBackoff
+	}
+	return d
+}
+
+func (q *JobQueue) persist(job *types.Job) {
+	if err := q.app.store.PutJob(job); err != nil {
+		log.Printf("[JOB-QUEUE] Unable to save job %s: %s\n", job.Id, err.Error())
+	}
+}
+
+func (q *JobQueue) signal() {
+	select {
+	case q.wake <- true:
+	default:
+	}
+}
+
+// Depth returns the number of jobs that are queued and running.
+func (q *JobQueue) Depth() (queued int, running int) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	for _, qj := range q.jobs {
+		if qj.job.Status == types.JobRunning {
+			running++
+		} else {
+			queued++
+		}
+	}
+	return queued, running
+}
+
+// Active returns copies of the jobs that are queued and running.
+func (q *JobQueue) Active() []*types.Job {
+	q.mux.Lock()
+	res := make([]*types.Job, 0, len(q.jobs))
+	for _, qj := range q.jobs {
+		job := *qj.job
+		res = append(res, &job)
+	}
+	q.mux.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(res[j].Created) })
+	return res
+}
+
+// History returns a page of the most recently updated jobs, optionally only those with a status.
+func (q *JobQueue) History(status, cursor string, size int) ([]*types.Job, string, error) {
+	return q.app.store.JobHistory(types.JobStatus(status), cursor, size)
+}
+
+// Statuses returns the status of each of the jobs, whether active or finished.
+func (q *JobQueue) Statuses(ids []string) (map[string]types.JobStatus, error) {
+	res := map[string]types.JobStatus{}
+	lookup := []string{}
+	q.mux.Lock()
+	for _, id := range ids {
+		if qj, ok := q.jobs[id]; ok {
+			res[id] = qj.job.Status
+		} else {
+			lookup = append(lookup, id)
+		}
+	}
+	q.mux.Unlock()
+	jobs, err := q.app.store.Jobs(lookup)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		res[job.Id] = job.Status
+	}
+	return res, nil
+}
+
+// CountFinished returns the number of stored jobs with a final status.
+func (q *JobQueue) CountFinished(status types.JobStatus) (int64, error) {
+	return q.app.store.CountJobs(status)
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -33,7 +33,7 @@ func TestMain(m *testing.M) {
 	index.SetMapping(ProjectEntryType, j.JsonString(es.ProjectEntryMapping))
 	index.SetMapping(ProjectType, j.JsonString(es.ProjectMapping))
 
-	testApp = NewApplication(index, "../single", "../compare", "../templates/", false)
+	testApp = NewApplication(index, "../templates/", false)
 	testApp.StartInternals()
 
 	os.Exit(m.Run())
@@ -0,0 +1,172 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	"github.com/venicegeo/vzutil-versioning/web/notify"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const notifyFlushInterval = time.Minute
+
+// startNotifier sets up delivery of project events. Email needs VZUTIL_SMTP_ADDR
+// and VZUTIL_SMTP_FROM, and VZUTIL_SMTP_USER and VZUTIL_SMTP_PASS when the
+// server requires a login.
+func (a *Application) startNotifier() {
+	client := &http.Client{Timeout: time.Second * 30}
+	senders := map[types.Channel]notify.Sender{
+		types.ChannelWebhook: &notify.WebhookSender{Client: client},
+		types.ChannelSlack:   &notify.SlackSender{Client: client},
+	}
+	if addr := os.Getenv("VZUTIL_SMTP_ADDR"); addr != "" {
+		if host, _, err := net.SplitHostPort(addr); err != nil {
+			log.Printf("[NOTIFY] Not sending email, VZUTIL_SMTP_ADDR %s is not a host and port: %s\n", addr, err.Error())
+		} else {
+			email := &notify.EmailSender{Addr: addr, From: os.Getenv("VZUTIL_SMTP_FROM")}
+			if user := os.Getenv("VZUTIL_SMTP_USER"); user != "" {
+				email.Auth = smtp.PlainAuth("", user, os.Getenv("VZUTIL_SMTP_PASS"), host)
+			}
+			senders[types.ChannelEmail] = email
+		}
+	}
+	a.notifier = notify.NewNotifier(senders, a.store)
+	go a.notifier.Run(context.Background(), notifyFlushInterval)
+}
+
+// notify hands an event to the subscriptions of its project in the background.
+func (a *Application) notify(e *notify.Event) {
+	go func() {
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		if project, found, err := a.store.GetProject(e.ProjectId); err == nil && found {
+			e.Project = project.DisplayName
+		} else {
+			e.Project = e.ProjectId
+		}
+		subs, err := a.store.Subscriptions(e.ProjectId)
+		if err != nil {
+			log.Printf("[NOTIFY] Unable to get the subscriptions of %s: %s\n", e.ProjectId, err.Error())
+			return
+		}
+		a.notifier.Notify(subs, e)
+	}()
+}
+
+func (a *Application) notifyDifference(diff *types.Difference) {
+	details := make([]string, len(diff.Changes), len(diff.Changes))
+	languages := map[string]bool{}
+	for i, change := range diff.Changes {
+		details[i] = change.String()
+		for _, lang := range []string{change.OldLanguage.String(), change.NewLanguage.String()} {
+			if lang != "" {
+				languages[lang] = true
+			}
+		}
+	}
+	a.notify(&notify.Event{
+		Kind:      types.EventDifference,
+		ProjectId: diff.ProjectName,
+		Repo:      diff.RepoName,
+		Ref:       diff.Ref,
+		Sha:       diff.NewSha,
+		Summary:   u.Format("%d dependency changes on %s from %s to %s", len(diff.Changes), strings.TrimPrefix(diff.Ref, "refs/"), shortSha(diff.OldSha), shortSha(diff.NewSha)),
+		Details:   details,
+		Languages: sortedKeys(languages),
+		Time:      diff.Timestamp,
+	})
+}
+
+func (a *Application) notifyScanFailed(job *types.Job) {
+	a.notify(&notify.Event{
+		Kind:      types.EventScanFailed,
+		ProjectId: job.ProjectId,
+		Repo:      job.RepoFullname,
+		Sha:       job.Sha,
+		Summary:   u.Format("Scan of %s failed after %d attempts", shortSha(job.Sha), job.Attempts),
+		Details:   []string{job.Error},
+	})
+}
+
+// notifyNewIssues reports the kinds of issue in a scan that none of the previous scans had.
+func (a *Application) notifyNewIssues(scan *types.Scan, previous []*types.Scan) {
+	if len(previous) == 0 || scan.Scan == nil {
+		return
+	}
+	known := map[string]bool{}
+	for _, old := range previous {
+		if old.Scan == nil {
+			continue
+		}
+		for _, issue := range old.Scan.Issues {
+			known[notify.IssueType(issue)] = true
+		}
+	}
+	details := []string{}
+	for _, issue := range scan.Scan.Issues {
+		if typ := notify.IssueType(issue); !known[typ] {
+			known[typ] = true
+			details = append(details, issue)
+		}
+	}
+	if len(details) == 0 {
+		return
+	}
+	a.notify(&notify.Event{
+		Kind:      types.EventNewIssue,
+		ProjectId: scan.ProjectId,
+		Repo:      scan.RepoFullname,
+		Sha:       scan.Sha,
+		Summary:   u.Format("%d new kinds of issue at %s", len(details), shortSha(scan.Sha)),
+		Details:   details,
+		Languages: scanLanguages(scan.Scan.Deps),
+	})
+}
+
+func scanLanguages(deps []dependency.Dependency) []string {
+	languages := map[string]bool{}
+	for _, dep := range deps {
+		languages[dep.Language.String()] = true
+	}
+	return sortedKeys(languages)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 10 {
+		return sha[:10]
+	}
+	return sha
+}
@@ -0,0 +1,258 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	c "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+type RefComparisonStatus string
+
+const RefsBoth RefComparisonStatus = "compared"
+const RefsOnlyFrom RefComparisonStatus = "only_from"
+const RefsOnlyTo RefComparisonStatus = "only_to"
+const RefsError RefComparisonStatus = "error"
+
+// RefComparison is the dependency changes of a project between two refs,
+// using the latest scan of each repository at each ref.
+type RefComparison struct {
+	FromProject string              `json:"from_project"`
+	FromRef     string              `json:"from_ref"`
+	ToProject   string              `json:"to_project"`
+	ToRef       string              `json:"to_ref"`
+	Repos       []RepoRefComparison `json:"repositories"`
+	Aggregate   []dependency.Change `json:"aggregate"`
+}
+
+type RepoRefComparison struct {
+	Repo    string              `json:"repository"`
+	Status  RefComparisonStatus `json:"status"`
+	FromSha string              `json:"from_sha"`
+	ToSha   string              `json:"to_sha"`
+	Error   string              `json:"error,omitempty"`
+	Changes []dependency.Change `json:"changes"`
+}
+
+func trimRef(ref string) string {
+	return strings.TrimPrefix(strings.TrimSpace(ref), "refs/")
+}
+
+// compareRefs diffs every repository of one project at fromRef against the same
+// repository of another, or the same, project at toRef.
+func compareRefs(fromProject *Project, fromRef string, toProject *Project, toRef string) (*RefComparison, error) {
+	fromRef, toRef = trimRef(fromRef), trimRef(toRef)
+	if fromRef == "" || toRef == "" {
+		return nil, u.Error("Both refs are required")
+	}
+	type result struct {
+		scans map[string]*types.Scan
+		err   error
+	}
+	fromChan, toChan := make(chan result, 1), make(chan result, 1)
+	go func() {
+		scans, err := fromProject.ScansByRefInProject(fromRef)
+		fromChan <- result{scans, err}
+	}()
+	go func() {
+		scans, err := toProject.ScansByRefInProject(toRef)
+		toChan <- result{scans, err}
+	}()
+	from, to := <-fromChan, <-toChan
+	if from.err != nil {
+		return nil, from.err
+	} else if to.err != nil {
+		return nil, to.err
+	}
+
+	res := &RefComparison{
+		FromProject: fromProject.DisplayName,
+		FromRef:     fromRef,
+		ToProject:   toProject.DisplayName,
+		ToRef:       toRef,
+		Repos:       []RepoRefComparison{},
+	}
+	names := map[string]bool{}
+	for name := range from.scans {
+		names[name] = true
+	}
+	for name := range to.scans {
+		names[name] = true
+	}
+	fromDeps, toDeps := map[string]dependency.Dependency{}, map[string]dependency.Dependency{}
+	for _, name := range keysOf(names) {
+		repo := RepoRefComparison{Repo: name, Changes: []dependency.Change{}}
+		fromScan, inFrom := from.scans[name]
+		toScan, inTo := to.scans[name]
+		switch {
+		case inFrom && fromScan.Scan == nil:
+			repo.Status, repo.Error = RefsError, fromScan.Sha
+		case inTo && toScan.Scan == nil:
+			repo.Status, repo.Error = RefsError, toScan.Sha
+		case inFrom && inTo:
+			repo.Status, repo.FromSha, repo.ToSha = RefsBoth, fromScan.Sha, toScan.Sha
+			repo.Changes = c.DiffScans(fromScan.Scan, toScan.Scan)
+		case inFrom:
+			repo.Status, repo.FromSha = RefsOnlyFrom, fromScan.Sha
+			repo.Changes = c.DiffScans(fromScan.Scan, nil)
+		default:
+			repo.Status, repo.ToSha = RefsOnlyTo, toScan.Sha
+			repo.Changes = c.DiffScans(nil, toScan.Scan)
+		}
+		if repo.Status != RefsError {
+			if inFrom {
+				for _, dep := range fromScan.Scan.Deps {
+					fromDeps[dep.FullString()] = dep
+				}
+			}
+			if inTo {
+				for _, dep := range toScan.Scan.Deps {
+					toDeps[dep.FullString()] = dep
+				}
+			}
+		}
+		res.Repos = append(res.Repos, repo)
+	}
+	res.Aggregate = dependency.Diff(depValues(fromDeps), depValues(toDeps))
+	return res, nil
+}
+
+func keysOf(m map[string]bool) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func depValues(m map[string]dependency.Dependency) []dependency.Dependency {
+	res := make(dependency.Dependencies, 0, len(m))
+	for _, dep := range m {
+		res = append(res, dep)
+	}
+	sort.Sort(res)
+	return res
+}
+
+func (rc *RefComparison) title() string {
+	if rc.FromProject == rc.ToProject {
+		return u.Format("%s from %s to %s", rc.FromProject, rc.FromRef, rc.ToRef)
+	}
+	return u.Format("%s at %s to %s at %s", rc.FromProject, rc.FromRef, rc.ToProject, rc.ToRef)
+}
+
+func (rc *RefComparison) repoHeading(repo *RepoRefComparison) string {
+	switch repo.Status {
+	case RefsBoth:
+		return u.Format("%s %s -> %s", repo.Repo, repo.FromSha, repo.ToSha)
+	case RefsOnlyFrom:
+		return u.Format("%s only scanned at %s (%s)", repo.Repo, rc.FromRef, repo.FromSha)
+	case RefsOnlyTo:
+		return u.Format("%s only scanned at %s (%s)", repo.Repo, rc.ToRef, repo.ToSha)
+	}
+	return u.Format("%s %s", repo.Repo, repo.Error)
+}
+
+// String renders the comparison as plain text tables.
+func (rc *RefComparison) String() string {
+	buf := bytes.NewBufferString(u.Format("All repositories of %s\n", rc.title()))
+	buf.WriteString(changesTable(rc.Aggregate).String())
+	for _, repo := range rc.Repos {
+		buf.WriteString("\n\n" + rc.repoHeading(&repo) + "\n")
+		if len(repo.Changes) > 0 {
+			buf.WriteString(changesTable(repo.Changes).String())
+		}
+	}
+	return buf.String()
+}
+
+func (rc *RefComparison) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Repository", "From Sha", "To Sha", "Change", "Dependency", "Old Version", "New Version", "Old Language", "New Language", "Level", "Component"})
+	write := func(repo, fromSha, toSha string, changes []dependency.Change) {
+		for _, c := range changes {
+			writer.Write([]string{repo, fromSha, toSha, string(c.Type), c.Name, c.OldVersion, c.NewVersion, c.OldLanguage.String(), c.NewLanguage.String(), string(c.Level), c.Component})
+		}
+	}
+	write("(all)", rc.FromRef, rc.ToRef, rc.Aggregate)
+	for _, repo := range rc.Repos {
+		if repo.Status == RefsError {
+			writer.Write([]string{repo.Repo, "", "", string(RefsError), repo.Error})
+			continue
+		}
+		write(repo.Repo, repo.FromSha, repo.ToSha, repo.Changes)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (rc *RefComparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rc)
+}
+
+// WriteMarkdown writes the comparison as release notes.
+func (rc *RefComparison) WriteMarkdown(w io.Writer) error {
+	buf := bytes.NewBufferString(u.Format("# Dependency changes in %s\n\n", rc.title()))
+	table := func(changes []dependency.Change) {
+		if len(changes) == 0 {
+			buf.WriteString("No changes.\n\n")
+			return
+		}
+		buf.WriteString("| Change | Dependency | Language | Old | New | Level |\n|---|---|---|---|---|---|\n")
+		for _, c := range changes {
+			lang := c.NewLanguage.String()
+			if c.Type == dependency.Removed {
+				lang = c.OldLanguage.String()
+			} else if c.Type == dependency.LanguageChanged {
+				lang = c.OldLanguage.String() + " → " + lang
+			}
+			buf.WriteString(u.Format("| %s | %s | %s | %s | %s | %s |\n", c.Type, markdownCell(c.Name), lang, markdownCell(c.OldVersion), markdownCell(c.NewVersion), c.Level))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("## All repositories\n\n")
+	table(rc.Aggregate)
+	for _, repo := range rc.Repos {
+		buf.WriteString(u.Format("## %s\n\n", repo.Repo))
+		switch repo.Status {
+		case RefsBoth:
+			buf.WriteString(u.Format("`%s` → `%s`\n\n", repo.FromSha, repo.ToSha))
+		case RefsError:
+			buf.WriteString(u.Format("Unable to compare: %s\n\n", repo.Error))
+			continue
+		default:
+			buf.WriteString(rc.repoHeading(&repo) + "\n\n")
+		}
+		table(repo.Changes)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func markdownCell(str string) string {
+	return strings.Replace(str, "|", `\|`, -1)
+}
@@ -15,14 +15,13 @@
 package app
 
 import (
-	"encoding/json"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/venicegeo/pz-gocommon/elasticsearch"
 	nt "github.com/venicegeo/pz-gocommon/gocommon"
-	"github.com/venicegeo/vzutil-versioning/web/es"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	"github.com/venicegeo/vzutil-versioning/web/store"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
 
@@ -31,11 +30,11 @@ type Retriever struct {
 }
 
 type Project struct {
-	index elasticsearch.IIndex
+	store store.Store
 	*types.Project
 }
 type Repository struct {
-	index   elasticsearch.IIndex
+	store   store.Store
 	project *Project
 	*types.Repository
 }
@@ -46,17 +45,7 @@ func NewRetriever(app *Application) *Retriever {
 
 //Test: TestGetScans
 func (p *Project) ScanBySha(sha string) (*types.Scan, bool, error) {
-	var entry = new(types.Scan)
-	var err error
-	//	var found bool
-
-	result, err := p.index.GetByID(RepositoryEntryType, sha+"-"+p.Id)
-	if result == nil {
-		return nil, false, err
-	} else if !result.Found {
-		return nil, false, nil
-	}
-	return entry, true, json.Unmarshal(*result.Source, entry)
+	return p.store.GetScan(p.Id, sha)
 }
 
 func (r *Retriever) ScanByShaNameGen(repo *Repository, sha string) (*types.Scan, error) {
@@ -91,59 +80,35 @@ func (r *Retriever) ScanByShaNameGen(repo *Repository, sha string) (*types.Scan,
 }
 
 func (project *Project) ScansByRefInProject(ref string) (map[string]*types.Scan, error) {
+	return project.scansByRef(ref, time.Time{})
+}
+
+// ScansByRefAsOf is ScansByRefInProject as the project stood at a time, so
+// each repository has its last scan on the ref at or before it.
+func (project *Project) ScansByRefAsOf(ref string, asOf time.Time) (map[string]*types.Scan, error) {
+	// scans are stored to the millisecond, so this keeps those made during asOf's
+	return project.scansByRef(ref, asOf.Truncate(time.Millisecond).Add(time.Millisecond))
+}
+
+func (project *Project) scansByRef(ref string, before time.Time) (map[string]*types.Scan, error) {
 	repos, err := project.GetAllRepositories()
 	if err != nil {
 		return nil, err
 	}
 	res := map[string]*types.Scan{}
-	query := map[string]interface{}{"query": map[string]interface{}{}}
-	query["query"] = map[string]interface{}{"bool": es.NewBool().
-		SetMust(
-			es.NewBoolQ(
-				es.NewTerm(types.Scan_RefsField, "refs/"+ref),
-				es.NewTerm(types.Scan_FullnameField, "%s")))}
-	query["sort"] = map[string]interface{}{
-		types.Scan_TimestampField: map[string]interface{}{
-			"order": "desc",
-		},
-	}
-	query["size"] = 1
-
-	dat, err := json.MarshalIndent(query, " ", "   ")
-	if err != nil {
-		return nil, err
-	}
 	wg := sync.WaitGroup{}
 	wg.Add(len(repos))
 	mux := sync.Mutex{}
-	addError := func(repoName, err string) {
-		mux.Lock()
-		res[repoName] = &types.Scan{RepoFullname: repoName, ProjectId: project.Id, Sha: err}
-		wg.Done()
-		mux.Unlock()
-	}
 	work := func(repoName string) {
-		q := u.Format(string(dat), repoName)
-		var i map[string]interface{}
-		json.Unmarshal([]byte(q), &i)
-		resp, err := project.index.SearchByJSON(RepositoryEntryType, i)
+		defer wg.Done()
+		entry, found, err := project.store.LatestScan(project.Id, repoName, "refs/"+ref, before)
 		if err != nil {
-			addError(repoName, u.Format("Error during query: %s", err.Error()))
-			return
-		}
-		if resp.Hits.TotalHits == 0 {
-			wg.Done()
-			return
-		}
-
-		var entry = new(types.Scan)
-		if err = json.Unmarshal(*resp.Hits.Hits[0].Source, entry); err != nil {
-			addError(repoName, "Couldnt get entry: "+err.Error())
+			entry = &types.Scan{RepoFullname: repoName, ProjectId: project.Id, Sha: u.Format("Error during query: %s", err.Error())}
+		} else if !found {
 			return
 		}
 		mux.Lock()
 		res[repoName] = entry
-		wg.Done()
 		mux.Unlock()
 	}
 	for _, repo := range repos {
@@ -153,24 +118,17 @@ func (project *Project) ScansByRefInProject(ref string) (map[string]*types.Scan,
 	return res, nil
 }
 
-// Returns map of refs to shas of a repository in a project
-func (r *Repository) MapRefToShas() (map[string][]string, int64, error) {
-	boool := es.NewBool().
-		SetMust(es.NewBoolQ(
-			es.NewTerm(types.Scan_FullnameField, r.Fullname),
-			es.NewTerm(types.Scan_ProjectIdField, r.ProjectId)))
-	entryDat, err := es.GetAll(r.index, RepositoryEntryType, map[string]interface{}{"bool": boool}, map[string]interface{}{types.Scan_TimestampField: "desc"})
+// Returns map of refs to shas of a page of the scans of a repository in a project,
+// along with the cursor of the next page
+func (r *Repository) MapRefToShas(cursor string, size int) (map[string][]string, string, error) {
+	scans, next, err := r.store.ScanPage(r.ProjectId, r.Fullname, cursor, size)
 	if err != nil {
-		return nil, 0, err
+		return nil, "", err
 	}
 
 	res := map[string][]string{}
 
-	for _, entryD := range entryDat.Hits {
-		entry := new(types.Scan)
-		if err := json.Unmarshal(*entryD.Source, entry); err != nil {
-			return nil, 0, err
-		}
+	for _, entry := range scans {
 		for _, refName := range entry.Refs {
 			if _, ok := res[refName]; !ok {
 				res[refName] = []string{}
@@ -178,82 +136,55 @@ func (r *Repository) MapRefToShas() (map[string][]string, int64, error) {
 			res[refName] = append(res[refName], entry.Sha)
 		}
 	}
-	return res, entryDat.TotalHits, nil
+	return res, next, nil
 }
 
 //Test: TestGetRepositories
 func (r *Repository) GetAllRefs() ([]string, error) {
-	in := es.NewAggQuery("refs", types.Scan_RefsField)
-	boool := es.NewBool().SetMust(es.NewBoolQ(es.NewTerm(types.Scan_FullnameField, r.Fullname), es.NewTerm(types.Scan_ProjectIdField, r.project.Id)))
-	in["query"] = map[string]interface{}{"bool": boool}
-	//	sort.Strings(res)
-	resp, err := r.index.SearchByJSON(RepositoryEntryType, in)
-	return es.GetAggKeysFromSearchResponse("refs", resp, err, func(a string) string { return strings.TrimPrefix(a, "refs/") })
+	refs, err := r.store.RepositoryRefs(r.project.Id, r.Fullname)
+	return trimRefs(refs), err
 }
 
 //Test: TestGetRepositories
 func (p *Project) GetAllRefs() ([]string, error) {
-	repos, err := p.GetAllRepositories()
-	if err != nil {
-		return nil, err
-	}
-	reposStr := make([]string, len(repos), len(repos))
-	for i, repo := range repos {
-		reposStr[i] = repo.Fullname
+	refs, err := p.store.ProjectRefs(p.Id)
+	return trimRefs(refs), err
+}
+
+func trimRefs(refs []string) []string {
+	for i, ref := range refs {
+		refs[i] = strings.TrimPrefix(ref, "refs/")
 	}
-	boool := es.NewBool().SetMust(es.NewBoolQ(es.NewTerms(types.Scan_FullnameField, reposStr...)))
-	query := es.NewAggQuery("refs", types.Scan_RefsField)
-	query["query"] = map[string]interface{}{"bool": boool}
-	resp, err := p.index.SearchByJSON(RepositoryEntryType, query)
-	return es.GetAggKeysFromSearchResponse("refs", resp, err, func(a string) string { return strings.TrimPrefix(a, "refs/") })
+	return refs
 }
 
 //Test: TestGetRepositories
 func (r *Retriever) ListRepositories() ([]string, error) {
-	agg := es.NewAggQuery("repo", types.Scan_FullnameField)
-	resp, err := r.app.index.SearchByJSON(RepositoryEntryType, agg)
-	return es.GetAggKeysFromSearchResponse("repo", resp, err)
+	return r.app.store.ScannedRepositories()
 }
 
 //Test: TestAddRepositories
 func (p *Project) GetAllRepositories() ([]*Repository, error) {
-	hits, err := es.GetAll(p.index, RepositoryType, es.NewTerm(types.Repository_ProjectIdField, p.Id))
+	repos, err := p.store.Repositories(p.Id)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]*Repository, hits.TotalHits, hits.TotalHits)
-	for i, hitData := range hits.Hits {
-		r := new(types.Repository)
-		if err = json.Unmarshal(*hitData.Source, r); err != nil {
-			return nil, err
-		}
-		res[i] = &Repository{p.index, p, r}
+	res := make([]*Repository, len(repos), len(repos))
+	for i, repo := range repos {
+		res[i] = &Repository{p.store, p, repo}
 	}
 	return res, nil
 }
 
 //Test: TestGetRepositories
 func (p *Project) GetRepository(repository string) (*Repository, error) {
-	boolq := es.NewBool().
-		SetMust(es.NewBoolQ(
-			es.NewTerm(types.Repository_ProjectIdField, p.Id),
-			es.NewTerm(types.Repository_NameField, repository)))
-	resp, err := p.index.SearchByJSON(RepositoryType, map[string]interface{}{
-		"query": map[string]interface{}{"bool": boolq},
-	})
+	repo, found, err := p.store.GetRepository(p.Id, repository)
 	if err != nil {
 		return nil, err
+	} else if !found {
+		return nil, u.Error("Repository %s does not exist in this project", repository)
 	}
-	if len(resp.Hits.Hits) != 1 {
-		return nil, u.Error("Total hits not 1 but %d", len(resp.Hits.Hits))
-	}
-	res := new(Repository)
-	if err = json.Unmarshal(*resp.Hits.Hits[0].Source, res); err != nil {
-		return nil, err
-	}
-	res.project = p
-	res.index = p.index
-	return res, nil
+	return &Repository{p.store, p, repo}, nil
 }
 
 //Test: TestAddRepositories
@@ -268,40 +199,29 @@ func (r *Retriever) GetRepository(repository, projectId string) (*Repository, *P
 
 //Test: TestAddProjects
 func (r *Retriever) GetProjectById(id string) (*Project, error) {
-	resp, err := r.app.index.GetByID(ProjectType, id)
+	p, found, err := r.app.store.GetProject(id)
 	if err != nil {
 		return nil, err
-	} else if !resp.Found {
-		return nil, u.Error("Project %s does not exist", err.Error())
-	}
-	p := new(types.Project)
-	if err = json.Unmarshal(*resp.Source, p); err != nil {
-		return nil, err
+	} else if !found {
+		return nil, u.Error("Project %s does not exist", id)
 	}
-	return &Project{r.app.index, p}, nil
+	return &Project{r.app.store, p}, nil
 }
 
 //Test: TestAddProjects
 func (r *Retriever) GetAllProjects() ([]*Project, error) {
-	hits, err := es.GetAll(r.app.index, ProjectType, map[string]interface{}{})
+	projects, err := r.app.store.Projects()
 	if err != nil {
 		return nil, err
 	}
-	res := make([]*Project, hits.TotalHits, hits.TotalHits)
-	for i, hitData := range hits.Hits {
-		t := new(types.Project)
-		if err = json.Unmarshal(*hitData.Source, t); err != nil {
-			return nil, err
-		}
-		res[i] = &Project{r.app.index, t}
+	res := make([]*Project, len(projects), len(projects))
+	for i, p := range projects {
+		res[i] = &Project{r.app.store, p}
 	}
 	return res, nil
 }
 
 //Test: TestAddRepositories
 func (r *Retriever) GetAllProjectNamesUsingRepository(repo string) ([]string, error) {
-	agg := es.NewAggQuery("projects", types.Repository_ProjectIdField)
-	agg["query"] = es.NewTerm("repo", repo)
-	resp, err := r.app.index.SearchByJSON(RepositoryType, agg)
-	return es.GetAggKeysFromSearchResponse("projects", resp, err)
+	return r.app.store.ProjectsUsingRepository(repo)
 }
@@ -0,0 +1,204 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/single/scan"
+	"github.com/venicegeo/vzutil-versioning/web/cron"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const schedulerTick = time.Minute
+const scheduleTimeout = time.Minute * 10
+
+// Scheduler runs the due schedules every minute. A run asks the remote of each
+// repository where the polled refs point and queues scans of the shas the
+// project has not seen, and of shas whose scans an older resolver made.
+type Scheduler struct {
+	app *Application
+
+	mux     sync.Mutex
+	running map[string]bool
+}
+
+// ScheduleRun is what one run of a schedule queued.
+type ScheduleRun struct {
+	New     int
+	Rescans int
+	Errors  []string
+}
+
+func NewScheduler(app *Application) *Scheduler {
+	return &Scheduler{app: app, running: map[string]bool{}}
+}
+
+func (s *Scheduler) Start() {
+	go func() {
+		for range time.Tick(schedulerTick) {
+			s.runDue(time.Now())
+		}
+	}()
+}
+
+// Prepare checks a schedule and works out when it next runs.
+func (s *Scheduler) Prepare(sched *types.Schedule) error {
+	expr, err := cron.Parse(sched.Cron)
+	if err != nil {
+		return err
+	}
+	if len(sched.Refs) == 0 {
+		return u.Error("At least one ref is required")
+	}
+	for i, ref := range sched.Refs {
+		if !strings.HasPrefix(ref, "refs/") {
+			ref = "refs/heads/" + ref
+			sched.Refs[i] = ref
+		}
+		if _, err := path.Match(ref, ""); err != nil {
+			return u.Error("Invalid ref pattern [%s]", ref)
+		}
+	}
+	sched.NextRun = expr.Next(time.Now())
+	return nil
+}
+
+func (s *Scheduler) runDue(now time.Time) {
+	scheds, err := s.app.store.Schedules("")
+	if err != nil {
+		log.Println("[SCHEDULER] Unable to get the schedules:", err.Error())
+		return
+	}
+	for _, sched := range scheds {
+		if sched.Enabled && !sched.NextRun.IsZero() && !now.Before(sched.NextRun) {
+			go s.RunNow(sched)
+		}
+	}
+}
+
+// RunNow runs a schedule and records the outcome on it, unless it is already running.
+func (s *Scheduler) RunNow(sched *types.Schedule) (*ScheduleRun, error) {
+	s.mux.Lock()
+	if s.running[sched.Id] {
+		s.mux.Unlock()
+		return nil, u.Error("This schedule is already running")
+	}
+	s.running[sched.Id] = true
+	s.mux.Unlock()
+	defer func() {
+		s.mux.Lock()
+		delete(s.running, sched.Id)
+		s.mux.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), scheduleTimeout)
+	defer cancel()
+	run, err := s.run(ctx, sched)
+	sched.LastRun = time.Now()
+	sched.LastError = ""
+	if err != nil {
+		sched.LastError = err.Error()
+	} else if len(run.Errors) > 0 {
+		sched.LastError = strings.Join(run.Errors, "\n")
+	}
+	if expr, e := cron.Parse(sched.Cron); e == nil {
+		sched.NextRun = expr.Next(sched.LastRun)
+	}
+	if e := s.app.store.PutSchedule(sched); e != nil {
+		log.Printf("[SCHEDULER] Unable to save schedule %s: %s\n", sched.Id, e.Error())
+	}
+	if err != nil {
+		log.Printf("[SCHEDULER] Schedule %s failed: %s\n", sched.Id, err.Error())
+		return nil, err
+	}
+	log.Printf("[SCHEDULER] Schedule %s queued %d new scans and %d rescans\n", sched.Id, run.New, run.Rescans)
+	return run, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, sched *types.Schedule) (*ScheduleRun, error) {
+	project, err := s.app.rtrvr.GetProjectById(sched.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	var repos []*Repository
+	if sched.RepoFullname == "" {
+		if repos, err = project.GetAllRepositories(); err != nil {
+			return nil, err
+		}
+	} else {
+		repo, err := project.GetRepository(sched.RepoFullname)
+		if err != nil {
+			return nil, err
+		}
+		repos = []*Repository{repo}
+	}
+	run := &ScheduleRun{Errors: []string{}}
+	for _, repo := range repos {
+		switch repo.DependencyInfo.CheckoutType {
+		case types.ExactSha, types.CustomRef:
+			// these always scan the same thing, whatever the incoming sha
+			continue
+		}
+		if err := s.poll(ctx, sched, repo, run); err != nil {
+			run.Errors = append(run.Errors, u.Format("%s: %s", repo.Fullname, err.Error()))
+		}
+	}
+	return run, nil
+}
+
+func (s *Scheduler) poll(ctx context.Context, sched *types.Schedule, repo *Repository, run *ScheduleRun) error {
+	remote, err := s.app.mirrors.LsRemote(ctx, repo.DependencyInfo.RepoFullname)
+	if err != nil {
+		return err
+	}
+	refs := make([]string, 0, len(remote))
+	for ref := range remote {
+		for _, pattern := range sched.Refs {
+			if ok, _ := path.Match(pattern, ref); ok {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		sha := remote[ref]
+		existing, found, err := s.app.store.GetScan(repo.ProjectId, sha)
+		if err != nil {
+			return err
+		}
+		request := &SingleRunnerRequest{repository: repo, sha: sha, ref: ref}
+		switch {
+		case !found:
+			s.app.ff.FireRequest(request)
+			run.New++
+		case !containsString(existing.Refs, ref):
+			// the scan only needs the ref added, which the job does when it exists
+			s.app.ff.FireRequest(request)
+		case sched.Rescan && existing.Scan != nil && existing.Scan.ResolverVersion < scan.ResolverVersion:
+			s.app.ff.FireRescan(request)
+			run.Rescans++
+		}
+	}
+	return nil
+}
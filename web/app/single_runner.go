@@ -15,14 +15,14 @@
 package app
 
 import (
-	"encoding/json"
-	"os/exec"
+	"context"
 	"regexp"
 	"strings"
 	"time"
 
 	nt "github.com/venicegeo/pz-gocommon/gocommon"
-	c "github.com/venicegeo/vzutil-versioning/common"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
+	"github.com/venicegeo/vzutil-versioning/single/scan"
 	"github.com/venicegeo/vzutil-versioning/web/es/types"
 	u "github.com/venicegeo/vzutil-versioning/web/util"
 )
@@ -45,49 +45,54 @@ func NewSingleRunner(app *Application) *SingleRunner {
 	}
 }
 
-func (sr *SingleRunner) ScanWithSingle(fullName string) ([]string, error) {
-	dat, err := exec.Command(sr.app.singleLocation, "--scan", fullName, "master").Output()
+func (sr *SingleRunner) ScanWithSingle(ctx context.Context, fullName string) ([]string, error) {
+	files, err := scan.ListFiles(ctx, &scan.Request{FullName: fullName, Checkout: "master", Cache: sr.app.mirrors, IncludeTest: true, ObjectRead: true})
 	if err != nil {
 		return nil, err
 	}
-	var output struct {
-		Files []string `json:"files"`
+	for i, f := range files {
+		files[i] = u.Format("%s/%s", fullName, f)
 	}
-	if err = json.Unmarshal(dat, &output); err != nil {
-		return nil, err
-	}
-	for i, f := range output.Files {
-		output.Files[i] = u.Format("%s/%s", fullName, f)
-	}
-	return output.Files, nil
+	return files, nil
 }
 
-func (sr *SingleRunner) RunAgainstSingle(printHeader string, printLocation chan string, request *SingleRunnerRequest) *types.Scan {
+func (sr *SingleRunner) RunAgainstSingle(ctx context.Context, printHeader string, printLocation chan string, request *SingleRunnerRequest) (*types.Scan, error) {
 	sr.sendStringTo(printLocation, "%sStarting work on %s", printHeader, request.sha)
 
-	args := make([]string, len(request.repository.DependencyInfo.FilesToScan)*2, len(request.repository.DependencyInfo.FilesToScan)*2)
-	i := 0
-	for _, f := range request.repository.DependencyInfo.FilesToScan {
-		args[i] = "--f"
-		args[i+1] = strings.TrimPrefix(f, request.repository.DependencyInfo.RepoFullname)[1:]
-		i += 2
+	req := &scan.Request{
+		FullName:    request.repository.DependencyInfo.RepoFullname,
+		Cache:       sr.app.mirrors,
+		IncludeTest: true,
+		ObjectRead:  true,
+		Checks:      sr.app.checks,
+		Files:       make([]string, len(request.repository.DependencyInfo.FilesToScan), len(request.repository.DependencyInfo.FilesToScan)),
+	}
+	var overrides []shas.Override
+	if request.repository.project != nil {
+		overrides = request.repository.project.ShaOverrides
+	}
+	var tags shas.TagSource
+	if sr.app.shaTags {
+		tags = scan.RemoteTags(sr.app.mirrors)
+	}
+	req.Shas = shas.NewResolver(tags, overrides)
+	for i, f := range request.repository.DependencyInfo.FilesToScan {
+		req.Files[i] = strings.TrimPrefix(f, request.repository.DependencyInfo.RepoFullname)[1:]
 	}
-	args = append(args, request.repository.DependencyInfo.RepoFullname)
 	switch request.repository.DependencyInfo.CheckoutType {
 	case types.IncomingSha:
-		args = append(args, request.sha)
+		req.Checkout = request.sha
 	case types.ExactSha:
-		args = append(args, request.repository.DependencyInfo.CustomField)
+		req.Checkout = request.repository.DependencyInfo.CustomField
 	case types.CustomRef:
-		args = append(args, request.repository.DependencyInfo.CustomField)
+		req.Checkout = request.repository.DependencyInfo.CustomField
 	case types.SameRef:
-		args = append(args, request.ref)
+		req.Checkout = request.ref
 	}
 
-	dat, err := exec.Command(sr.app.singleLocation, args...).CombinedOutput()
+	singleRet, err := scan.Run(ctx, req)
 	if err != nil {
-		sr.sendStringTo(printLocation, "%sUnable to run against %s [%s]\n%s", printHeader, request.sha, err.Error(), string(dat))
-		return nil
+		return nil, sr.failure(printLocation, printHeader, "Unable to run against %s [%s]", request.sha, err.Error())
 	}
 	res := &types.Scan{
 		RepoFullname: request.repository.Fullname,
@@ -95,11 +100,6 @@ func (sr *SingleRunner) RunAgainstSingle(printHeader string, printLocation chan
 		Refs:         []string{request.ref},
 		Sha:          request.sha,
 	}
-	var singleRet = new(c.DependencyScan)
-	if err = json.Unmarshal(dat, singleRet); err != nil {
-		sr.sendStringTo(printLocation, "%sUnable to run against %s [%s]", printHeader, request.sha, err.Error())
-		return nil
-	}
 	//TODO
 	//	if singleRet.Sha != request.sha {
 	//		sr.sendStringTo(printLocation, "%sGeneration failed to run against %s, it ran against sha %s", printHeader, request.sha, singleRet.Sha)
@@ -107,23 +107,28 @@ func (sr *SingleRunner) RunAgainstSingle(printHeader string, printLocation chan
 	//	}
 	{ //Find timestamp of commit
 		code, body, _, err := nt.HTTP(nt.GET, "https://github.com/"+request.repository.Fullname+"/commit/"+request.sha, nt.NewHeaderBuilder().GetHeader(), nil)
-		if err != nil || code != 200 {
-			sr.sendStringTo(printLocation, "%sUnable to find timestamp for %s [%d: %s]", printHeader, request.repository.Fullname, code, err.Error())
-			return nil
+		if err != nil {
+			return nil, sr.failure(printLocation, printHeader, "Unable to find timestamp for %s [%s]", request.repository.Fullname, err.Error())
+		} else if code != 200 {
+			return nil, sr.failure(printLocation, printHeader, "Unable to find timestamp for %s [%d]", request.repository.Fullname, code)
 		}
 		matches := sr.findCommitTime.FindStringSubmatch(strings.TrimSpace(string(body)))
-		if len(matches) == 1 {
-			sr.sendStringTo(printLocation, "%sCould not scrub commit timestamp", printHeader)
-			return nil
+		if len(matches) != 2 {
+			return nil, sr.failure(printLocation, printHeader, "Could not scrub commit timestamp")
 		}
 		if res.Timestamp, err = time.Parse(time.RFC3339, matches[1]); err != nil {
-			sr.sendStringTo(printLocation, "%sError parsing timestamp for %s [%s]", printHeader, request.repository.Fullname, err.Error())
-			return nil
+			return nil, sr.failure(printLocation, printHeader, "Error parsing timestamp for %s [%s]", request.repository.Fullname, err.Error())
 		}
 	}
 	sr.sendStringTo(printLocation, "%sFinished work on %s", printHeader, request.sha)
 	res.Scan = singleRet
-	return res
+	return res, nil
+}
+
+func (sr *SingleRunner) failure(location chan string, printHeader string, format string, args ...interface{}) error {
+	err := u.Error(format, args...)
+	sr.sendStringTo(location, "%s%s", printHeader, err.Error())
+	return err
 }
 func (sr *SingleRunner) sendStringTo(location chan string, format string, args ...interface{}) {
 	if location != nil {
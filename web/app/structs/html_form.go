@@ -22,10 +22,11 @@ import (
 type HtmlForm struct {
 	dat    string
 	method string
+	action string
 }
 
 func NewHtmlForm(elem fmt.Stringer) *HtmlForm {
-	return &HtmlForm{elem.String(), "get"}
+	return &HtmlForm{elem.String(), "get", ""}
 }
 func (h *HtmlForm) Get() *HtmlForm {
 	h.method = "get"
@@ -36,10 +37,18 @@ func (h *HtmlForm) Post() *HtmlForm {
 	return h
 }
 
+func (h *HtmlForm) Action(action string) *HtmlForm {
+	h.action = action
+	return h
+}
+
 func (h *HtmlForm) Template() template.HTML {
 	return template.HTML(h.String())
 }
 
 func (h *HtmlForm) String() string {
+	if h.action != "" {
+		return fmt.Sprintf("<form method=\"%s\" action=\"%s\">\n%s\n</form>", h.method, template.HTMLEscapeString(h.action), h.dat)
+	}
 	return fmt.Sprintf("<form method=\"%s\">\n%s\n</form>", h.method, h.dat)
 }
@@ -0,0 +1,169 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cron reads the five field cron expressions used by schedules.
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// Expression is a parsed cron expression. A time matches when each of its
+// minute, hour, day of month, month and day of week is in the allowed set.
+type Expression struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Parse reads an expression of minute, hour, day of month, month and day of
+// week. Fields take *, numbers, names of months and days, ranges, lists and
+// steps, and the common @daily style macros are accepted too.
+func Parse(expr string) (*Expression, error) {
+	expr = strings.TrimSpace(strings.ToLower(expr))
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, u.Error("Cron expression [%s] needs 5 fields", expr)
+	}
+	e := &Expression{}
+	var err error
+	if e.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if e.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if e.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if e.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if e.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, err
+	}
+	// 7 is another name for sunday
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domAny = fields[2] == "*"
+	e.dowAny = fields[4] == "*"
+	return e, nil
+}
+
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	var res uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, u.Error("Invalid step in [%s]", field)
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+			if hi < lo {
+				return 0, u.Error("Invalid range [%s]", part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			res |= 1 << uint(v)
+		}
+	}
+	return res, nil
+}
+
+func parseValue(str string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if str == name {
+			return i + min, nil
+		}
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil || v < min || v > max {
+		return 0, u.Error("[%s] is not between %d and %d", str, min, max)
+	}
+	return v, nil
+}
+
+func (e *Expression) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+	// like cron, when both are restricted a day matching either one is enough
+	switch {
+	case e.domAny && e.dowAny:
+		return true
+	case e.domAny:
+		return dow
+	case e.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t that the expression matches, or the
+// zero time if there is none in the next five years.
+func (e *Expression) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case e.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !e.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case e.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case e.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
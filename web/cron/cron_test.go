@@ -0,0 +1,57 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2018, 3, 14, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2018, 3, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2018, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon-fri", time.Date(2018, 3, 15, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2018, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2018, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2018, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10-12/2 * * *", time.Date(2018, 3, 14, 12, 5, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if assert.NoError(t, err, test.expr) {
+			assert.Equal(t, test.next, e.Next(from), test.expr)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
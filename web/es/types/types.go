@@ -15,18 +15,25 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	c "github.com/venicegeo/vzutil-versioning/common"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+	"github.com/venicegeo/vzutil-versioning/common/shas"
 )
 
 var escape = regexp.MustCompile(`[^a-zA-Z\-_]`)
 
 type Project struct {
-	Id          string `json:"id"`
-	DisplayName string `json:"displayname"`
-	EscapedName string `json:"escapedname"`
+	Id           string          `json:"id"`
+	DisplayName  string          `json:"displayname"`
+	EscapedName  string          `json:"escapedname"`
+	ShaOverrides []shas.Override `json:"sha_overrides"`
 }
 
 const ProjectMapping = `{
@@ -34,7 +41,8 @@ const ProjectMapping = `{
 	"properties":{
 		"` + Project_IdField + `":{"type":"keyword"},
 		"` + Project_DisplayNameField + `":{"type":"keyword"},
-		"` + Project_EscapedName + `":{"type":"keyword"}
+		"` + Project_EscapedName + `":{"type":"keyword"},
+		"sha_overrides":` + shas.OverrideMapping + `
 	}
 }`
 const Project_IdField = `id`
@@ -42,7 +50,7 @@ const Project_DisplayNameField = `displayname`
 const Project_EscapedName = `escapedname`
 
 func NewProject(id, name string) Project {
-	return Project{id, name, escape.ReplaceAllString(name, "_")}
+	return Project{Id: id, DisplayName: name, EscapedName: escape.ReplaceAllString(name, "_")}
 }
 
 //--------------------------------------------------------------------------------
@@ -108,6 +116,7 @@ const Scan_TimestampField = "timestamp"
 const Scan_SubDependenciesField = "scan." + c.DependenciesField
 const Scan_SubFullNameField = "scan." + c.FullNameField
 const Scan_SubFilesField = "scan." + c.FilesField
+const Scan_SubComponentsField = "scan." + c.ComponentsField
 
 const ScanMapping string = `{
 	"dynamic":"strict",
@@ -116,7 +125,378 @@ const ScanMapping string = `{
 		"` + Scan_ProjectIdField + `":{"type":"keyword"},
 		"` + Scan_RefsField + `":{"type":"keyword"},
 		"` + Scan_ShaField + `":{"type":"keyword"},
-		"` + Scan_TimestampField + `":{"type":"keyword"},
+		"` + Scan_TimestampField + `":{"type":"date"},
 		"scan":` + c.DependencyScanMapping + `
 	}
 }`
+
+//--------------------------------------------------------------------------------
+
+type Job struct {
+	Id           string    `json:"id"`
+	ProjectId    string    `json:"project_id"`
+	RepoFullname string    `json:"repo"`
+	Sha          string    `json:"sha"`
+	Refs         []string  `json:"refs"`
+	Status       JobStatus `json:"status"`
+	CheckExists  bool      `json:"check_exists"`
+	Transient    bool      `json:"transient"`
+	Backfill     string    `json:"backfill"`
+	Attempts     int       `json:"attempts"`
+	MaxAttempts  int       `json:"max_attempts"`
+	Error        string    `json:"error"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+	NextAttempt  time.Time `json:"next_attempt"`
+}
+
+type JobStatus string
+
+const JobQueued JobStatus = "queued"
+const JobRunning JobStatus = "running"
+const JobSucceeded JobStatus = "succeeded"
+const JobFailed JobStatus = "failed"
+
+const Job_IdField = "id"
+const Job_ProjectIdField = "project_id"
+const Job_FullnameField = "repo"
+const Job_StatusField = "status"
+const Job_UpdatedField = "updated"
+const Job_BackfillField = "backfill"
+
+const JobMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"` + Job_IdField + `":{"type":"keyword"},
+		"` + Job_ProjectIdField + `":{"type":"keyword"},
+		"` + Job_FullnameField + `":{"type":"keyword"},
+		"sha":{"type":"keyword"},
+		"refs":{"type":"keyword"},
+		"` + Job_StatusField + `":{"type":"keyword"},
+		"check_exists":{"type":"boolean"},
+		"transient":{"type":"boolean"},
+		"` + Job_BackfillField + `":{"type":"keyword"},
+		"attempts":{"type":"integer"},
+		"max_attempts":{"type":"integer"},
+		"error":{"type":"text"},
+		"created":{"type":"date"},
+		"` + Job_UpdatedField + `":{"type":"date"},
+		"next_attempt":{"type":"date"}
+	}
+}`
+
+//--------------------------------------------------------------------------------
+
+type Backfill struct {
+	Id           string         `json:"id"`
+	ProjectId    string         `json:"project_id"`
+	RepoFullname string         `json:"repo"`
+	Ref          string         `json:"ref"`
+	Since        time.Time      `json:"since"`
+	Until        time.Time      `json:"until"`
+	Stride       BackfillStride `json:"stride"`
+	Nth          int            `json:"nth"`
+	TagPattern   string         `json:"tag_pattern"`
+	Status       BackfillStatus `json:"status"`
+	Error        string         `json:"error"`
+	Items        []BackfillItem `json:"items"`
+	Diffs        int            `json:"diffs"`
+	Created      time.Time      `json:"created"`
+	Updated      time.Time      `json:"updated"`
+}
+
+type BackfillItem struct {
+	RepoFullname string    `json:"repo"`
+	Sha          string    `json:"sha"`
+	Ref          string    `json:"ref"`
+	Time         time.Time `json:"time"`
+	JobId        string    `json:"job_id"`
+}
+
+type BackfillStride string
+
+const StrideEvery BackfillStride = "every"
+const StrideNth BackfillStride = "nth"
+const StrideFirstParent BackfillStride = "first_parent"
+const StrideTags BackfillStride = "tags"
+
+type BackfillStatus string
+
+const BackfillEnqueuing BackfillStatus = "enqueuing"
+const BackfillScanning BackfillStatus = "scanning"
+const BackfillDiffing BackfillStatus = "diffing"
+const BackfillDone BackfillStatus = "done"
+const BackfillFailed BackfillStatus = "failed"
+
+const Backfill_ProjectIdField = "project_id"
+const Backfill_StatusField = "status"
+const Backfill_CreatedField = "created"
+
+const BackfillMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"` + Backfill_ProjectIdField + `":{"type":"keyword"},
+		"repo":{"type":"keyword"},
+		"ref":{"type":"keyword"},
+		"since":{"type":"date"},
+		"until":{"type":"date"},
+		"stride":{"type":"keyword"},
+		"nth":{"type":"integer"},
+		"tag_pattern":{"type":"keyword"},
+		"` + Backfill_StatusField + `":{"type":"keyword"},
+		"error":{"type":"text"},
+		"items":{"type":"object","enabled":false},
+		"diffs":{"type":"integer"},
+		"` + Backfill_CreatedField + `":{"type":"date"},
+		"updated":{"type":"date"}
+	}
+}`
+
+//--------------------------------------------------------------------------------
+
+type Difference struct {
+	Id          string     `json:"id"`
+	RepoName    string     `json:"repo_name"`
+	ProjectName string     `json:"project_name"`
+	Ref         string     `json:"ref"`
+	OldSha      string     `json:"old_sha"`
+	NewSha      string     `json:"new_sha"`
+	Changes     []d.Change `json:"changes"`
+	// Removed and Added are only set on differences recorded before Changes.
+	Removed   []string  `json:"removed"`
+	Added     []string  `json:"added"`
+	Timestamp time.Time `json:"time"`
+}
+
+const Difference_ProjectIdField = "project_name"
+const Difference_OldShaField = "old_sha"
+const Difference_NewShaField = "new_sha"
+const Difference_TimeField = "time"
+
+const DifferenceMapping = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"repo_name":{"type":"keyword"},
+		"` + Difference_ProjectIdField + `":{"type":"keyword"},
+		"ref":{"type":"keyword"},
+		"` + Difference_OldShaField + `":{"type":"keyword"},
+		"` + Difference_NewShaField + `":{"type":"keyword"},
+		"changes":` + d.ChangeMapping + `,
+		"removed":{"type":"keyword"},
+		"added":{"type":"keyword"},
+		"` + Difference_TimeField + `":{"type":"date"}
+	}
+}`
+
+func (d *Difference) SimpleString() string {
+	return d.RepoName + " " + strings.TrimPrefix(d.Ref, "refs/") + " " + d.Timestamp.String()
+}
+
+//--------------------------------------------------------------------------------
+
+// Subscription sends the events of a project to an email address or a webhook.
+type Subscription struct {
+	Id        string      `json:"id"`
+	ProjectId string      `json:"project_id"`
+	Channel   Channel     `json:"channel"`
+	Target    string      `json:"target"`
+	Events    []EventKind `json:"events"`
+	Languages []string    `json:"languages"`
+	Repos     []string    `json:"repos"`
+	// DigestMinutes batches events into one message per window, or sends each
+	// event as it happens when zero.
+	DigestMinutes int       `json:"digest_minutes"`
+	Created       time.Time `json:"created"`
+}
+
+type Channel string
+
+const ChannelEmail Channel = "email"
+const ChannelWebhook Channel = "webhook"
+const ChannelSlack Channel = "slack"
+
+type EventKind string
+
+const EventDifference EventKind = "difference"
+const EventScanFailed EventKind = "scan_failed"
+const EventNewIssue EventKind = "new_issue"
+
+const Subscription_ProjectIdField = "project_id"
+
+const SubscriptionMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"` + Subscription_ProjectIdField + `":{"type":"keyword"},
+		"channel":{"type":"keyword"},
+		"target":{"type":"keyword"},
+		"events":{"type":"keyword"},
+		"languages":{"type":"keyword"},
+		"repos":{"type":"keyword"},
+		"digest_minutes":{"type":"integer"},
+		"created":{"type":"date"}
+	}
+}`
+
+// Digest holds the events queued for a subscription with a digest until they
+// are due to be sent. Its id is the id of the subscription.
+type Digest struct {
+	Id        string    `json:"id"`
+	ProjectId string    `json:"project_id"`
+	Due       time.Time `json:"due"`
+	// Events are kept as the json of the notify events, which are not searched.
+	Events  []json.RawMessage `json:"events"`
+	Created time.Time         `json:"created"`
+}
+
+const Digest_ProjectIdField = "project_id"
+
+const DigestMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"` + Digest_ProjectIdField + `":{"type":"keyword"},
+		"due":{"type":"date"},
+		"events":{"type":"object","enabled":false},
+		"created":{"type":"date"}
+	}
+}`
+
+//--------------------------------------------------------------------------------
+
+// Schedule polls the refs of a project's repositories on a cron expression and
+// scans the shas that are new to them.
+type Schedule struct {
+	Id        string `json:"id"`
+	ProjectId string `json:"project_id"`
+	// RepoFullname limits the schedule to one repository, or all of the project when empty.
+	RepoFullname string `json:"repo"`
+	Cron         string `json:"cron"`
+	// Refs are glob patterns of the full refs to poll, like refs/heads/master or refs/tags/*.
+	Refs []string `json:"refs"`
+	// Rescan also redoes the latest scans on the refs that an older resolver made.
+	Rescan    bool      `json:"rescan"`
+	Enabled   bool      `json:"enabled"`
+	LastRun   time.Time `json:"last_run"`
+	NextRun   time.Time `json:"next_run"`
+	LastError string    `json:"last_error"`
+	Created   time.Time `json:"created"`
+}
+
+const Schedule_ProjectIdField = "project_id"
+
+const ScheduleMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"` + Schedule_ProjectIdField + `":{"type":"keyword"},
+		"repo":{"type":"keyword"},
+		"cron":{"type":"keyword"},
+		"refs":{"type":"keyword"},
+		"rescan":{"type":"boolean"},
+		"enabled":{"type":"boolean"},
+		"last_run":{"type":"date"},
+		"next_run":{"type":"date"},
+		"last_error":{"type":"text"},
+		"created":{"type":"date"}
+	}
+}`
+
+//--------------------------------------------------------------------------------
+
+// ApprovedList is one version of a project's approved-software list, as the
+// items read from an imported sheet. Each import is a new version.
+type ApprovedList struct {
+	Id        string `json:"id"`
+	ProjectId string `json:"project_id"`
+	Version   int    `json:"version"`
+	FileName  string `json:"file_name"`
+	// Columns are the column names or letters of the sheet the items were read from.
+	Columns ApprovedColumns `json:"columns"`
+	Items   []ApprovedItem  `json:"items"`
+	// Bundles condense the packages of each bundle into the bundle before
+	// reconciling, like the package_bundles of extended.
+	Bundles []ApprovedBundle `json:"bundles"`
+	Created time.Time        `json:"created"`
+}
+
+type ApprovedColumns struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Component string `json:"component"`
+	Language  string `json:"language"`
+}
+
+type ApprovedItem struct {
+	Name      string       `json:"name"`
+	Version   string       `json:"version"`
+	Component string       `json:"component"`
+	Language  lan.Language `json:"language"`
+}
+
+type ApprovedBundle struct {
+	Name     string   `json:"name"`
+	Packages []string `json:"packages"`
+}
+
+func ApprovedListId(projectId string, version int) string {
+	return fmt.Sprintf("%s-%d", projectId, version)
+}
+
+// Dependencies returns the items with their component as the project.
+func (l *ApprovedList) Dependencies() d.GenericDependencies {
+	res := make(d.GenericDependencies, len(l.Items), len(l.Items))
+	for i, item := range l.Items {
+		res[i] = d.NewGenericDependency(item.Name, item.Version, item.Component, item.Language)
+	}
+	return res
+}
+
+func (l *ApprovedList) BundleMap() map[string][]string {
+	res := make(map[string][]string, len(l.Bundles))
+	for _, b := range l.Bundles {
+		res[b.Name] = b.Packages
+	}
+	return res
+}
+
+const ApprovedList_ProjectIdField = "project_id"
+const ApprovedList_VersionField = "version"
+
+const ApprovedListMapping string = `{
+	"dynamic":"strict",
+	"properties":{
+		"id":{"type":"keyword"},
+		"` + ApprovedList_ProjectIdField + `":{"type":"keyword"},
+		"` + ApprovedList_VersionField + `":{"type":"integer"},
+		"file_name":{"type":"keyword"},
+		"columns":{
+			"dynamic":"strict",
+			"properties":{
+				"name":{"type":"keyword"},
+				"version":{"type":"keyword"},
+				"component":{"type":"keyword"},
+				"language":{"type":"keyword"}
+			}
+		},
+		"items":{
+			"dynamic":"strict",
+			"properties":{
+				"name":{"type":"keyword"},
+				"version":{"type":"keyword"},
+				"component":{"type":"keyword"},
+				"language":{"type":"keyword"}
+			}
+		},
+		"bundles":{
+			"dynamic":"strict",
+			"properties":{
+				"name":{"type":"keyword"},
+				"packages":{"type":"keyword"}
+			}
+		},
+		"created":{"type":"date"}
+	}
+}`
@@ -15,44 +15,84 @@
 package es
 
 import (
+	"encoding/base64"
+	"encoding/json"
+
 	"github.com/venicegeo/pz-gocommon/elasticsearch"
 	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
 )
 
+const pageSize = 500
+
 func GetAll(index elasticsearch.IIndex, typ string, query interface{}, vsort ...interface{}) (*elastic.SearchHits, error) {
 	return GetAllSource(index, typ, query, true, vsort...)
 }
+
+// GetAllSource walks every page of a query with search_after, so it is not
+// limited by the max result window.
 func GetAllSource(index elasticsearch.IIndex, typ string, query interface{}, source interface{}, vsort ...interface{}) (*elastic.SearchHits, error) {
-	from := int64(0)
-	size := int64(20)
 	res := &elastic.SearchHits{0, nil, []*elastic.SearchHit{}}
-	var sort interface{}
-	if len(vsort) > 0 {
-		sort = vsort[0]
-	} else {
-		sort = map[string]interface{}{}
-	}
+	cursor := ""
 	for {
-		q := map[string]interface{}{
-			"from":    from,
-			"size":    size,
-			"_source": source,
-			"query":   query,
-			"sort":    sort,
-		}
-		result, err := index.SearchByJSON(typ, q)
+		hits, next, err := SearchPage(index, typ, query, source, vsort, cursor, pageSize)
 		if err != nil {
 			return nil, err
 		}
-		if len(result.Hits.Hits) == 0 {
+		res.Hits = append(res.Hits, hits...)
+		if next == "" {
 			break
 		}
-		res.Hits = append(res.Hits, result.Hits.Hits...)
-		if int64(len(res.Hits)) < size {
-			break
-		}
-		from += size
+		cursor = next
 	}
 	res.TotalHits = int64(len(res.Hits))
 	return res, nil
 }
+
+// SearchPage returns up to size hits that sort after the cursor, along with the
+// cursor of the next page, which is empty once there are no more hits. Ties in
+// the sort are broken by the document uid.
+func SearchPage(index elasticsearch.IIndex, typ string, query interface{}, source interface{}, sort []interface{}, cursor string, size int) ([]*elastic.SearchHit, string, error) {
+	q := map[string]interface{}{
+		"size":    size,
+		"_source": source,
+		"query":   query,
+		"sort":    append(append([]interface{}{}, sort...), map[string]interface{}{"_uid": "asc"}),
+	}
+	if query == nil {
+		q["query"] = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		q["search_after"] = after
+	}
+	result, err := index.SearchByJSON(typ, q)
+	if err != nil {
+		return nil, "", err
+	}
+	hits := result.Hits.Hits
+	if len(hits) < size {
+		return hits, "", nil
+	}
+	next, err := encodeCursor(hits[len(hits)-1].Sort)
+	return hits, next, err
+}
+
+func encodeCursor(sort []interface{}) (string, error) {
+	dat, err := json.Marshal(sort)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(dat), nil
+}
+
+func decodeCursor(cursor string) ([]interface{}, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var after []interface{}
+	return after, json.Unmarshal(dat, &after)
+}
@@ -15,31 +15,57 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 
 	"github.com/venicegeo/pz-gocommon/elasticsearch"
 	"github.com/venicegeo/vzutil-versioning/web/app"
 	s "github.com/venicegeo/vzutil-versioning/web/app/structs"
+	"github.com/venicegeo/vzutil-versioning/web/store"
 )
 
 func main() {
 	if os.Getenv("VZUTIL_AUTH") == "" {
 		log.Fatalln("NO CREDENTIALS")
 	}
-	url, user, pass, err := s.GetVcapES()
-	log.Printf("The elasticsearch url has been found to be [%s]\n", url)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	index, err := elasticsearch.NewIndex2(url, user, pass, "versioning_tool", app.ESMapping)
+	st, err := openStore()
 	if err != nil {
 		log.Fatalln(err.Error())
-	} else {
-		log.Println(index.GetVersion())
 	}
 
-	app := app.NewApplication(index, "./single", "./compare", "templates/", false)
+	app := app.NewApplication(st, "templates/", false)
 	app.StartInternals()
 	log.Println(<-app.StartServer())
 }
+
+// openStore opens the store named by VZUTIL_STORE, either the elasticsearch
+// index from VCAP_SERVICES (the default) or the files under VZUTIL_STORE_DIR.
+func openStore() (store.Store, error) {
+	switch os.Getenv("VZUTIL_STORE") {
+	case "", "es":
+		url, user, pass, err := s.GetVcapES()
+		log.Printf("The elasticsearch url has been found to be [%s]\n", url)
+		if err != nil {
+			return nil, err
+		}
+		if err = store.MigrateES(url, user, pass, "versioning_tool"); err != nil {
+			return nil, err
+		}
+		index, err := elasticsearch.NewIndex2(url, user, pass, "versioning_tool", store.ESMapping)
+		if err != nil {
+			return nil, err
+		}
+		log.Println(index.GetVersion())
+		return store.NewESStore(index), nil
+	case "file":
+		dir := os.Getenv("VZUTIL_STORE_DIR")
+		if dir == "" {
+			dir = "data"
+		}
+		log.Printf("Using the file store in [%s]\n", dir)
+		return store.NewFileStore(dir)
+	default:
+		return nil, errors.New("Unknown VZUTIL_STORE " + os.Getenv("VZUTIL_STORE"))
+	}
+}
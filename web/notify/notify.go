@@ -0,0 +1,351 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// Event is something that happened in a project that subscribers may want to hear about.
+type Event struct {
+	Kind      types.EventKind `json:"kind"`
+	ProjectId string          `json:"project_id"`
+	Project   string          `json:"project"`
+	Repo      string          `json:"repository"`
+	Ref       string          `json:"ref,omitempty"`
+	Sha       string          `json:"sha,omitempty"`
+	Summary   string          `json:"summary"`
+	Details   []string        `json:"details,omitempty"`
+	// Languages are the languages the event touches. Events without any pass
+	// every language filter.
+	Languages []string  `json:"languages,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+var issueValues = regexp.MustCompile(`\[[^\]]*\]`)
+
+// IssueType strips the bracketed values out of an issue, so that issues about
+// different packages of the same kind compare equal.
+func IssueType(issue string) string {
+	return issueValues.ReplaceAllString(issue, "[]")
+}
+
+// Matches reports whether the subscription wants the event. Empty filters
+// accept everything, and repositories may be glob patterns.
+func Matches(sub *types.Subscription, e *Event) bool {
+	if sub.ProjectId != e.ProjectId {
+		return false
+	}
+	if len(sub.Events) > 0 {
+		found := false
+		for _, kind := range sub.Events {
+			found = found || kind == e.Kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(sub.Repos) > 0 {
+		found := false
+		for _, pattern := range sub.Repos {
+			ok, _ := path.Match(pattern, e.Repo)
+			found = found || ok
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(sub.Languages) > 0 && len(e.Languages) > 0 {
+		for _, want := range sub.Languages {
+			for _, lang := range e.Languages {
+				if strings.EqualFold(want, lang) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Text renders events as a plain message.
+func Text(events []*Event) string {
+	buf := bytes.NewBufferString("")
+	for i, e := range events {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(u.Format("[%s] %s: %s\n", e.Project, e.Repo, e.Summary))
+		for _, detail := range e.Details {
+			buf.WriteString("    " + detail + "\n")
+		}
+	}
+	return buf.String()
+}
+
+func subject(events []*Event) string {
+	if len(events) == 1 {
+		return u.Format("[%s] %s: %s", events[0].Project, events[0].Repo, events[0].Summary)
+	}
+	return u.Format("[%s] %d dependency events", events[0].Project, len(events))
+}
+
+// Sender delivers events to the target of a subscription.
+type Sender interface {
+	Send(ctx context.Context, target string, events []*Event) error
+}
+
+// WebhookSender posts the events as json.
+type WebhookSender struct {
+	Client *http.Client
+}
+
+// SlackSender posts the events as the text of a Slack incoming webhook message.
+type SlackSender struct {
+	Client *http.Client
+}
+
+// EmailSender mails the events through an SMTP server.
+type EmailSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (w *WebhookSender) Send(ctx context.Context, target string, events []*Event) error {
+	return postJSON(ctx, w.Client, target, map[string]interface{}{"events": events})
+}
+
+func (s *SlackSender) Send(ctx context.Context, target string, events []*Event) error {
+	return postJSON(ctx, s.Client, target, map[string]string{"text": "*" + subject(events) + "*\n```\n" + Text(events) + "```"})
+}
+
+// ParseEmail reads a single bare email address, refusing anything that could
+// add lines to the headers of a message.
+func ParseEmail(target string) (string, error) {
+	if strings.ContainsAny(target, "\r\n") {
+		return "", u.Error("An email address can not hold a line break")
+	}
+	addr, err := mail.ParseAddress(target)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
+
+func (e *EmailSender) Send(ctx context.Context, target string, events []*Event) error {
+	target, err := ParseEmail(target)
+	if err != nil {
+		return err
+	}
+	msg := u.Format("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		e.From, target, subject(events), strings.Replace(Text(events), "\n", "\r\n", -1))
+	return smtp.SendMail(e.Addr, e.Auth, e.From, []string{target}, []byte(msg))
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	dat, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(dat))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return u.Error("%s responded %s", url, resp.Status)
+	}
+	return nil
+}
+
+// DigestStore keeps the digests waiting to be sent, so that they outlive a restart.
+type DigestStore interface {
+	GetSubscription(id string) (*types.Subscription, bool, error)
+	PutDigest(digest *types.Digest) error
+	GetDigest(id string) (*types.Digest, bool, error)
+	DeleteDigest(id string) error
+	Digests() ([]*types.Digest, error)
+}
+
+// Notifier sends events to the subscriptions that match them, either straight
+// away or batched into a digest per subscription.
+type Notifier struct {
+	senders map[types.Channel]Sender
+	digests DigestStore
+	timeout time.Duration
+	// retry is how long a digest that failed to send waits before the next try.
+	retry time.Duration
+
+	mux sync.Mutex
+}
+
+func NewNotifier(senders map[types.Channel]Sender, digests DigestStore) *Notifier {
+	return &Notifier{senders: senders, digests: digests, timeout: time.Second * 30, retry: time.Minute * 5}
+}
+
+// Notify sends the event to each matching subscription without a digest, and
+// queues it for the others. It returns the first error.
+func (n *Notifier) Notify(subs []*types.Subscription, e *Event) error {
+	var err error
+	for _, sub := range subs {
+		if !Matches(sub, e) {
+			continue
+		}
+		if sub.DigestMinutes <= 0 {
+			if e := n.send(sub, []*Event{e}); e != nil && err == nil {
+				err = e
+			}
+			continue
+		}
+		if e := n.queue(sub, e); e != nil {
+			log.Printf("[NOTIFY] Unable to queue a digest for %s: %s\n", sub.Target, e.Error())
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+func (n *Notifier) queue(sub *types.Subscription, e *Event) error {
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	digest, found, err := n.digests.GetDigest(sub.Id)
+	if err != nil {
+		return err
+	} else if !found {
+		now := time.Now()
+		digest = &types.Digest{Id: sub.Id, ProjectId: sub.ProjectId, Due: now.Add(time.Duration(sub.DigestMinutes) * time.Minute), Created: now}
+	}
+	digest.Events = append(digest.Events, dat)
+	return n.digests.PutDigest(digest)
+}
+
+// Flush sends the digests that are due, or all of them when forced. A digest
+// is only removed once it has been sent, and one that fails to send is tried
+// again after the retry delay. Digests of subscriptions that no longer exist
+// are dropped.
+func (n *Notifier) Flush(force bool) error {
+	now := time.Now()
+	digests, err := n.digests.Digests()
+	if err != nil {
+		log.Println("[NOTIFY] Unable to read the queued digests:", err.Error())
+		return err
+	}
+	for _, digest := range digests {
+		if !force && now.Before(digest.Due) {
+			continue
+		}
+		if e := n.flush(digest); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (n *Notifier) flush(digest *types.Digest) error {
+	sub, found, err := n.digests.GetSubscription(digest.Id)
+	if err != nil {
+		return err
+	} else if !found {
+		return n.digests.DeleteDigest(digest.Id)
+	}
+	events := make([]*Event, len(digest.Events), len(digest.Events))
+	for i, dat := range digest.Events {
+		events[i] = new(Event)
+		if err = json.Unmarshal(dat, events[i]); err != nil {
+			return err
+		}
+	}
+	sendErr := n.send(sub, events)
+
+	// Events may have been queued while sending, so the stored digest is read
+	// again and only the events that were sent are taken out of it.
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	current, found, err := n.digests.GetDigest(digest.Id)
+	if err != nil || !found {
+		return err
+	}
+	if sendErr != nil {
+		current.Due = time.Now().Add(n.retry)
+		if err = n.digests.PutDigest(current); err != nil {
+			log.Printf("[NOTIFY] Unable to requeue the digest for %s: %s\n", sub.Target, err.Error())
+		}
+		return sendErr
+	}
+	if len(current.Events) <= len(events) {
+		return n.digests.DeleteDigest(digest.Id)
+	}
+	current.Events = current.Events[len(events):]
+	return n.digests.PutDigest(current)
+}
+
+// Run flushes due digests every interval until the context is done.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.Flush(false)
+		}
+	}
+}
+
+func (n *Notifier) send(sub *types.Subscription, events []*Event) error {
+	sender, ok := n.senders[sub.Channel]
+	if !ok {
+		err := u.Error("No sender is configured for %s", sub.Channel)
+		log.Printf("[NOTIFY] Unable to notify %s: %s\n", sub.Target, err.Error())
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	if err := sender.Send(ctx, sub.Target, events); err != nil {
+		log.Printf("[NOTIFY] Unable to notify %s: %s\n", sub.Target, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	"github.com/venicegeo/vzutil-versioning/web/store"
+)
+
+type receiver struct {
+	*httptest.Server
+	mux    sync.Mutex
+	status int
+	bodies []string
+}
+
+func newReceiver(status int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		dat, _ := ioutil.ReadAll(req.Body)
+		r.mux.Lock()
+		r.bodies = append(r.bodies, string(dat))
+		status := r.status
+		r.mux.Unlock()
+		w.WriteHeader(status)
+	}))
+	return r
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mux.Lock()
+	r.status = status
+	r.mux.Unlock()
+}
+
+func newTestDigests(t *testing.T, subs ...*types.Subscription) (*store.FileStore, func()) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.NewFileStore(dir)
+	if err == nil {
+		for _, sub := range subs {
+			if err = s.PutSubscription(sub); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func testEvent(kind types.EventKind, repo string, languages ...string) *Event {
+	return &Event{Kind: kind, ProjectId: "p", Project: "Project", Repo: repo, Summary: string(kind), Languages: languages}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		sub     types.Subscription
+		event   *Event
+		matches bool
+	}{
+		{types.Subscription{ProjectId: "p"}, testEvent(types.EventDifference, "org/a"), true},
+		{types.Subscription{ProjectId: "q"}, testEvent(types.EventDifference, "org/a"), false},
+		{types.Subscription{ProjectId: "p", Events: []types.EventKind{types.EventScanFailed}}, testEvent(types.EventDifference, "org/a"), false},
+		{types.Subscription{ProjectId: "p", Repos: []string{"org/pz-*"}}, testEvent(types.EventDifference, "org/pz-logger"), true},
+		{types.Subscription{ProjectId: "p", Repos: []string{"org/pz-*"}}, testEvent(types.EventDifference, "org/bf-ui"), false},
+		{types.Subscription{ProjectId: "p", Languages: []string{"go"}}, testEvent(types.EventDifference, "org/a", "java", "go"), true},
+		{types.Subscription{ProjectId: "p", Languages: []string{"go"}}, testEvent(types.EventDifference, "org/a", "java"), false},
+		{types.Subscription{ProjectId: "p", Languages: []string{"go"}}, testEvent(types.EventScanFailed, "org/a"), true},
+	}
+	for i, test := range tests {
+		assert.Equal(t, test.matches, Matches(&test.sub, test.event), "test %d", i)
+	}
+}
+
+func TestIssueType(t *testing.T) {
+	assert.Equal(t, IssueType("Unknown sha [abc] for package [x]"), IssueType("Unknown sha [def] for package [y]"))
+	assert.NotEqual(t, IssueType("Package [x] is missing a version"), IssueType("Unknown sha [def] for package [y]"))
+}
+
+func TestParseEmail(t *testing.T) {
+	addr, err := ParseEmail("Some One <someone@example.com>")
+	assert.NoError(t, err)
+	assert.Equal(t, "someone@example.com", addr)
+	for _, bad := range []string{"someone", "a@b, c@d", "someone@example.com\r\nBcc: other@example.com", "someone@example.com\nBcc: other@example.com"} {
+		_, err = ParseEmail(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	webhook, slack := newReceiver(200), newReceiver(200)
+	defer webhook.Close()
+	defer slack.Close()
+	subs := []*types.Subscription{
+		{Id: "1", ProjectId: "p", Channel: types.ChannelWebhook, Target: webhook.URL},
+		{Id: "2", ProjectId: "p", Channel: types.ChannelSlack, Target: slack.URL, DigestMinutes: 60},
+	}
+	digests, cleanup := newTestDigests(t, subs...)
+	defer cleanup()
+	senders := map[types.Channel]Sender{
+		types.ChannelWebhook: &WebhookSender{},
+		types.ChannelSlack:   &SlackSender{},
+	}
+	n := NewNotifier(senders, digests)
+	assert.NoError(t, n.Notify(subs, testEvent(types.EventDifference, "org/a")))
+	assert.NoError(t, n.Notify(subs, testEvent(types.EventScanFailed, "org/b")))
+
+	if assert.Len(t, webhook.bodies, 2) {
+		var body struct {
+			Events []*Event `json:"events"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(webhook.bodies[0]), &body))
+		if assert.Len(t, body.Events, 1) {
+			assert.Equal(t, "org/a", body.Events[0].Repo)
+		}
+	}
+	assert.Len(t, slack.bodies, 0, "the digest was sent early")
+
+	assert.NoError(t, n.Flush(false))
+	assert.Len(t, slack.bodies, 0, "the digest was sent before it was due")
+	n = NewNotifier(senders, digests)
+	assert.NoError(t, n.Flush(true))
+	if assert.Len(t, slack.bodies, 1) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(slack.bodies[0]), &body))
+		assert.True(t, strings.Contains(body.Text, "org/a") && strings.Contains(body.Text, "org/b"), body.Text)
+	}
+	assert.NoError(t, n.Flush(true))
+	assert.Len(t, slack.bodies, 1)
+}
+
+func TestDeliveryFailure(t *testing.T) {
+	broken := newReceiver(500)
+	defer broken.Close()
+	subs := []*types.Subscription{
+		{Id: "1", ProjectId: "p", Channel: types.ChannelWebhook, Target: broken.URL},
+		{Id: "2", ProjectId: "p", Channel: types.ChannelEmail, Target: "someone@example.com"},
+		{Id: "3", ProjectId: "p", Channel: types.ChannelWebhook, Target: broken.URL, DigestMinutes: 60},
+	}
+	digests, cleanup := newTestDigests(t, subs...)
+	defer cleanup()
+	n := NewNotifier(map[types.Channel]Sender{types.ChannelWebhook: &WebhookSender{}}, digests)
+	assert.Error(t, n.Notify(subs, testEvent(types.EventDifference, "org/a")))
+	assert.Len(t, broken.bodies, 1)
+
+	assert.Error(t, n.Flush(true))
+	assert.Len(t, broken.bodies, 2)
+	digest, found, err := digests.GetDigest("3")
+	assert.NoError(t, err)
+	if assert.True(t, found, "the digest was lost when sending it failed") {
+		assert.Len(t, digest.Events, 1)
+		assert.True(t, digest.Due.After(time.Now()), "the failed digest was not put off")
+	}
+	assert.NoError(t, n.Flush(false))
+	assert.Len(t, broken.bodies, 2, "the failed digest was retried straight away")
+
+	broken.setStatus(200)
+	assert.NoError(t, n.Notify(subs[2:], testEvent(types.EventScanFailed, "org/b")))
+	assert.NoError(t, n.Flush(true))
+	if assert.Len(t, broken.bodies, 3) {
+		assert.True(t, strings.Contains(broken.bodies[2], "org/a") && strings.Contains(broken.bodies[2], "org/b"), broken.bodies[2])
+	}
+	_, found, _ = digests.GetDigest("3")
+	assert.False(t, found)
+}
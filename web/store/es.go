@@ -0,0 +1,648 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch"
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/web/es"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+const ESMapping = `
+{
+	"mappings": {
+		"` + ScanType + `": ` + types.ScanMapping + `,
+		"` + DifferenceType + `": ` + types.DifferenceMapping + `,
+		"` + RepositoryType + `": ` + types.RepositoryMapping + `,
+		"` + ProjectType + `": ` + types.ProjectMapping + `,
+		"` + JobType + `": ` + types.JobMapping + `,
+		"` + BackfillType + `": ` + types.BackfillMapping + `,
+		"` + SubscriptionType + `": ` + types.SubscriptionMapping + `,
+		"` + DigestType + `": ` + types.DigestMapping + `,
+		"` + ScheduleType + `": ` + types.ScheduleMapping + `,
+		"` + ApprovedListType + `": ` + types.ApprovedListMapping + `
+	}
+}`
+const ScanType = `repository_entry`
+const DifferenceType = `difference`
+const RepositoryType = `repository`
+const ProjectType = `project`
+const JobType = `job`
+const BackfillType = `backfill`
+const SubscriptionType = `subscription`
+const DigestType = `digest`
+const ScheduleType = `schedule`
+const ApprovedListType = `approved_list`
+
+type ESStore struct {
+	index elasticsearch.IIndex
+}
+
+// NewESStore wraps an index, or the alias of one, set up by MigrateES.
+func NewESStore(index elasticsearch.IIndex) *ESStore {
+	return &ESStore{index}
+}
+
+func (s *ESStore) get(typ, id string, into interface{}) (bool, error) {
+	resp, err := s.index.GetByID(typ, id)
+	if resp == nil {
+		return false, err
+	} else if !resp.Found {
+		return false, nil
+	}
+	return true, json.Unmarshal(*resp.Source, into)
+}
+
+func (s *ESStore) post(typ, id string, obj interface{}) error {
+	_, err := s.index.PostData(typ, id, obj)
+	return err
+}
+
+func (s *ESStore) search(typ string, query map[string]interface{}) ([]*elastic.SearchHit, error) {
+	resp, err := s.index.SearchByJSON(typ, query)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Hits.Hits, nil
+}
+
+func (s *ESStore) count(typ string, query interface{}) (int64, error) {
+	resp, err := s.index.SearchByJSON(typ, map[string]interface{}{
+		"query": query,
+		"size":  0,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Hits.TotalHits, nil
+}
+
+func (s *ESStore) deleteAll(typ string, query interface{}) error {
+	hits, err := es.GetAllSource(s.index, typ, query, false)
+	if err != nil {
+		return err
+	}
+	for _, hit := range hits.Hits {
+		if _, err = s.index.DeleteByID(typ, hit.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func must(items ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": es.NewBool().SetMust(es.NewBoolQ(items...))}
+}
+
+func (s *ESStore) GetProject(id string) (*types.Project, bool, error) {
+	project := new(types.Project)
+	if found, err := s.get(ProjectType, id, project); !found || err != nil {
+		return nil, found, err
+	}
+	return project, true, nil
+}
+
+func (s *ESStore) ProjectByName(displayName string) (*types.Project, bool, error) {
+	hits, err := s.search(ProjectType, map[string]interface{}{
+		"query": es.NewTerm(types.Project_DisplayNameField, displayName),
+		"size":  1,
+	})
+	if err != nil || len(hits) == 0 {
+		return nil, false, err
+	}
+	project := new(types.Project)
+	return project, true, json.Unmarshal(*hits[0].Source, project)
+}
+
+func (s *ESStore) Projects() ([]*types.Project, error) {
+	hits, err := es.GetAll(s.index, ProjectType, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Project, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.Project)
+		if err = json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *ESStore) PutProject(project *types.Project) error {
+	return s.post(ProjectType, project.Id, project)
+}
+
+func (s *ESStore) DeleteProject(id string) error {
+	if _, err := s.index.DeleteByIDWait(ProjectType, id); err != nil {
+		return err
+	}
+	if err := s.deleteAll(RepositoryType, es.NewTerm(types.Repository_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(SubscriptionType, es.NewTerm(types.Subscription_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(DigestType, es.NewTerm(types.Digest_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(ScheduleType, es.NewTerm(types.Schedule_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(ApprovedListType, es.NewTerm(types.ApprovedList_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(DifferenceType, es.NewTerm(types.Difference_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(JobType, es.NewTerm(types.Job_ProjectIdField, id)); err != nil {
+		return err
+	}
+	if err := s.deleteAll(BackfillType, es.NewTerm(types.Backfill_ProjectIdField, id)); err != nil {
+		return err
+	}
+	return s.deleteAll(ScanType, es.NewTerm(types.Scan_ProjectIdField, id))
+}
+
+func (s *ESStore) repositoryHit(projectId, fullname string) (*elastic.SearchHit, error) {
+	hits, err := s.search(RepositoryType, map[string]interface{}{
+		"query": must(
+			es.NewTerm(types.Repository_ProjectIdField, projectId),
+			es.NewTerm(types.Repository_NameField, fullname)),
+		"size": 1,
+	})
+	if err != nil || len(hits) == 0 {
+		return nil, err
+	}
+	return hits[0], nil
+}
+
+func (s *ESStore) GetRepository(projectId, fullname string) (*types.Repository, bool, error) {
+	hit, err := s.repositoryHit(projectId, fullname)
+	if hit == nil {
+		return nil, false, err
+	}
+	repo := new(types.Repository)
+	return repo, true, json.Unmarshal(*hit.Source, repo)
+}
+
+func (s *ESStore) Repositories(projectId string) ([]*types.Repository, error) {
+	hits, err := es.GetAll(s.index, RepositoryType, es.NewTerm(types.Repository_ProjectIdField, projectId))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Repository, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.Repository)
+		if err = json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *ESStore) PutRepository(repo *types.Repository) error {
+	return s.post(RepositoryType, repo.Id, repo)
+}
+
+func (s *ESStore) DeleteRepository(projectId, fullname string) error {
+	hit, err := s.repositoryHit(projectId, fullname)
+	if err != nil {
+		return err
+	} else if hit == nil {
+		return u.Error("Repository %s does not exist in %s", fullname, projectId)
+	}
+	if _, err = s.index.DeleteByIDWait(RepositoryType, hit.Id); err != nil {
+		return err
+	}
+	return s.deleteAll(ScanType, must(
+		es.NewTerm(types.Scan_FullnameField, fullname),
+		es.NewTerm(types.Scan_ProjectIdField, projectId)))
+}
+
+func (s *ESStore) ProjectsUsingRepository(fullname string) ([]string, error) {
+	agg := es.NewAggQuery("projects", types.Repository_ProjectIdField)
+	agg["query"] = es.NewTerm(types.Repository_NameField, fullname)
+	resp, err := s.index.SearchByJSON(RepositoryType, agg)
+	return es.GetAggKeysFromSearchResponse("projects", resp, err)
+}
+
+func (s *ESStore) GetScan(projectId, sha string) (*types.Scan, bool, error) {
+	scan := new(types.Scan)
+	if found, err := s.get(ScanType, ScanId(projectId, sha), scan); !found || err != nil {
+		return nil, found, err
+	}
+	return scan, true, nil
+}
+
+func (s *ESStore) PutScan(scan *types.Scan) error {
+	return s.post(ScanType, ScanId(scan.ProjectId, scan.Sha), scan)
+}
+
+func (s *ESStore) ScanPage(projectId, fullname, cursor string, size int) ([]*types.Scan, string, error) {
+	hits, next, err := es.SearchPage(s.index, ScanType, must(
+		es.NewTerm(types.Scan_FullnameField, fullname),
+		es.NewTerm(types.Scan_ProjectIdField, projectId)), true,
+		[]interface{}{map[string]interface{}{types.Scan_TimestampField: "desc"}}, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	res := make([]*types.Scan, len(hits), len(hits))
+	for i, hit := range hits {
+		res[i] = new(types.Scan)
+		if err = json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return res, next, nil
+}
+
+func (s *ESStore) LatestScan(projectId, fullname, ref string, before time.Time) (*types.Scan, bool, error) {
+	boolq := es.NewBoolQ(
+		es.NewTerm(types.Scan_FullnameField, fullname),
+		es.NewTerm(types.Scan_RefsField, ref),
+		es.NewTerm(types.Scan_ProjectIdField, projectId))
+	if !before.IsZero() {
+		boolq.Add(es.NewRange(types.Scan_TimestampField, "lt", before))
+	}
+	hits, err := s.search(ScanType, map[string]interface{}{
+		"query": map[string]interface{}{"bool": es.NewBool().SetMust(boolq)},
+		"sort": map[string]interface{}{
+			types.Scan_TimestampField: "desc",
+		},
+		"size": 1,
+	})
+	if err != nil || len(hits) == 0 {
+		return nil, false, err
+	}
+	scan := new(types.Scan)
+	return scan, true, json.Unmarshal(*hits[0].Source, scan)
+}
+
+func (s *ESStore) RepositoryRefs(projectId, fullname string) ([]string, error) {
+	agg := es.NewAggQuery("refs", types.Scan_RefsField)
+	agg["query"] = must(
+		es.NewTerm(types.Scan_FullnameField, fullname),
+		es.NewTerm(types.Scan_ProjectIdField, projectId))
+	resp, err := s.index.SearchByJSON(ScanType, agg)
+	return es.GetAggKeysFromSearchResponse("refs", resp, err)
+}
+
+func (s *ESStore) ProjectRefs(projectId string) ([]string, error) {
+	agg := es.NewAggQuery("refs", types.Scan_RefsField)
+	agg["query"] = es.NewTerm(types.Scan_ProjectIdField, projectId)
+	resp, err := s.index.SearchByJSON(ScanType, agg)
+	return es.GetAggKeysFromSearchResponse("refs", resp, err)
+}
+
+func (s *ESStore) ScannedRepositories() ([]string, error) {
+	agg := es.NewAggQuery("repo", types.Scan_FullnameField)
+	resp, err := s.index.SearchByJSON(ScanType, agg)
+	return es.GetAggKeysFromSearchResponse("repo", resp, err)
+}
+
+func (s *ESStore) SearchDependency(fullnames []string, name, versionPrefix string) ([]*DependencyHit, error) {
+	nested := es.NewNestedQuery(types.Scan_SubDependenciesField)
+	nested.SetInnerQuery(must(
+		es.NewTerm(types.Scan_SubDependenciesField+"."+d.NameField, name),
+		es.NewWildcard(types.Scan_SubDependenciesField+"."+d.VersionField, versionPrefix+"*")))
+	query := map[string]interface{}{"bool": es.NewBool().
+		SetMust(es.NewBoolQ(nested)).
+		SetFilter(es.NewBoolQ(es.NewTerms(types.Scan_FullnameField, fullnames...)))}
+
+	hits, err := es.GetAllSource(s.index, ScanType, query, []string{types.Scan_FullnameField, types.Scan_RefsField, types.Scan_ShaField, types.Scan_SubComponentsField})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*DependencyHit, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		var scan types.Scan
+		if err = json.Unmarshal(*hit.Source, &scan); err != nil {
+			return nil, err
+		}
+		res[i] = &DependencyHit{RepoFullname: scan.RepoFullname, Sha: scan.Sha, Refs: scan.Refs}
+		for _, inner := range hit.InnerHits[types.Scan_SubDependenciesField].Hits.Hits {
+			var dep d.Dependency
+			if err = json.Unmarshal(*inner.Source, &dep); err != nil {
+				return nil, err
+			}
+			res[i].Dependencies = append(res[i].Dependencies, dep)
+		}
+		if scan.Scan != nil && scan.Scan.Components != nil {
+			res[i].Components = hitComponents(scan.Scan, name, versionPrefix)
+		}
+	}
+	return res, nil
+}
+
+func (s *ESStore) GetDifference(id string) (*types.Difference, bool, error) {
+	diff := new(types.Difference)
+	if found, err := s.get(DifferenceType, id, diff); !found || err != nil {
+		return nil, found, err
+	}
+	return diff, true, nil
+}
+
+func (s *ESStore) DifferencePage(projectId, cursor string, size int) ([]*types.Difference, string, error) {
+	hits, next, err := es.SearchPage(s.index, DifferenceType, es.NewTerm(types.Difference_ProjectIdField, projectId), true,
+		[]interface{}{map[string]interface{}{types.Difference_TimeField: "desc"}}, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	res := make([]*types.Difference, len(hits), len(hits))
+	for i, hit := range hits {
+		res[i] = new(types.Difference)
+		if err = json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return res, next, nil
+}
+
+func (s *ESStore) CountDifferences(projectId string) (int64, error) {
+	return s.count(DifferenceType, es.NewTerm(types.Difference_ProjectIdField, projectId))
+}
+
+func (s *ESStore) PutDifference(diff *types.Difference) error {
+	return s.post(DifferenceType, diff.Id, diff)
+}
+
+func (s *ESStore) DeleteDifference(id string) error {
+	_, err := s.index.DeleteByIDWait(DifferenceType, id)
+	return err
+}
+
+func (s *ESStore) DifferenceExists(projectId, oldSha, newSha string) (bool, error) {
+	count, err := s.count(DifferenceType, must(
+		es.NewTerm(types.Difference_ProjectIdField, projectId),
+		es.NewTerm(types.Difference_OldShaField, oldSha),
+		es.NewTerm(types.Difference_NewShaField, newSha)))
+	return count > 0, err
+}
+
+func (s *ESStore) PutJob(job *types.Job) error {
+	return s.post(JobType, job.Id, job)
+}
+
+func (s *ESStore) jobs(hits []*elastic.SearchHit, err error) ([]*types.Job, error) {
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Job, len(hits), len(hits))
+	for i, hit := range hits {
+		res[i] = new(types.Job)
+		if err = json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *ESStore) Jobs(ids []string) ([]*types.Job, error) {
+	res := []*types.Job{}
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > 500 {
+			batch = batch[:500]
+		}
+		ids = ids[len(batch):]
+		jobs, err := s.jobs(s.search(JobType, map[string]interface{}{
+			"query": es.NewTerms(types.Job_IdField, batch...),
+			"size":  len(batch),
+		}))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, jobs...)
+	}
+	return res, nil
+}
+
+func (s *ESStore) JobsByStatus(statuses ...types.JobStatus) ([]*types.Job, error) {
+	strs := make([]string, len(statuses), len(statuses))
+	for i, status := range statuses {
+		strs[i] = string(status)
+	}
+	hits, err := es.GetAll(s.index, JobType, es.NewTerms(types.Job_StatusField, strs...))
+	if err != nil {
+		return nil, err
+	}
+	return s.jobs(hits.Hits, nil)
+}
+
+func (s *ESStore) JobHistory(status types.JobStatus, cursor string, size int) ([]*types.Job, string, error) {
+	var query interface{}
+	if status != "" {
+		query = es.NewTerm(types.Job_StatusField, string(status))
+	}
+	hits, next, err := es.SearchPage(s.index, JobType, query, true,
+		[]interface{}{map[string]interface{}{types.Job_UpdatedField: "desc"}}, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	jobs, err := s.jobs(hits, nil)
+	return jobs, next, err
+}
+
+func (s *ESStore) CountJobs(status types.JobStatus) (int64, error) {
+	return s.count(JobType, es.NewTerm(types.Job_StatusField, string(status)))
+}
+
+func (s *ESStore) PutBackfill(bf *types.Backfill) error {
+	return s.post(BackfillType, bf.Id, bf)
+}
+
+func (s *ESStore) GetBackfill(id string) (*types.Backfill, bool, error) {
+	bf := new(types.Backfill)
+	if found, err := s.get(BackfillType, id, bf); !found || err != nil {
+		return nil, found, err
+	}
+	return bf, true, nil
+}
+
+func (s *ESStore) backfills(hits []*elastic.SearchHit) ([]*types.Backfill, error) {
+	res := make([]*types.Backfill, len(hits), len(hits))
+	for i, hit := range hits {
+		res[i] = new(types.Backfill)
+		if err := json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *ESStore) BackfillsByStatus(statuses ...types.BackfillStatus) ([]*types.Backfill, error) {
+	strs := make([]string, len(statuses), len(statuses))
+	for i, status := range statuses {
+		strs[i] = string(status)
+	}
+	hits, err := es.GetAll(s.index, BackfillType, es.NewTerms(types.Backfill_StatusField, strs...))
+	if err != nil {
+		return nil, err
+	}
+	return s.backfills(hits.Hits)
+}
+
+func (s *ESStore) ProjectBackfills(projectId string, size int) ([]*types.Backfill, error) {
+	hits, err := s.search(BackfillType, map[string]interface{}{
+		"query": es.NewTerm(types.Backfill_ProjectIdField, projectId),
+		"sort": map[string]interface{}{
+			types.Backfill_CreatedField: "desc",
+		},
+		"size": size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.backfills(hits)
+}
+
+func (s *ESStore) PutSubscription(sub *types.Subscription) error {
+	return s.post(SubscriptionType, sub.Id, sub)
+}
+
+func (s *ESStore) GetSubscription(id string) (*types.Subscription, bool, error) {
+	sub := new(types.Subscription)
+	if found, err := s.get(SubscriptionType, id, sub); !found || err != nil {
+		return nil, found, err
+	}
+	return sub, true, nil
+}
+
+func (s *ESStore) DeleteSubscription(id string) error {
+	_, err := s.index.DeleteByIDWait(SubscriptionType, id)
+	return err
+}
+
+func (s *ESStore) Subscriptions(projectId string) ([]*types.Subscription, error) {
+	hits, err := es.GetAll(s.index, SubscriptionType, es.NewTerm(types.Subscription_ProjectIdField, projectId))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Subscription, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.Subscription)
+		if err := json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(res[j].Created) })
+	return res, nil
+}
+
+func (s *ESStore) PutDigest(digest *types.Digest) error {
+	return s.post(DigestType, digest.Id, digest)
+}
+
+func (s *ESStore) GetDigest(id string) (*types.Digest, bool, error) {
+	digest := new(types.Digest)
+	if found, err := s.get(DigestType, id, digest); !found || err != nil {
+		return nil, found, err
+	}
+	return digest, true, nil
+}
+
+func (s *ESStore) DeleteDigest(id string) error {
+	_, err := s.index.DeleteByIDWait(DigestType, id)
+	return err
+}
+
+func (s *ESStore) Digests() ([]*types.Digest, error) {
+	hits, err := es.GetAll(s.index, DigestType, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Digest, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.Digest)
+		if err := json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Due.Before(res[j].Due) })
+	return res, nil
+}
+
+func (s *ESStore) PutSchedule(sched *types.Schedule) error {
+	return s.post(ScheduleType, sched.Id, sched)
+}
+
+func (s *ESStore) GetSchedule(id string) (*types.Schedule, bool, error) {
+	sched := new(types.Schedule)
+	if found, err := s.get(ScheduleType, id, sched); !found || err != nil {
+		return nil, found, err
+	}
+	return sched, true, nil
+}
+
+func (s *ESStore) DeleteSchedule(id string) error {
+	_, err := s.index.DeleteByIDWait(ScheduleType, id)
+	return err
+}
+
+func (s *ESStore) Schedules(projectId string) ([]*types.Schedule, error) {
+	var query interface{}
+	if projectId != "" {
+		query = es.NewTerm(types.Schedule_ProjectIdField, projectId)
+	}
+	hits, err := es.GetAll(s.index, ScheduleType, query)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.Schedule, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.Schedule)
+		if err := json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(res[j].Created) })
+	return res, nil
+}
+
+func (s *ESStore) PutApprovedList(list *types.ApprovedList) error {
+	return s.post(ApprovedListType, list.Id, list)
+}
+
+func (s *ESStore) GetApprovedList(id string) (*types.ApprovedList, bool, error) {
+	list := new(types.ApprovedList)
+	if found, err := s.get(ApprovedListType, id, list); !found || err != nil {
+		return nil, found, err
+	}
+	return list, true, nil
+}
+
+func (s *ESStore) ApprovedLists(projectId string) ([]*types.ApprovedList, error) {
+	hits, err := es.GetAll(s.index, ApprovedListType, es.NewTerm(types.ApprovedList_ProjectIdField, projectId))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*types.ApprovedList, len(hits.Hits), len(hits.Hits))
+	for i, hit := range hits.Hits {
+		res[i] = new(types.ApprovedList)
+		if err := json.Unmarshal(*hit.Source, res[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version > res[j].Version })
+	return res, nil
+}
@@ -0,0 +1,897 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// FileStore keeps every item as a json file under a directory, one directory per
+// type. Repositories and differences are further kept in a folder per project,
+// and scans in a folder per project and repository, so that the queries about
+// one of them only read its own files. Items are read from disk when asked for
+// and each write touches only the file of the item written. It needs no other
+// service to run, which suits testing and small installs.
+type FileStore struct {
+	dir string
+
+	mux *sync.RWMutex
+}
+
+// grouping reads the folders an item of a type is kept in.
+type grouping func(dat []byte) ([]string, error)
+
+// groupings lists the types kept in folders, by how to read the folders of an item.
+var groupings = map[string]grouping{
+	RepositoryType: func(dat []byte) ([]string, error) {
+		var repo types.Repository
+		err := json.Unmarshal(dat, &repo)
+		return []string{repo.ProjectId}, err
+	},
+	ScanType: func(dat []byte) ([]string, error) {
+		var scan types.Scan
+		err := json.Unmarshal(dat, &scan)
+		return []string{scan.ProjectId, scan.RepoFullname}, err
+	},
+	DifferenceType: func(dat []byte) ([]string, error) {
+		var diff types.Difference
+		err := json.Unmarshal(dat, &diff)
+		return []string{diff.ProjectName}, err
+	},
+}
+
+// groupDepth is the number of folders the items of a type are kept under.
+var groupDepth = map[string]int{RepositoryType: 1, ScanType: 2, DifferenceType: 1}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	s := &FileStore{dir, &sync.RWMutex{}}
+	for _, typ := range []string{ProjectType, RepositoryType, ScanType, DifferenceType, JobType, BackfillType, SubscriptionType, DigestType, ScheduleType, ApprovedListType} {
+		if err := os.MkdirAll(filepath.Join(dir, typ), 0755); err != nil {
+			return nil, err
+		}
+	}
+	for typ, groups := range groupings {
+		if err := s.regroup(typ, groups); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// regroup moves the items of a type that were written before the type was
+// kept in folders into their folders.
+func (s *FileStore) regroup(typ string, groups grouping) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, typ, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		folders, err := groups(dat)
+		if err != nil {
+			return u.Error("Unable to read %s: %s", file, err.Error())
+		}
+		dest := filepath.Join(s.folder(typ, folders...), filepath.Base(file))
+		if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err = os.Rename(file, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segment escapes a folder or file name. An empty name gets a folder of its
+// own that no escaped name can clash with.
+func segment(name string) string {
+	if name == "" {
+		return "%"
+	}
+	return url.PathEscape(name)
+}
+
+// folder is the directory of the items of a type under the given folders.
+func (s *FileStore) folder(typ string, folders ...string) string {
+	parts := []string{s.dir, typ}
+	for _, folder := range folders {
+		parts = append(parts, segment(folder))
+	}
+	return filepath.Join(parts...)
+}
+
+// pattern matches the files of the items of a type under the given folders,
+// and under any folder for the levels after them. An empty id matches every item.
+func (s *FileStore) pattern(typ, id string, folders ...string) string {
+	parts := []string{s.folder(typ, folders...)}
+	for i := len(folders); i < groupDepth[typ]; i++ {
+		parts = append(parts, "*")
+	}
+	if id == "" {
+		return filepath.Join(append(parts, "*.json")...)
+	}
+	return filepath.Join(append(parts, segment(id)+".json")...)
+}
+
+func (s *FileStore) get(typ, id string, into interface{}, folders ...string) (bool, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	files, err := filepath.Glob(s.pattern(typ, id, folders...))
+	if err != nil || len(files) == 0 {
+		return false, err
+	}
+	dat, err := ioutil.ReadFile(files[0])
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(dat, into)
+}
+
+// put writes an item, which has to be given all of the folders of its type.
+func (s *FileStore) put(typ, id string, obj interface{}, folders ...string) error {
+	dat, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	path := s.pattern(typ, id, folders...)
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, dat, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// remove deletes an item by its id, wherever it is under the given folders.
+func (s *FileStore) remove(typ, id string, folders ...string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	files, err := filepath.Glob(s.pattern(typ, id, folders...))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFolder deletes every item of a type under the given folders.
+func (s *FileStore) removeFolder(typ string, folders ...string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return os.RemoveAll(s.folder(typ, folders...))
+}
+
+// deleteWhere removes the items of a type that the filter decodes and accepts.
+func (s *FileStore) deleteWhere(typ string, filter func([]byte) (bool, error)) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	files, err := filepath.Glob(s.pattern(typ, ""))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		remove, err := filter(dat)
+		if err != nil {
+			return err
+		}
+		if !remove {
+			continue
+		}
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// each decodes every item of a type under the given folders in turn, reading
+// one file at a time.
+func (s *FileStore) each(typ string, decode func([]byte) error, folders ...string) error {
+	return s.eachMatch(s.pattern(typ, "", folders...), decode)
+}
+
+func (s *FileStore) eachMatch(pattern string, decode func([]byte) error) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = decode(dat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) GetProject(id string) (*types.Project, bool, error) {
+	project := new(types.Project)
+	if found, err := s.get(ProjectType, id, project); !found || err != nil {
+		return nil, found, err
+	}
+	return project, true, nil
+}
+
+func (s *FileStore) ProjectByName(displayName string) (*types.Project, bool, error) {
+	projects, err := s.Projects()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, project := range projects {
+		if project.DisplayName == displayName {
+			return project, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s *FileStore) Projects() ([]*types.Project, error) {
+	res := []*types.Project{}
+	err := s.each(ProjectType, func(dat []byte) error {
+		project := new(types.Project)
+		res = append(res, project)
+		return json.Unmarshal(dat, project)
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].DisplayName < res[j].DisplayName })
+	return res, err
+}
+
+func (s *FileStore) PutProject(project *types.Project) error {
+	return s.put(ProjectType, project.Id, project)
+}
+
+func (s *FileStore) DeleteProject(id string) error {
+	if err := s.deleteWhere(ProjectType, func(dat []byte) (bool, error) {
+		var project types.Project
+		if err := json.Unmarshal(dat, &project); err != nil {
+			return false, err
+		}
+		return project.Id == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.removeFolder(RepositoryType, id); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(SubscriptionType, func(dat []byte) (bool, error) {
+		var sub types.Subscription
+		if err := json.Unmarshal(dat, &sub); err != nil {
+			return false, err
+		}
+		return sub.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(DigestType, func(dat []byte) (bool, error) {
+		var digest types.Digest
+		if err := json.Unmarshal(dat, &digest); err != nil {
+			return false, err
+		}
+		return digest.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(ScheduleType, func(dat []byte) (bool, error) {
+		var sched types.Schedule
+		if err := json.Unmarshal(dat, &sched); err != nil {
+			return false, err
+		}
+		return sched.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(ApprovedListType, func(dat []byte) (bool, error) {
+		var list types.ApprovedList
+		if err := json.Unmarshal(dat, &list); err != nil {
+			return false, err
+		}
+		return list.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.removeFolder(DifferenceType, id); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(JobType, func(dat []byte) (bool, error) {
+		var job types.Job
+		if err := json.Unmarshal(dat, &job); err != nil {
+			return false, err
+		}
+		return job.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	if err := s.deleteWhere(BackfillType, func(dat []byte) (bool, error) {
+		var bf types.Backfill
+		if err := json.Unmarshal(dat, &bf); err != nil {
+			return false, err
+		}
+		return bf.ProjectId == id, nil
+	}); err != nil {
+		return err
+	}
+	return s.removeFolder(ScanType, id)
+}
+
+func (s *FileStore) GetRepository(projectId, fullname string) (*types.Repository, bool, error) {
+	repos, err := s.Repositories(projectId)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, repo := range repos {
+		if repo.Fullname == fullname {
+			return repo, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// repositories returns the repositories under the folders that the filter accepts.
+func (s *FileStore) repositories(filter func(*types.Repository) bool, folders ...string) ([]*types.Repository, error) {
+	res := []*types.Repository{}
+	err := s.each(RepositoryType, func(dat []byte) error {
+		repo := new(types.Repository)
+		if err := json.Unmarshal(dat, repo); err != nil {
+			return err
+		}
+		if filter(repo) {
+			res = append(res, repo)
+		}
+		return nil
+	}, folders...)
+	sort.Slice(res, func(i, j int) bool { return res[i].Fullname < res[j].Fullname })
+	return res, err
+}
+
+func (s *FileStore) Repositories(projectId string) ([]*types.Repository, error) {
+	return s.repositories(func(repo *types.Repository) bool { return true }, projectId)
+}
+
+func (s *FileStore) PutRepository(repo *types.Repository) error {
+	return s.put(RepositoryType, repo.Id, repo, repo.ProjectId)
+}
+
+func (s *FileStore) DeleteRepository(projectId, fullname string) error {
+	repo, found, err := s.GetRepository(projectId, fullname)
+	if err != nil {
+		return err
+	} else if !found {
+		return u.Error("Repository %s does not exist in %s", fullname, projectId)
+	}
+	if err = s.remove(RepositoryType, repo.Id, projectId); err != nil {
+		return err
+	}
+	return s.removeFolder(ScanType, projectId, fullname)
+}
+
+func (s *FileStore) ProjectsUsingRepository(fullname string) ([]string, error) {
+	repos, err := s.repositories(func(repo *types.Repository) bool { return repo.Fullname == fullname })
+	if err != nil {
+		return nil, err
+	}
+	set := map[string]bool{}
+	for _, repo := range repos {
+		set[repo.ProjectId] = true
+	}
+	return keys(set), nil
+}
+
+func (s *FileStore) GetScan(projectId, sha string) (*types.Scan, bool, error) {
+	scan := new(types.Scan)
+	if found, err := s.get(ScanType, ScanId(projectId, sha), scan, projectId); !found || err != nil {
+		return nil, found, err
+	}
+	return scan, true, nil
+}
+
+func (s *FileStore) PutScan(scan *types.Scan) error {
+	return s.put(ScanType, ScanId(scan.ProjectId, scan.Sha), scan, scan.ProjectId, scan.RepoFullname)
+}
+
+// scans returns the scans under the folders that the filter accepts, newest first.
+func (s *FileStore) scans(filter func(*types.Scan) bool, folders ...string) ([]*types.Scan, error) {
+	return s.scansMatching(s.pattern(ScanType, "", folders...), filter)
+}
+
+func (s *FileStore) scansMatching(pattern string, filter func(*types.Scan) bool) ([]*types.Scan, error) {
+	res := []*types.Scan{}
+	err := s.eachMatch(pattern, func(dat []byte) error {
+		scan := new(types.Scan)
+		if err := json.Unmarshal(dat, scan); err != nil {
+			return err
+		}
+		if filter(scan) {
+			res = append(res, scan)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return scanKey(res[i]) > scanKey(res[j]) })
+	return res, err
+}
+
+func scanKey(scan *types.Scan) string {
+	return sortKey(scan.Timestamp, ScanId(scan.ProjectId, scan.Sha))
+}
+
+func (s *FileStore) ScanPage(projectId, fullname, cursor string, size int) ([]*types.Scan, string, error) {
+	scans, err := s.scans(func(scan *types.Scan) bool { return true }, projectId, fullname)
+	if err != nil {
+		return nil, "", err
+	}
+	start, end, next, err := pageOf(len(scans), func(i int) string { return scanKey(scans[i]) }, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	return scans[start:end], next, nil
+}
+
+func (s *FileStore) LatestScan(projectId, fullname, ref string, before time.Time) (*types.Scan, bool, error) {
+	scans, err := s.scans(func(scan *types.Scan) bool {
+		return containsString(scan.Refs, ref) && (before.IsZero() || scan.Timestamp.Before(before))
+	}, projectId, fullname)
+	if err != nil || len(scans) == 0 {
+		return nil, false, err
+	}
+	return scans[0], true, nil
+}
+
+// refs returns the refs of the scans under the folders.
+func (s *FileStore) refs(folders ...string) ([]string, error) {
+	scans, err := s.scans(func(scan *types.Scan) bool { return true }, folders...)
+	if err != nil {
+		return nil, err
+	}
+	set := map[string]bool{}
+	for _, scan := range scans {
+		for _, ref := range scan.Refs {
+			set[ref] = true
+		}
+	}
+	return keys(set), nil
+}
+
+func (s *FileStore) RepositoryRefs(projectId, fullname string) ([]string, error) {
+	return s.refs(projectId, fullname)
+}
+
+func (s *FileStore) ProjectRefs(projectId string) ([]string, error) {
+	return s.refs(projectId)
+}
+
+// ScannedRepositories reads the names of the repository folders of the scans,
+// without reading any scan.
+func (s *FileStore) ScannedRepositories() ([]string, error) {
+	s.mux.RLock()
+	files, err := filepath.Glob(s.pattern(ScanType, ""))
+	s.mux.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	set := map[string]bool{}
+	for _, file := range files {
+		name, err := url.PathUnescape(filepath.Base(filepath.Dir(file)))
+		if err != nil {
+			return nil, u.Error("Unable to read the repository of %s: %s", file, err.Error())
+		}
+		set[name] = true
+	}
+	return keys(set), nil
+}
+
+func (s *FileStore) SearchDependency(fullnames []string, name, versionPrefix string) ([]*DependencyHit, error) {
+	res := []*DependencyHit{}
+	filter := func(scan *types.Scan) bool {
+		if scan.Scan == nil {
+			return false
+		}
+		hit := &DependencyHit{RepoFullname: scan.RepoFullname, Sha: scan.Sha, Refs: scan.Refs}
+		for _, dep := range scan.Scan.Deps {
+			if dep.Name == name && strings.HasPrefix(dep.Version, versionPrefix) {
+				hit.Dependencies = append(hit.Dependencies, dep)
+			}
+		}
+		if len(hit.Dependencies) > 0 {
+			hit.Components = hitComponents(scan.Scan, name, versionPrefix)
+			res = append(res, hit)
+		}
+		return false
+	}
+	for _, fullname := range fullnames {
+		if _, err := s.scansMatching(filepath.Join(s.dir, ScanType, "*", segment(fullname), "*.json"), filter); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// differences returns the differences of a project, newest first.
+func (s *FileStore) differences(projectId string) ([]*types.Difference, error) {
+	res := []*types.Difference{}
+	err := s.each(DifferenceType, func(dat []byte) error {
+		diff := new(types.Difference)
+		if err := json.Unmarshal(dat, diff); err != nil {
+			return err
+		}
+		res = append(res, diff)
+		return nil
+	}, projectId)
+	sort.Slice(res, func(i, j int) bool { return differenceKey(res[i]) > differenceKey(res[j]) })
+	return res, err
+}
+
+func differenceKey(diff *types.Difference) string {
+	return sortKey(diff.Timestamp, diff.Id)
+}
+
+func (s *FileStore) GetDifference(id string) (*types.Difference, bool, error) {
+	diff := new(types.Difference)
+	if found, err := s.get(DifferenceType, id, diff); !found || err != nil {
+		return nil, found, err
+	}
+	return diff, true, nil
+}
+
+func (s *FileStore) DifferencePage(projectId, cursor string, size int) ([]*types.Difference, string, error) {
+	diffs, err := s.differences(projectId)
+	if err != nil {
+		return nil, "", err
+	}
+	start, end, next, err := pageOf(len(diffs), func(i int) string { return differenceKey(diffs[i]) }, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	return diffs[start:end], next, nil
+}
+
+func (s *FileStore) CountDifferences(projectId string) (int64, error) {
+	diffs, err := s.differences(projectId)
+	return int64(len(diffs)), err
+}
+
+func (s *FileStore) PutDifference(diff *types.Difference) error {
+	return s.put(DifferenceType, diff.Id, diff, diff.ProjectName)
+}
+
+func (s *FileStore) DeleteDifference(id string) error {
+	return s.remove(DifferenceType, id)
+}
+
+func (s *FileStore) DifferenceExists(projectId, oldSha, newSha string) (bool, error) {
+	diffs, err := s.differences(projectId)
+	if err != nil {
+		return false, err
+	}
+	for _, diff := range diffs {
+		if diff.OldSha == oldSha && diff.NewSha == newSha {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *FileStore) PutJob(job *types.Job) error {
+	return s.put(JobType, job.Id, job)
+}
+
+// jobs returns the jobs the filter accepts, most recently updated first.
+func (s *FileStore) jobs(filter func(*types.Job) bool) ([]*types.Job, error) {
+	res := []*types.Job{}
+	err := s.each(JobType, func(dat []byte) error {
+		job := new(types.Job)
+		if err := json.Unmarshal(dat, job); err != nil {
+			return err
+		}
+		if filter(job) {
+			res = append(res, job)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return jobKey(res[i]) > jobKey(res[j]) })
+	return res, err
+}
+
+func jobKey(job *types.Job) string {
+	return sortKey(job.Updated, job.Id)
+}
+
+func (s *FileStore) Jobs(ids []string) ([]*types.Job, error) {
+	res := []*types.Job{}
+	for _, id := range ids {
+		job := new(types.Job)
+		if found, err := s.get(JobType, id, job); err != nil {
+			return nil, err
+		} else if found {
+			res = append(res, job)
+		}
+	}
+	return res, nil
+}
+
+func (s *FileStore) JobsByStatus(statuses ...types.JobStatus) ([]*types.Job, error) {
+	return s.jobs(func(job *types.Job) bool {
+		for _, status := range statuses {
+			if job.Status == status {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *FileStore) JobHistory(status types.JobStatus, cursor string, size int) ([]*types.Job, string, error) {
+	jobs, err := s.jobs(func(job *types.Job) bool { return status == "" || job.Status == status })
+	if err != nil {
+		return nil, "", err
+	}
+	start, end, next, err := pageOf(len(jobs), func(i int) string { return jobKey(jobs[i]) }, cursor, size)
+	if err != nil {
+		return nil, "", err
+	}
+	return jobs[start:end], next, nil
+}
+
+func (s *FileStore) CountJobs(status types.JobStatus) (int64, error) {
+	jobs, err := s.JobsByStatus(status)
+	return int64(len(jobs)), err
+}
+
+func (s *FileStore) PutBackfill(bf *types.Backfill) error {
+	return s.put(BackfillType, bf.Id, bf)
+}
+
+func (s *FileStore) GetBackfill(id string) (*types.Backfill, bool, error) {
+	bf := new(types.Backfill)
+	if found, err := s.get(BackfillType, id, bf); !found || err != nil {
+		return nil, found, err
+	}
+	return bf, true, nil
+}
+
+// backfills returns the backfills the filter accepts, most recently created first.
+func (s *FileStore) backfills(filter func(*types.Backfill) bool) ([]*types.Backfill, error) {
+	res := []*types.Backfill{}
+	err := s.each(BackfillType, func(dat []byte) error {
+		bf := new(types.Backfill)
+		if err := json.Unmarshal(dat, bf); err != nil {
+			return err
+		}
+		if filter(bf) {
+			res = append(res, bf)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.After(res[j].Created) })
+	return res, err
+}
+
+func (s *FileStore) BackfillsByStatus(statuses ...types.BackfillStatus) ([]*types.Backfill, error) {
+	return s.backfills(func(bf *types.Backfill) bool {
+		for _, status := range statuses {
+			if bf.Status == status {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *FileStore) ProjectBackfills(projectId string, size int) ([]*types.Backfill, error) {
+	bfs, err := s.backfills(func(bf *types.Backfill) bool { return bf.ProjectId == projectId })
+	if len(bfs) > size {
+		bfs = bfs[:size]
+	}
+	return bfs, err
+}
+
+func (s *FileStore) PutSubscription(sub *types.Subscription) error {
+	return s.put(SubscriptionType, sub.Id, sub)
+}
+
+func (s *FileStore) GetSubscription(id string) (*types.Subscription, bool, error) {
+	sub := new(types.Subscription)
+	if found, err := s.get(SubscriptionType, id, sub); !found || err != nil {
+		return nil, found, err
+	}
+	return sub, true, nil
+}
+
+func (s *FileStore) DeleteSubscription(id string) error {
+	return s.remove(SubscriptionType, id)
+}
+
+func (s *FileStore) Subscriptions(projectId string) ([]*types.Subscription, error) {
+	res := []*types.Subscription{}
+	err := s.each(SubscriptionType, func(dat []byte) error {
+		sub := new(types.Subscription)
+		if err := json.Unmarshal(dat, sub); err != nil {
+			return err
+		}
+		if sub.ProjectId == projectId {
+			res = append(res, sub)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(res[j].Created) })
+	return res, err
+}
+
+func (s *FileStore) PutDigest(digest *types.Digest) error {
+	return s.put(DigestType, digest.Id, digest)
+}
+
+func (s *FileStore) GetDigest(id string) (*types.Digest, bool, error) {
+	digest := new(types.Digest)
+	if found, err := s.get(DigestType, id, digest); !found || err != nil {
+		return nil, found, err
+	}
+	return digest, true, nil
+}
+
+func (s *FileStore) DeleteDigest(id string) error {
+	return s.remove(DigestType, id)
+}
+
+func (s *FileStore) Digests() ([]*types.Digest, error) {
+	res := []*types.Digest{}
+	err := s.each(DigestType, func(dat []byte) error {
+		digest := new(types.Digest)
+		res = append(res, digest)
+		return json.Unmarshal(dat, digest)
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Due.Before(res[j].Due) })
+	return res, err
+}
+
+func (s *FileStore) PutSchedule(sched *types.Schedule) error {
+	return s.put(ScheduleType, sched.Id, sched)
+}
+
+func (s *FileStore) GetSchedule(id string) (*types.Schedule, bool, error) {
+	sched := new(types.Schedule)
+	if found, err := s.get(ScheduleType, id, sched); !found || err != nil {
+		return nil, found, err
+	}
+	return sched, true, nil
+}
+
+func (s *FileStore) DeleteSchedule(id string) error {
+	return s.remove(ScheduleType, id)
+}
+
+func (s *FileStore) Schedules(projectId string) ([]*types.Schedule, error) {
+	res := []*types.Schedule{}
+	err := s.each(ScheduleType, func(dat []byte) error {
+		sched := new(types.Schedule)
+		if err := json.Unmarshal(dat, sched); err != nil {
+			return err
+		}
+		if projectId == "" || sched.ProjectId == projectId {
+			res = append(res, sched)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(res[j].Created) })
+	return res, err
+}
+
+func (s *FileStore) PutApprovedList(list *types.ApprovedList) error {
+	return s.put(ApprovedListType, list.Id, list)
+}
+
+func (s *FileStore) GetApprovedList(id string) (*types.ApprovedList, bool, error) {
+	list := new(types.ApprovedList)
+	if found, err := s.get(ApprovedListType, id, list); !found || err != nil {
+		return nil, found, err
+	}
+	return list, true, nil
+}
+
+func (s *FileStore) ApprovedLists(projectId string) ([]*types.ApprovedList, error) {
+	res := []*types.ApprovedList{}
+	err := s.each(ApprovedListType, func(dat []byte) error {
+		list := new(types.ApprovedList)
+		if err := json.Unmarshal(dat, list); err != nil {
+			return err
+		}
+		if list.ProjectId == projectId {
+			res = append(res, list)
+		}
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Version > res[j].Version })
+	return res, err
+}
+
+const sortKeyLayout = "2006-01-02T15:04:05.000000000"
+
+// sortKey orders items by time and then id, the same as their string order.
+func sortKey(t time.Time, id string) string {
+	return t.UTC().Format(sortKeyLayout) + " " + id
+}
+
+// pageOf finds the page after the cursor in a list sorted by descending key.
+// The cursor is the key of the last item on the previous page.
+func pageOf(length int, key func(int) string, cursor string, size int) (int, int, string, error) {
+	start := 0
+	if cursor != "" {
+		dat, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return 0, 0, "", u.Error("Invalid cursor: %s", err.Error())
+		}
+		after := string(dat)
+		start = sort.Search(length, func(i int) bool { return key(i) < after })
+	}
+	end := start + size
+	if end >= length {
+		return start, length, "", nil
+	}
+	return start, end, base64.RawURLEncoding.EncodeToString([]byte(key(end - 1))), nil
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func keys(set map[string]bool) []string {
+	res := make([]string, 0, len(set))
+	for key := range set {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,370 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	c "github.com/venicegeo/vzutil-versioning/common"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	lan "github.com/venicegeo/vzutil-versioning/common/language"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+)
+
+func newTestStore(t *testing.T) (*FileStore, func()) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestFileStoreProjects(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	one := types.NewProject("1", "Project One")
+	assert.NoError(s.PutProject(&one))
+	assert.NoError(s.PutRepository(&types.Repository{Id: "r1", ProjectId: "1", Fullname: "org/repo"}))
+	assert.NoError(s.PutScan(&types.Scan{RepoFullname: "org/repo", ProjectId: "1", Sha: "abc"}))
+
+	project, found, err := s.ProjectByName("Project One")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("1", project.Id)
+
+	projects, err := s.ProjectsUsingRepository("org/repo")
+	assert.NoError(err)
+	assert.Equal([]string{"1"}, projects)
+
+	assert.NoError(s.DeleteProject("1"))
+	_, found, err = s.GetProject("1")
+	assert.NoError(err)
+	assert.False(found)
+	_, found, _ = s.GetRepository("1", "org/repo")
+	assert.False(found)
+	_, found, _ = s.GetScan("1", "abc")
+	assert.False(found)
+}
+
+func TestFileStoreDeleteProjectWork(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	for _, id := range []string{"1", "2"} {
+		project := types.NewProject(id, "Project "+id)
+		assert.NoError(s.PutProject(&project))
+		assert.NoError(s.PutJob(&types.Job{Id: "j" + id, ProjectId: id, Status: types.JobQueued, Updated: now}))
+		assert.NoError(s.PutDifference(&types.Difference{Id: "d" + id, ProjectName: id, OldSha: "a", NewSha: "b"}))
+		assert.NoError(s.PutBackfill(&types.Backfill{Id: "b" + id, ProjectId: id, Status: types.BackfillScanning, Created: now}))
+	}
+
+	assert.NoError(s.DeleteProject("1"))
+	jobs, err := s.JobsByStatus(types.JobQueued)
+	assert.NoError(err)
+	if assert.Len(jobs, 1) {
+		assert.Equal("j2", jobs[0].Id)
+	}
+	exists, err := s.DifferenceExists("1", "a", "b")
+	assert.NoError(err)
+	assert.False(exists)
+	exists, _ = s.DifferenceExists("2", "a", "b")
+	assert.True(exists)
+	_, found, _ := s.GetBackfill("b1")
+	assert.False(found)
+	bfs, err := s.BackfillsByStatus(types.BackfillScanning)
+	assert.NoError(err)
+	assert.Len(bfs, 1)
+}
+
+func TestFileStoreFolders(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	scan := &types.Scan{RepoFullname: "org/repo", ProjectId: "1", Refs: []string{"refs/heads/master"}, Sha: "abc", Timestamp: time.Now()}
+	assert.NoError(s.PutScan(scan))
+	_, err := os.Stat(filepath.Join(s.dir, ScanType, "1", "org%2Frepo", "abc-1.json"))
+	assert.NoError(err, "the scan is not in the folder of its project and repository")
+
+	// A broken scan of another repository is never read by queries about this one.
+	other := filepath.Join(s.dir, ScanType, "1", "org%2Fother")
+	assert.NoError(os.MkdirAll(other, 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(other, "def-1.json"), []byte("{"), 0644))
+	latest, found, err := s.LatestScan("1", "org/repo", "refs/heads/master", time.Time{})
+	assert.NoError(err)
+	if assert.True(found) {
+		assert.Equal("abc", latest.Sha)
+	}
+	_, found, err = s.GetScan("1", "abc")
+	assert.NoError(err)
+	assert.True(found)
+	repos, err := s.ScannedRepositories()
+	assert.NoError(err)
+	assert.Len(repos, 2)
+
+	// Items written before they were kept in folders are moved into them.
+	dat, _ := json.Marshal(&types.Scan{RepoFullname: "org/old", ProjectId: "2", Sha: "fed"})
+	assert.NoError(ioutil.WriteFile(filepath.Join(s.dir, ScanType, "fed-2.json"), dat, 0644))
+	dat, _ = json.Marshal(&types.Difference{Id: "d", ProjectName: "2", OldSha: "a", NewSha: "b"})
+	assert.NoError(ioutil.WriteFile(filepath.Join(s.dir, DifferenceType, "d.json"), dat, 0644))
+	s, err = NewFileStore(s.dir)
+	assert.NoError(err)
+	_, found, err = s.GetScan("2", "fed")
+	assert.NoError(err)
+	assert.True(found)
+	exists, err := s.DifferenceExists("2", "a", "b")
+	assert.NoError(err)
+	assert.True(exists)
+
+	assert.NoError(s.PutRepository(&types.Repository{Id: "r1", ProjectId: "1", Fullname: "org/repo"}))
+	assert.NoError(s.DeleteRepository("1", "org/repo"))
+	_, err = os.Stat(filepath.Join(s.dir, ScanType, "1", "org%2Frepo"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestFileStoreScans(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	put := func(sha string, age time.Duration, refs ...string) {
+		assert.NoError(s.PutScan(&types.Scan{
+			RepoFullname: "org/repo",
+			ProjectId:    "1",
+			Refs:         refs,
+			Sha:          sha,
+			Timestamp:    now.Add(-age),
+			Scan:         &c.DependencyScan{Deps: []d.Dependency{{Name: "lib", Version: "1." + sha}}},
+		}))
+	}
+	put("a", time.Hour*2, "refs/heads/master")
+	put("b", time.Hour, "refs/heads/master", "refs/tags/v1")
+	put("c", 0, "refs/heads/dev")
+
+	scans, next, err := s.ScanPage("1", "org/repo", "", 2)
+	assert.NoError(err)
+	if assert.Len(scans, 2) {
+		assert.Equal("c", scans[0].Sha)
+		assert.Equal("b", scans[1].Sha)
+	}
+	scans, next, err = s.ScanPage("1", "org/repo", next, 2)
+	assert.NoError(err)
+	if assert.Len(scans, 1) {
+		assert.Equal("a", scans[0].Sha)
+	}
+	assert.Equal("", next)
+	_, _, err = s.ScanPage("1", "org/repo", "not a cursor!", 2)
+	assert.Error(err)
+
+	scan, found, err := s.LatestScan("1", "org/repo", "refs/heads/master", time.Time{})
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("b", scan.Sha)
+	scan, found, _ = s.LatestScan("1", "org/repo", "refs/heads/master", now.Add(-time.Hour))
+	assert.True(found)
+	assert.Equal("a", scan.Sha)
+	_, found, _ = s.LatestScan("1", "org/repo", "refs/heads/master", now.Add(-time.Hour*3))
+	assert.False(found)
+
+	refs, err := s.RepositoryRefs("1", "org/repo")
+	assert.NoError(err)
+	assert.Equal([]string{"refs/heads/dev", "refs/heads/master", "refs/tags/v1"}, refs)
+
+	hits, err := s.SearchDependency([]string{"org/repo"}, "lib", "1.b")
+	assert.NoError(err)
+	if assert.Len(hits, 1) {
+		assert.Equal("b", hits[0].Sha)
+		assert.Len(hits[0].Dependencies, 1)
+		assert.Equal([]string{"."}, hits[0].Components)
+	}
+	hits, _ = s.SearchDependency([]string{"org/other"}, "lib", "")
+	assert.Len(hits, 0)
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	assert.NoError(s.PutJob(&types.Job{Id: "j1", Status: types.JobQueued, Updated: now}))
+	assert.NoError(s.PutJob(&types.Job{Id: "j2", Status: types.JobFailed, Updated: now.Add(time.Minute)}))
+	assert.NoError(s.PutDifference(&types.Difference{Id: "d/1", ProjectName: "1", OldSha: "a", NewSha: "b"}))
+
+	s, err := NewFileStore(s.dir)
+	assert.NoError(err)
+
+	jobs, err := s.JobsByStatus(types.JobQueued, types.JobRunning)
+	assert.NoError(err)
+	assert.Len(jobs, 1)
+	history, next, err := s.JobHistory("", "", 1)
+	assert.NoError(err)
+	if assert.Len(history, 1) {
+		assert.Equal("j2", history[0].Id)
+	}
+	history, next, err = s.JobHistory("", next, 1)
+	assert.NoError(err)
+	if assert.Len(history, 1) {
+		assert.Equal("j1", history[0].Id)
+	}
+	assert.Equal("", next)
+	count, err := s.CountJobs(types.JobFailed)
+	assert.NoError(err)
+	assert.EqualValues(1, count)
+
+	exists, err := s.DifferenceExists("1", "a", "b")
+	assert.NoError(err)
+	assert.True(exists)
+	assert.NoError(s.DeleteDifference("d/1"))
+	exists, _ = s.DifferenceExists("1", "a", "b")
+	assert.False(exists)
+}
+
+func TestFileStoreSubscriptions(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	assert.NoError(s.PutSubscription(&types.Subscription{Id: "b", ProjectId: "1", Channel: types.ChannelSlack, Created: now}))
+	assert.NoError(s.PutSubscription(&types.Subscription{Id: "a", ProjectId: "1", Channel: types.ChannelEmail, Created: now.Add(-time.Hour)}))
+	assert.NoError(s.PutSubscription(&types.Subscription{Id: "c", ProjectId: "2", Channel: types.ChannelWebhook, Created: now}))
+
+	subs, err := s.Subscriptions("1")
+	assert.NoError(err)
+	if assert.Len(subs, 2) {
+		assert.Equal("a", subs[0].Id)
+		assert.Equal("b", subs[1].Id)
+	}
+
+	sub, found, err := s.GetSubscription("c")
+	assert.NoError(err)
+	if assert.True(found) {
+		assert.Equal("2", sub.ProjectId)
+	}
+
+	assert.NoError(s.DeleteSubscription("a"))
+	_, found, _ = s.GetSubscription("a")
+	assert.False(found)
+	subs, _ = s.Subscriptions("1")
+	assert.Len(subs, 1)
+
+	assert.NoError(s.DeleteProject("2"))
+	subs, _ = s.Subscriptions("2")
+	assert.Len(subs, 0)
+}
+
+func TestFileStoreDigests(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	assert.NoError(s.PutDigest(&types.Digest{Id: "a", ProjectId: "1", Due: now.Add(time.Hour)}))
+	assert.NoError(s.PutDigest(&types.Digest{Id: "b", ProjectId: "1", Due: now, Events: []json.RawMessage{json.RawMessage(`{"kind":"difference"}`)}}))
+	assert.NoError(s.PutDigest(&types.Digest{Id: "c", ProjectId: "2", Due: now}))
+
+	digests, err := s.Digests()
+	assert.NoError(err)
+	if assert.Len(digests, 3) {
+		assert.Equal("a", digests[2].Id)
+	}
+	digest, found, err := s.GetDigest("b")
+	assert.NoError(err)
+	if assert.True(found) && assert.Len(digest.Events, 1) {
+		assert.JSONEq(`{"kind":"difference"}`, string(digest.Events[0]))
+	}
+
+	assert.NoError(s.DeleteDigest("b"))
+	_, found, _ = s.GetDigest("b")
+	assert.False(found)
+	assert.NoError(s.DeleteProject("2"))
+	digests, _ = s.Digests()
+	assert.Len(digests, 1)
+}
+
+func TestFileStoreSchedules(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	now := time.Now()
+	assert.NoError(s.PutSchedule(&types.Schedule{Id: "a", ProjectId: "1", Cron: "@daily", Created: now}))
+	assert.NoError(s.PutSchedule(&types.Schedule{Id: "b", ProjectId: "2", Cron: "@hourly", Created: now.Add(-time.Hour)}))
+
+	all, err := s.Schedules("")
+	assert.NoError(err)
+	if assert.Len(all, 2) {
+		assert.Equal("b", all[0].Id)
+	}
+	one, err := s.Schedules("1")
+	assert.NoError(err)
+	assert.Len(one, 1)
+
+	sched, found, err := s.GetSchedule("a")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("@daily", sched.Cron)
+
+	assert.NoError(s.DeleteSchedule("a"))
+	_, found, _ = s.GetSchedule("a")
+	assert.False(found)
+}
+
+func TestFileStoreApprovedLists(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	assert := assert.New(t)
+
+	for version := 1; version <= 2; version++ {
+		list := &types.ApprovedList{Id: types.ApprovedListId("1", version), ProjectId: "1", Version: version,
+			Items: []types.ApprovedItem{{Name: "junit", Version: "4.12", Component: "pz-gateway", Language: lan.Java}}}
+		assert.NoError(s.PutApprovedList(list))
+	}
+	assert.NoError(s.PutApprovedList(&types.ApprovedList{Id: types.ApprovedListId("2", 1), ProjectId: "2", Version: 1}))
+
+	lists, err := s.ApprovedLists("1")
+	assert.NoError(err)
+	if assert.Len(lists, 2) {
+		assert.Equal(2, lists[0].Version)
+		assert.Equal("junit:4.12:java", lists[0].Dependencies()[0].FullString())
+	}
+	list, found, err := s.GetApprovedList("1-1")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(1, list.Version)
+
+	assert.NoError(s.DeleteProject("1"))
+	lists, _ = s.ApprovedLists("1")
+	assert.Len(lists, 0)
+	lists, _ = s.ApprovedLists("2")
+	assert.Len(lists, 1)
+}
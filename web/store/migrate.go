@@ -0,0 +1,224 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	u "github.com/venicegeo/vzutil-versioning/web/util"
+)
+
+// Migration is a change to ESMapping. Every migration made since an index was
+// created is applied by one reindex into a new index with the current mapping,
+// running the scripts of the migrations in order over each document.
+type Migration struct {
+	Version     int
+	Description string
+	// Script is an optional painless script run against ctx._source.
+	Script string
+}
+
+// Migrations lists the schema versions. Version 1 is the index that was created
+// without an alias before migrations existed. Append to this whenever ESMapping changes.
+var Migrations = []Migration{
+	{1, "Initial schema", ""},
+	{2, "Map timestamps as dates", ""},
+	{3, "Typed dependency changes in differences", ""},
+	{4, "Dependency shas and project sha overrides", ""},
+	{5, "Notification subscriptions", ""},
+	{6, "Schedules and the resolver version of scans", ""},
+	{7, "Repository checks on scans", ""},
+	{8, "Versioned approved-software lists", ""},
+	{9, "Components of scans and changes", ""},
+	{10, "Scan configuration committed in repositories", ""},
+	{11, "Queued notification digests", ""},
+}
+
+func SchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// MigrateES brings the alias up to the current schema version. The data lives in
+// indices named alias_v<version>, with the alias pointing at the current one.
+// A new install gets an index of the current version. Old versioned indices are
+// left in place after a migration so they can be restored by hand.
+func MigrateES(esUrl, user, pass, alias string) error {
+	ctx := context.Background()
+	client, err := elastic.NewClient(
+		elastic.SetURL(esUrl),
+		elastic.SetBasicAuth(user, pass),
+		elastic.SetSniff(false),
+		elastic.SetMaxRetries(5),
+	)
+	if err != nil {
+		return err
+	}
+
+	current, version, err := aliasedIndex(ctx, client, alias)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		exists, err := client.IndexExists(alias).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			current, version = alias, 1
+		}
+	}
+	latest := SchemaVersion()
+	target := versionedIndex(alias, latest)
+	switch {
+	case current == "":
+		log.Println("[MIGRATE] Creating", target)
+		if err = createIndex(ctx, client, target); err != nil {
+			return err
+		}
+		_, err = client.Alias().Add(target, alias).Do(ctx)
+		return err
+	case version == latest:
+		return nil
+	case version > latest:
+		return u.Error("Index %s is at schema version %d, newer than this service's %d", current, version, latest)
+	}
+
+	log.Printf("[MIGRATE] Migrating %s from version %d to %d\n", current, version, latest)
+	if exists, err := client.IndexExists(target).Do(ctx); err != nil {
+		return err
+	} else if exists {
+		log.Println("[MIGRATE] Removing", target, "left by an unfinished migration")
+		if _, err = client.DeleteIndex(target).Do(ctx); err != nil {
+			return err
+		}
+	}
+	if err = createIndex(ctx, client, target); err != nil {
+		return err
+	}
+	if err = reindex(ctx, client, current, target, pendingScript(version)); err != nil {
+		return err
+	}
+	if current == alias {
+		// An alias can not share the name of an index, so the unversioned index has to go first.
+		if _, err = client.DeleteIndex(current).Do(ctx); err != nil {
+			return err
+		}
+		_, err = client.Alias().Add(target, alias).Do(ctx)
+	} else {
+		_, err = client.Alias().Remove(current, alias).Add(target, alias).Do(ctx)
+		log.Println("[MIGRATE] Kept", current, "which can be deleted once", target, "is verified")
+	}
+	if err == nil {
+		log.Println("[MIGRATE] Finished migrating to", target)
+	}
+	return err
+}
+
+func versionedIndex(alias string, version int) string {
+	return alias + "_v" + strconv.Itoa(version)
+}
+
+var versionSuffix = regexp.MustCompile(`_v(\d+)$`)
+
+// indexVersion reads the schema version from the name of an index behind the alias.
+func indexVersion(alias, index string) (int, error) {
+	match := versionSuffix.FindStringSubmatch(index)
+	if match == nil || !strings.HasPrefix(index, alias) {
+		return 0, u.Error("Index %s behind %s is not versioned", index, alias)
+	}
+	return strconv.Atoi(match[1])
+}
+
+// aliasedIndex returns the index the alias points at, if the alias exists.
+func aliasedIndex(ctx context.Context, client *elastic.Client, alias string) (string, int, error) {
+	resp, err := client.PerformRequest(ctx, "GET", "/_alias/"+url.PathEscape(alias), nil, nil, 404)
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode == 404 {
+		return "", 0, nil
+	}
+	indices := map[string]interface{}{}
+	if err = json.Unmarshal(resp.Body, &indices); err != nil {
+		return "", 0, err
+	}
+	if len(indices) != 1 {
+		return "", 0, u.Error("Alias %s points at %d indices", alias, len(indices))
+	}
+	for index := range indices {
+		version, err := indexVersion(alias, index)
+		return index, version, err
+	}
+	return "", 0, nil
+}
+
+func createIndex(ctx context.Context, client *elastic.Client, index string) error {
+	resp, err := client.CreateIndex(index).BodyString(ESMapping).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !resp.Acknowledged {
+		return u.Error("Creating %s was not acknowledged", index)
+	}
+	return nil
+}
+
+// pendingScript joins the scripts of the migrations after a version.
+func pendingScript(version int) string {
+	scripts := []string{}
+	for _, migration := range Migrations {
+		if migration.Version > version && migration.Script != "" {
+			scripts = append(scripts, migration.Script)
+		}
+	}
+	return strings.Join(scripts, "\n")
+}
+
+func reindex(ctx context.Context, client *elastic.Client, source, dest, script string) error {
+	body := map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	}
+	if script != "" {
+		body["script"] = map[string]interface{}{"lang": "painless", "inline": script}
+	}
+	params := url.Values{}
+	params.Set("wait_for_completion", "true")
+	params.Set("refresh", "true")
+	resp, err := client.PerformRequest(ctx, "POST", "/_reindex", params, body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Total    int64             `json:"total"`
+		Created  int64             `json:"created"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err = json.Unmarshal(resp.Body, &result); err != nil {
+		return err
+	}
+	if len(result.Failures) > 0 {
+		return u.Error("Reindexing %s into %s had %d failures, the first being %s", source, dest, len(result.Failures), string(result.Failures[0]))
+	}
+	log.Printf("[MIGRATE] Reindexed %d of %d documents from %s into %s\n", result.Created, result.Total, source, dest)
+	return nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexVersion(t *testing.T) {
+	assert := assert.New(t)
+	version, err := indexVersion("versioning_tool", versionedIndex("versioning_tool", 12))
+	assert.NoError(err)
+	assert.Equal(12, version)
+	_, err = indexVersion("versioning_tool", "versioning_tool")
+	assert.Error(err)
+	_, err = indexVersion("versioning_tool", "other_v2")
+	assert.Error(err)
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, migration := range Migrations {
+		assert.Equal(t, i+1, migration.Version, migration.Description)
+	}
+	assert.Equal(t, "", pendingScript(SchemaVersion()))
+}
@@ -0,0 +1,133 @@
+// Copyright 2018, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"strings"
+	"time"
+
+	c "github.com/venicegeo/vzutil-versioning/common"
+	d "github.com/venicegeo/vzutil-versioning/common/dependency"
+	"github.com/venicegeo/vzutil-versioning/web/es/types"
+)
+
+// Store is where the service keeps its projects, repositories, scans,
+// differences, jobs, backfills, subscriptions, digests, schedules and approved lists. Lookups report whether the item was found
+// separately from errors. Methods that page take the cursor returned with the
+// previous page, or an empty one for the first, and return an empty cursor
+// once there are no more pages.
+type Store interface {
+	GetProject(id string) (*types.Project, bool, error)
+	ProjectByName(displayName string) (*types.Project, bool, error)
+	Projects() ([]*types.Project, error)
+	PutProject(project *types.Project) error
+	// DeleteProject also deletes everything else kept for the project, from its
+	// repositories and scans to its differences, jobs and backfills.
+	DeleteProject(id string) error
+
+	GetRepository(projectId, fullname string) (*types.Repository, bool, error)
+	Repositories(projectId string) ([]*types.Repository, error)
+	PutRepository(repo *types.Repository) error
+	// DeleteRepository also deletes the scans of the repository in the project.
+	DeleteRepository(projectId, fullname string) error
+	ProjectsUsingRepository(fullname string) ([]string, error)
+
+	GetScan(projectId, sha string) (*types.Scan, bool, error)
+	PutScan(scan *types.Scan) error
+	// ScanPage returns the scans of a repository in a project, newest first.
+	ScanPage(projectId, fullname, cursor string, size int) ([]*types.Scan, string, error)
+	// LatestScan returns the newest scan of a repository on a ref, only
+	// considering scans before the given time unless it is zero.
+	LatestScan(projectId, fullname, ref string, before time.Time) (*types.Scan, bool, error)
+	RepositoryRefs(projectId, fullname string) ([]string, error)
+	ProjectRefs(projectId string) ([]string, error)
+	ScannedRepositories() ([]string, error)
+	// SearchDependency finds the scans of the repositories that contain a
+	// dependency with the name and a version starting with the prefix.
+	SearchDependency(fullnames []string, name, versionPrefix string) ([]*DependencyHit, error)
+
+	GetDifference(id string) (*types.Difference, bool, error)
+	// DifferencePage returns the differences of a project, newest first.
+	DifferencePage(projectId, cursor string, size int) ([]*types.Difference, string, error)
+	CountDifferences(projectId string) (int64, error)
+	PutDifference(diff *types.Difference) error
+	DeleteDifference(id string) error
+	DifferenceExists(projectId, oldSha, newSha string) (bool, error)
+
+	PutJob(job *types.Job) error
+	Jobs(ids []string) ([]*types.Job, error)
+	JobsByStatus(statuses ...types.JobStatus) ([]*types.Job, error)
+	// JobHistory returns the jobs with the status, or any status if empty, most recently updated first.
+	JobHistory(status types.JobStatus, cursor string, size int) ([]*types.Job, string, error)
+	CountJobs(status types.JobStatus) (int64, error)
+
+	PutBackfill(bf *types.Backfill) error
+	GetBackfill(id string) (*types.Backfill, bool, error)
+	BackfillsByStatus(statuses ...types.BackfillStatus) ([]*types.Backfill, error)
+	// ProjectBackfills returns the most recently created backfills of a project.
+	ProjectBackfills(projectId string, size int) ([]*types.Backfill, error)
+
+	PutSubscription(sub *types.Subscription) error
+	GetSubscription(id string) (*types.Subscription, bool, error)
+	DeleteSubscription(id string) error
+	// Subscriptions returns the subscriptions of a project, oldest first.
+	Subscriptions(projectId string) ([]*types.Subscription, error)
+
+	PutDigest(digest *types.Digest) error
+	GetDigest(id string) (*types.Digest, bool, error)
+	DeleteDigest(id string) error
+	// Digests returns the queued digests of every subscription, soonest due first.
+	Digests() ([]*types.Digest, error)
+
+	PutSchedule(sched *types.Schedule) error
+	GetSchedule(id string) (*types.Schedule, bool, error)
+	DeleteSchedule(id string) error
+	// Schedules returns the schedules of a project, or of every project when
+	// the id is empty, oldest first.
+	Schedules(projectId string) ([]*types.Schedule, error)
+
+	PutApprovedList(list *types.ApprovedList) error
+	GetApprovedList(id string) (*types.ApprovedList, bool, error)
+	// ApprovedLists returns the versions of a project's approved list, newest first.
+	ApprovedLists(projectId string) ([]*types.ApprovedList, error)
+}
+
+type DependencyHit struct {
+	RepoFullname string
+	Sha          string
+	Refs         []string
+	Dependencies []d.Dependency
+	// Components are the components of the scan holding the dependencies.
+	Components []string
+}
+
+// hitComponents names the components of the scan with a dependency of the name
+// and a version starting with the prefix.
+func hitComponents(scan *c.DependencyScan, name, versionPrefix string) []string {
+	res := []string{}
+	for _, comp := range scan.ComponentsOrRoot() {
+		for _, dep := range comp.Deps {
+			if dep.Name == name && strings.HasPrefix(dep.Version, versionPrefix) {
+				res = append(res, comp.Name)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func ScanId(projectId, sha string) string {
+	return sha + "-" + projectId
+}